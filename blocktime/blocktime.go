@@ -0,0 +1,130 @@
+// Package blocktime estimates the wall clock time of directory blocks that
+// have not been created yet, from the timestamps of recent blocks.
+//
+// Blocks are nominally 10 minutes apart, but drift with network conditions.
+// The estimate uses the average spacing of the recent blocks, and the range
+// widens with the number of blocks ahead: the spacing of each block varies,
+// and the average itself is only known from a limited number of samples.
+package blocktime
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultBlockTime is the spacing assumed when there are too few samples
+const DefaultBlockTime = 10 * time.Minute
+
+// DefaultWindow is the number of recent blocks estimates are made from
+const DefaultWindow = 1000
+
+// z95 is the number of standard deviations covering 95% of a normal distribution
+const z95 = 1.96
+
+// Sample is the timestamp of a directory block
+type Sample struct {
+	Height int
+	Time   time.Time
+}
+
+// Estimate is when a block was, or is expected to be, created. Earliest and
+// Latest are a 95% confidence range, equal to Time if the block exists.
+type Estimate struct {
+	BlockHeight int       `json:"blockHeight"`
+	Time        time.Time `json:"time"`
+	Earliest    time.Time `json:"earliest"`
+	Latest      time.Time `json:"latest"`
+	// Observed is true if the block exists, and Time is its timestamp
+	Observed bool `json:"observed"`
+}
+
+// Estimator extrapolates block times from the latest sample
+type Estimator struct {
+	latest Sample
+	// mean and stddev of the spacing between blocks, in seconds
+	mean      float64
+	stddev    float64
+	intervals int
+}
+
+// NewEstimator builds an estimator from block timestamps, in any order. It
+// returns nil if there are no samples.
+func NewEstimator(samples []Sample) *Estimator {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Height < sorted[j].Height })
+
+	e := new(Estimator)
+	e.latest = sorted[len(sorted)-1]
+	first := sorted[0]
+
+	// The mean is over the whole window, so gaps in the samples do not bias
+	// it. The variance needs the spacing of single blocks.
+	if blocks := e.latest.Height - first.Height; blocks > 0 {
+		e.mean = e.latest.Time.Sub(first.Time).Seconds() / float64(blocks)
+		var sumSq float64
+		for i := 1; i < len(sorted); i++ {
+			if sorted[i].Height-sorted[i-1].Height != 1 {
+				continue
+			}
+			d := sorted[i].Time.Sub(sorted[i-1].Time).Seconds() - e.mean
+			sumSq += d * d
+			e.intervals++
+		}
+		if e.intervals > 1 {
+			e.stddev = math.Sqrt(sumSq / float64(e.intervals-1))
+		}
+	}
+
+	if e.mean <= 0 || e.intervals < 2 {
+		// Not enough to go on, so the nominal spacing with a wide range
+		e.mean = DefaultBlockTime.Seconds()
+		e.stddev = e.mean / 10
+		e.intervals = 1
+	}
+	return e
+}
+
+// BlockTime is the average spacing of blocks
+func (e *Estimator) BlockTime() time.Duration {
+	return time.Duration(e.mean * float64(time.Second))
+}
+
+// Latest is the newest block the estimates are made from
+func (e *Estimator) Latest() Sample {
+	return e.latest
+}
+
+// Estimate returns the expected time of the block at the height. Heights
+// below the latest sample are extrapolated backwards the same way, for blocks
+// whose timestamp is not known.
+func (e *Estimator) Estimate(height int) Estimate {
+	n := float64(height - e.latest.Height)
+	at := e.latest.Time.Add(time.Duration(n * e.mean * float64(time.Second)))
+	if n == 0 {
+		return Observed(e.latest)
+	}
+
+	// Each block's spacing varies by stddev, and the mean is off by up to
+	// stddev/sqrt(intervals), which adds up over every block ahead.
+	n = math.Abs(n)
+	variance := n*e.stddev*e.stddev + n*n*e.stddev*e.stddev/float64(e.intervals)
+	spread := time.Duration(z95 * math.Sqrt(variance) * float64(time.Second))
+
+	return Estimate{
+		BlockHeight: height,
+		Time:        at,
+		Earliest:    at.Add(-spread),
+		Latest:      at.Add(spread),
+	}
+}
+
+// Observed is the estimate of a block that exists
+func Observed(s Sample) Estimate {
+	return Estimate{BlockHeight: s.Height, Time: s.Time, Earliest: s.Time, Latest: s.Time, Observed: true}
+}
@@ -0,0 +1,53 @@
+package blocktime_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/Emyrk/go-factom-vote/blocktime"
+)
+
+func TestEstimator(t *testing.T) {
+	if NewEstimator(nil) != nil {
+		t.Error("exp no estimator without samples")
+	}
+
+	// Blocks alternating 9 and 11 minutes apart
+	start := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	var samples []Sample
+	at := start
+	for h := 100; h <= 200; h++ {
+		samples = append(samples, Sample{h, at})
+		if h%2 == 0 {
+			at = at.Add(9 * time.Minute)
+		} else {
+			at = at.Add(11 * time.Minute)
+		}
+	}
+	e := NewEstimator(samples)
+	if e.BlockTime() != 10*time.Minute {
+		t.Errorf("exp block time of 10m, got %s", e.BlockTime())
+	}
+
+	latest := samples[len(samples)-1]
+	if est := e.Estimate(latest.Height); !est.Observed || !est.Time.Equal(latest.Time) {
+		t.Errorf("exp the latest block to be observed, got %v", est)
+	}
+
+	near, far := e.Estimate(latest.Height+6), e.Estimate(latest.Height+600)
+	if !near.Time.Equal(latest.Time.Add(time.Hour)) {
+		t.Errorf("exp an hour after the latest block, got %s", near.Time)
+	}
+	if near.Observed || !near.Earliest.Before(near.Time) || !near.Latest.After(near.Time) {
+		t.Errorf("exp a range around the estimate, got %v", near)
+	}
+	if far.Latest.Sub(far.Time) <= near.Latest.Sub(near.Time) {
+		t.Errorf("exp the range to widen with distance, %s then %s", near.Latest.Sub(near.Time), far.Latest.Sub(far.Time))
+	}
+
+	// Too few samples to measure, the nominal time is used
+	e = NewEstimator(samples[:1])
+	if e.BlockTime() != DefaultBlockTime {
+		t.Errorf("exp the default block time, got %s", e.BlockTime())
+	}
+}
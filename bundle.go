@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Emyrk/go-factom-vote/config"
+	"github.com/Emyrk/go-factom-vote/vote"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/FactomProject/factom"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// runExportVote writes the raw entries of a vote, and the identity keys they
+// are checked with, to a bundle that verify-bundle checks with no factomd.
+//
+//	go-factom-vote export-vote -config=factom-vote.toml [-o=vote.json.gz] <vote chain>
+func runExportVote(args []string) int {
+	fs := flag.NewFlagSet("export-vote", flag.ExitOnError)
+	out := fs.String("o", "", "File to write the bundle to, <vote chain>.json.gz by default")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "go-factom-vote export-vote [-o=<file>] <vote chain>")
+		fs.PrintDefaults()
+	}
+
+	cfg := config.Default()
+	cfg.Log.Level = "none"
+	if err := cfg.Load(fs, args); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	cfg.ApplyLogLevel()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	votechain, err := primitives.HexToHash(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("parsing vote chain id: %s\n", err.Error())
+		return 2
+	}
+
+	// The bundle is read from factomd, and recounted in memory
+	factom.SetFactomdServer(cfg.Factomd.Location())
+	c := vote.NewAPIController(cfg.Factomd.Location())
+	b, err := c.ExportVote(context.Background(), votechain)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	path := *out
+	if path == "" {
+		path = votechain.String() + ".json.gz"
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if err := common.WriteBundle(f, b); err != nil {
+		f.Close()
+		fmt.Println(err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Printf("Wrote vote %s at height %d to %s\n", b.VoteChain, b.BlockHeight, path)
+	fmt.Printf("\t%d eligible list entries, %d vote entries, %d identity entries\n", len(b.EligibleList), len(b.VoteEntries), len(b.IdentityEntries))
+	return 0
+}
+
+// runVerifyBundle recomputes the result of a vote from a bundle written by
+// export-vote, with no factomd or database, and prints it.
+//
+//	go-factom-vote verify-bundle vote.json.gz
+func runVerifyBundle(args []string) int {
+	fs := flag.NewFlagSet("verify-bundle", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "go-factom-vote verify-bundle <file, or - for stdin>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	b, err := common.ReadBundle(bytes.NewReader(data))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	stats, r, err := b.Verify()
+	if err != nil {
+		fmt.Printf("INVALID bundle of vote %s: %s\n", b.VoteChain, err.Error())
+		return 1
+	}
+	fmt.Printf("Recounted vote %s at height %d\n", b.VoteChain, b.BlockHeight)
+	fmt.Printf("\t%d eligible voters, %d commits, %d reveals\n", len(r.EligibleVoters(r.Vote.Proposal.Vote.PhasesBlockHeights.CommitStart)), len(r.Commits()), len(r.Reveals()))
+	for _, s := range r.Skipped {
+		fmt.Printf("\tskipped entry %s at %d: %s\n", s.EntryHash, s.BlockHeight, s.Reason)
+	}
+	fmt.Printf("The identity keys are derived from %d identity chain entries\n", len(b.IdentityEntries))
+
+	// The receipts are only as good as the DBlocks they end in
+	dblocks := b.DBlocks()
+	var heights []int
+	for h := range dblocks {
+		heights = append(heights, h)
+	}
+	sort.Ints(heights)
+	fmt.Println("The entries are in these DBlocks, compare their KeyMRs to any factomd:")
+	for _, h := range heights {
+		fmt.Printf("\t%d %s\n", h, dblocks[h])
+	}
+	fmt.Printf("The bundle height %d is as exported, and entries missing from the bundle are not detected\n", b.BlockHeight)
+
+	result, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Println(string(result))
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Emyrk/go-factom-vote/compare"
+	"github.com/Emyrk/go-factom-vote/config"
+	"github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/database"
+)
+
+// runCompare checks the configured api servers and databases agree on the
+// votes, every vote if none are given. The first source is the reference the
+// others are compared against. Exits 1 if any diverge.
+//
+//	go-factom-vote compare -config=factom-vote.toml [-endpoints=http://a:8080,http://b:8080] [vote chain...]
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	endpoints := fs.String("endpoints", "", "Comma separated api server urls, overriding compare.endpoints")
+	asJSON := fs.Bool("json", false, "Print the report as json")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "go-factom-vote compare [-endpoints=<url>,<url>...] [vote chain...]")
+		fs.PrintDefaults()
+	}
+
+	cfg := config.Default()
+	cfg.Log.Level = "none"
+	if err := cfg.Load(fs, args); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	cfg.ApplyLogLevel()
+	if *endpoints != "" {
+		cfg.Compare.Endpoints = strings.Split(*endpoints, ",")
+		if err := cfg.Validate(); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+
+	var sources []compare.Source
+	for _, e := range cfg.Compare.Endpoints {
+		sources = append(sources, compare.NewAPISource(e))
+	}
+	for _, d := range cfg.Compare.Databases {
+		db, err := database.InitDb(d.SqlConfig())
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer db.DB.Close()
+		name := fmt.Sprintf("postgres://%s:%d/%s", d.Host, d.Port, d.Schema)
+		sources = append(sources, compare.NewDBSource(name, &apiserver.GraphQLSQLDB{SQLDatabase: db}))
+	}
+
+	report, err := compare.Compare(context.Background(), sources, fs.Args())
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		report.WriteText(os.Stdout)
+	}
+	if report.Diverged() {
+		return 1
+	}
+	return 0
+}
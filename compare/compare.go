@@ -0,0 +1,360 @@
+// Package compare checks that daemons run by different organizations agree
+// on the votes they have synced.
+//
+// Each source, an api server or a database, is compared against the first.
+// A vote is compared in parts: the proposal, the eligible voters, the counted
+// reveals and the results. Parts that are lists of entries are walked in chain
+// order, so a divergence is reported at the first entry that differs.
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+const (
+	PartVote     = "vote"
+	PartProposal = "proposal"
+	PartEligible = "eligible"
+	PartReveals  = "reveals"
+	PartResults  = "results"
+)
+
+// Divergence is a part of a vote a source does not agree with the reference on
+type Divergence struct {
+	VoteChain string `json:"voteChain"`
+	Part      string `json:"part"`
+	Reference string `json:"reference"`
+	Source    string `json:"source"`
+	// EntryHash is the first entry that differs, empty if the part is not a
+	// list of entries or no entry differs
+	EntryHash string `json:"entryHash,omitempty"`
+	// Fields are the json fields that differ, of the first differing entry of
+	// a list. 'missing' or 'extra' if the source does not have what the
+	// reference has, or the other way around.
+	Fields []string `json:"fields"`
+	// Entries is the number of entries that differ, for parts that are lists
+	Entries int `json:"entries,omitempty"`
+}
+
+// Report is the result of comparing the sources
+type Report struct {
+	Sources     []string     `json:"sources"`
+	Votes       []string     `json:"votes"`
+	Divergences []Divergence `json:"divergences"`
+}
+
+// Compare compares the votes across the sources. If no votes are given,
+// every vote any source has is compared.
+func Compare(ctx context.Context, sources []Source, voteChains []string) (*Report, error) {
+	if len(sources) < 2 {
+		return nil, fmt.Errorf("need at least 2 sources to compare, have %d", len(sources))
+	}
+
+	r := new(Report)
+	r.Divergences = []Divergence{}
+	for _, s := range sources {
+		r.Sources = append(r.Sources, s.Name())
+	}
+
+	if len(voteChains) == 0 {
+		seen := make(map[string]bool)
+		for _, s := range sources {
+			chains, err := s.VoteChains(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", s.Name(), err.Error())
+			}
+			for _, c := range chains {
+				if !seen[c] {
+					seen[c] = true
+					voteChains = append(voteChains, c)
+				}
+			}
+		}
+		sort.Strings(voteChains)
+	}
+	r.Votes = voteChains
+
+	for _, chain := range voteChains {
+		snapshots := make([]*Snapshot, len(sources))
+		for i, s := range sources {
+			snap, err := s.Snapshot(ctx, chain)
+			if err != nil {
+				return nil, fmt.Errorf("%s: vote %s: %s", s.Name(), chain, err.Error())
+			}
+			snapshots[i] = snap
+		}
+
+		for i := 1; i < len(sources); i++ {
+			for _, d := range CompareSnapshots(snapshots[0], snapshots[i]) {
+				d.VoteChain = chain
+				d.Reference = sources[0].Name()
+				d.Source = sources[i].Name()
+				r.Divergences = append(r.Divergences, d)
+			}
+		}
+	}
+	return r, nil
+}
+
+// CompareSnapshots returns the parts of b that differ from the reference a.
+// A nil snapshot is a vote the source does not have.
+func CompareSnapshots(a, b *Snapshot) []Divergence {
+	switch {
+	case a == nil && b == nil:
+		return nil
+	case a == nil:
+		return []Divergence{{Part: PartVote, Fields: []string{"extra"}}}
+	case b == nil:
+		return []Divergence{{Part: PartVote, Fields: []string{"missing"}}}
+	}
+
+	var divs []Divergence
+	if d := compareProposal(a, b); d != nil {
+		divs = append(divs, *d)
+	}
+	if d := compareEntries(PartEligible, eligibleEntries(a), eligibleEntries(b)); d != nil {
+		divs = append(divs, *d)
+	}
+	if d := compareEntries(PartReveals, countedEntries(a), countedEntries(b)); d != nil {
+		divs = append(divs, *d)
+	}
+	if d := compareResults(a, b); d != nil {
+		divs = append(divs, *d)
+	}
+	return divs
+}
+
+func compareProposal(a, b *Snapshot) *Divergence {
+	va, vb := a.Vote, b.Vote
+	va.Search, vb.Search = nil, nil
+	fields := diffFields(va, vb)
+	if len(fields) == 0 {
+		return nil
+	}
+	return &Divergence{Part: PartProposal, EntryHash: a.Vote.Admin.AdminEntryHash, Fields: fields}
+}
+
+func compareResults(a, b *Snapshot) *Divergence {
+	switch {
+	case a.Results == nil && b.Results == nil:
+		return nil
+	case a.Results == nil:
+		return &Divergence{Part: PartResults, Fields: []string{"extra"}}
+	case b.Results == nil:
+		return &Divergence{Part: PartResults, Fields: []string{"missing"}}
+	}
+
+	d := new(Divergence)
+	d.Part = PartResults
+	d.Fields = diffFields(resultFields(a.Results), resultFields(b.Results))
+
+	// Every ballot, counted or not, in the order of the reveals
+	if ballots := compareEntries(PartResults, ballotEntries(a, false), ballotEntries(b, false)); ballots != nil {
+		d.EntryHash = ballots.EntryHash
+		d.Entries = ballots.Entries
+		d.Fields = append(d.Fields, "ballots")
+	}
+	if len(d.Fields) == 0 {
+		return nil
+	}
+	return d
+}
+
+// resultFields is the results without the ballots, which are compared as
+// entries, and with the winners in a fixed order
+func resultFields(stats *common.VoteStats) common.VoteStats {
+	s := *stats
+	s.Ballots = nil
+	s.Provisional = false
+	s.Unrevealed = nil
+	s.WeightedWinners = append([]common.VoteOptionStats{}, stats.WeightedWinners...)
+	sort.Slice(s.WeightedWinners, func(i, j int) bool { return s.WeightedWinners[i].Option < s.WeightedWinners[j].Option })
+	return s
+}
+
+// entry is an entry of a list, compared by the json of its value
+type entry struct {
+	Height    int
+	EntryHash string
+	Value     interface{}
+}
+
+func eligibleEntries(s *Snapshot) []entry {
+	var entries []entry
+	for _, v := range s.EligibleVoters {
+		// The keys are looked up by each daemon, and are not from the chain
+		v.SigningKeys = nil
+		entries = append(entries, entry{v.BlockHeight, v.EntryHash, v})
+	}
+	return entries
+}
+
+// countedEntries are the reveals the results counted
+func countedEntries(s *Snapshot) []entry {
+	return ballotEntries(s, true)
+}
+
+func ballotEntries(s *Snapshot, countedOnly bool) []entry {
+	if s.Results == nil {
+		return nil
+	}
+	heights := make(map[string]int)
+	for _, r := range s.Reveals {
+		heights[r.EntryHash] = r.BlockHeight
+	}
+
+	var entries []entry
+	for _, b := range s.Results.Ballots {
+		if countedOnly && b.Status != common.BallotCounted {
+			continue
+		}
+		entries = append(entries, entry{heights[b.EntryHash], b.EntryHash, b})
+	}
+	return entries
+}
+
+// compareEntries walks both lists in chain order. The divergence is at the
+// first entry that is in only one list, or differs between them.
+func compareEntries(part string, a, b []entry) *Divergence {
+	sortEntries(a)
+	sortEntries(b)
+
+	var d *Divergence
+	found := func(hash string, fields []string) {
+		if d == nil {
+			d = &Divergence{Part: part, EntryHash: hash, Fields: fields}
+		}
+		d.Entries++
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && entryBefore(a[i], b[j])):
+			found(a[i].EntryHash, []string{"missing"})
+			i++
+		case i == len(a) || entryBefore(b[j], a[i]):
+			found(b[j].EntryHash, []string{"extra"})
+			j++
+		default:
+			if fields := diffFields(a[i].Value, b[j].Value); len(fields) > 0 {
+				found(a[i].EntryHash, fields)
+			}
+			i++
+			j++
+		}
+	}
+	return d
+}
+
+func sortEntries(entries []entry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entryBefore(entries[i], entries[j]) })
+}
+
+func entryBefore(a, b entry) bool {
+	if a.Height != b.Height {
+		return a.Height < b.Height
+	}
+	return a.EntryHash < b.EntryHash
+}
+
+// diffFields returns the json paths of the fields that differ, eg:
+// 'options.yes.weight'. Items of arrays are by their index.
+func diffFields(a, b interface{}) []string {
+	fa, fb := make(map[string]string), make(map[string]string)
+	flatten(a, fa)
+	flatten(b, fb)
+
+	var fields []string
+	for k, v := range fa {
+		if w, ok := fb[k]; !ok || v != w {
+			fields = append(fields, k)
+		}
+	}
+	for k := range fb {
+		if _, ok := fa[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// flatten sets the json of every leaf of v by its path
+func flatten(v interface{}, into map[string]string) {
+	data, _ := json.Marshal(v)
+	var generic interface{}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	dec.Decode(&generic)
+	flattenValue("", generic, into)
+}
+
+func flattenValue(path string, v interface{}, into map[string]string) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) > 0 {
+			for k, e := range val {
+				flattenValue(join(k), e, into)
+			}
+			return
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			for i, e := range val {
+				flattenValue(join(fmt.Sprint(i)), e, into)
+			}
+			return
+		}
+	}
+	data, _ := json.Marshal(v)
+	into[path] = string(data)
+}
+
+// Diverged is true if any source does not agree with the reference
+func (r *Report) Diverged() bool {
+	return len(r.Divergences) > 0
+}
+
+// WriteText prints the report for a person, grouped by vote
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Compared %d votes across %d sources, against %s\n", len(r.Votes), len(r.Sources), r.Sources[0])
+
+	votes := 0
+	last := ""
+	for _, d := range r.Divergences {
+		if d.VoteChain != last {
+			fmt.Fprintf(w, "vote %s\n", d.VoteChain)
+			last = d.VoteChain
+			votes++
+		}
+		fmt.Fprintf(w, "\t%s: %s differs", d.Part, d.Source)
+		if d.EntryHash != "" {
+			fmt.Fprintf(w, " from entry %s", d.EntryHash)
+		}
+		if d.Entries > 1 {
+			fmt.Fprintf(w, " (%d entries)", d.Entries)
+		}
+		fmt.Fprintf(w, ": %s\n", strings.Join(d.Fields, ", "))
+	}
+
+	if r.Diverged() {
+		fmt.Fprintf(w, "%d divergences in %d votes\n", len(r.Divergences), votes)
+	} else {
+		fmt.Fprintln(w, "All sources agree")
+	}
+}
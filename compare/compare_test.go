@@ -0,0 +1,238 @@
+package compare_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/compare"
+	"github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+const (
+	testChain    = "a000000000000000000000000000000000000000000000000000000000000000"
+	testEligible = "e000000000000000000000000000000000000000000000000000000000000000"
+)
+
+func hash(b byte) string {
+	return strings.Repeat(string([]byte{b}), 64)
+}
+
+func option(name string, count, weight int64) common.VoteOptionStats {
+	var o common.VoteOptionStats
+	o.Option = name
+	o.Count = common.NewDecimal(count)
+	o.Weight = common.NewDecimal(weight)
+	return o
+}
+
+// testSnapshot is a complete binary vote with three voters, two of which
+// revealed
+func testSnapshot() *Snapshot {
+	s := new(Snapshot)
+	s.Vote.Chainid = testChain
+	s.Vote.Admin.AdminEntryHash = hash('0')
+	s.Vote.Admin.Complete = true
+	s.Vote.Proposal.Title = "Test vote"
+	s.Vote.Definition.PhasesBlockHeights.CommitStart = 100
+	s.Vote.Definition.EligibleVoterChain = testEligible
+	s.Vote.Definition.Config.Options = []string{"yes", "no"}
+
+	for i, id := range []string{"v1", "v2", "v3"} {
+		s.EligibleVoters = append(s.EligibleVoters, apiserver.EligibleVoter{
+			VoterID: id, VoteWeight: common.NewDecimal(1), BlockHeight: 90, EligibleList: testEligible,
+			EntryHash: hash(byte('1' + i)), SigningKeys: []string{hash('k')},
+		})
+	}
+	s.Reveals = []apiserver.VoteReveal{
+		{VoterID: "v1", VoteChain: testChain, Vote: []string{"yes"}, EntryHash: hash('a'), BlockHeight: 120},
+		{VoterID: "v2", VoteChain: testChain, Vote: []string{"no"}, EntryHash: hash('b'), BlockHeight: 121},
+	}
+
+	r := new(common.VoteStats)
+	r.VoteChain = testChain
+	r.Valid = true
+	r.OptionStats = map[string]common.VoteOptionStats{
+		"yes": option("yes", 1, 1),
+		"no":  option("no", 1, 1),
+	}
+	r.WeightedWinners = []common.VoteOptionStats{r.OptionStats["yes"], r.OptionStats["no"]}
+	r.Ballots = []common.Ballot{
+		{VoterID: "v1", EntryHash: hash('a'), Options: []string{"yes"}, Status: common.BallotCounted, Weight: common.NewDecimal(1)},
+		{VoterID: "v2", EntryHash: hash('b'), Options: []string{"no"}, Status: common.BallotCounted, Weight: common.NewDecimal(1)},
+	}
+	s.Results = r
+	return s
+}
+
+// serve is an in process api server with the rest api of the snapshot. Lists
+// are paged by the index of the item, so every page is asked for.
+func serve(t *testing.T, s *Snapshot) *httptest.Server {
+	page := func(w http.ResponseWriter, r *http.Request, n int, build func(from, to int, info apiserver.PageInfo) interface{}) {
+		first, _ := strconv.Atoi(r.URL.Query().Get("first"))
+		from := 0
+		if after := r.URL.Query().Get("after"); after != "" {
+			from, _ = strconv.Atoi(after)
+		}
+		to := n
+		if first > 0 && from+first < n {
+			to = from + first
+		}
+		info := apiserver.PageInfo{HasNextPage: to < n, EndCursor: strconv.Itoa(to)}
+		json.NewEncoder(w).Encode(build(from, to, info))
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		votePath := "/v1/votes/" + testChain
+		switch {
+		case r.URL.Path == "/v1/votes":
+			var votes []apiserver.Vote
+			if s != nil {
+				votes = append(votes, s.Vote)
+			}
+			page(w, r, len(votes), func(from, to int, info apiserver.PageInfo) interface{} {
+				return apiserver.VoteList{Votes: votes[from:to], PageInfo: info}
+			})
+		case s == nil:
+			http.NotFound(w, r)
+		case r.URL.Path == votePath:
+			json.NewEncoder(w).Encode(s.Vote)
+		case r.URL.Path == "/v1/eligible-lists/"+testEligible+"/voters":
+			if r.URL.Query().Get("blockHeight") != "100" {
+				t.Errorf("exp the voters at the commit start, got %s", r.URL.RawQuery)
+			}
+			page(w, r, len(s.EligibleVoters), func(from, to int, info apiserver.PageInfo) interface{} {
+				return apiserver.EligibleVoterContainer{EligibleVoters: s.EligibleVoters[from:to], PageInfo: info}
+			})
+		case r.URL.Path == votePath+"/reveals":
+			page(w, r, len(s.Reveals), func(from, to int, info apiserver.PageInfo) interface{} {
+				return apiserver.VoteRevealContainer{Reveals: s.Reveals[from:to], PageInfo: info}
+			})
+		case r.URL.Path == votePath+"/results" && s.Results != nil:
+			json.NewEncoder(w).Encode(s.Results)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func compareServers(t *testing.T, snapshots ...*Snapshot) *Report {
+	var sources []Source
+	for _, s := range snapshots {
+		srv := serve(t, s)
+		defer srv.Close()
+		api := NewAPISource(srv.URL)
+		api.PageSize = 1
+		sources = append(sources, api)
+	}
+
+	r, err := Compare(context.Background(), sources, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestCompareAgree(t *testing.T) {
+	b := testSnapshot()
+	// Not from the chain, so not compared
+	b.EligibleVoters[0].SigningKeys = nil
+	b.Results.WeightedWinners = []common.VoteOptionStats{b.Results.OptionStats["no"], b.Results.OptionStats["yes"]}
+
+	r := compareServers(t, testSnapshot(), b, testSnapshot())
+	if r.Diverged() {
+		t.Errorf("exp no divergences, got %+v", r.Divergences)
+	}
+	if !reflect.DeepEqual(r.Votes, []string{testChain}) {
+		t.Errorf("exp the vote to be compared, got %v", r.Votes)
+	}
+}
+
+func TestCompareDiverged(t *testing.T) {
+	// A different weight of the second voter
+	weight := testSnapshot()
+	weight.EligibleVoters[1].VoteWeight = common.NewDecimal(2)
+
+	// The second reveal was not counted, so the results differ
+	excluded := testSnapshot()
+	excluded.Results.Ballots[1].Status = common.BallotExcluded
+	excluded.Results.Ballots[1].Reason = "not eligible"
+	excluded.Results.OptionStats["no"] = option("no", 0, 0)
+
+	// Not synced to the end of the vote
+	behind := testSnapshot()
+	behind.Vote.Admin.Complete = false
+	behind.Results = nil
+
+	r := compareServers(t, testSnapshot(), weight, excluded, behind, nil)
+
+	type found struct {
+		Source    int
+		Part      string
+		EntryHash string
+		Fields    []string
+	}
+	exp := []found{
+		{1, PartEligible, hash('2'), []string{"weight"}},
+		{2, PartReveals, hash('b'), []string{"missing"}},
+		{2, PartResults, hash('b'), []string{"options.no.count", "options.no.weight", "ballots"}},
+		{3, PartProposal, hash('0'), []string{"admin.complete"}},
+		{3, PartReveals, hash('a'), []string{"missing"}},
+		{3, PartResults, "", []string{"missing"}},
+		{4, PartVote, "", []string{"missing"}},
+	}
+
+	if len(r.Divergences) != len(exp) {
+		t.Fatalf("exp %d divergences, got %d: %+v", len(exp), len(r.Divergences), r.Divergences)
+	}
+	for i, e := range exp {
+		d := r.Divergences[i]
+		if d.VoteChain != testChain || d.Reference != r.Sources[0] || d.Source != r.Sources[e.Source] {
+			t.Errorf("%d: exp %s against %s, got %+v", i, r.Sources[e.Source], r.Sources[0], d)
+		}
+		if d.Part != e.Part || d.EntryHash != e.EntryHash || !reflect.DeepEqual(d.Fields, e.Fields) {
+			t.Errorf("%d: exp %+v, got %+v", i, e, d)
+		}
+	}
+
+	// Both reveals of the vote are missing from the one behind
+	if r.Divergences[4].Entries != 2 {
+		t.Errorf("exp 2 differing entries, got %d", r.Divergences[4].Entries)
+	}
+
+	var buf bytes.Buffer
+	r.WriteText(&buf)
+	if !strings.Contains(buf.String(), "eligible: "+r.Sources[1]+" differs from entry "+hash('2')+": weight") {
+		t.Errorf("exp the divergence in the report, got\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "7 divergences in 1 votes") {
+		t.Errorf("exp a summary, got\n%s", buf.String())
+	}
+}
+
+func TestCompareSources(t *testing.T) {
+	if _, err := Compare(context.Background(), []Source{NewAPISource("http://localhost")}, nil); err == nil {
+		t.Errorf("exp a single source to fail")
+	}
+
+	// An api server that errors fails the comparison, rather than diverging
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"errors":[{"message":"database is down"}]}`))
+	}))
+	defer broken.Close()
+	srv := serve(t, testSnapshot())
+	defer srv.Close()
+
+	_, err := Compare(context.Background(), []Source{NewAPISource(srv.URL), NewAPISource(broken.URL)}, []string{testChain})
+	if err == nil || !strings.Contains(err.Error(), "database is down") {
+		t.Errorf("exp the error of the api server, got %v", err)
+	}
+}
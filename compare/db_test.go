@@ -0,0 +1,288 @@
+package compare_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/compare"
+	"github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/database"
+)
+
+// snapshotDriver is a database/sql driver that answers the queries the
+// apiserver runs for the rest api and DBSource from a snapshot, so both are
+// tested over the real sql scanning and cursor paging without postgres. The
+// queries are told apart by the table they read, and lists are filtered and
+// paged the way the sql of PageArgs.Wrap asks.
+type snapshotDriver struct{}
+
+var snapshotDBs = struct {
+	sync.Mutex
+	m map[string]*Snapshot
+}{m: make(map[string]*Snapshot)}
+
+func init() {
+	sql.Register("compare-snapshot", snapshotDriver{})
+}
+
+// snapshotDB is the apiserver database of the snapshot, nil for none
+func snapshotDB(t *testing.T, s *Snapshot) *apiserver.GraphQLSQLDB {
+	snapshotDBs.Lock()
+	name := strconv.Itoa(len(snapshotDBs.m))
+	snapshotDBs.m[name] = s
+	snapshotDBs.Unlock()
+
+	db, err := sql.Open("compare-snapshot", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiserver.GraphQLSQLDB{SQLDatabase: &database.SQLDatabase{DB: db}}
+}
+
+func (snapshotDriver) Open(name string) (driver.Conn, error) {
+	snapshotDBs.Lock()
+	defer snapshotDBs.Unlock()
+	return &snapshotConn{snapshotDBs.m[name]}, nil
+}
+
+type snapshotConn struct {
+	s *Snapshot
+}
+
+func (c *snapshotConn) Prepare(query string) (driver.Stmt, error) {
+	return &snapshotStmt{c, query}, nil
+}
+func (c *snapshotConn) Close() error              { return nil }
+func (c *snapshotConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("read only") }
+
+type snapshotStmt struct {
+	c     *snapshotConn
+	query string
+}
+
+func (s *snapshotStmt) Close() error  { return nil }
+func (s *snapshotStmt) NumInput() int { return -1 }
+func (s *snapshotStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("read only")
+}
+func (s *snapshotStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.query(s.query, args)
+}
+
+// row is a row of a list, with the columns cursors page by
+type row struct {
+	height int64
+	hash   string
+	values []driver.Value
+}
+
+var limitRe = regexp.MustCompile(`LIMIT (\d+)`)
+
+func (c *snapshotConn) query(q string, args []driver.Value) (driver.Rows, error) {
+	s := c.s
+	var rows []row
+	switch {
+	case strings.Contains(q, "FROM proposals"):
+		// Listed, or asked for by chain
+		if s != nil && (strings.Contains(q, "full_count") || args[0] == s.Vote.Chainid) {
+			rows = append(rows, voteRow(s.Vote))
+		}
+	case strings.Contains(q, "fetch_eligible_voters("):
+		for _, v := range eligibleVoters(s) {
+			if v.EligibleList == args[0] && int64(v.BlockHeight) < args[1].(int64) {
+				rows = append(rows, row{int64(v.BlockHeight), v.EntryHash, []driver.Value{
+					v.VoterID, v.EligibleList, v.VoteWeight.String(), v.EntryHash, int64(v.BlockHeight), strings.Join(v.SigningKeys, ","),
+				}})
+			}
+		}
+	case strings.Contains(q, "FROM reveals"):
+		for _, r := range reveals(s) {
+			if r.VoteChain == args[0] {
+				rows = append(rows, row{int64(r.BlockHeight), r.EntryHash, []driver.Value{
+					r.VoterID, r.VoteChain, strings.Join(r.Vote, ","), r.Secret, r.HmacAlgo, r.EntryHash, int64(r.BlockHeight),
+				}})
+			}
+		}
+	case strings.Contains(q, "FROM results"):
+		if s != nil && s.Results != nil && args[0] == s.Results.VoteChain {
+			rows = append(rows, resultsRow(s))
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query: %s", q)
+	}
+
+	// The count is of every row that matches, before the page is cut
+	if strings.Contains(q, "full_count") {
+		for i := range rows {
+			rows[i].values = append(rows[i].values, int64(len(rows)))
+		}
+	}
+	if strings.Contains(q, "ORDER BY block_height ASC, entry_hash ASC") {
+		sort.SliceStable(rows, func(i, j int) bool {
+			if rows[i].height != rows[j].height {
+				return rows[i].height < rows[j].height
+			}
+			return rows[i].hash < rows[j].hash
+		})
+	}
+	if strings.Contains(q, "(block_height, entry_hash) > (") {
+		height, hash := args[len(args)-2].(int64), args[len(args)-1].(string)
+		var after []row
+		for _, r := range rows {
+			if r.height > height || (r.height == height && r.hash > hash) {
+				after = append(after, r)
+			}
+		}
+		rows = after
+	}
+	if m := limitRe.FindStringSubmatch(q); m != nil {
+		if n, _ := strconv.Atoi(m[1]); n < len(rows) {
+			rows = rows[:n]
+		}
+	}
+
+	res := &snapshotRows{}
+	for _, r := range rows {
+		res.values = append(res.values, r.values)
+	}
+	return res, nil
+}
+
+func eligibleVoters(s *Snapshot) []apiserver.EligibleVoter {
+	if s == nil {
+		return nil
+	}
+	return s.EligibleVoters
+}
+
+func reveals(s *Snapshot) []apiserver.VoteReveal {
+	if s == nil {
+		return nil
+	}
+	return s.Reveals
+}
+
+// voteRow is in the columns of voterow
+func voteRow(v apiserver.Vote) row {
+	return row{int64(v.Admin.AdminBlockHeight), v.Admin.AdminEntryHash, []driver.Value{
+		v.Admin.VoteInitator, v.Admin.SigningKey, v.Admin.Signature,
+		v.Proposal.Title, v.Proposal.Text, v.Proposal.ExternalRef.Href, v.Proposal.ExternalRef.Hash.Value, v.Proposal.ExternalRef.Hash.Algo,
+		int64(v.Definition.PhasesBlockHeights.CommitStart), int64(v.Definition.PhasesBlockHeights.CommitStop),
+		int64(v.Definition.PhasesBlockHeights.RevealStart), int64(v.Definition.PhasesBlockHeights.RevealStop),
+		v.Definition.EligibleVoterChain,
+		int64(v.Definition.VoteType), strings.Join(v.Definition.Config.Options, ","), v.Definition.Config.AllowAbstention,
+		v.Definition.Config.ComputeResultsAgainst, int64(v.Definition.Config.MinOptions), int64(v.Definition.Config.MaxOptions),
+		v.Definition.Config.AcceptanceCriteria, v.Definition.Config.WinnerCriteria,
+		v.Chainid, v.Admin.AdminEntryHash, int64(v.Admin.AdminBlockHeight), v.Admin.Registered, v.Admin.Complete,
+		int64(v.Admin.ProtocolVersion),
+	}}
+}
+
+// resultsRow is in the columns of VoteStats.SelectRows
+func resultsRow(s *Snapshot) row {
+	r := s.Results
+	options, _ := json.Marshal(r.OptionStats)
+	winners, _ := json.Marshal(r.WeightedWinners)
+	ballots, _ := json.Marshal(r.Ballots)
+	return row{values: []driver.Value{
+		r.VoteChain, r.Valid,
+		r.CompleteStats.Count.String(), r.CompleteStats.Weight.String(),
+		r.VotedStats.Count.String(), r.VotedStats.Weight.String(),
+		r.AbstainedStats.Count.String(), r.AbstainedStats.Weight.String(),
+		r.Turnout.UnweightedTurnout.String(), r.Turnout.WeightedTurnout.String(),
+		r.Support.CountDenominator.String(), r.Support.WeightDenominator.String(),
+		string(options), string(winners), ballots, int64(r.AlgorithmVersion), r.InputHash,
+	}}
+}
+
+type snapshotRows struct {
+	values [][]driver.Value
+}
+
+func (r *snapshotRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+func (r *snapshotRows) Close() error { return nil }
+func (r *snapshotRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// serveDB is an api server with the rest api of the apiserver over the
+// database
+func serveDB(db *apiserver.GraphQLSQLDB) *httptest.Server {
+	srv := new(apiserver.GraphQLServer)
+	srv.Limits = apiserver.DefaultLimits()
+	// Every page is a request
+	srv.Limits.Rate = 0
+	srv.SQLDB = *db
+	return httptest.NewServer(srv.RESTHandler())
+}
+
+// The rest api is served by GraphQLServer.RESTHandler, next to the graphql of
+// Handler, and is what APISource reads. Both it and DBSource read the same
+// database here, and must agree with the hand written api server of serve.
+func TestCompareDatabase(t *testing.T) {
+	behind := testSnapshot()
+	behind.Reveals = behind.Reveals[:1]
+
+	db := snapshotDB(t, testSnapshot())
+	api := serveDB(db)
+	defer api.Close()
+	apiSource := NewAPISource(api.URL)
+	apiSource.PageSize = 1
+
+	behindAPI := serveDB(snapshotDB(t, behind))
+	defer behindAPI.Close()
+
+	fake := serve(t, testSnapshot())
+	defer fake.Close()
+
+	sources := []Source{NewAPISource(fake.URL), apiSource, NewDBSource("database", db), NewAPISource(behindAPI.URL)}
+	r, err := Compare(context.Background(), sources, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Votes) != 1 || r.Votes[0] != testChain {
+		t.Errorf("exp the vote to be compared, got %v", r.Votes)
+	}
+
+	// Only the reveal missing from the database behind
+	if len(r.Divergences) != 2 {
+		t.Fatalf("exp 2 divergences, got %+v", r.Divergences)
+	}
+	for _, d := range r.Divergences {
+		if d.Source != r.Sources[3] {
+			t.Errorf("exp only the database behind to diverge, got %+v", d)
+		}
+	}
+	if d := r.Divergences[0]; d.Part != PartReveals || d.EntryHash != hash('b') {
+		t.Errorf("exp the missing reveal, got %+v", d)
+	}
+
+	// A vote the database does not have
+	for _, source := range sources[1:3] {
+		s, err := source.Snapshot(context.Background(), hash('f'))
+		if err != nil || s != nil {
+			t.Errorf("%s: exp no vote, got %v, %v", source.Name(), s, err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+// DefaultPageSize is the number of items asked for per request. It is within
+// the default apiserver max_limit.
+const DefaultPageSize = 500
+
+// Source is the view one daemon has of the votes
+type Source interface {
+	// Name identifies the source in the report
+	Name() string
+	// VoteChains lists every vote the source has
+	VoteChains(ctx context.Context) ([]string, error)
+	// Snapshot returns what the source has of the vote, nil if it does not
+	// have the vote
+	Snapshot(ctx context.Context, voteChain string) (*Snapshot, error)
+}
+
+// Snapshot is everything compared of a single vote
+type Snapshot struct {
+	Vote apiserver.Vote
+	// EligibleVoters are the voters of the eligible list at the commit start,
+	// the same the results are counted against
+	EligibleVoters []apiserver.EligibleVoter
+	Reveals        []apiserver.VoteReveal
+	// Results are nil if the source has not computed them
+	Results *common.VoteStats
+}
+
+// APISource reads the rest api of an api server
+type APISource struct {
+	// URL is the base of the api server, eg: http://localhost:8080
+	URL      string
+	Client   *http.Client
+	PageSize int
+}
+
+func NewAPISource(base string) *APISource {
+	a := new(APISource)
+	a.URL = strings.TrimSuffix(base, "/")
+	a.Client = http.DefaultClient
+	a.PageSize = DefaultPageSize
+	return a
+}
+
+func (a *APISource) Name() string {
+	return a.URL
+}
+
+func (a *APISource) VoteChains(ctx context.Context) ([]string, error) {
+	var chains []string
+	err := a.pages(ctx, "/v1/votes", url.Values{}, func(data []byte) (apiserver.PageInfo, error) {
+		var list apiserver.VoteList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return list.PageInfo, err
+		}
+		for _, v := range list.Votes {
+			chains = append(chains, v.Chainid)
+		}
+		return list.PageInfo, nil
+	})
+	return chains, err
+}
+
+func (a *APISource) Snapshot(ctx context.Context, voteChain string) (*Snapshot, error) {
+	s := new(Snapshot)
+	found, err := a.get(ctx, "/v1/votes/"+voteChain, nil, &s.Vote)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	q := url.Values{"blockHeight": {strconv.Itoa(s.Vote.Definition.PhasesBlockHeights.CommitStart)}}
+	err = a.pages(ctx, "/v1/eligible-lists/"+s.Vote.Definition.EligibleVoterChain+"/voters", q, func(data []byte) (apiserver.PageInfo, error) {
+		var list apiserver.EligibleVoterContainer
+		if err := json.Unmarshal(data, &list); err != nil {
+			return list.PageInfo, err
+		}
+		s.EligibleVoters = append(s.EligibleVoters, list.EligibleVoters...)
+		return list.PageInfo, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.pages(ctx, "/v1/votes/"+voteChain+"/reveals", url.Values{}, func(data []byte) (apiserver.PageInfo, error) {
+		var list apiserver.VoteRevealContainer
+		if err := json.Unmarshal(data, &list); err != nil {
+			return list.PageInfo, err
+		}
+		s.Reveals = append(s.Reveals, list.Reveals...)
+		return list.PageInfo, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := new(common.VoteStats)
+	found, err = a.get(ctx, "/v1/votes/"+voteChain+"/results", nil, results)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		s.Results = results
+	}
+	return s, nil
+}
+
+// pages reads every page of a list, following the cursors
+func (a *APISource) pages(ctx context.Context, path string, q url.Values, read func(data []byte) (apiserver.PageInfo, error)) error {
+	q.Set("first", strconv.Itoa(a.PageSize))
+	for {
+		var raw json.RawMessage
+		found, err := a.get(ctx, path, q, &raw)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%s: %s not found", a.URL, path)
+		}
+		page, err := read(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %s", a.URL, path, err.Error())
+		}
+		if !page.HasNextPage {
+			return nil
+		}
+		q.Set("after", page.EndCursor)
+	}
+}
+
+// get reads the json of the path into v. Not found is not an error.
+func (a *APISource) get(ctx context.Context, path string, q url.Values, v interface{}) (bool, error) {
+	u := a.URL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := a.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Errors are in the graphql format
+		var res struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if json.Unmarshal(data, &res) == nil && len(res.Errors) > 0 {
+			return false, fmt.Errorf("%s: %s", u, res.Errors[0].Message)
+		}
+		return false, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("%s: %s", u, err.Error())
+	}
+	return true, nil
+}
+
+// DBSource reads the database of a scraper
+type DBSource struct {
+	Label string
+	DB    *apiserver.GraphQLSQLDB
+}
+
+func NewDBSource(label string, db *apiserver.GraphQLSQLDB) *DBSource {
+	d := new(DBSource)
+	d.Label = label
+	d.DB = db
+	return d
+}
+
+func (d *DBSource) Name() string {
+	return d.Label
+}
+
+func (d *DBSource) VoteChains(ctx context.Context) ([]string, error) {
+	list, err := d.DB.FetchAllVotes(ctx, 0, false, 0, 0, nil, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	var chains []string
+	for _, v := range list.Votes {
+		chains = append(chains, v.Chainid)
+	}
+	return chains, nil
+}
+
+func (d *DBSource) Snapshot(ctx context.Context, voteChain string) (*Snapshot, error) {
+	v, err := d.DB.FetchVote(ctx, voteChain)
+	if _, ok := err.(*apiserver.NotFoundError); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := new(Snapshot)
+	s.Vote = *v
+
+	// An empty page is every item, in block order
+	voters, err := d.DB.FetchEligibleVoters(ctx, v.Definition.EligibleVoterChain, v.Definition.PhasesBlockHeights.CommitStart, 0, 0, new(apiserver.PageArgs))
+	if err != nil {
+		return nil, err
+	}
+	s.EligibleVoters = voters.EligibleVoters
+
+	reveals, err := d.DB.FetchAllReveals(ctx, voteChain, 0, 0, new(apiserver.PageArgs))
+	if err != nil {
+		return nil, err
+	}
+	s.Reveals = reveals.Reveals
+
+	s.Results, err = d.DB.FetchVoteStats(ctx, voteChain)
+	if _, ok := err.(*apiserver.NotFoundError); ok {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
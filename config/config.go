@@ -0,0 +1,456 @@
+// Package config is the single configuration model shared by scraperd,
+// api-serverd and the go-factom-vote cli.
+//
+// Settings are layered, each overriding the last:
+//  1. Defaults (Default())
+//  2. The toml config file, given by -config or FACTOM_VOTE_CONFIG
+//  3. Environment variables, FACTOM_VOTE_<SECTION>_<KEY>
+//  4. Flags explicitly set on the command line
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Emyrk/go-factom-vote/health"
+	"github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/Emyrk/go-factom-vote/vote/database"
+	log "github.com/sirupsen/logrus"
+)
+
+// EnvPrefix is prepended to every environment variable override
+const EnvPrefix = "FACTOM_VOTE_"
+
+type Config struct {
+	Factomd   FactomdConfig   `toml:"factomd" json:"factomd"`
+	Postgres  PostgresConfig  `toml:"postgres" json:"postgres"`
+	APIServer APIServerConfig `toml:"apiserver" json:"apiserver"`
+	Scraper   ScraperConfig   `toml:"scraper" json:"scraper"`
+	Profiler  ProfilerConfig  `toml:"profiler" json:"profiler"`
+	Log       LogConfig       `toml:"log" json:"log"`
+	Health    HealthConfig    `toml:"health" json:"health"`
+	Compare   CompareConfig   `toml:"compare" json:"compare"`
+}
+
+type FactomdConfig struct {
+	Host string `toml:"host" json:"host"`
+	Port int    `toml:"port" json:"port"`
+}
+
+// Location is the host:port of the factomd api
+func (f FactomdConfig) Location() string {
+	return fmt.Sprintf("%s:%d", f.Host, f.Port)
+}
+
+type PostgresConfig struct {
+	Host     string `toml:"host" json:"host"`
+	Port     int    `toml:"port" json:"port"`
+	User     string `toml:"user" json:"user"`
+	Password string `toml:"password" json:"password"`
+	Schema   string `toml:"schema" json:"schema"`
+
+	MaxOpenConns int `toml:"max_open_conns" json:"max_open_conns"`
+	MaxIdleConns int `toml:"max_idle_conns" json:"max_idle_conns"`
+}
+
+// SqlConfig converts the postgres section to the config the database package expects
+func (p PostgresConfig) SqlConfig() database.SqlConfig {
+	return database.SqlConfig{
+		SqlConfigType: database.SQL_CON_CUSTOM,
+		User:          p.User,
+		Pass:          p.Password,
+		Host:          p.Host,
+		Port:          p.Port,
+		Schema:        database.SCHEMA(p.Schema),
+		MaxOpenConns:  p.MaxOpenConns,
+		MaxIdleConns:  p.MaxIdleConns,
+	}
+}
+
+type APIServerConfig struct {
+	// Listen is the address the graphql api is served on
+	Listen string `toml:"listen" json:"listen"`
+
+	// Limits on queries and clients, 0 disables each
+	MaxDepth       int    `toml:"max_depth" json:"max_depth"`
+	MaxCost        int    `toml:"max_cost" json:"max_cost"`
+	MaxLimit       int    `toml:"max_limit" json:"max_limit"`
+	RequestTimeout string `toml:"request_timeout" json:"request_timeout"`
+	// RateLimit is requests per second per ip or api key, with bursts of RateBurst
+	RateLimit float64  `toml:"rate_limit" json:"rate_limit"`
+	RateBurst int      `toml:"rate_burst" json:"rate_burst"`
+	APIKeys   []string `toml:"api_keys" json:"api_keys"`
+	// FieldCosts override the weight of graphql fields in the query cost
+	FieldCosts map[string]int `toml:"field_costs" json:"field_costs"`
+}
+
+// Limits converts the apiserver section to the limits the api server expects.
+// The config must be valid.
+func (a APIServerConfig) Limits() apiserver.Limits {
+	timeout, _ := time.ParseDuration(a.RequestTimeout)
+	return apiserver.Limits{
+		MaxDepth:   a.MaxDepth,
+		MaxCost:    a.MaxCost,
+		MaxLimit:   a.MaxLimit,
+		Timeout:    timeout,
+		Rate:       a.RateLimit,
+		Burst:      a.RateBurst,
+		APIKeys:    a.APIKeys,
+		FieldCosts: a.FieldCosts,
+	}
+}
+
+type ScraperConfig struct {
+	// Listen is the address metrics, health and the profiler are served on
+	Listen string `toml:"listen" json:"listen"`
+	// SigningKey is a file of the hex ed25519 seed results are signed with.
+	// Results are not signed if empty.
+	SigningKey string `toml:"signing_key" json:"signing_key"`
+}
+
+// Signer loads the signing key, nil if results are not signed
+func (s ScraperConfig) Signer() (*common.ResultSigner, error) {
+	if s.SigningKey == "" {
+		return nil, nil
+	}
+	return common.LoadResultSigner(s.SigningKey)
+}
+
+type ProfilerConfig struct {
+	Enabled bool `toml:"enabled" json:"enabled"`
+}
+
+type LogConfig struct {
+	// Level is one of 'debug', 'info', 'warn', 'error', or 'none'
+	Level string `toml:"level" json:"level"`
+}
+
+type HealthConfig struct {
+	// MaxLag is the number of blocks the database can trail factomd before /readyz fails
+	MaxLag int `toml:"max_lag" json:"max_lag"`
+}
+
+type CompareConfig struct {
+	// Endpoints are the base urls of the api servers 'go-factom-vote compare'
+	// checks agree, eg: http://localhost:8080
+	Endpoints []string `toml:"endpoints" json:"endpoints"`
+	// Databases are read directly instead, or as well
+	Databases []PostgresConfig `toml:"databases" json:"databases"`
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	c := new(Config)
+	c.Factomd.Host = "localhost"
+	c.Factomd.Port = 8088
+	c.Postgres.Host = "localhost"
+	c.Postgres.Port = 5432
+	c.Postgres.User = "postgres"
+	c.Postgres.Password = "password"
+	c.Postgres.Schema = database.SCHEMA_PUBLIC.String()
+	c.Postgres.MaxOpenConns = database.MAX_OPEN_CON
+	c.Postgres.MaxIdleConns = database.MAX_IDLE_CON
+	c.APIServer.Listen = ":8080"
+	limits := apiserver.DefaultLimits()
+	c.APIServer.MaxDepth = limits.MaxDepth
+	c.APIServer.MaxCost = limits.MaxCost
+	c.APIServer.MaxLimit = limits.MaxLimit
+	c.APIServer.RequestTimeout = limits.Timeout.String()
+	c.APIServer.RateLimit = limits.Rate
+	c.APIServer.RateBurst = limits.Burst
+	c.Scraper.Listen = ":6060"
+	c.Profiler.Enabled = true
+	c.Log.Level = "info"
+	c.Health.MaxLag = health.DefaultMaxLag
+	return c
+}
+
+// Load registers the shared flags on fs, parses args, and layers the config
+// file, environment and flags on top of c. Daemon specific flags should be
+// registered on fs before calling Load. The result is validated.
+func (c *Config) Load(fs *flag.FlagSet, args []string) error {
+	path := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "Path to a toml config file")
+	// The flags are parsed into a scratch copy, so only flags explicitly set
+	// override the file and environment.
+	f := *c
+	fs.StringVar(&f.Factomd.Host, "fhost", c.Factomd.Host, "Factomd host")
+	fs.IntVar(&f.Factomd.Port, "fport", c.Factomd.Port, "Factomd port")
+	location := fs.String("s", "", "Deprecated, use -fhost and -fport. Factomd api location as host:port")
+	fs.StringVar(&f.Postgres.Host, "phost", c.Postgres.Host, "Postgres host")
+	fs.IntVar(&f.Postgres.Port, "pport", c.Postgres.Port, "Postgres port")
+	fs.StringVar(&f.APIServer.Listen, "listen", c.APIServer.Listen, "Address the api server listens on")
+	fs.StringVar(&f.Scraper.Listen, "slisten", c.Scraper.Listen, "Address the scraper serves metrics, health and the profiler on")
+	fs.BoolVar(&f.Profiler.Enabled, "profiler", c.Profiler.Enabled, "Expose the go pprof endpoints")
+	fs.StringVar(&f.Log.Level, "l", c.Log.Level, "Set log level to 'debug', 'info', 'warn', 'error', or 'none'")
+	fs.IntVar(&f.Health.MaxLag, "maxlag", c.Health.MaxLag, "Blocks the database can trail factomd before /readyz fails")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *path != "" {
+		if err := c.LoadFile(*path); err != nil {
+			return err
+		}
+	}
+
+	if err := c.LoadEnv(); err != nil {
+		return err
+	}
+
+	// -s is the factomd location from before -fhost and -fport, which win
+	// over it
+	if *location != "" {
+		host, port, err := net.SplitHostPort(*location)
+		if err != nil {
+			return fmt.Errorf("-s: %s", err.Error())
+		}
+		c.Factomd.Host = host
+		if c.Factomd.Port, err = strconv.Atoi(port); err != nil {
+			return fmt.Errorf("-s: port %q is not a number", port)
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "fhost":
+			c.Factomd.Host = f.Factomd.Host
+		case "fport":
+			c.Factomd.Port = f.Factomd.Port
+		case "phost":
+			c.Postgres.Host = f.Postgres.Host
+		case "pport":
+			c.Postgres.Port = f.Postgres.Port
+		case "listen":
+			c.APIServer.Listen = f.APIServer.Listen
+		case "slisten":
+			c.Scraper.Listen = f.Scraper.Listen
+		case "profiler":
+			c.Profiler.Enabled = f.Profiler.Enabled
+		case "l":
+			c.Log.Level = f.Log.Level
+		case "maxlag":
+			c.Health.MaxLag = f.Health.MaxLag
+		}
+	})
+
+	return c.Validate()
+}
+
+// LoadFile overrides c with any settings in the toml file. Unknown keys are an error.
+func (c *Config) LoadFile(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if err != nil {
+		return fmt.Errorf("config file %s: %s", path, err.Error())
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		return fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// LoadEnv overrides c with any FACTOM_VOTE_ environment variables that are set.
+// FACTOMD_LOC and PG_HOST are still read for older docker setups.
+func (c *Config) LoadEnv() error {
+	var errs []string
+	str := func(name string, v *string) {
+		if e, ok := os.LookupEnv(name); ok {
+			*v = e
+		}
+	}
+	num := func(name string, v *int) {
+		if e, ok := os.LookupEnv(name); ok {
+			i, err := strconv.Atoi(e)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+				return
+			}
+			*v = i
+		}
+	}
+	float := func(name string, v *float64) {
+		if e, ok := os.LookupEnv(name); ok {
+			f, err := strconv.ParseFloat(e, 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+				return
+			}
+			*v = f
+		}
+	}
+	boolean := func(name string, v *bool) {
+		if e, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(e)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+				return
+			}
+			*v = b
+		}
+	}
+
+	str("FACTOMD_LOC", &c.Factomd.Host)
+	str("PG_HOST", &c.Postgres.Host)
+
+	str(EnvPrefix+"FACTOMD_HOST", &c.Factomd.Host)
+	num(EnvPrefix+"FACTOMD_PORT", &c.Factomd.Port)
+	str(EnvPrefix+"POSTGRES_HOST", &c.Postgres.Host)
+	num(EnvPrefix+"POSTGRES_PORT", &c.Postgres.Port)
+	str(EnvPrefix+"POSTGRES_USER", &c.Postgres.User)
+	str(EnvPrefix+"POSTGRES_PASSWORD", &c.Postgres.Password)
+	str(EnvPrefix+"POSTGRES_SCHEMA", &c.Postgres.Schema)
+	num(EnvPrefix+"POSTGRES_MAX_OPEN_CONNS", &c.Postgres.MaxOpenConns)
+	num(EnvPrefix+"POSTGRES_MAX_IDLE_CONNS", &c.Postgres.MaxIdleConns)
+	str(EnvPrefix+"APISERVER_LISTEN", &c.APIServer.Listen)
+	num(EnvPrefix+"APISERVER_MAX_DEPTH", &c.APIServer.MaxDepth)
+	num(EnvPrefix+"APISERVER_MAX_COST", &c.APIServer.MaxCost)
+	num(EnvPrefix+"APISERVER_MAX_LIMIT", &c.APIServer.MaxLimit)
+	str(EnvPrefix+"APISERVER_REQUEST_TIMEOUT", &c.APIServer.RequestTimeout)
+	float(EnvPrefix+"APISERVER_RATE_LIMIT", &c.APIServer.RateLimit)
+	num(EnvPrefix+"APISERVER_RATE_BURST", &c.APIServer.RateBurst)
+	if e, ok := os.LookupEnv(EnvPrefix + "APISERVER_API_KEYS"); ok {
+		c.APIServer.APIKeys = strings.Split(e, ",")
+	}
+	str(EnvPrefix+"SCRAPER_LISTEN", &c.Scraper.Listen)
+	str(EnvPrefix+"SCRAPER_SIGNING_KEY", &c.Scraper.SigningKey)
+	boolean(EnvPrefix+"PROFILER_ENABLED", &c.Profiler.Enabled)
+	str(EnvPrefix+"LOG_LEVEL", &c.Log.Level)
+	num(EnvPrefix+"HEALTH_MAX_LAG", &c.Health.MaxLag)
+	if e, ok := os.LookupEnv(EnvPrefix + "COMPARE_ENDPOINTS"); ok {
+		c.Compare.Endpoints = strings.Split(e, ",")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("environment: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Validate returns every problem with the config, not just the first
+func (c *Config) Validate() error {
+	var errs []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	validPort := func(p int) bool { return p > 0 && p < 65536 }
+	validAddr := func(a string) bool {
+		_, port, err := net.SplitHostPort(a)
+		return err == nil && port != ""
+	}
+
+	check(c.Factomd.Host != "", "factomd.host is empty")
+	check(validPort(c.Factomd.Port), "factomd.port %d is not a valid port", c.Factomd.Port)
+	check(c.Postgres.Host != "", "postgres.host is empty")
+	check(validPort(c.Postgres.Port), "postgres.port %d is not a valid port", c.Postgres.Port)
+	check(c.Postgres.User != "", "postgres.user is empty")
+	check(c.Postgres.Password != "", "postgres.password is empty")
+	check(c.Postgres.Schema != "", "postgres.schema is empty")
+	check(c.Postgres.MaxOpenConns >= 0, "postgres.max_open_conns cannot be negative")
+	check(c.Postgres.MaxIdleConns >= 0, "postgres.max_idle_conns cannot be negative")
+	check(c.Postgres.MaxOpenConns == 0 || c.Postgres.MaxIdleConns <= c.Postgres.MaxOpenConns,
+		"postgres.max_idle_conns %d is more than max_open_conns %d", c.Postgres.MaxIdleConns, c.Postgres.MaxOpenConns)
+	check(validAddr(c.APIServer.Listen), "apiserver.listen %q is not host:port", c.APIServer.Listen)
+	check(c.APIServer.MaxDepth >= 0, "apiserver.max_depth cannot be negative")
+	check(c.APIServer.MaxCost >= 0, "apiserver.max_cost cannot be negative")
+	check(c.APIServer.MaxLimit >= 0, "apiserver.max_limit cannot be negative")
+	timeout, timeoutErr := time.ParseDuration(c.APIServer.RequestTimeout)
+	if c.APIServer.RequestTimeout == "" {
+		timeout, timeoutErr = 0, nil
+	}
+	check(timeoutErr == nil && timeout >= 0, "apiserver.request_timeout %q is not a duration (eg: 30s)", c.APIServer.RequestTimeout)
+	check(c.APIServer.RateLimit >= 0, "apiserver.rate_limit cannot be negative")
+	check(c.APIServer.RateBurst >= 0, "apiserver.rate_burst cannot be negative")
+	check(validAddr(c.Scraper.Listen), "scraper.listen %q is not host:port", c.Scraper.Listen)
+	_, _, lvlErr := parseLevel(c.Log.Level)
+	check(lvlErr == nil, "log.level %q is not one of 'debug', 'info', 'warn', 'error', or 'none'", c.Log.Level)
+	check(c.Health.MaxLag >= 0, "health.max_lag cannot be negative")
+	for i, e := range c.Compare.Endpoints {
+		u, err := url.Parse(e)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"compare.endpoints[%d] %q is not an http url", i, e)
+	}
+	for i, d := range c.Compare.Databases {
+		check(d.Host != "", "compare.databases[%d].host is empty", i)
+		check(validPort(d.Port), "compare.databases[%d].port %d is not a valid port", i, d.Port)
+		check(d.User != "", "compare.databases[%d].user is empty", i)
+		check(d.Schema != "", "compare.databases[%d].schema is empty", i)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the config that is safe to print
+func (c Config) Redacted() Config {
+	if c.Postgres.Password != "" {
+		c.Postgres.Password = "<redacted>"
+	}
+	if len(c.Compare.Databases) > 0 {
+		dbs := make([]PostgresConfig, len(c.Compare.Databases))
+		for i, d := range c.Compare.Databases {
+			if d.Password != "" {
+				d.Password = "<redacted>"
+			}
+			dbs[i] = d
+		}
+		c.Compare.Databases = dbs
+	}
+	if len(c.APIServer.APIKeys) > 0 {
+		c.APIServer.APIKeys = []string{fmt.Sprintf("<%d redacted>", len(c.APIServer.APIKeys))}
+	}
+	return c
+}
+
+// String prints the config with secrets redacted
+func (c Config) String() string {
+	data, _ := json.MarshalIndent(c.Redacted(), "", "  ")
+	return string(data)
+}
+
+// ApplyLogLevel sets the logrus level. 'none' discards all log output.
+func (c *Config) ApplyLogLevel() {
+	lvl, none, err := parseLevel(c.Log.Level)
+	if err != nil {
+		return
+	}
+	if none {
+		log.SetLevel(log.FatalLevel)
+		log.SetOutput(ioutil.Discard)
+		return
+	}
+	log.SetLevel(lvl)
+}
+
+func parseLevel(level string) (lvl log.Level, none bool, err error) {
+	switch strings.ToLower(level) {
+	case "warn", "warning":
+		return log.WarnLevel, false, nil
+	case "debug":
+		return log.DebugLevel, false, nil
+	case "info":
+		return log.InfoLevel, false, nil
+	case "error":
+		return log.ErrorLevel, false, nil
+	case "none":
+		return log.FatalLevel, true, nil
+	}
+	return 0, false, fmt.Errorf("unknown log level %s", level)
+}
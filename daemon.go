@@ -1 +1,120 @@
 package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/config"
+	"github.com/Emyrk/go-factom-vote/health"
+	"github.com/Emyrk/go-factom-vote/notify"
+	"github.com/Emyrk/go-factom-vote/scraper"
+	"github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/database"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// runDaemon runs the scraper and the graphql api in one process. They share a
+// database pool, and the api is told about every block the scraper applies.
+// Everything is served on apiserver.listen.
+//
+//	go-factom-vote daemon -config=factom-vote.toml
+func runDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	cfg := config.Default()
+	if err := cfg.Load(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	cfg.ApplyLogLevel()
+	log.Infof("Configuration:\n%s", cfg)
+
+	db, err := database.InitDb(cfg.Postgres.SqlConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := scraper.NewScraperWithDB(cfg.Factomd.Host, cfg.Factomd.Port, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if s.Signer, err = cfg.Scraper.Signer(); err != nil {
+		log.Fatal(err)
+	}
+	if s.Signer != nil {
+		log.Infof("Signing results with %s", s.Signer.PublicKey())
+	}
+
+	bus := notify.NewBus()
+	s.SetNotifier(bus)
+
+	srv := apiserver.NewGraphQLServerWithDB(db, cfg.Factomd.Host, cfg.Factomd.Port)
+	srv.Events = bus
+	srv.Limits = cfg.APIServer.Limits()
+	srv.Blocks = s.Factom
+	h, err := srv.Handler()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scraper.RegisterPrometheus()
+	apiserver.RegisterPrometheus()
+
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", h)
+	mux.Handle(apiserver.RESTPrefix, srv.RESTHandler())
+	mux.Handle("/metrics", promhttp.Handler())
+	health.NewChecker(db, s.ChainHead, cfg.Health.MaxLag).Register(mux)
+	if cfg.Profiler.Enabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		srv.Follow(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Catchup(ctx)
+	}()
+
+	server := &http.Server{Addr: cfg.APIServer.Listen, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("daemon http server stopped: %v", err)
+		}
+	}()
+	log.Infof("Running daemon on %s", cfg.APIServer.Listen)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigs
+	log.Infof("Received %s, finishing current block and draining requests", sig)
+
+	sctx, scancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer scancel()
+	if err := server.Shutdown(sctx); err != nil {
+		log.Errorf("shutdown: %v", err)
+	}
+
+	cancel()
+	wg.Wait()
+	db.Close()
+	log.Info("Daemon stopped")
+}
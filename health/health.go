@@ -0,0 +1,148 @@
+// Package health serves the /healthz and /readyz endpoints shared by the
+// scraper and api server daemons.
+//
+// /healthz reports whether the daemon can reach its dependencies (postgres and
+// factomd). /readyz additionally requires the database to be within MaxLag
+// blocks of the chain head, so a daemon still catching up is alive but not ready.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/vote/database"
+)
+
+// DefaultMaxLag is the number of blocks the database can trail the chain head
+// and still be considered ready
+const DefaultMaxLag = 10
+
+// checkTimeout bounds how long a single probe can take
+const checkTimeout = 5 * time.Second
+
+// Checker probes the dependencies of a daemon
+type Checker struct {
+	DB *database.SQLDatabase
+	// ChainHead returns the height of the directory block head from factomd
+	ChainHead func(ctx context.Context) (int, error)
+	// MaxLag is the sync lag, in blocks, past which the daemon is not ready
+	MaxLag int
+}
+
+func NewChecker(db *database.SQLDatabase, chainHead func(ctx context.Context) (int, error), maxLag int) *Checker {
+	c := new(Checker)
+	c.DB = db
+	c.ChainHead = chainHead
+	c.MaxLag = maxLag
+	return c
+}
+
+// Dependency is the result of probing a single dependency
+type Dependency struct {
+	OK      bool   `json:"ok"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status is the json body returned by both endpoints
+type Status struct {
+	Status   string     `json:"status"`
+	Database Dependency `json:"database"`
+	Factomd  Dependency `json:"factomd"`
+
+	SyncedHeight  int        `json:"syncedHeight"`
+	ChainHead     int        `json:"chainHead"`
+	Lag           int        `json:"lag"`
+	MaxLag        int        `json:"maxLag"`
+	LastBlockTime *time.Time `json:"lastBlockTime"`
+}
+
+// Healthy is true if all dependencies are reachable
+func (s *Status) Healthy() bool {
+	return s.Database.OK && s.Factomd.OK
+}
+
+// Ready is true if the daemon is healthy and synced to within MaxLag blocks
+func (s *Status) Ready() bool {
+	return s.Healthy() && s.Lag <= s.MaxLag
+}
+
+// Check probes the database and factomd
+func (c *Checker) Check(ctx context.Context) *Status {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	s := new(Status)
+	s.MaxLag = c.MaxLag
+	s.SyncedHeight = -1
+	s.ChainHead = -1
+
+	s.Database = probe(func() error {
+		if err := c.DB.PingContext(ctx); err != nil {
+			return err
+		}
+		height, at, err := c.DB.FetchLastCompleted(ctx)
+		if err != nil {
+			return err
+		}
+		s.SyncedHeight = height
+		if height >= 0 {
+			s.LastBlockTime = &at
+		}
+		return nil
+	})
+
+	s.Factomd = probe(func() error {
+		head, err := c.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+		s.ChainHead = head
+		return nil
+	})
+
+	if s.Healthy() {
+		s.Lag = s.ChainHead - s.SyncedHeight
+		if s.Lag < 0 {
+			s.Lag = 0
+		}
+	}
+
+	if s.Ready() {
+		s.Status = "ok"
+	} else if s.Healthy() {
+		s.Status = "syncing"
+	} else {
+		s.Status = "unavailable"
+	}
+	return s
+}
+
+func probe(f func() error) Dependency {
+	start := time.Now()
+	err := f()
+	d := Dependency{OK: err == nil, Latency: time.Since(start).String()}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	return d
+}
+
+// Register adds /healthz and /readyz to the mux
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.serve(func(s *Status) bool { return s.Healthy() }))
+	mux.HandleFunc("/readyz", c.serve(func(s *Status) bool { return s.Ready() }))
+}
+
+func (c *Checker) serve(pass func(s *Status) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := c.Check(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !pass(s) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(s)
+	}
+}
@@ -1,64 +1,86 @@
 package main
 
 import (
+	"context"
 	"flag"
 	_ "flag"
 	"fmt"
+	"io"
 
+	"github.com/FactomProject/factom"
+	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
 
-	"io/ioutil"
-	"strings"
+	"os"
 
+	"github.com/Emyrk/go-factom-vote/config"
 	"github.com/Emyrk/go-factom-vote/vote"
-	"github.com/Emyrk/go-factom-vote/vote/common"
-	log "github.com/sirupsen/logrus"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "daemon":
+			runDaemon(os.Args[2:])
+			return
+		case "verify-attestation":
+			os.Exit(runVerifyAttestation(os.Args[2:]))
+		case "verify-receipt":
+			os.Exit(runVerifyReceipt(os.Args[2:]))
+		case "compare":
+			os.Exit(runCompare(os.Args[2:]))
+		case "export-vote":
+			os.Exit(runExportVote(os.Args[2:]))
+		case "verify-bundle":
+			os.Exit(runVerifyBundle(os.Args[2:]))
+		}
+	}
+
 	var (
-		all     = flag.Bool("all", false, "Parse for all identities")
+		all     = flag.Bool("all", false, "Recount every vote in the registration chain")
 		rootHex = flag.String("v", "", "Vote Chain in hex")
-		factomd = flag.String("s", "localhost:8088", "Factomd api location")
 		pretty  = flag.Bool("p", false, "Make the printout pretty for us mere humans")
-		loglvl  = flag.String("l", "none", "Set log level to 'debug', 'info', 'warn', 'error', or 'none'")
+		format  = flag.String("format", "json", "Print the recount as json, table or markdown")
 	)
 
-	flag.Parse()
+	cfg := config.Default()
+	cfg.Log.Level = "none"
+	if err := cfg.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Println(err)
+		return
+	}
+	cfg.ApplyLogLevel()
 
-	switch strings.ToLower(*loglvl) {
-	case "warn", "warning":
-		log.SetLevel(log.WarnLevel)
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "error":
-		log.SetLevel(log.ErrorLevel)
-	case "none":
-		log.SetLevel(log.FatalLevel)
-		log.SetOutput(ioutil.Discard)
+	var write func(io.Writer, []recountedVote) error
+	switch *format {
+	case "json":
+		write = func(w io.Writer, votes []recountedVote) error { return writeJSON(w, votes, *pretty) }
+	case "table":
+		write = writeTable
+	case "markdown":
+		write = writeMarkdown
+	default:
+		fmt.Printf("unknown format %q, exp json, table or markdown\n", *format)
+		os.Exit(2)
 	}
 
-	var data []byte
-	c := vote.NewAPIController(*factomd)
+	// Votes are read from factomd and recounted in memory, with no database
+	factom.SetFactomdServer(cfg.Factomd.Location())
+	c := vote.NewAPIController(cfg.Factomd.Location())
 	if !c.IsWorking() {
 		fmt.Println("Factomd location is not working")
-		return
+		os.Exit(1)
 	}
 
+	ctx := context.Background()
+	var votechains []interfaces.IHash
 	if *all {
-		//ids, err := parseAll(c)
-		//if err != nil {
-		//	fmt.Println(err)
-		//	return
-		//}
-		//
-		//data, err = json.Marshal(ids)
-		//if err != nil {
-		//	fmt.Println(err)
-		//	return
-		//}
+		var err error
+		votechains, err = c.FindRegisteredVotes(ctx)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	} else {
 		// Single
 		if *rootHex == "" {
@@ -66,41 +88,27 @@ func main() {
 			return
 		}
 
-		v, err := parseSingle(*rootHex, c)
+		votechain, err := primitives.HexToHash(*rootHex)
 		if err != nil {
-			fmt.Println(err)
-			return
+			fmt.Printf("parsing vote chain id: %s\n", err.Error())
+			os.Exit(2)
 		}
-
-		fmt.Println(v)
+		votechains = append(votechains, votechain)
 	}
 
-	var _ = pretty
-	var _ = data
-	//if *pretty {
-	//	var dst bytes.Buffer
-	//	err := json.Indent(&dst, data, "", "\t")
-	//	if err != nil {
-	//		fmt.Println(err)
-	//		return
-	//	}
-	//	fmt.Println(string(dst.Bytes()))
-	//} else {
-	//	fmt.Println(string(data))
-	//}
-}
-
-func parseSingle(votechainHex string, c *vote.Controller) (*common.Vote, error) {
-	votechain, err := primitives.HexToHash(votechainHex)
-	if err != nil {
-		return nil, fmt.Errorf("parsing vote chain id: %s", err.Error())
+	var votes []recountedVote
+	failed := false
+	for _, votechain := range votechains {
+		rv := recountVote(ctx, c, votechain)
+		failed = failed || rv.Error != ""
+		votes = append(votes, rv)
+	}
+	if err := write(os.Stdout, votes); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// A single vote that could not be recounted fails
+	if failed && !*all {
+		os.Exit(1)
 	}
-
-	vote, err := c.FindVote(votechain)
-	return vote, err
 }
-
-//func parseAll(c *factom_identity.Controller) (map[string]*identity.Identity, error) {
-//	ids, err := c.FindAllIdentities()
-//	return ids, err
-//}
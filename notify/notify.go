@@ -0,0 +1,93 @@
+// Package notify carries the changes the scraper makes as it applies blocks to
+// the api server, so it can push them to clients without polling the database.
+// In daemon mode the Bus is shared in-process. When the scraper and api server
+// are separate, events go through a postgres LISTEN/NOTIFY channel (postgres.go).
+package notify
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var notifylog = log.WithFields(log.Fields{"file": "notify.go"})
+
+const (
+	// BlockApplied is published once a directory block is fully in the database
+	BlockApplied = "block-applied"
+	// VoteUpdated is published when a proposal is added, registered, or has its results computed
+	VoteUpdated = "vote-updated"
+	NewCommit   = "new-commit"
+	NewReveal   = "new-reveal"
+	// PhaseChanged is published when the applied block moves a vote into its
+	// commit, reveal or complete phase
+	PhaseChanged = "phase-changed"
+)
+
+// Phases a vote can move into
+const (
+	PhaseCommit   = "commit"
+	PhaseReveal   = "reveal"
+	PhaseComplete = "complete"
+)
+
+type Event struct {
+	Kind   string `json:"kind"`
+	Height int    `json:"height"`
+	// Chain is the vote chain the event is about, if any
+	Chain   string `json:"chain,omitempty"`
+	VoterID string `json:"voterId,omitempty"`
+	Phase   string `json:"phase,omitempty"`
+}
+
+// Notifier is anything changes can be published to
+type Notifier interface {
+	Publish(e Event)
+}
+
+// Bus fans out published events to every subscriber. Publishing never blocks,
+// a subscriber that falls behind misses events rather than stalling the scraper.
+type Bus struct {
+	sync.RWMutex
+	subs map[int]chan Event
+	next int
+}
+
+func NewBus() *Bus {
+	b := new(Bus)
+	b.subs = make(map[int]chan Event)
+	return b
+}
+
+func (b *Bus) Publish(e Event) {
+	b.RLock()
+	defer b.RUnlock()
+	for id, c := range b.subs {
+		select {
+		case c <- e:
+		default:
+			notifylog.WithFields(log.Fields{"subscriber": id, "kind": e.Kind}).Warn("subscriber is full, dropping event")
+		}
+	}
+}
+
+// Subscribe returns a channel of all events published from now on, and a
+// function to unsubscribe that closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	b.Lock()
+	defer b.Unlock()
+	id := b.next
+	b.next++
+	c := make(chan Event, buffer)
+	b.subs[id] = c
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.Lock()
+			delete(b.subs, id)
+			b.Unlock()
+			close(c)
+		})
+	}
+}
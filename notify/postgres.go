@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+// Channel is the postgres NOTIFY channel events are sent on
+const Channel = "factom_vote_events"
+
+// PGNotifier publishes events to every api server listening on Channel
+type PGNotifier struct {
+	DB *sql.DB
+}
+
+func NewPGNotifier(db *sql.DB) *PGNotifier {
+	n := new(PGNotifier)
+	n.DB = db
+	return n
+}
+
+// Publish sends the event with pg_notify. Events are best effort, a failure is
+// logged and does not stop the scraper.
+func (n *PGNotifier) Publish(e Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		notifylog.WithField("func", "Publish").Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = n.DB.ExecContext(ctx, `SELECT pg_notify($1, $2)`, Channel, string(data))
+	if err != nil {
+		notifylog.WithFields(log.Fields{"func": "Publish", "kind": e.Kind}).Error(err)
+	}
+}
+
+// ListenPG republishes every event sent on Channel to n, until ctx is done.
+// The listener reconnects on its own if the connection to postgres drops.
+func ListenPG(ctx context.Context, connStr string, n Notifier) error {
+	flog := notifylog.WithField("func", "ListenPG")
+	l := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			flog.Error(err)
+		}
+	})
+	defer l.Close()
+
+	if err := l.Listen(Channel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case pn := <-l.Notify:
+			// A nil notification means the connection was re-established,
+			// and events sent while it was down are lost.
+			if pn == nil {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(pn.Extra), &e); err != nil {
+				flog.Error(err)
+				continue
+			}
+			n.Publish(e)
+		case <-time.After(90 * time.Second):
+			go l.Ping()
+		}
+	}
+}
@@ -2,10 +2,15 @@ create table completed
 (
   block_height integer not null
     constraint cmpleted_pkey
-    primary key
+    primary key,
+  completed_at timestamp with time zone default now() not null,
+  block_timestamp timestamp with time zone
 )
 ;
 
+comment on column completed.block_timestamp is 'Timestamp of the directory block'
+;
+
 create table proposals
 (
   vote_initiator char(64),
@@ -36,10 +41,18 @@ create table proposals
   vote_accept_criteria varchar,
   vote_winner_criteria varchar,
   complete boolean default false,
-  protocol_version integer default 0
+  protocol_version integer default 0,
+  search_vector tsvector
 )
 ;
 
+create index proposals_search_vector_index
+  on proposals using gin (search_vector)
+;
+
+comment on column proposals.search_vector is 'Maintained by proposals_search_vector_update'
+;
+
 comment on column proposals.vote_accept_criteria is 'Raw JSON'
 ;
 
@@ -108,7 +121,7 @@ create table eligible_voters
 (
   voter_id char(64) not null,
   eligible_list char(64) not null,
-  weight double precision,
+  weight numeric,
   entry_hash char(64) not null,
   block_height integer,
   signing_keys varchar,
@@ -164,18 +177,21 @@ create table results
     constraint results_pkey
     primary key,
   valid_vote boolean,
-  complete_count double precision,
-  complete_weight double precision,
-  voted_count double precision,
-  voted_weight integer,
-  abstained_count double precision,
-  abstained_weight double precision,
-  turnout_unweighted double precision,
-  turnout_weighted double precision,
-  support_unweighted double precision,
-  support_weighted double precision,
+  complete_count numeric,
+  complete_weight numeric,
+  voted_count numeric,
+  voted_weight numeric,
+  abstained_count numeric,
+  abstained_weight numeric,
+  turnout_unweighted numeric,
+  turnout_weighted numeric,
+  support_unweighted numeric,
+  support_weighted numeric,
   option_stats varchar,
-  winner_stats varchar
+  winner_stats varchar,
+  ballots varchar,
+  algorithm_version integer,
+  input_hash char(64)
 )
 ;
 
@@ -186,6 +202,59 @@ create unique index results_vote_chain_uindex
 comment on table results is 'result of vote when complete (passed reveal phase)'
 ;
 
+comment on column results.algorithm_version is 'Version of the tally the result was computed with, null before versioning'
+;
+
+comment on column results.input_hash is 'sha256 of the vote config, eligible voters and reveals the result was computed from'
+;
+
+create table results_history
+(
+  id serial not null
+    constraint results_history_pkey
+    primary key,
+  vote_chain char(64) not null,
+  recomputed_at timestamp with time zone default now() not null,
+  previous_version integer,
+  algorithm_version integer,
+  previous_input_hash char(64),
+  input_hash char(64),
+  differences varchar,
+  previous_result varchar,
+  result varchar
+)
+;
+
+create index results_history_vote_chain_index
+  on results_history (vote_chain)
+;
+
+comment on table results_history is 'results replaced when recomputed, and what changed'
+;
+
+create table result_attestations
+(
+  id serial not null
+    constraint result_attestations_pkey
+    primary key,
+  vote_chain char(64) not null,
+  signed_at timestamp with time zone default now() not null,
+  dblock_height integer not null,
+  dblock_keymr char(64) not null,
+  engine_version varchar not null,
+  result varchar not null,
+  public_key char(64) not null,
+  signature char(128) not null
+)
+;
+
+create index result_attestations_vote_chain_index
+  on result_attestations (vote_chain)
+;
+
+comment on table result_attestations is 'signatures of the scraper over the results it computed'
+;
+
 create function insert_commit(param_voter_id character, param_signing_key character, param_signature character varying, param_commitment character varying, param_vote_chain character, param_entry_hash character, param_block_height integer) returns integer
 language plpgsql
 as $$
@@ -331,7 +400,7 @@ END;
 $$
 ;
 
-create function insert_eligible_voter(param_voter_id character, param_eligible_list character, param_weight double precision, param_entry_hash character, param_block_height integer, param_signing_keys character varying) returns integer
+create function insert_eligible_voter(param_voter_id character, param_eligible_list character, param_weight numeric, param_entry_hash character, param_block_height integer, param_signing_keys character varying) returns integer
 language plpgsql
 as $$
 BEGIN
@@ -438,7 +507,26 @@ END;
 $$
 ;
 
-create function fetch_eligible_voters(param_eligible_list character, param_block_height integer) returns TABLE(voter_id character, eligible_list character, weight double precision, entry_hash character, block_height integer, signing_keys character varying, full_count bigint)
+create function proposals_search_vector_update() returns trigger
+language plpgsql
+as $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('english', coalesce(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(NEW.description, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(NEW.external_href, '')), 'C');
+  RETURN NEW;
+END;
+$$
+;
+
+create trigger proposals_search_vector_trigger
+  before insert or update of title, description, external_href
+  on proposals
+  for each row execute procedure proposals_search_vector_update()
+;
+
+create function fetch_eligible_voters(param_eligible_list character, param_block_height integer) returns TABLE(voter_id character, eligible_list character, weight numeric, entry_hash character, block_height integer, signing_keys character varying, full_count bigint)
 language plpgsql
 as $$
 BEGIN
@@ -453,7 +541,7 @@ END;
 $$
 ;
 
-create function fetch_proposal_entries(param_vote_chain character) returns TABLE(voter_id character, weight double precision, entry_hash character, commit character, reveal character)
+create function fetch_proposal_entries(param_vote_chain character) returns TABLE(voter_id character, weight numeric, entry_hash character, commit character, reveal character)
 language plpgsql
 as $$
 DECLARE
@@ -477,7 +565,7 @@ END;
 $$
 ;
 
-create function insert_results(param_vote_chain character, param_valid_vote boolean, param_complete_count double precision, param_complete_weight double precision, param_voted_count double precision, param_voted_weight double precision, param_abstained_count double precision, param_abstained_weight double precision, param_turnout_unweighted double precision, param_turnout_weighted double precision, param_support_unweighted double precision, param_support_weighted double precision, param_option_stats character varying, param_winner_stats character varying) returns integer
+create function insert_results(param_vote_chain character, param_valid_vote boolean, param_complete_count numeric, param_complete_weight numeric, param_voted_count numeric, param_voted_weight numeric, param_abstained_count numeric, param_abstained_weight numeric, param_turnout_unweighted numeric, param_turnout_weighted numeric, param_support_unweighted numeric, param_support_weighted numeric, param_option_stats character varying, param_winner_stats character varying, param_ballots character varying, param_algorithm_version integer, param_input_hash character) returns integer
 language plpgsql
 as $$
 DECLARE
@@ -503,7 +591,10 @@ BEGIN
                         support_unweighted,
                         support_weighted,
                         option_stats,
-                        winner_stats)
+                        winner_stats,
+                        ballots,
+                        algorithm_version,
+                        input_hash)
     VALUES(param_vote_chain,
       param_valid_vote,
       param_complete_count,
@@ -517,7 +608,10 @@ BEGIN
       param_support_unweighted,
            param_support_weighted,
            param_option_stats,
-           param_winner_stats);
+           param_winner_stats,
+           param_ballots,
+           param_algorithm_version,
+           param_input_hash);
 
     UPDATE proposals SET complete = True WHERE chain_id = param_vote_chain;
     RETURN 1;
@@ -5,7 +5,7 @@ CREATE OR REPLACE FUNCTION fetch_eligible_voters(
   RETURNS TABLE (
     voter_id CHAR(64),
     eligible_list CHAR(64),
-    weight DOUBLE PRECISION,
+    weight NUMERIC,
     entry_hash CHAR(64),
     block_height INTEGER,
     signing_keys VARCHAR,
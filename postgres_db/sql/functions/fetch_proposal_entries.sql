@@ -3,7 +3,7 @@ CREATE OR REPLACE FUNCTION fetch_proposal_entries(
 )
   RETURNS TABLE (
     voter_id CHAR(64),
-    weight DOUBLE PRECISION,
+    weight NUMERIC,
     entry_hash CHAR(64),
     commit CHAR(64),
     reveal CHAR(64)
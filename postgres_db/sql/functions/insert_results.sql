@@ -1,18 +1,21 @@
 CREATE OR REPLACE FUNCTION insert_results(
   param_vote_chain CHAR(64),
   param_valid_vote BOOLEAN,
-  param_complete_count FLOAT,
-  param_complete_weight FLOAT,
-  param_voted_count FLOAT,
-  param_voted_weight FLOAT,
-  param_abstained_count FLOAT,
-  param_abstained_weight FLOAT,
-  param_turnout_unweighted FLOAT,
-  param_turnout_weighted FLOAT,
-  param_support_unweighted FLOAT,
-  param_support_weighted FLOAT,
+  param_complete_count NUMERIC,
+  param_complete_weight NUMERIC,
+  param_voted_count NUMERIC,
+  param_voted_weight NUMERIC,
+  param_abstained_count NUMERIC,
+  param_abstained_weight NUMERIC,
+  param_turnout_unweighted NUMERIC,
+  param_turnout_weighted NUMERIC,
+  param_support_unweighted NUMERIC,
+  param_support_weighted NUMERIC,
   param_option_stats VARCHAR,
-  param_winner_stats VARCHAR)
+  param_winner_stats VARCHAR,
+  param_ballots VARCHAR,
+  param_algorithm_version INTEGER,
+  param_input_hash CHAR(64))
   RETURNS INTEGER AS $$
 DECLARE
 BEGIN
@@ -37,7 +40,10 @@ BEGIN
                         support_unweighted,
                         support_weighted,
                         option_stats,
-                        winner_stats)
+                        winner_stats,
+                        ballots,
+                        algorithm_version,
+                        input_hash)
     VALUES(param_vote_chain,
             param_valid_vote,
             param_complete_count,
@@ -51,7 +57,10 @@ BEGIN
             param_support_unweighted,
             param_support_weighted,
             param_option_stats,
-            param_winner_stats);
+            param_winner_stats,
+            param_ballots,
+            param_algorithm_version,
+            param_input_hash);
 
     UPDATE proposals SET complete = True WHERE chain_id = param_vote_chain;
     RETURN 1;
@@ -0,0 +1,13 @@
+-- Keeps proposals.search_vector in step with the searchable text. The title
+-- ranks above the description, which ranks above the external reference.
+CREATE OR REPLACE FUNCTION proposals_search_vector_update()
+  RETURNS TRIGGER
+AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('english', coalesce(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(NEW.description, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(NEW.external_href, '')), 'C');
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
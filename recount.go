@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Emyrk/go-factom-vote/vote"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// recountedVote is a vote as the cli prints it
+type recountedVote struct {
+	VoteChain   string `json:"voteChain"`
+	Title       string `json:"title,omitempty"`
+	BlockHeight int    `json:"blockHeight"`
+
+	Proposal       *common.ProposalEntry `json:"proposal,omitempty"`
+	EligibleVoters int                   `json:"eligibleVoters"`
+	Commits        int                   `json:"commits"`
+	Reveals        int                   `json:"reveals"`
+	Skipped        []common.SkippedEntry `json:"skipped,omitempty"`
+	Result         *common.VoteStats     `json:"result,omitempty"`
+
+	// Error is why the vote could not be recounted
+	Error string `json:"error,omitempty"`
+}
+
+// recountVote reads the vote from factomd and recounts it in memory
+func recountVote(ctx context.Context, c *vote.Controller, votechain interfaces.IHash) recountedVote {
+	rv := recountedVote{VoteChain: votechain.String()}
+	b, r, err := c.RecountVote(ctx, votechain)
+	if err != nil {
+		rv.Error = err.Error()
+		return rv
+	}
+
+	p := r.Vote.Proposal
+	rv.Title = p.Proposal.Title
+	rv.BlockHeight = b.BlockHeight
+	rv.Proposal = p
+	rv.EligibleVoters = len(r.EligibleVoters(p.Vote.PhasesBlockHeights.CommitStart))
+	rv.Commits = len(r.Commits())
+	rv.Reveals = len(r.Reveals())
+	rv.Skipped = r.Skipped
+
+	// There is nothing to count before the reveal phase
+	if b.BlockHeight < p.Vote.PhasesBlockHeights.RevealStart {
+		return rv
+	}
+	rv.Result, err = r.Result(b.BlockHeight)
+	if err != nil {
+		rv.Error = err.Error()
+	}
+	return rv
+}
+
+func writeJSON(w io.Writer, votes []recountedVote, pretty bool) error {
+	var data []byte
+	var err error
+	if pretty {
+		data, err = json.MarshalIndent(votes, "", "\t")
+	} else {
+		data, err = json.Marshal(votes)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// status is a short summary of the recount
+func (rv recountedVote) status() string {
+	switch {
+	case rv.Error != "":
+		return "error: " + rv.Error
+	case rv.Result == nil:
+		return "not revealed yet"
+	case rv.Result.Provisional:
+		return "provisional"
+	case rv.Result.Valid:
+		return "valid"
+	case rv.Result.InvalidReason != "":
+		return "invalid: " + rv.Result.InvalidReason
+	}
+	return "invalid"
+}
+
+// options are the option stats of the result in the order of the vote
+func (rv recountedVote) options() []common.VoteOptionStats {
+	if rv.Result == nil {
+		return nil
+	}
+	var names, others []string
+	if rv.Proposal != nil {
+		names = append(names, rv.Proposal.Vote.Config.Options...)
+	}
+	for name := range rv.Result.OptionStats {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	names = append(names, others...)
+
+	var options []common.VoteOptionStats
+	for _, name := range names {
+		if o, ok := rv.Result.OptionStats[name]; ok {
+			options = append(options, o)
+		}
+	}
+	return options
+}
+
+func (rv recountedVote) winners() string {
+	if rv.Result == nil {
+		return ""
+	}
+	var winners []string
+	for _, w := range rv.Result.WeightedWinners {
+		winners = append(winners, w.Option)
+	}
+	return strings.Join(winners, ", ")
+}
+
+func writeTable(w io.Writer, votes []recountedVote) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, rv := range votes {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "Vote\t%s\n", rv.VoteChain)
+		if rv.Title != "" {
+			fmt.Fprintf(tw, "Title\t%s\n", rv.Title)
+		}
+		fmt.Fprintf(tw, "Status\t%s\n", rv.status())
+		if rv.Error != "" {
+			continue
+		}
+		fmt.Fprintf(tw, "Height\t%d\n", rv.BlockHeight)
+		fmt.Fprintf(tw, "Eligible voters\t%d\n", rv.EligibleVoters)
+		fmt.Fprintf(tw, "Commits\t%d\n", rv.Commits)
+		fmt.Fprintf(tw, "Reveals\t%d\n", rv.Reveals)
+		fmt.Fprintf(tw, "Skipped entries\t%d\n", len(rv.Skipped))
+		if rv.Result == nil {
+			continue
+		}
+		fmt.Fprintf(tw, "Turnout\t%s (weighted %s)\n", rv.Result.Turnout.UnweightedTurnout, rv.Result.Turnout.WeightedTurnout)
+		fmt.Fprintf(tw, "Winners\t%s\n", rv.winners())
+		fmt.Fprintln(tw, "\nOption\tCount\tWeight")
+		for _, o := range rv.options() {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", o.Option, o.Count, o.Weight)
+		}
+	}
+	return tw.Flush()
+}
+
+func writeMarkdown(w io.Writer, votes []recountedVote) error {
+	for i, rv := range votes {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		title := rv.Title
+		if title == "" {
+			title = rv.VoteChain
+		}
+		fmt.Fprintf(w, "## %s\n\n", title)
+		fmt.Fprintf(w, "- Vote: `%s`\n", rv.VoteChain)
+		fmt.Fprintf(w, "- Status: %s\n", rv.status())
+		if rv.Error != "" {
+			continue
+		}
+		fmt.Fprintf(w, "- Height: %d\n", rv.BlockHeight)
+		fmt.Fprintf(w, "- Eligible voters: %d, commits: %d, reveals: %d, skipped entries: %d\n", rv.EligibleVoters, rv.Commits, rv.Reveals, len(rv.Skipped))
+		if rv.Result == nil {
+			continue
+		}
+		fmt.Fprintf(w, "- Turnout: %s (weighted %s)\n", rv.Result.Turnout.UnweightedTurnout, rv.Result.Turnout.WeightedTurnout)
+		fmt.Fprintf(w, "- Winners: %s\n\n", rv.winners())
+		fmt.Fprintln(w, "| Option | Count | Weight |")
+		fmt.Fprintln(w, "| --- | ---: | ---: |")
+		for _, o := range rv.options() {
+			fmt.Fprintf(w, "| %s | %s | %s |\n", strings.Replace(o.Option, "|", "\\|", -1), o.Count, o.Weight)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+func decimal(t *testing.T, s string) common.Decimal {
+	d, err := common.ParseDecimal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func optionStats(t *testing.T, name, count, weight string) common.VoteOptionStats {
+	var o common.VoteOptionStats
+	o.Option = name
+	o.Count = decimal(t, count)
+	o.Weight = decimal(t, weight)
+	return o
+}
+
+// recountedVotes are a vote in each state the cli prints
+func recountedVotes(t *testing.T) []recountedVote {
+	p := new(common.ProposalEntry)
+	p.Vote.Config.Options = []string{"yes", "no", "a|b"}
+
+	result := func() *common.VoteStats {
+		r := new(common.VoteStats)
+		r.Valid = true
+		r.Turnout.UnweightedTurnout = decimal(t, "0.5")
+		r.Turnout.WeightedTurnout = decimal(t, "0.75")
+		r.OptionStats = map[string]common.VoteOptionStats{
+			"no":  optionStats(t, "no", "1", "1"),
+			"yes": optionStats(t, "yes", "2", "2.5"),
+			"a|b": optionStats(t, "a|b", "0", "0"),
+			// Not an option of the proposal, so after those that are
+			"other": optionStats(t, "other", "0", "0"),
+		}
+		r.WeightedWinners = []common.VoteOptionStats{r.OptionStats["yes"]}
+		return r
+	}
+
+	valid := recountedVote{VoteChain: "chain1", Title: "Budget", BlockHeight: 200, Proposal: p,
+		EligibleVoters: 4, Commits: 3, Reveals: 3, Result: result(),
+		Skipped: []common.SkippedEntry{{EntryHash: "e1", BlockHeight: 150, Reason: "not eligible"}}}
+	provisional := recountedVote{VoteChain: "chain2", BlockHeight: 180, Proposal: p, EligibleVoters: 4, Commits: 3, Reveals: 1, Result: result()}
+	provisional.Result.Provisional = true
+	invalid := recountedVote{VoteChain: "chain3", Title: "Turnout", BlockHeight: 200, Proposal: p, Result: result()}
+	invalid.Result.Valid = false
+	invalid.Result.InvalidReason = "minimum turnout not met"
+	invalid.Result.WeightedWinners = nil
+	unrevealed := recountedVote{VoteChain: "chain4", Title: "Later", BlockHeight: 90, EligibleVoters: 4, Commits: 1}
+	failed := recountedVote{VoteChain: "chain5", Error: "no proposal entry"}
+
+	return []recountedVote{valid, provisional, invalid, unrevealed, failed}
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTable(&buf, recountedVotes(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{
+		"Vote chain1",
+		"Title Budget",
+		"Status valid",
+		"Height 200",
+		"Eligible voters 4",
+		"Commits 3",
+		"Reveals 3",
+		"Skipped entries 1",
+		"Turnout 0.5 (weighted 0.75)",
+		"Winners yes",
+		"",
+		"Option Count Weight",
+		"yes 2 2.5",
+		"no 1 1",
+		"a|b 0 0",
+		"other 0 0",
+		"",
+		"Vote chain2",
+		"Status provisional",
+		"Height 180",
+		"Eligible voters 4",
+		"Commits 3",
+		"Reveals 1",
+		"Skipped entries 0",
+		"Turnout 0.5 (weighted 0.75)",
+		"Winners yes",
+		"",
+		"Option Count Weight",
+		"yes 2 2.5",
+		"no 1 1",
+		"a|b 0 0",
+		"other 0 0",
+		"",
+		"Vote chain3",
+		"Title Turnout",
+		"Status invalid: minimum turnout not met",
+		"Height 200",
+		"Eligible voters 0",
+		"Commits 0",
+		"Reveals 0",
+		"Skipped entries 0",
+		"Turnout 0.5 (weighted 0.75)",
+		"Winners",
+		"",
+		"Option Count Weight",
+		"yes 2 2.5",
+		"no 1 1",
+		"a|b 0 0",
+		"other 0 0",
+		"",
+		"Vote chain4",
+		"Title Later",
+		"Status not revealed yet",
+		"Height 90",
+		"Eligible voters 4",
+		"Commits 1",
+		"Reveals 0",
+		"Skipped entries 0",
+		"",
+		"Vote chain5",
+		"Status error: no proposal entry",
+	}
+
+	// The columns are aligned with spaces, which are not compared
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.Join(strings.Fields(lines[i]), " ")
+	}
+	if strings.Join(lines, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("exp\n%s\ngot\n%s", strings.Join(exp, "\n"), buf.String())
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeMarkdown(&buf, recountedVotes(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	table := "| Option | Count | Weight |\n" +
+		"| --- | ---: | ---: |\n" +
+		"| yes | 2 | 2.5 |\n" +
+		"| no | 1 | 1 |\n" +
+		"| a\\|b | 0 | 0 |\n" +
+		"| other | 0 | 0 |\n"
+	exp := "## Budget\n\n" +
+		"- Vote: `chain1`\n" +
+		"- Status: valid\n" +
+		"- Height: 200\n" +
+		"- Eligible voters: 4, commits: 3, reveals: 3, skipped entries: 1\n" +
+		"- Turnout: 0.5 (weighted 0.75)\n" +
+		"- Winners: yes\n\n" +
+		table +
+		"\n## chain2\n\n" +
+		"- Vote: `chain2`\n" +
+		"- Status: provisional\n" +
+		"- Height: 180\n" +
+		"- Eligible voters: 4, commits: 3, reveals: 1, skipped entries: 0\n" +
+		"- Turnout: 0.5 (weighted 0.75)\n" +
+		"- Winners: yes\n\n" +
+		table +
+		"\n## Turnout\n\n" +
+		"- Vote: `chain3`\n" +
+		"- Status: invalid: minimum turnout not met\n" +
+		"- Height: 200\n" +
+		"- Eligible voters: 0, commits: 0, reveals: 0, skipped entries: 0\n" +
+		"- Turnout: 0.5 (weighted 0.75)\n" +
+		"- Winners: \n\n" +
+		table +
+		"\n## Later\n\n" +
+		"- Vote: `chain4`\n" +
+		"- Status: not revealed yet\n" +
+		"- Height: 90\n" +
+		"- Eligible voters: 4, commits: 1, reveals: 0, skipped entries: 0\n" +
+		"\n## chain5\n\n" +
+		"- Vote: `chain5`\n" +
+		"- Status: error: no proposal entry\n"
+	if buf.String() != exp {
+		t.Errorf("exp\n%s\ngot\n%s", exp, buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	votes := recountedVotes(t)
+	// The proposal is printed as its entry, which is not compared here
+	for i := range votes {
+		votes[i].Proposal = nil
+	}
+
+	for _, pretty := range []bool{true, false} {
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, votes, pretty); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Count(buf.String(), "\n")
+		if pretty && lines < 10 || !pretty && lines != 1 {
+			t.Errorf("pretty %t: got %d lines", pretty, lines)
+		}
+
+		var got []map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(votes) {
+			t.Fatalf("exp %d votes, got %d", len(votes), len(got))
+		}
+
+		valid := got[0]
+		if valid["voteChain"] != "chain1" || valid["title"] != "Budget" || valid["blockHeight"] != 200.0 || valid["reveals"] != 3.0 {
+			t.Errorf("exp the recount of the vote, got %v", valid)
+		}
+		result, _ := valid["result"].(map[string]interface{})
+		if result == nil || result["valid"] != true {
+			t.Errorf("exp the valid result, got %v", valid["result"])
+		}
+		if skipped, _ := valid["skipped"].([]interface{}); len(skipped) != 1 {
+			t.Errorf("exp the skipped entry, got %v", valid["skipped"])
+		}
+
+		if result, _ := got[1]["result"].(map[string]interface{}); result == nil || result["provisional"] != true {
+			t.Errorf("exp a provisional result, got %v", got[1]["result"])
+		}
+		if _, ok := got[3]["result"]; ok {
+			t.Errorf("exp no result before the reveal phase, got %v", got[3]["result"])
+		}
+		if got[4]["error"] != "no proposal entry" {
+			t.Errorf("exp the error, got %v", got[4])
+		}
+		if _, ok := got[4]["skipped"]; ok {
+			t.Errorf("exp no skipped entries in an error, got %v", got[4])
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package scraper
 
 import (
+	"context"
 	"encoding/hex"
+	"time"
 
 	"github.com/FactomProject/factom"
 	"github.com/FactomProject/factomd/common/adminBlock"
@@ -18,18 +20,20 @@ import (
 const level string = "level"
 const bolt string = "bolt"
 
+// Fetcher retrieves blocks and entries from factomd. Every call takes a context
+// so a shutdown can abandon a fetch that has not started yet.
 type Fetcher interface {
-	FetchDBlockHead() (interfaces.IDirectoryBlock, error)
+	FetchDBlockHead(ctx context.Context) (interfaces.IDirectoryBlock, error)
 	//FetchDBlock(hash interfaces.IHash) (interfaces.IDirectoryBlock, error)
-	FetchHeadIndexByChainID(chainID interfaces.IHash) (interfaces.IHash, error)
-	FetchEBlock(hash interfaces.IHash) (interfaces.IEntryBlock, error)
+	FetchHeadIndexByChainID(ctx context.Context, chainID interfaces.IHash) (interfaces.IHash, error)
+	FetchEBlock(ctx context.Context, hash interfaces.IHash) (interfaces.IEntryBlock, error)
 
-	FetchEntry(hash string) (interfaces.IEntry, error)
-	FetchDBlockByHeight(dBlockHeight uint32) (interfaces.IDirectoryBlock, error)
-	FetchABlockByHeight(blockHeight uint32) (interfaces.IAdminBlock, error)
-	FetchFBlockByHeight(blockHeight uint32) (interfaces.IFBlock, error)
-	FetchECBlockByHeight(blockHeight uint32) (interfaces.IEntryCreditBlock, error)
-	FetchECBlock(keymr interfaces.IHash) (interfaces.IEntryCreditBlock, error)
+	FetchEntry(ctx context.Context, hash string) (interfaces.IEntry, error)
+	FetchDBlockByHeight(ctx context.Context, dBlockHeight uint32) (interfaces.IDirectoryBlock, error)
+	FetchABlockByHeight(ctx context.Context, blockHeight uint32) (interfaces.IAdminBlock, error)
+	FetchFBlockByHeight(ctx context.Context, blockHeight uint32) (interfaces.IFBlock, error)
+	FetchECBlockByHeight(ctx context.Context, blockHeight uint32) (interfaces.IEntryCreditBlock, error)
+	FetchECBlock(ctx context.Context, keymr interfaces.IHash) (interfaces.IEntryCreditBlock, error)
 }
 
 var _ Fetcher = (*APIReader)(nil)
@@ -62,7 +66,11 @@ func NewAPIReader(loc string) *APIReader {
 	return a
 }
 
-func (a *APIReader) FetchEntry(hash string) (interfaces.IEntry, error) {
+func (a *APIReader) FetchEntry(ctx context.Context, hash string) (interfaces.IEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("entry", time.Now())
 	raw, err := factom.GetRaw(hash)
 	if err != nil {
 		return nil, err
@@ -70,7 +78,11 @@ func (a *APIReader) FetchEntry(hash string) (interfaces.IEntry, error) {
 	return rawBytesToEntry(raw)
 }
 
-func (a *APIReader) FetchEBlock(hash interfaces.IHash) (interfaces.IEntryBlock, error) {
+func (a *APIReader) FetchEBlock(ctx context.Context, hash interfaces.IHash) (interfaces.IEntryBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("eblock", time.Now())
 	raw, err := factom.GetRaw(hash.String())
 	if err != nil {
 		return nil, err
@@ -78,7 +90,11 @@ func (a *APIReader) FetchEBlock(hash interfaces.IHash) (interfaces.IEntryBlock,
 	return rawBytesToEblock(raw)
 }
 
-func (a *APIReader) FetchDBlockHead() (interfaces.IDirectoryBlock, error) {
+func (a *APIReader) FetchDBlockHead(ctx context.Context) (interfaces.IDirectoryBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("dblock_head", time.Now())
 	head, err := factom.GetDBlockHead()
 	if err != nil {
 		return nil, err
@@ -90,7 +106,11 @@ func (a *APIReader) FetchDBlockHead() (interfaces.IDirectoryBlock, error) {
 	return rawBytesToDblock(raw)
 }
 
-func (a *APIReader) FetchDBlockByHeight(height uint32) (interfaces.IDirectoryBlock, error) {
+func (a *APIReader) FetchDBlockByHeight(ctx context.Context, height uint32) (interfaces.IDirectoryBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("dblock", time.Now())
 	raw, err := factom.GetBlockByHeightRaw("d", int64(height))
 	if err != nil {
 		return nil, err
@@ -103,7 +123,11 @@ func (a *APIReader) FetchDBlockByHeight(height uint32) (interfaces.IDirectoryBlo
 	return rawBytesToDblock(data)
 }
 
-func (a *APIReader) FetchFBlockByHeight(height uint32) (interfaces.IFBlock, error) {
+func (a *APIReader) FetchFBlockByHeight(ctx context.Context, height uint32) (interfaces.IFBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("fblock", time.Now())
 	raw, err := factom.GetBlockByHeightRaw("f", int64(height))
 	if err != nil {
 		return nil, err
@@ -116,7 +140,11 @@ func (a *APIReader) FetchFBlockByHeight(height uint32) (interfaces.IFBlock, erro
 	return rawBytesToFblock(data)
 }
 
-func (a *APIReader) FetchABlockByHeight(height uint32) (interfaces.IAdminBlock, error) {
+func (a *APIReader) FetchABlockByHeight(ctx context.Context, height uint32) (interfaces.IAdminBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("ablock", time.Now())
 	raw, err := factom.GetBlockByHeightRaw("a", int64(height))
 	if err != nil {
 		return nil, err
@@ -129,7 +157,11 @@ func (a *APIReader) FetchABlockByHeight(height uint32) (interfaces.IAdminBlock,
 	return rawBytesToAblock(data)
 }
 
-func (a *APIReader) FetchECBlock(keymr interfaces.IHash) (interfaces.IEntryCreditBlock, error) {
+func (a *APIReader) FetchECBlock(ctx context.Context, keymr interfaces.IHash) (interfaces.IEntryCreditBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("ecblock", time.Now())
 	data, err := factom.GetRaw(keymr.String())
 	if err != nil {
 		return nil, err
@@ -138,7 +170,11 @@ func (a *APIReader) FetchECBlock(keymr interfaces.IHash) (interfaces.IEntryCredi
 	return rawBytesToECblock(data)
 }
 
-func (a *APIReader) FetchECBlockByHeight(height uint32) (interfaces.IEntryCreditBlock, error) {
+func (a *APIReader) FetchECBlockByHeight(ctx context.Context, height uint32) (interfaces.IEntryCreditBlock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("ecblock_by_height", time.Now())
 	raw, err := factom.GetBlockByHeightRaw("ec", int64(height))
 	if err != nil {
 		return nil, err
@@ -151,7 +187,11 @@ func (a *APIReader) FetchECBlockByHeight(height uint32) (interfaces.IEntryCredit
 	return rawBytesToECblock(data)
 }
 
-func (a *APIReader) FetchHeadIndexByChainID(chainID interfaces.IHash) (interfaces.IHash, error) {
+func (a *APIReader) FetchHeadIndexByChainID(ctx context.Context, chainID interfaces.IHash) (interfaces.IHash, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer observeFactomd("chain_head", time.Now())
 	resp, err := factom.GetChainHead(chainID.String())
 	if err != nil {
 		return nil, err
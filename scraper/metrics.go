@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/vote"
+	"github.com/Emyrk/go-factom-vote/vote/database"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	SyncedHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factom_vote_scraper_synced_height",
+		Help: "Highest directory block fully applied to the database",
+	})
+	ChainHead = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factom_vote_scraper_chain_head",
+		Help: "Height of the directory block head reported by factomd",
+	})
+	SyncLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factom_vote_scraper_lag_blocks",
+		Help: "Number of blocks the scraper is behind the chain head",
+	})
+	BlocksApplied = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factom_vote_scraper_blocks_applied_total",
+		Help: "Directory blocks applied to the database",
+	})
+	BlocksPerSecond = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factom_vote_scraper_blocks_per_second",
+		Help: "Sync speed, computed from the time taken to apply the last block",
+	})
+	FactomdCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "factom_vote_factomd_call_duration_seconds",
+		Help:    "Latency of factomd api calls, by call",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"call"})
+
+	registerOnce sync.Once
+)
+
+// RegisterPrometheus registers all metrics exported by the scraper daemon,
+// including the vote watcher and database metrics. It is safe to call more than once.
+func RegisterPrometheus() {
+	registerOnce.Do(func() {
+		prometheus.MustRegister(SyncedHeight)
+		prometheus.MustRegister(ChainHead)
+		prometheus.MustRegister(SyncLag)
+		prometheus.MustRegister(BlocksApplied)
+		prometheus.MustRegister(BlocksPerSecond)
+		prometheus.MustRegister(FactomdCallDuration)
+	})
+	vote.RegisterPrometheus()
+	database.RegisterPrometheus()
+}
+
+// observeFactomd is meant to be deferred at the top of a factomd call
+func observeFactomd(call string, start time.Time) {
+	FactomdCallDuration.WithLabelValues(call).Observe(time.Since(start).Seconds())
+}
+
+// setHeights updates the sync gauges
+func setHeights(synced, top uint32) {
+	SyncedHeight.Set(float64(synced))
+	ChainHead.Set(float64(top))
+	lag := float64(0)
+	if top > synced {
+		lag = float64(top - synced)
+	}
+	SyncLag.Set(lag)
+}
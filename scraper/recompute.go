@@ -0,0 +1,79 @@
+package scraper
+
+import (
+	"context"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// Recompute computes the results of every complete vote again with the
+// current tally, and replaces the stored results that differ, or were
+// computed by another version or from other inputs. Replaced results are kept
+// in the results history.
+func (s *Scraper) Recompute(ctx context.Context) error {
+	flog := scraperlog.WithFields(log.Fields{"func": "Recompute", "version": common.ResultsAlgorithmVersion})
+	flog.Info("Recompute started")
+
+	votes, err := s.Database.FetchResultVotes(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Results are signed as of the highest block synced
+	height := s.Database.FetchHighestDBInserted(ctx)
+	var keyMR string
+	if s.Signer != nil && height >= 0 {
+		dblock, err := s.Factom.FetchDBlockByHeight(ctx, uint32(height))
+		if err != nil {
+			return err
+		}
+		keyMR = dblock.GetKeyMR().String()
+	}
+
+	replaced, changed := 0, 0
+	for _, v := range votes {
+		if ctx.Err() != nil {
+			flog.Info("Recompute stopped")
+			return nil
+		}
+
+		chain := v.Proposal.ProposalChain.String()
+		vlog := flog.WithField("vote", chain)
+		previous, err := s.Database.FetchResults(ctx, chain)
+		if err != nil {
+			return err
+		}
+
+		results, err := s.tallyVote(ctx, v)
+		if _, ok := err.(tallyError); ok {
+			vlog.Error(err)
+		} else if err != nil {
+			return err
+		}
+		if results == nil {
+			continue
+		}
+
+		diffs := common.DiffResults(previous, results)
+		if len(diffs) == 0 && previous.AlgorithmVersion == results.AlgorithmVersion && previous.InputHash == results.InputHash {
+			continue
+		}
+
+		attestation, err := s.attest(results, height, keyMR)
+		if err != nil {
+			return err
+		}
+		if err := s.Database.ReplaceResults(ctx, previous, results, diffs, attestation); err != nil {
+			return err
+		}
+		replaced++
+		if len(diffs) > 0 {
+			changed++
+			vlog.WithFields(log.Fields{"previous": previous.AlgorithmVersion, "differences": diffs}).Warn("Results changed")
+		}
+	}
+
+	flog.WithFields(log.Fields{"votes": len(votes), "replaced": replaced, "changed": changed}).Info("Recompute finished")
+	return nil
+}
@@ -1,10 +1,12 @@
 package scraper
 
 import (
+	"context"
 	"fmt"
 
 	"time"
 
+	"github.com/Emyrk/go-factom-vote/notify"
 	"github.com/Emyrk/go-factom-vote/vote"
 	"github.com/Emyrk/go-factom-vote/vote/common"
 	"github.com/Emyrk/go-factom-vote/vote/database"
@@ -15,37 +17,56 @@ import (
 var scraperlog = log.WithFields(log.Fields{"file": "scraper.go"})
 
 type Scraper struct {
-	Factom          Fetcher
-	Database        *database.SQLDatabase
-	WalletdLocation string
+	Factom   Fetcher
+	Database *database.SQLDatabase
 
 	// IdentityControl
 	VoteControl *vote.VoteWatcher
+
+	// Notifier, if set, is told about every block applied. Use SetNotifier.
+	Notifier notify.Notifier
+
+	// Signer, if set, signs every result computed
+	Signer *common.ResultSigner
 }
 
 func NewScraper(host string, port int, config *database.SqlConfig) (*Scraper, error) {
-	flog := scraperlog.WithField("func", "NewScraper")
-
-	s := new(Scraper)
-	factomd := fmt.Sprintf("%s:%d", host, port)
-	s.Factom = NewAPIReader(factomd)
-	_, err := s.Factom.FetchDBlockHead()
+	var (
+		db  *database.SQLDatabase
+		err error
+	)
+	if config != nil {
+		db, err = database.InitDb(*config)
+	} else {
+		db, err = database.InitLocalDB()
+	}
 	if err != nil {
 		return nil, err
 	}
-	flog.Infof("Factomd location %s", factomd)
+	scraperlog.WithField("func", "NewScraper").Infof("Postgres database connected")
 
-	if config != nil {
-		s.Database, err = database.InitDb(*config)
-	} else {
-		s.Database, err = database.InitLocalDB()
+	s, err := NewScraperWithDB(host, port, db)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
+	return s, nil
+}
+
+// NewScraperWithDB creates a scraper that shares an existing database pool
+func NewScraperWithDB(host string, port int, db *database.SQLDatabase) (*Scraper, error) {
+	flog := scraperlog.WithField("func", "NewScraperWithDB")
+
+	s := new(Scraper)
+	factomd := fmt.Sprintf("%s:%d", host, port)
+	s.Factom = NewAPIReader(factomd)
+	_, err := s.Factom.FetchDBlockHead(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	flog.Infof("Postgres database connected")
+	flog.Infof("Factomd location %s", factomd)
 
-	s.WalletdLocation = "localhost:8089"
+	s.Database = db
 
 	s.VoteControl = vote.NewVoteWatcherWithDB(s.Database)
 	// TODO: Sync Vote Control
@@ -53,58 +74,111 @@ func NewScraper(host string, port int, config *database.SqlConfig) (*Scraper, er
 	return s, nil
 }
 
-var CurrentCatchup uint32
-var CurrentTop uint32
+// SetNotifier sets where the scraper and its vote watcher publish changes to
+func (s *Scraper) SetNotifier(n notify.Notifier) {
+	s.Notifier = n
+	s.VoteControl.Notifier = n
+}
 
-func (s *Scraper) Catchup() {
+// ChainHead returns the height of the directory block head from factomd. The
+// fetchers only check ctx before calling factomd, so if ctx is done first the
+// call is left to finish in the background.
+func (s *Scraper) ChainHead(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	type head struct {
+		height int
+		err    error
+	}
+	done := make(chan head, 1)
+	go func() {
+		dblock, err := s.Factom.FetchDBlockHead(ctx)
+		if err != nil {
+			done <- head{-1, err}
+			return
+		}
+		done <- head{int(dblock.GetDatabaseHeight()), nil}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case h := <-done:
+		return h.height, h.err
+	}
+}
+
+// Catchup syncs blocks from factomd into the database until ctx is cancelled.
+// Cancellation is only checked between blocks, so a block that has started
+// being applied is always finished before Catchup returns.
+func (s *Scraper) Catchup(ctx context.Context) {
 	flog := scraperlog.WithFields(log.Fields{"func": "CatchUp"})
 	flog.Info("Catchup started")
 	// Find the highest height completed
-	next := uint32(s.Database.FetchHighestDBInserted() + 1)
+	next := uint32(s.Database.FetchHighestDBInserted(ctx) + 1)
 
-	getNextTop := func() uint32 {
+	getNextTop := func() (uint32, bool) {
 		for {
-			topDblock, err := s.Factom.FetchDBlockHead()
+			topDblock, err := s.Factom.FetchDBlockHead(ctx)
 			if err != nil {
 				flog.Error(err)
-				time.Sleep(3 * time.Second)
+				if !wait(ctx, 3*time.Second) {
+					return 0, false
+				}
 				continue
 			}
-			return topDblock.GetDatabaseHeight()
+			return topDblock.GetDatabaseHeight(), true
 		}
 	}
 
 	start := time.Now()
-	top := getNextTop()
-	CurrentTop = top
+	top, ok := getNextTop()
+	if !ok {
+		flog.Info("Catchup stopped")
+		return
+	}
+	setHeights(next-1, top)
 	changes := 0
 
+	// The block being applied must not be interrupted by a shutdown, so
+	// everything inside the loop body runs on a context that is never cancelled.
+	bctx := context.Background()
+
 MainCatchupLoop:
 	for {
+		if ctx.Err() != nil {
+			flog.WithField("next", next).Info("Catchup stopped")
+			return
+		}
+
 		if next%10 == 0 {
 			flog.WithFields(log.Fields{"current": next, "top": top, "time": time.Since(start), "changes": changes}).Info("")
 		}
 		start = time.Now()
 		if next > top {
-			top = getNextTop()
+			if top, ok = getNextTop(); !ok {
+				continue
+			}
+			setHeights(next-1, top)
 			if next > top {
-				time.Sleep(30 * time.Second)
+				wait(ctx, 30*time.Second)
 				continue
 			}
 		}
-		CurrentCatchup = next
 
-		dblock, err := s.Factom.FetchDBlockByHeight(next)
+		dblock, err := s.Factom.FetchDBlockByHeight(bctx, next)
 		if err != nil {
-			errorAndWait(flog.WithField("fetch", "dblock"), err)
+			errorAndWait(ctx, flog.WithField("fetch", "dblock"), err)
 			continue
 		}
 
 		var eblocks []interfaces.IEntryBlock
 		for _, e := range dblock.GetEBlockDBEntries() {
-			eblock, err := s.Factom.FetchEBlock(e.GetKeyMR())
+			eblock, err := s.Factom.FetchEBlock(bctx, e.GetKeyMR())
 			if err != nil {
-				errorAndWait(flog.WithField("fetch", "eblock"), err)
+				errorAndWait(ctx, flog.WithField("fetch", "eblock"), err)
 				continue MainCatchupLoop
 			}
 			eblocks = append(eblocks, eblock)
@@ -124,12 +198,12 @@ MainCatchupLoop:
 					continue
 				}
 
-				entry, err := s.Factom.FetchEntry(ehash.String())
+				entry, err := s.Factom.FetchEntry(bctx, ehash.String())
 				if err != nil {
-					errorAndWait(hog.WithFields(log.Fields{"fetch": "entry", "hash": ehash.String()}), err)
+					errorAndWait(ctx, hog.WithFields(log.Fields{"fetch": "entry", "hash": ehash.String()}), err)
 					continue MainCatchupLoop
 				}
-				change, err := s.VoteControl.ProcessEntry(entry, height, t, true)
+				change, err := s.VoteControl.ProcessEntry(bctx, entry, height, t, true)
 				if err != nil {
 					hog.WithFields(log.Fields{"vote-parse": "entry", "hash": ehash.String()}).Error(err)
 					//continue MainCatchupLoop // TODO :Remove
@@ -140,67 +214,64 @@ MainCatchupLoop:
 			}
 		}
 
-		s.VoteControl.ProcessOldEntries()
+		s.VoteControl.ProcessOldEntries(bctx)
 
 		// Now we check if any votes are complete
-		err = s.computeResults(int(height))
+		err = s.computeResults(bctx, int(height), dblock.GetKeyMR().String())
 		if err != nil {
-			errorAndWait(hog.WithFields(log.Fields{"insert": "completed"}), err)
+			errorAndWait(ctx, hog.WithFields(log.Fields{"insert": "completed"}), err)
 			continue MainCatchupLoop
 		}
 
-		err = s.Database.InsertCompleted(int(next))
+		err = s.Database.InsertCompleted(bctx, int(next), dblock.GetHeader().GetTimestamp().GetTime())
 		if err != nil {
-			errorAndWait(hog.WithFields(log.Fields{"insert": "completed"}), err)
+			errorAndWait(ctx, hog.WithFields(log.Fields{"insert": "completed"}), err)
 			continue MainCatchupLoop
 		}
 		// End loop
+		s.publishBlock(bctx, int(next))
+		BlocksApplied.Inc()
+		BlocksPerSecond.Set(1 / time.Since(start).Seconds())
+		setHeights(next, top)
 		next++
 		changes = 0
 	}
 }
 
-func (s *Scraper) computeResults(dbheight int) error {
+// computeResults stores the results of the votes complete at the directory
+// block of the height and keymr
+func (s *Scraper) computeResults(ctx context.Context, dbheight int, keyMR string) error {
 	flog := scraperlog.WithFields(log.Fields{"func": "computeResults", "height": dbheight})
-	votes, err := s.Database.FetchCompleteVotes(dbheight)
+	votes, err := s.Database.FetchCompleteVotes(ctx, dbheight)
 	if err != nil {
 		return err
 	}
 
-	tx, err := s.Database.Begin()
+	tx, err := s.Database.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	for _, v := range votes {
-		// Grab voters, commits, and reveals
-		voters, err := s.Database.FetchEligibleVoters(v.Proposal.Vote.EligibleVotersChainID.String(), v.Proposal.Vote.PhasesBlockHeights.CommitStart)
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-
-		commits, err := s.Database.FetchCommits(v.Proposal.ProposalChain.String())
-		if err != nil {
-			tx.Rollback()
-			return err
-		}
-
-		var _ = commits
-
-		reveals, err := s.Database.FetchReveals(v.Proposal.ProposalChain.String())
-		if err != nil {
+		results, err := s.tallyVote(ctx, v)
+		if _, ok := err.(tallyError); ok {
+			flog.WithField("vote", v.Proposal.ProposalChain.String()).Error(err)
+		} else if err != nil {
 			tx.Rollback()
 			return err
 		}
 
-		results, err := common.ComputeResult(v, voters, reveals)
-		if err != nil {
-			flog.WithField("vote", v.Proposal.ProposalChain.String()).Error(err)
-		}
-
 		if results != nil {
-			err = s.Database.InsertGenericTX(results, tx)
+			err = s.Database.InsertGenericTX(ctx, results, tx)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			attestation, err := s.attest(results, dbheight, keyMR)
+			if err == nil && attestation != nil {
+				err = s.Database.InsertAttestation(ctx, attestation, tx)
+			}
 			if err != nil {
 				tx.Rollback()
 				return err
@@ -214,10 +285,84 @@ func (s *Scraper) computeResults(dbheight int) error {
 		return err
 	}
 
+	for _, v := range votes {
+		s.publish(notify.Event{Kind: notify.VoteUpdated, Height: dbheight, Chain: v.Proposal.ProposalChain.String()})
+	}
+
 	return nil
 }
 
-func errorAndWait(logger *log.Entry, err error) {
+// tallyError is an error computing the results, rather than fetching what
+// they are computed from. The results may still be set.
+type tallyError struct {
+	error
+}
+
+// tallyVote computes the results of the vote from its eligible voters, as of
+// the start of the commit phase, and its reveals
+func (s *Scraper) tallyVote(ctx context.Context, v *common.Vote) (*common.VoteStats, error) {
+	voters, err := s.Database.FetchEligibleVoters(ctx, v.Proposal.Vote.EligibleVotersChainID.String(), v.Proposal.Vote.PhasesBlockHeights.CommitStart)
+	if err != nil {
+		return nil, err
+	}
+
+	reveals, err := s.Database.FetchReveals(ctx, v.Proposal.ProposalChain.String())
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := common.ComputeResult(v, voters, reveals)
+	if err != nil {
+		return results, tallyError{err}
+	}
+	return results, nil
+}
+
+// attest signs the results, if the scraper has a signer
+func (s *Scraper) attest(results *common.VoteStats, dbheight int, keyMR string) (*common.ResultAttestation, error) {
+	if s.Signer == nil {
+		return nil, nil
+	}
+	return s.Signer.Sign(results, dbheight, keyMR)
+}
+
+// publishBlock tells the notifier about the phase changes at height, then that
+// the block has been applied
+func (s *Scraper) publishBlock(ctx context.Context, height int) {
+	if s.Notifier == nil {
+		return
+	}
+
+	changes, err := s.Database.FetchPhaseChanges(ctx, height)
+	if err != nil {
+		scraperlog.WithFields(log.Fields{"func": "publishBlock", "height": height}).Error(err)
+	}
+	for chain, phase := range changes {
+		s.publish(notify.Event{Kind: notify.PhaseChanged, Height: height, Chain: chain, Phase: phase})
+	}
+	s.publish(notify.Event{Kind: notify.BlockApplied, Height: height})
+}
+
+// publish tells the notifier, if there is one, about a change
+func (s *Scraper) publish(e notify.Event) {
+	if s.Notifier != nil {
+		s.Notifier.Publish(e)
+	}
+}
+
+func errorAndWait(ctx context.Context, logger *log.Entry, err error) {
 	logger.Error(err)
-	time.Sleep(2 * time.Second)
+	wait(ctx, 2*time.Second)
+}
+
+// wait sleeps for d, returning early with false if ctx is cancelled first
+func wait(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
 }
@@ -0,0 +1,41 @@
+package scraper_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/Emyrk/go-factom-vote/scraper"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// stuckFetcher is a factomd that does not answer the head until the test is
+// over, the rest of the interface is left nil
+type stuckFetcher struct {
+	Fetcher
+	stuck chan struct{}
+}
+
+func (f *stuckFetcher) FetchDBlockHead(ctx context.Context) (interfaces.IDirectoryBlock, error) {
+	<-f.stuck
+	return nil, context.Canceled
+}
+
+func TestChainHeadTimeout(t *testing.T) {
+	f := &stuckFetcher{stuck: make(chan struct{})}
+	defer close(f.stuck)
+	s := new(Scraper)
+	s.Factom = f
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.ChainHead(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("exp the deadline to be exceeded, got %v", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("exp to give up at the deadline, took %s", took)
+	}
+}
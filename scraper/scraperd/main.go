@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
-	"github.com/Emyrk/go-factom-vote/vote/database"
+	"github.com/Emyrk/go-factom-vote/config"
+	"github.com/Emyrk/go-factom-vote/health"
+	"github.com/Emyrk/go-factom-vote/notify"
 
 	"github.com/Emyrk/go-factom-vote/scraper"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
 type arrayFlags []string
 
-var version = "v1.0.0"
+var version = common.DaemonVersion
 
 func (i *arrayFlags) String() string {
 	return "my string representation"
@@ -25,36 +35,36 @@ func (i *arrayFlags) Set(value string) error {
 func main() {
 	enabledRoutines := arrayFlags{}
 
-	var (
-		factomdhost = flag.String("fhost", "localhost", "Factomd host")
-		factomdport = flag.Int("fport", 8088, "Factomd port")
-
-		postgreshost = flag.String("phost", "localhost", "Postgres host")
-		postgresport = flag.Int("pport", 5432, "Postgres port")
-	)
-
 	// For Debugging
 	flag.Var(&enabledRoutines, "routine", "Can modify which routines are run")
-	flag.Parse()
-
-	go StartProfiler(true)
-
-	config := new(database.SqlConfig)
-	if *postgreshost != "localhost" {
-		config.SqlConfigType = database.SQL_CON_CUSTOM
-		config.User = "postgres"
-		config.Pass = "password"
-		config.Host = *postgreshost
-		config.Port = *postgresport
-		config.Schema = database.SCHEMA_PUBLIC
-	} else {
-		config = nil
+
+	cfg := config.Default()
+	if err := cfg.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
+	cfg.ApplyLogLevel()
+	log.Infof("Configuration:\n%s", cfg)
 
-	s, err := scraper.NewScraper(*factomdhost, *factomdport, config)
+	scraper.RegisterPrometheus()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	sqlConfig := cfg.Postgres.SqlConfig()
+	s, err := scraper.NewScraper(cfg.Factomd.Host, cfg.Factomd.Port, &sqlConfig)
 	if err != nil {
 		panic(err)
 	}
+	if s.Signer, err = cfg.Scraper.Signer(); err != nil {
+		log.Fatal(err)
+	}
+	if s.Signer != nil {
+		log.Infof("Signing results with %s", s.Signer.PublicKey())
+	}
+	// Api servers in other processes are told about changes through postgres
+	s.SetNotifier(notify.NewPGNotifier(s.Database.DB))
+
+	health.NewChecker(s.Database, s.ChainHead, cfg.Health.MaxLag).Register(mux)
+	go StartProfiler(cfg.Scraper.Listen, mux, cfg.Profiler.Enabled)
 
 	log.Infof("Running Scraper %s", version)
 
@@ -62,20 +72,37 @@ func main() {
 		enabledRoutines = []string{"catchup"}
 	}
 
-	// Does as goroutine if not last
-	do := func(f func(), i, l int) {
-		if i == l {
-			f()
-		} else {
-			go f()
-		}
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigs
+		log.Infof("Received %s, finishing current block before exiting", sig)
+		cancel()
+	}()
 
 	// Kinda hacky, but allows me to only run 1 routine if I want.
-	for i, r := range enabledRoutines {
+	var wg sync.WaitGroup
+	for _, r := range enabledRoutines {
 		switch r {
 		case "catchup":
-			do(s.Catchup, i, len(enabledRoutines)-1)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.Catchup(ctx)
+			}()
+		case "recompute":
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.Recompute(ctx); err != nil {
+					log.Error(err)
+				}
+			}()
 		}
 	}
+
+	wg.Wait()
+	s.Database.Close()
+	log.Info("Scraper stopped")
 }
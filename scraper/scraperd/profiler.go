@@ -1,22 +1,24 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	_ "net/http/pprof"
+	"net/http/pprof"
 )
 
-// StartProfiler runs the go pprof tool
+// StartProfiler serves the mux (metrics and health) on listen, and if profile
+// is set, the go pprof tool as well.
 // `go tool pprof http://localhost:6060/debug/pprof/profile`
 // https://golang.org/pkg/net/http/pprof/
-func StartProfiler(expose bool) {
-	_ = log.Print
+func StartProfiler(listen string, mux *http.ServeMux, profile bool) {
 	//runtime.MemProfileRate = mpr
-	pre := "localhost"
-	if expose {
-		pre = ""
+	if profile {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 	}
-	log.Println(http.ListenAndServe(fmt.Sprintf("%s:%d", pre, 6060), nil))
+	log.Println(http.ListenAndServe(listen, mux))
 	//runtime.SetBlockProfileRate(100000)
 }
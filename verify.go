@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+// runVerifyAttestation checks result attestations offline. The file is an
+// attestation, or a list of them as served by
+// /v1/votes/{chain}/results/attestations. They must be signed by -key, the
+// key of the scraper that is trusted, as any key can sign a valid attestation.
+//
+//	go-factom-vote verify-attestation -key=<hex public key> attestations.json
+func runVerifyAttestation(args []string) int {
+	fs := flag.NewFlagSet("verify-attestation", flag.ExitOnError)
+	key := fs.String("key", "", "Hex public key the attestations must be signed by, required")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "go-factom-vote verify-attestation -key=<hex public key> <file, or - for stdin>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *key == "" {
+		fmt.Println("-key is required, an attestation is only as good as the key it is signed by")
+		return 2
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	attestations, err := readAttestations(data)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	failed := 0
+	for _, a := range attestations {
+		if err := verifyAttestation(a, *key); err != nil {
+			fmt.Printf("INVALID vote %s: %s\n", a.VoteChain, err.Error())
+			failed++
+			continue
+		}
+		stats, _ := a.Stats()
+		var winners []string
+		for _, w := range stats.WeightedWinners {
+			winners = append(winners, w.Option)
+		}
+		fmt.Printf("VALID vote %s signed by %s\n", a.VoteChain, a.PublicKey)
+		fmt.Printf("\tat dblock %d %s, %s\n", a.DBlockHeight, a.DBlockKeyMR, a.EngineVersion)
+		fmt.Printf("\tvalid: %t, winners: %s\n", stats.Valid, strings.Join(winners, ", "))
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runVerifyReceipt checks a voter receipt offline, as served by
+// /v1/votes/{chain}/receipts/{voterId} or the graphql receipt query. The
+// receipt proves the entries are in the directory blocks it names, whose
+// KeyMRs should be compared to factomd or an explorer.
+//
+//	go-factom-vote verify-receipt receipt.json
+func runVerifyReceipt(args []string) int {
+	fs := flag.NewFlagSet("verify-receipt", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "go-factom-vote verify-receipt <file, or - for stdin>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// The graphql response is unwrapped
+	var wrapped struct {
+		Data struct {
+			Receipt json.RawMessage `json:"receipt"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(data, &wrapped) == nil && len(wrapped.Data.Receipt) > 0 {
+		data = wrapped.Data.Receipt
+	}
+	r := new(common.VoterReceipt)
+	if err := json.Unmarshal(data, r); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if err := r.Verify(); err != nil {
+		fmt.Printf("INVALID receipt of voter %s in vote %s: %s\n", r.VoterID, r.VoteChain, err.Error())
+		return 1
+	}
+	fmt.Printf("VALID receipt of voter %s in vote %s\n", r.VoterID, r.VoteChain)
+	for _, e := range []struct {
+		Name    string
+		Receipt *common.EntryReceipt
+	}{{"commit", r.Commit}, {"reveal", r.Reveal}} {
+		if e.Receipt == nil {
+			fmt.Printf("\t%s: none\n", e.Name)
+			continue
+		}
+		fmt.Printf("\t%s %s\n", e.Name, e.Receipt.EntryHash)
+		fmt.Printf("\t\tin eblock %s, dblock %d %s\n", e.Receipt.EBlockKeyMR, e.Receipt.DBlockHeight, e.Receipt.DBlockKeyMR)
+	}
+	fmt.Println("Check the dblock KeyMRs match those of factomd")
+	return 0
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(path)
+}
+
+func readAttestations(data []byte) ([]*common.ResultAttestation, error) {
+	var attestations []*common.ResultAttestation
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &attestations); err != nil {
+			return nil, err
+		}
+	} else {
+		a := new(common.ResultAttestation)
+		if err := json.Unmarshal(trimmed, a); err != nil {
+			return nil, err
+		}
+		attestations = append(attestations, a)
+	}
+	if len(attestations) == 0 {
+		return nil, fmt.Errorf("no attestations found")
+	}
+	return attestations, nil
+}
+
+func verifyAttestation(a *common.ResultAttestation, key string) error {
+	if !strings.EqualFold(key, a.PublicKey) {
+		return fmt.Errorf("signed by %s, not %s", a.PublicKey, key)
+	}
+	return a.Verify()
+}
@@ -1,67 +1,79 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/Emyrk/go-factom-vote/config"
+	"github.com/Emyrk/go-factom-vote/health"
+	"github.com/Emyrk/go-factom-vote/notify"
+	"github.com/Emyrk/go-factom-vote/scraper"
 	"github.com/Emyrk/go-factom-vote/vote/api-server"
-	"github.com/Emyrk/go-factom-vote/vote/database"
-	"github.com/graphql-go/handler"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 
-	var (
-		postgreshost = flag.String("phost", "localhost", "Postgres host")
-		postgresport = flag.Int("pport", 5432, "Postgres port")
-
-		factomdhost = flag.String("fhost", "localhost", "Factomd host")
-		factomdport = flag.Int("fport", 8088, "Factomd port")
-	)
-
-	flag.Parse()
-
-	config := new(database.SqlConfig)
-
-	config.SqlConfigType = database.SQL_CON_CUSTOM
-	config.User = "postgres"
-	config.Pass = "password"
-	config.Host = *postgreshost
-	config.Port = *postgresport
-	config.Schema = database.SCHEMA_PUBLIC
-	if *postgreshost != "localhost" {
-		config.SqlConfigType = database.SQL_CON_LOCAL
+	cfg := config.Default()
+	if err := cfg.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
 	}
+	cfg.ApplyLogLevel()
+	log.Printf("Configuration:\n%s", cfg)
 
-	srv, err := apiserver.NewGraphQLServer(*config, *factomdhost, *factomdport)
+	srv, err := apiserver.NewGraphQLServer(cfg.Postgres.SqlConfig(), cfg.Factomd.Host, cfg.Factomd.Port)
 	if err != nil {
 		panic(err)
 	}
+	srv.Limits = cfg.APIServer.Limits()
+	srv.Blocks = scraper.NewAPIReader(cfg.Factomd.Location())
 
-	schema, err := srv.CreateSchema()
+	// Subscriptions are fed by the scraper's notifications through postgres
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	srv.Events = notify.NewBus()
+	go func() {
+		sqlConfig := cfg.Postgres.SqlConfig()
+		if err := notify.ListenPG(listenCtx, sqlConfig.ConnectionString(), srv.Events); err != nil {
+			log.Printf("cannot listen for events, subscriptions will not receive updates: %v", err)
+		}
+	}()
+
+	h, err := srv.Handler()
 	if err != nil {
 		log.Fatalf("failed to create new schema, error: %v", err)
 	}
 
-	h := handler.New(&handler.Config{
-		Schema:     &schema,
-		Pretty:     true,
-		GraphiQL:   false,
-		Playground: true,
-	})
+	apiserver.RegisterPrometheus()
+	http.Handle("/graphql", h)
+	http.Handle(apiserver.RESTPrefix, srv.RESTHandler())
+	http.Handle("/metrics", promhttp.Handler())
+	health.NewChecker(srv.SQLDB.SQLDatabase, srv.ChainHead, cfg.Health.MaxLag).Register(http.DefaultServeMux)
 
-	http.Handle("/graphql", disableCors(h))
-	http.ListenAndServe(":8080", nil)
-}
+	server := &http.Server{Addr: cfg.APIServer.Listen}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("api server stopped: %v", err)
+		}
+	}()
 
-// disableCors from: https://github.com/graphql-go/graphql/issues/290
-func disableCors(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, Content-Length, Accept-Encoding")
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigs
+	log.Printf("Received %s, draining in-flight requests", sig)
 
-		h.ServeHTTP(w, r)
-	})
+	// In-flight graphql requests are given time to finish before the
+	// database connections are closed.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	stopListening()
+	srv.SQLDB.Close()
 }
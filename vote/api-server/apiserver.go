@@ -1,26 +1,157 @@
 package apiserver
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 
+	"github.com/Emyrk/go-factom-vote/notify"
 	"github.com/Emyrk/go-factom-vote/vote/database"
 	"github.com/FactomProject/factom"
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/handler"
 )
 
 type GraphQLServer struct {
 	SQLDB GraphQLSQLDB
+
+	// Events are the changes published by the scraper. Subscriptions are
+	// only available if set.
+	Events *notify.Bus
+
+	// Limits are applied to every request. Set before calling Handler.
+	Limits Limits
+
+	// Blocks are read from factomd to build receipts. Receipts are only
+	// available if set.
+	Blocks BlockFetcher
+
+	limiterOnce sync.Once
+	limiter     *rateLimiter
+
+	// syncedHeight is kept up to date by Follow when the scraper runs in the
+	// same process. It is -1 when not following, and the database is asked instead.
+	syncedHeight int64
 }
 
 func NewGraphQLServer(sqlConfig database.SqlConfig, factomHost string, factomPort int) (*GraphQLServer, error) {
-	s := new(GraphQLServer)
 	db, err := database.InitDb(sqlConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewGraphQLServerWithDB(db, factomHost, factomPort), nil
+}
+
+// NewGraphQLServerWithDB creates a server that shares an existing database pool
+func NewGraphQLServerWithDB(db *database.SQLDatabase, factomHost string, factomPort int) *GraphQLServer {
+	s := new(GraphQLServer)
 	s.SQLDB.SQLDatabase = db
+	s.syncedHeight = -1
+	s.Limits = DefaultLimits()
 
 	factom.SetFactomdServer(fmt.Sprintf("%s:%d", factomHost, factomPort))
 
-	return s, nil
+	return s
+}
+
+// Handler builds the graphql schema and returns the http handler serving it
+func (s *GraphQLServer) Handler() (http.Handler, error) {
+	schema, err := s.CreateSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:     &schema,
+		Pretty:     true,
+		GraphiQL:   false,
+		Playground: true,
+	})
+
+	// Subscriptions share the graphql path, as the websocket clients expect
+	return disableCors(s.limitRequests(schema, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			s.serveSubscriptions(w, r, schema)
+			return
+		}
+		s.withRequestLoaders(h).ServeHTTP(w, r)
+	}))), nil
+}
+
+// Follow keeps the synced height up to date from s.Events until ctx is done
+func (s *GraphQLServer) Follow(ctx context.Context) {
+	if s.Events == nil {
+		return
+	}
+	events, unsubscribe := s.Events.Subscribe(64)
+	defer unsubscribe()
+
+	// Seed from the database, as the scraper may be mid catchup
+	atomic.StoreInt64(&s.syncedHeight, int64(s.SQLDB.FetchHighestDBInserted(ctx)))
+	defer atomic.StoreInt64(&s.syncedHeight, -1)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if e.Kind == notify.BlockApplied {
+				atomic.StoreInt64(&s.syncedHeight, int64(e.Height))
+			}
+		}
+	}
+}
+
+// SyncedHeight is the highest block applied to the database
+func (s *GraphQLServer) SyncedHeight(ctx context.Context) int {
+	if h := atomic.LoadInt64(&s.syncedHeight); h >= 0 {
+		return int(h)
+	}
+	return s.SQLDB.FetchHighestDBInserted(ctx)
+}
+
+// ChainHead returns the height of the directory block head from factomd. The
+// factom client takes no context, so if ctx is done first the call is left to
+// finish in the background.
+func (s *GraphQLServer) ChainHead(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+
+	type head struct {
+		height int
+		err    error
+	}
+	done := make(chan head, 1)
+	go func() {
+		heights, err := factom.GetHeights()
+		if err != nil {
+			done <- head{-1, err}
+			return
+		}
+		done <- head{int(heights.DirectoryBlockHeight), nil}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	case h := <-done:
+		return h.height, h.err
+	}
+}
+
+// disableCors from: https://github.com/graphql-go/graphql/issues/290
+func disableCors(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, Content-Length, Accept-Encoding, X-API-Key")
+
+		h.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,36 @@
+package apiserver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/FactomProject/factom"
+)
+
+func TestChainHeadTimeout(t *testing.T) {
+	// A factomd that does not answer until the test is over
+	stuck := make(chan struct{})
+	factomd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stuck
+	}))
+	defer factomd.Close()
+	defer close(stuck)
+	factom.SetFactomdServer(strings.TrimPrefix(factomd.URL, "http://"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := new(GraphQLServer).ChainHead(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("exp the deadline to be exceeded, got %v", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("exp to give up at the deadline, took %s", took)
+	}
+}
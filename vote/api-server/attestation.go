@@ -0,0 +1,99 @@
+package apiserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// A scraper configured with a signing key signs every result it computes.
+// The attestations are served as signed, so they can be verified offline
+// with `go-factom-vote verify-attestation`.
+
+// ResultAttestation is a signature of the scraper over results it computed.
+// The fields are those of common.ResultAttestation, which reads the json.
+type ResultAttestation struct {
+	VoteChain     string    `json:"voteChain"`
+	SignedAt      time.Time `json:"signedAt"`
+	DBlockHeight  int       `json:"dblockHeight"`
+	DBlockKeyMR   string    `json:"dblockKeyMR"`
+	EngineVersion string    `json:"engineVersion"`
+	Result        string    `json:"result"`
+	PublicKey     string    `json:"publicKey"`
+	Signature     string    `json:"signature"`
+}
+
+var ResultAttestationGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ResultAttestation",
+	Description: "A signature of the scraper over results it computed, and the block it had synced to",
+	Fields: graphql.Fields{
+		"voteChain": &graphql.Field{
+			Type: graphql.String,
+		},
+		"signedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"dblockHeight": &graphql.Field{
+			Description: "Height of the directory block the scraper had synced to",
+			Type:        graphql.Int,
+		},
+		"dblockKeyMR": &graphql.Field{
+			Description: "KeyMR of the directory block the scraper had synced to",
+			Type:        graphql.String,
+		},
+		"engineVersion": &graphql.Field{
+			Description: "Version of the daemon and the tally",
+			Type:        graphql.String,
+		},
+		"result": &graphql.Field{
+			Description: "Canonical json of the results that were signed",
+			Type:        graphql.String,
+		},
+		"publicKey": &graphql.Field{
+			Description: "Hex ed25519 public key of the scraper",
+			Type:        graphql.String,
+		},
+		"signature": &graphql.Field{
+			Description: "Hex ed25519 signature",
+			Type:        graphql.String,
+		},
+	}})
+
+func (s *GraphQLServer) resultAttestations() *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(ResultAttestationGraphQLType),
+		Description: "Signatures of the scraper over the results of a vote, newest first",
+		Args: graphql.FieldConfigArgument{
+			"voteChain": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			voteChain, _ := p.Args["voteChain"].(string)
+			return s.SQLDB.FetchResultAttestations(p.Context, voteChain)
+		},
+	}
+}
+
+// FetchResultAttestations returns the attestations of the results of the
+// vote, newest first
+func (g *GraphQLSQLDB) FetchResultAttestations(ctx context.Context, chainid string) ([]ResultAttestation, error) {
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, `SELECT vote_chain, signed_at, dblock_height, dblock_keymr,
+		engine_version, result, public_key, signature FROM result_attestations WHERE vote_chain = $1 ORDER BY signed_at DESC, id DESC`, chainid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attestations := []ResultAttestation{}
+	for rows.Next() {
+		var a ResultAttestation
+		if err := rows.Scan(&a.VoteChain, &a.SignedAt, &a.DBlockHeight, &a.DBlockKeyMR,
+			&a.EngineVersion, &a.Result, &a.PublicKey, &a.Signature); err != nil {
+			return nil, err
+		}
+		attestations = append(attestations, a)
+	}
+	return attestations, rows.Err()
+}
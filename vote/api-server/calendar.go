@@ -0,0 +1,128 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/blocktime"
+)
+
+// Vote schedules are also served as iCalendar (RFC 5545) feeds, so voters can
+// subscribe to them in their calendar. Each phase is an event, and the times
+// are estimates until the blocks exist.
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+// rawResponse is a rest response written as is, rather than as json
+type rawResponse struct {
+	ContentType string
+	Body        []byte
+}
+
+type calendarVote struct {
+	Vote     Vote
+	Schedule *VoteSchedule
+}
+
+// FetchVoteCalendar returns the schedules of the votes, fetching the block
+// times of them all at once
+func (g *GraphQLSQLDB) FetchVoteCalendar(ctx context.Context, votes []Vote) ([]calendarVote, error) {
+	var heights []int
+	for i := range votes {
+		heights = append(heights, scheduleHeights(&votes[i])...)
+	}
+	times, err := g.FetchBlockTimes(ctx, heights)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := make([]calendarVote, len(votes))
+	for i := range votes {
+		cal[i] = calendarVote{votes[i], newVoteSchedule(&votes[i], times)}
+	}
+	return cal, nil
+}
+
+// icalendar writes the phases of the votes as events
+func icalendar(name string, now time.Time, votes []calendarVote) []byte {
+	var buf bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		buf.WriteString(foldICalLine(fmt.Sprintf(format, args...)))
+		buf.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//go-factom-vote//vote schedule//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:%s", escapeICalText(name))
+	for _, v := range votes {
+		phases := []struct {
+			Name     string
+			Schedule PhaseSchedule
+		}{
+			{"Discussion", v.Schedule.Discussion},
+			{"Commit", v.Schedule.Commit},
+			{"Reveal", v.Schedule.Reveal},
+		}
+		for _, p := range phases {
+			if p.Schedule.Start == nil || p.Schedule.End == nil {
+				continue
+			}
+			line("BEGIN:VEVENT")
+			line("UID:%s-%s@factom-vote", v.Vote.Chainid, strings.ToLower(p.Name))
+			line("DTSTAMP:%s", icalTime(now))
+			line("DTSTART:%s", icalTime(p.Schedule.Start.Time))
+			line("DTEND:%s", icalTime(p.Schedule.End.Time))
+			line("SUMMARY:%s", escapeICalText(fmt.Sprintf("%s phase: %s", p.Name, v.Vote.Proposal.Title)))
+			line("DESCRIPTION:%s", escapeICalText(phaseDescription(v.Vote.Chainid, p.Schedule)))
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+func phaseDescription(chain string, p PhaseSchedule) string {
+	desc := fmt.Sprintf("Vote %s, blocks %d to %d.", chain, p.Start.BlockHeight, p.End.BlockHeight)
+	for _, e := range []struct {
+		Name string
+		Est  *blocktime.Estimate
+	}{{"Starts", p.Start}, {"Ends", p.End}} {
+		if !e.Est.Observed {
+			desc += fmt.Sprintf("\n%s between %s and %s (estimated from block times).", e.Name,
+				e.Est.Earliest.UTC().Format(time.RFC1123), e.Est.Latest.UTC().Format(time.RFC1123))
+		}
+	}
+	return desc
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICalText(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+// foldICalLine splits lines longer than 75 octets, without splitting a
+// utf-8 character. Continuation lines start with a space.
+func foldICalLine(s string) string {
+	var buf bytes.Buffer
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			buf.WriteString("\r\n ")
+			n = 1
+		}
+		buf.WriteRune(r)
+		n += size
+	}
+	return buf.String()
+}
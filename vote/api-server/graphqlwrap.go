@@ -1,6 +1,7 @@
 package apiserver
 
 import (
+	"context"
 	"fmt"
 
 	"strings"
@@ -12,10 +13,42 @@ import (
 	"github.com/Emyrk/go-factom-vote/vote/common"
 	"github.com/Emyrk/go-factom-vote/vote/database"
 	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
 )
 
 var noextra []interface{}
 
+// NotFoundError is returned when the row asked for does not exist
+type NotFoundError struct {
+	What string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.What + " not found"
+}
+
+// ArgumentError is returned for invalid filter, sort or paging arguments
+type ArgumentError struct {
+	Message string
+}
+
+func (e *ArgumentError) Error() string {
+	return e.Message
+}
+
+func argumentErrorf(format string, args ...interface{}) error {
+	return &ArgumentError{fmt.Sprintf(format, args...)}
+}
+
+// chainIDArg normalizes an identity or chain id argument
+func chainIDArg(what, id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if len(id) != 64 {
+		return "", argumentErrorf("'%s' is not a %s id, expected a 64 character chain id", id, what)
+	}
+	return id, nil
+}
+
 // Wrapper for the sql db to have fetch functions that will be in the format for graphql
 type GraphQLSQLDB struct {
 	*database.SQLDatabase
@@ -30,12 +63,12 @@ var eligibleVoterRow = "voter_id, eligible_list, weight, entry_hash, block_heigh
 var commitRow = `voter_id, vote_chain, signing_key, signature, commitment, entry_hash, block_height`
 var revealRow = `voter_id, vote_chain, vote, secret, hmac_algo, entry_hash, block_height`
 
-func (g *GraphQLSQLDB) FetchProposalEntries(chainid string) ([]ProposalEntry, error) {
+func (g *GraphQLSQLDB) FetchProposalEntries(ctx context.Context, chainid string) ([]ProposalEntry, error) {
 	query := `
 		SELECT voter_id, weight, entry_hash, commit, reveal FROM fetch_proposal_entries($1);
 	`
 
-	rows, err := g.SQLDatabase.DB.Query(query, chainid)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, chainid)
 	if err != nil {
 		return nil, err
 	}
@@ -43,6 +76,7 @@ func (g *GraphQLSQLDB) FetchProposalEntries(chainid string) ([]ProposalEntry, er
 	var arr []ProposalEntry
 	for rows.Next() {
 		e := NewProposalEntry()
+		e.VoteChain = chainid
 		err := rows.Scan(&e.VoterId, &e.Weight, &e.EntryHash, &e.Commit, &e.Reveal)
 		if err != nil {
 			return nil, err
@@ -54,16 +88,16 @@ func (g *GraphQLSQLDB) FetchProposalEntries(chainid string) ([]ProposalEntry, er
 	return arr, nil
 }
 
-func (g *GraphQLSQLDB) FetchVote(chainid string) (*Vote, error) {
+func (g *GraphQLSQLDB) FetchVote(ctx context.Context, chainid string) (*Vote, error) {
 	query := fmt.Sprintf(`SELECT %s FROM proposals WHERE chain_id = $1`, voterow)
-	rows, err := g.SQLDatabase.DB.Query(query, chainid)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, chainid)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("vote not found")
+		return nil, &NotFoundError{"vote"}
 	}
 
 	v := new(Vote)
@@ -75,7 +109,7 @@ func (g *GraphQLSQLDB) FetchVote(chainid string) (*Vote, error) {
 	return v, nil
 }
 
-func (g *GraphQLSQLDB) FetchAllVoteStats(valid bool, offset int, limit int) (*VoteResultList, error) {
+func (g *GraphQLSQLDB) FetchAllVoteStats(ctx context.Context, valid bool, offset int, limit int, page *PageArgs) (*VoteResultList, error) {
 	r := new(common.VoteStats)
 	where := ""
 	var args []interface{}
@@ -83,34 +117,59 @@ func (g *GraphQLSQLDB) FetchAllVoteStats(valid bool, offset int, limit int) (*Vo
 		where = "WHERE valid_vote = $1 "
 		args = append(args, valid)
 	}
-	query := fmt.Sprintf("SELECT %s, count(*) OVER() AS full_count FROM results %s", r.SelectRows(), where)
-	if offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", offset)
-	}
+	// Results are paged by the block height and entry hash of their proposal
+	query := fmt.Sprintf(`SELECT %s, count(*) OVER() AS full_count,
+		COALESCE(proposals.block_height, 0) AS block_height, COALESCE(proposals.entry_hash, '') AS entry_hash
+		FROM results LEFT JOIN proposals ON proposals.chain_id = results.vote_chain %s`, r.SelectRows(), where)
+	if page != nil {
+		query, args = page.Wrap(query, args)
+	} else {
+		if offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", offset)
+		}
 
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+		if limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", limit)
+		}
 	}
 
-	rows, err := g.SQLDatabase.DB.Query(query, args...)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var results []common.VoteStats
+	var cursors []Cursor
 	count := new(int)
 	for rows.Next() {
 		v := new(common.VoteStats)
-		err = scanVoteResults(rows, v, []interface{}{count})
+		var c Cursor
+		err = scanVoteResults(rows, v, []interface{}{count, &c.BlockHeight, &c.EntryHash})
 
 		if err != nil {
 			return nil, err
 		}
 		results = append(results, *v)
+		cursors = append(cursors, NewCursor(c.BlockHeight, c.EntryHash))
 	}
 
 	container := new(VoteResultList)
+	if page != nil {
+		var n int
+		n, container.PageInfo = page.Trim(len(results), func(i, j int) {
+			results[i], results[j] = results[j], results[i]
+			cursors[i], cursors[j] = cursors[j], cursors[i]
+		})
+		results, cursors = results[:n], cursors[:n]
+	} else {
+		container.PageInfo = offsetPageInfo(offset, len(results), *count)
+	}
+	for i := range results {
+		container.Edges = append(container.Edges, Edge{Cursor: cursors[i].String(), Node: results[i]})
+	}
+	container.PageInfo.setCursors(container.Edges)
+
 	container.Votes = results
 	container.Info.TotalCount = *count
 	container.Info.Offset = offset
@@ -119,17 +178,17 @@ func (g *GraphQLSQLDB) FetchAllVoteStats(valid bool, offset int, limit int) (*Vo
 	return container, nil
 }
 
-func (g *GraphQLSQLDB) FetchVoteStats(chainid string) (*common.VoteStats, error) {
+func (g *GraphQLSQLDB) FetchVoteStats(ctx context.Context, chainid string) (*common.VoteStats, error) {
 	r := new(common.VoteStats)
 	query := fmt.Sprintf(`SELECT %s FROM results WHERE vote_chain = $1`, r.SelectRows())
-	rows, err := g.SQLDatabase.DB.Query(query, chainid)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, chainid)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("vote results not found")
+		return nil, &NotFoundError{"vote results"}
 	}
 
 	r, err = r.ScanRow(rows)
@@ -139,16 +198,40 @@ func (g *GraphQLSQLDB) FetchVoteStats(chainid string) (*common.VoteStats, error)
 	return r, nil
 }
 
-func (g *GraphQLSQLDB) FetchEligibleList(chainid string) (*EligibleList, error) {
+// FetchProvisionalVoteStats returns the results of the vote if it is
+// complete. Otherwise they are computed from the reveals so far, and marked
+// as provisional.
+func (g *GraphQLSQLDB) FetchProvisionalVoteStats(ctx context.Context, chainid string) (*common.VoteStats, error) {
+	stats, err := g.FetchVoteStats(ctx, chainid)
+	if _, ok := err.(*NotFoundError); !ok {
+		return stats, err
+	}
+
+	v, err := g.fetchTallyVote(ctx, chainid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Counted the same as the scraper does once the vote is complete. The
+	// results are provisional until then, even if the reveal phase is over.
+	stats, _, err = g.tallyAt(ctx, v, g.FetchHighestDBInserted(ctx))
+	if err != nil {
+		return nil, err
+	}
+	stats.Provisional = true
+	return stats, nil
+}
+
+func (g *GraphQLSQLDB) FetchEligibleList(ctx context.Context, chainid string) (*EligibleList, error) {
 	query := fmt.Sprintf(`SELECT %s FROM eligible_list WHERE chain_id = $1`, eligibleListRow)
-	rows, err := g.SQLDatabase.DB.Query(query, chainid)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, chainid)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("list not found")
+		return nil, &NotFoundError{"list"}
 	}
 
 	e := new(EligibleList)
@@ -166,7 +249,7 @@ func (g *GraphQLSQLDB) FetchEligibleList(chainid string) (*EligibleList, error)
 	return e, nil
 }
 
-func (g *GraphQLSQLDB) FetchEligibleVoters(chainid string, blockHeight, limit, offset int) (*EligibleVoterContainer, error) {
+func (g *GraphQLSQLDB) FetchEligibleVoters(ctx context.Context, chainid string, blockHeight, limit, offset int, page *PageArgs) (*EligibleVoterContainer, error) {
 	//query := fmt.Sprintf(`
 	//SELECT eligible_voters.voter_id, eligible_list, weight, entry_hash, eligible_voters.block_height, signing_keys, count(*) OVER() AS full_count
 	//FROM eligible_voters
@@ -182,15 +265,20 @@ func (g *GraphQLSQLDB) FetchEligibleVoters(chainid string, blockHeight, limit, o
 		blockHeight = 9999999
 	}
 
-	if offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", offset)
-	}
+	args := []interface{}{chainid, blockHeight}
+	if page != nil {
+		query, args = page.Wrap(query, args)
+	} else {
+		if offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", offset)
+		}
 
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+		if limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", limit)
+		}
 	}
 
-	rows, err := g.SQLDatabase.DB.Query(query, chainid, blockHeight)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +297,18 @@ func (g *GraphQLSQLDB) FetchEligibleVoters(chainid string, blockHeight, limit, o
 	}
 
 	container := new(EligibleVoterContainer)
+	if page != nil {
+		var n int
+		n, container.PageInfo = page.Trim(len(votes), func(i, j int) { votes[i], votes[j] = votes[j], votes[i] })
+		votes = votes[:n]
+	} else {
+		container.PageInfo = offsetPageInfo(offset, len(votes), *count)
+	}
+	for _, v := range votes {
+		container.Edges = append(container.Edges, Edge{Cursor: NewCursor(v.BlockHeight, v.EntryHash).String(), Node: v})
+	}
+	container.PageInfo.setCursors(container.Edges)
+
 	container.EligibleVoters = votes
 	container.Info.TotalCount = *count
 	container.Info.Limit = limit
@@ -238,6 +338,9 @@ func scanEligibleVoter(rows *sql.Rows, v *EligibleVoter, extra []interface{}) er
 
 func scanVoteResults(rows *sql.Rows, v *common.VoteStats, extra []interface{}) error {
 	var optJson, winJson string
+	var ballotJson []byte // Null for results stored before ballots were
+	var version sql.NullInt64
+	var inputHash sql.NullString
 
 	arr := []interface{}{
 		&v.VoteChain,
@@ -254,6 +357,9 @@ func scanVoteResults(rows *sql.Rows, v *common.VoteStats, extra []interface{}) e
 		&v.Support.WeightDenominator,
 		&optJson,
 		&winJson,
+		&ballotJson,
+		&version,
+		&inputHash,
 	}
 
 	arr = append(arr, extra...)
@@ -263,6 +369,10 @@ func scanVoteResults(rows *sql.Rows, v *common.VoteStats, extra []interface{}) e
 
 	json.Unmarshal([]byte(optJson), &v.OptionStats)
 	json.Unmarshal([]byte(winJson), &v.WeightedWinners)
+	if ballotJson != nil {
+		json.Unmarshal(ballotJson, &v.Ballots)
+	}
+	v.AlgorithmVersion, v.InputHash = int(version.Int64), inputHash.String
 
 	return err
 }
@@ -279,10 +389,11 @@ var validSortOptions = map[string]string{
 	"blockHeight":   "block_height",
 }
 
-func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset int, params map[string]interface{}) (*VoteList, error) {
+func (g *GraphQLSQLDB) FetchAllVotes(ctx context.Context, registered int, active bool, limit, offset int, page *PageArgs, params map[string]interface{}) (*VoteList, error) {
 	//var args []interface{}
 	status, _ := params["status"].(string)
 	title, _ := params["title"].(string)
+	search, _ := params["search"].(string)
 	voter, _ := params["voter"].(string)
 	vi, _ := params["voteInitiator"].(string)
 	voteChain, _ := params["voteChain"].(string)
@@ -315,7 +426,7 @@ func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset
 		query = query.Where("reveal_stop <= (SELECT max(block_height) FROM completed)")
 	case "":
 	default:
-		return nil, fmt.Errorf("'%s' is not a valid status, choose from 'discussion, commit, reveal, complete'", status)
+		return nil, argumentErrorf("'%s' is not a valid status, choose from 'discussion, commit, reveal, complete'", status)
 	}
 
 	if title != "" {
@@ -323,6 +434,14 @@ func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset
 		//args = append(args, "%"+title+"%")
 	}
 
+	if search != "" {
+		query = query.Column("ts_rank(search_vector, "+tsQuery+") AS search_rank", search)
+		query = query.Column("ts_headline('english', coalesce(title, ''), "+tsQuery+", 'HighlightAll=TRUE')", search)
+		query = query.Column("ts_headline('english', coalesce(description, ''), "+tsQuery+")", search)
+		query = query.Column("ts_headline('english', coalesce(external_href, ''), "+tsQuery+", 'HighlightAll=TRUE')", search)
+		query = query.Where("search_vector @@ "+tsQuery, search)
+	}
+
 	if vi != "" {
 		query = query.Where("vote_initiator LIKE ?", "%"+vi+"%")
 	}
@@ -331,6 +450,10 @@ func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset
 		query = query.Where("chain_id LIKE ?", "%"+voteChain+"%")
 	}
 
+	if sort != "" && page != nil {
+		return nil, argumentErrorf("'sort' cannot be combined with cursor pagination, which is always by block height")
+	}
+
 	if sort != "" {
 		sortCols := common.SplitString(sort, ",")
 		orders := common.SplitString(sortOrder, ",")
@@ -350,9 +473,12 @@ func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset
 				for k, _ := range validSortOptions {
 					valid = append(valid, k)
 				}
-				return nil, fmt.Errorf("'%s' is not a valid sorting option. Options: %v", sort, valid)
+				return nil, argumentErrorf("'%s' is not a valid sorting option. Options: %v", sort, valid)
 			}
 		}
+	} else if search != "" && page == nil {
+		// Cursors are by block height, otherwise the best matches come first
+		query = query.OrderBy("search_rank DESC", "block_height DESC")
 	}
 
 	if voter != "" {
@@ -360,20 +486,25 @@ func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset
 		query = query.RightJoin(joinQuery, "%"+voter+"%")
 	}
 
-	if offset > 0 {
-		query = query.Offset(uint64(offset))
-	}
+	if page == nil {
+		if offset > 0 {
+			query = query.Offset(uint64(offset))
+		}
 
-	if limit > 0 {
-		query = query.Limit(uint64(limit))
+		if limit > 0 {
+			query = query.Limit(uint64(limit))
+		}
 	}
 
 	q, args, err := query.ToSql()
 	if err != nil {
 		return nil, err
 	}
+	if page != nil {
+		q, args = page.Wrap(q, args)
+	}
 
-	rows, err := g.SQLDatabase.DB.Query(q, args...)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -383,7 +514,12 @@ func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset
 	count := new(int)
 	for rows.Next() {
 		v := new(Vote)
-		err = scanVote(rows, v, []interface{}{count})
+		extra := []interface{}{count}
+		if search != "" {
+			v.Search = new(SearchMatch)
+			extra = append(extra, &v.Search.Score, &v.Search.Highlight.Title, &v.Search.Highlight.Text, &v.Search.Highlight.Href)
+		}
+		err = scanVote(rows, v, extra)
 
 		if err != nil {
 			return nil, err
@@ -392,6 +528,18 @@ func (g *GraphQLSQLDB) FetchAllVotes(registered int, active bool, limit, offset
 	}
 
 	container := new(VoteList)
+	if page != nil {
+		var n int
+		n, container.PageInfo = page.Trim(len(votes), func(i, j int) { votes[i], votes[j] = votes[j], votes[i] })
+		votes = votes[:n]
+	} else {
+		container.PageInfo = offsetPageInfo(offset, len(votes), *count)
+	}
+	for _, v := range votes {
+		container.Edges = append(container.Edges, Edge{Cursor: NewCursor(v.Admin.AdminBlockHeight, v.Admin.AdminEntryHash).String(), Node: v})
+	}
+	container.PageInfo.setCursors(container.Edges)
+
 	container.Votes = votes
 	container.Info.TotalCount = *count
 	container.Info.Offset = offset
@@ -447,18 +595,23 @@ func scanVote(rows *sql.Rows, v *Vote, extra []interface{}) error {
 	return err
 }
 
-func (g *GraphQLSQLDB) FetchAllCommits(chainid string, limit, offset int) (*VoteCommitContainer, error) {
+func (g *GraphQLSQLDB) FetchAllCommits(ctx context.Context, chainid string, limit, offset int, page *PageArgs) (*VoteCommitContainer, error) {
 	query := fmt.Sprintf(`SELECT %s, count(*) OVER() AS full_count FROM commits WHERE vote_chain = $1`, commitRow)
 
-	if offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", offset)
-	}
+	args := []interface{}{chainid}
+	if page != nil {
+		query, args = page.Wrap(query, args)
+	} else {
+		if offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", offset)
+		}
 
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+		if limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", limit)
+		}
 	}
 
-	rows, err := g.SQLDatabase.DB.Query(query, chainid)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -477,6 +630,18 @@ func (g *GraphQLSQLDB) FetchAllCommits(chainid string, limit, offset int) (*Vote
 	}
 
 	container := new(VoteCommitContainer)
+	if page != nil {
+		var n int
+		n, container.PageInfo = page.Trim(len(commits), func(i, j int) { commits[i], commits[j] = commits[j], commits[i] })
+		commits = commits[:n]
+	} else {
+		container.PageInfo = offsetPageInfo(offset, len(commits), *count)
+	}
+	for _, v := range commits {
+		container.Edges = append(container.Edges, Edge{Cursor: NewCursor(v.BlockHeight, v.EntryHash).String(), Node: v})
+	}
+	container.PageInfo.setCursors(container.Edges)
+
 	container.Commits = commits
 	container.Info.TotalCount = *count
 	container.Info.Offset = offset
@@ -485,16 +650,16 @@ func (g *GraphQLSQLDB) FetchAllCommits(chainid string, limit, offset int) (*Vote
 	return container, nil
 }
 
-func (g *GraphQLSQLDB) FetchCommit(voterID, voteChain string) (*VoteCommit, error) {
+func (g *GraphQLSQLDB) FetchCommit(ctx context.Context, voterID, voteChain string) (*VoteCommit, error) {
 	query := fmt.Sprintf(`SELECT %s FROM commits WHERE voter_id = $1 AND vote_chain = $2`, commitRow)
-	rows, err := g.SQLDatabase.DB.Query(query, voterID, voteChain)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, voterID, voteChain)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("list not found")
+		return nil, &NotFoundError{"list"}
 	}
 
 	c := new(VoteCommit)
@@ -524,18 +689,23 @@ func scanCommit(rows *sql.Rows, c *VoteCommit, extra []interface{}) error {
 	return err
 }
 
-func (g *GraphQLSQLDB) FetchAllReveals(chainid string, limit, offset int) (*VoteRevealContainer, error) {
+func (g *GraphQLSQLDB) FetchAllReveals(ctx context.Context, chainid string, limit, offset int, page *PageArgs) (*VoteRevealContainer, error) {
 	query := fmt.Sprintf(`SELECT %s, count(*) OVER() AS full_count FROM reveals WHERE vote_chain = $1`, revealRow)
 
-	if offset > 0 {
-		query += fmt.Sprintf(" OFFSET %d", offset)
-	}
+	args := []interface{}{chainid}
+	if page != nil {
+		query, args = page.Wrap(query, args)
+	} else {
+		if offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", offset)
+		}
 
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
+		if limit > 0 {
+			query += fmt.Sprintf(" LIMIT %d", limit)
+		}
 	}
 
-	rows, err := g.SQLDatabase.DB.Query(query, chainid)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -554,6 +724,18 @@ func (g *GraphQLSQLDB) FetchAllReveals(chainid string, limit, offset int) (*Vote
 	}
 
 	container := new(VoteRevealContainer)
+	if page != nil {
+		var n int
+		n, container.PageInfo = page.Trim(len(reveals), func(i, j int) { reveals[i], reveals[j] = reveals[j], reveals[i] })
+		reveals = reveals[:n]
+	} else {
+		container.PageInfo = offsetPageInfo(offset, len(reveals), *count)
+	}
+	for _, v := range reveals {
+		container.Edges = append(container.Edges, Edge{Cursor: NewCursor(v.BlockHeight, v.EntryHash).String(), Node: v})
+	}
+	container.PageInfo.setCursors(container.Edges)
+
 	container.Reveals = reveals
 	container.Info.TotalCount = *count
 	container.Info.Offset = offset
@@ -562,16 +744,16 @@ func (g *GraphQLSQLDB) FetchAllReveals(chainid string, limit, offset int) (*Vote
 	return container, nil
 }
 
-func (g *GraphQLSQLDB) FetchReveal(voterID, voteChain string) (*VoteReveal, error) {
+func (g *GraphQLSQLDB) FetchReveal(ctx context.Context, voterID, voteChain string) (*VoteReveal, error) {
 	query := fmt.Sprintf(`SELECT %s FROM reveals WHERE voter_id = $1 AND vote_chain = $2`, revealRow)
-	rows, err := g.SQLDatabase.DB.Query(query, voterID, voteChain)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, voterID, voteChain)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	if !rows.Next() {
-		return nil, fmt.Errorf("list not found")
+		return nil, &NotFoundError{"list"}
 	}
 
 	r := new(VoteReveal)
@@ -605,11 +787,125 @@ func scanReveal(rows *sql.Rows, r *VoteReveal, extra []interface{}) error {
 	return err
 }
 
-func (g *GraphQLSQLDB) FetchNumberOfResults() (int, error) {
+func (g *GraphQLSQLDB) FetchNumberOfResults(ctx context.Context) (int, error) {
 	query := fmt.Sprintf(`SELECT count(*) FROM results`)
-	row := g.SQLDatabase.DB.QueryRow(query)
+	row := g.SQLDatabase.DB.QueryRowContext(ctx, query)
 	var count int
 	err := row.Scan(&count)
 
 	return count, err
 }
+
+// Batch fetches used by the request loaders, see loader.go
+
+func (g *GraphQLSQLDB) FetchCommitsByVoter(ctx context.Context, keys []VoterKey) (map[VoterKey]VoteCommit, error) {
+	chains, voters := splitVoterKeys(keys)
+	query := fmt.Sprintf(`SELECT %s FROM commits
+		WHERE (vote_chain, voter_id) IN (SELECT * FROM unnest($1::text[], $2::text[]))`, commitRow)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, pq.Array(chains), pq.Array(voters))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	commits := make(map[VoterKey]VoteCommit)
+	for rows.Next() {
+		c := new(VoteCommit)
+		err = scanCommit(rows, c, noextra)
+		if err != nil {
+			return nil, err
+		}
+		commits[newVoterKey(c.VoteChain, c.VoterID)] = *c
+	}
+	return commits, rows.Err()
+}
+
+func (g *GraphQLSQLDB) FetchRevealsByVoter(ctx context.Context, keys []VoterKey) (map[VoterKey]VoteReveal, error) {
+	chains, voters := splitVoterKeys(keys)
+	query := fmt.Sprintf(`SELECT %s FROM reveals
+		WHERE (vote_chain, voter_id) IN (SELECT * FROM unnest($1::text[], $2::text[]))`, revealRow)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, pq.Array(chains), pq.Array(voters))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reveals := make(map[VoterKey]VoteReveal)
+	for rows.Next() {
+		r := new(VoteReveal)
+		err = scanReveal(rows, r, noextra)
+		if err != nil {
+			return nil, err
+		}
+		reveals[newVoterKey(r.VoteChain, r.VoterID)] = *r
+	}
+	return reveals, rows.Err()
+}
+
+func (g *GraphQLSQLDB) FetchVoteStatsByChain(ctx context.Context, chains []string) (map[string]common.VoteStats, error) {
+	r := new(common.VoteStats)
+	query := fmt.Sprintf(`SELECT %s FROM results WHERE vote_chain = ANY($1::text[])`, r.SelectRows())
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, pq.Array(chains))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]common.VoteStats)
+	for rows.Next() {
+		v := new(common.VoteStats)
+		err = scanVoteResults(rows, v, noextra)
+		if err != nil {
+			return nil, err
+		}
+		results[strings.TrimSpace(v.VoteChain)] = *v
+	}
+	return results, rows.Err()
+}
+
+func (g *GraphQLSQLDB) FetchEligibleVotersAt(ctx context.Context, keys []EligibleKey) (map[EligibleKey][]EligibleVoter, error) {
+	lists := make([]string, len(keys))
+	heights := make([]int64, len(keys))
+	for i, k := range keys {
+		lists[i] = k.EligibleList
+		heights[i] = int64(k.BlockHeight)
+	}
+
+	// Each list is read at its own height, the same as FetchEligibleVoters
+	query := `
+		SELECT voters.voter_id, voters.eligible_list, voters.weight, voters.entry_hash, voters.block_height, voters.signing_keys,
+			list.height
+		FROM unnest($1::text[], $2::integer[]) AS list(chain_id, height),
+			LATERAL fetch_eligible_voters(list.chain_id::character(64), list.height) AS voters
+		ORDER BY voters.block_height, voters.entry_hash`
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, pq.Array(lists), pq.Array(heights))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	voters := make(map[EligibleKey][]EligibleVoter)
+	for rows.Next() {
+		v := new(EligibleVoter)
+		var height int
+		err = scanEligibleVoter(rows, v, []interface{}{&height})
+		if err != nil {
+			return nil, err
+		}
+		k := EligibleKey{strings.TrimSpace(v.EligibleList), height}
+		voters[k] = append(voters[k], *v)
+	}
+	return voters, rows.Err()
+}
+
+func newVoterKey(voteChain, voterID string) VoterKey {
+	return VoterKey{strings.TrimSpace(voteChain), strings.TrimSpace(voterID)}
+}
+
+func splitVoterKeys(keys []VoterKey) (chains, voters []string) {
+	for _, k := range keys {
+		chains = append(chains, k.VoteChain)
+		voters = append(voters, k.VoterID)
+	}
+	return
+}
@@ -0,0 +1,89 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/graphql-go/graphql"
+)
+
+// Stored results are recomputed by the scraper's recompute routine when the
+// tally changes. The results they replaced are kept in the history.
+
+// ResultChange is stored results replaced by a recompute
+type ResultChange struct {
+	VoteChain    string    `json:"voteChain"`
+	RecomputedAt time.Time `json:"recomputedAt"`
+	// Differences are the json names of the parts of the results that changed.
+	// Empty if only the version or input hash did.
+	Differences []string         `json:"differences"`
+	Previous    common.VoteStats `json:"previous"`
+	Result      common.VoteStats `json:"result"`
+}
+
+var ResultChangeGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ResultChange",
+	Description: "Results replaced when recomputed with a newer version of the tally",
+	Fields: graphql.Fields{
+		"voteChain": &graphql.Field{
+			Type: graphql.String,
+		},
+		"recomputedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"differences": &graphql.Field{
+			Description: "Parts of the results that changed, empty if only the version or input hash did",
+			Type:        graphql.NewList(graphql.String),
+		},
+		"previous": &graphql.Field{
+			Description: "The results that were replaced",
+			Type:        VoteResultsGraphQLType,
+		},
+		"result": &graphql.Field{
+			Description: "The results they were replaced with",
+			Type:        VoteResultsGraphQLType,
+		},
+	}})
+
+func (s *GraphQLServer) resultHistory() *graphql.Field {
+	return &graphql.Field{
+		Type:        graphql.NewList(ResultChangeGraphQLType),
+		Description: "Every time the stored results of a vote were replaced by a recompute, oldest first",
+		Args: graphql.FieldConfigArgument{
+			"voteChain": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			voteChain, _ := p.Args["voteChain"].(string)
+			return s.SQLDB.FetchResultHistory(p.Context, voteChain)
+		},
+	}
+}
+
+// FetchResultHistory returns the changes to the stored results of the vote,
+// oldest first
+func (g *GraphQLSQLDB) FetchResultHistory(ctx context.Context, chainid string) ([]ResultChange, error) {
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, `SELECT vote_chain, recomputed_at, differences, previous_result, result
+		FROM results_history WHERE vote_chain = $1 ORDER BY recomputed_at, id`, chainid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []ResultChange{}
+	for rows.Next() {
+		var c ResultChange
+		var diffs, previous, result string
+		if err := rows.Scan(&c.VoteChain, &c.RecomputedAt, &diffs, &previous, &result); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(diffs), &c.Differences)
+		json.Unmarshal([]byte(previous), &c.Previous)
+		json.Unmarshal([]byte(result), &c.Result)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,352 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/graphql-go/graphql"
+)
+
+// The initiator dashboard groups everything an identity created, the
+// proposals and the eligible lists, by the vote_initiator columns.
+
+type InitiatorDashboard struct {
+	VoteInitiator string             `json:"voteInitiator"`
+	Proposals     InitiatorProposals `json:"proposals"`
+	Registration  RegistrationCounts `json:"registration"`
+	EligibleLists []InitiatorList    `json:"eligibleLists"`
+	Outcomes      []VoteOutcome      `json:"outcomes"`
+	Summary       OutcomeSummary     `json:"summary"`
+}
+
+// InitiatorProposals are the proposals of an initiator by the phase they are
+// in, newest first.
+type InitiatorProposals struct {
+	Discussion []Vote `json:"discussion"`
+	Commit     []Vote `json:"commit"`
+	Reveal     []Vote `json:"reveal"`
+	Complete   []Vote `json:"complete"`
+}
+
+type RegistrationCounts struct {
+	Registered   int `json:"registered"`
+	Unregistered int `json:"unregistered"`
+}
+
+// InitiatorList is an eligible list the initiator controls, with its voters
+// as of the latest block.
+type InitiatorList struct {
+	Admin       EligibleListAdmin `json:"admin"`
+	Voters      int               `json:"voters"`
+	TotalWeight common.Decimal    `json:"totalWeight"`
+	Votes       int               `json:"votes"`
+}
+
+// VoteOutcome is the result of a complete vote, at the height it was decided
+type VoteOutcome struct {
+	VoteChain   string           `json:"voteChain"`
+	Title       string           `json:"title"`
+	BlockHeight int              `json:"blockHeight"`
+	Result      common.VoteStats `json:"result"`
+}
+
+// OutcomeSummary is over the complete proposals of the initiator
+type OutcomeSummary struct {
+	Complete               int            `json:"complete"`
+	Accepted               int            `json:"accepted"`
+	AcceptanceRate         float64        `json:"acceptanceRate"`
+	AverageTurnout         common.Decimal `json:"averageTurnout"`
+	AverageWeightedTurnout common.Decimal `json:"averageWeightedTurnout"`
+}
+
+var InitiatorProposalsGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "InitiatorProposals",
+	Description: "Proposals by the phase they are in, newest first",
+	Fields: graphql.Fields{
+		"discussion": &graphql.Field{
+			Type: graphql.NewList(VoteGraphQLType),
+		},
+		"commit": &graphql.Field{
+			Type: graphql.NewList(VoteGraphQLType),
+		},
+		"reveal": &graphql.Field{
+			Type: graphql.NewList(VoteGraphQLType),
+		},
+		"complete": &graphql.Field{
+			Type: graphql.NewList(VoteGraphQLType),
+		},
+	}})
+
+var RegistrationCountsGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "RegistrationCounts",
+	Description: "Proposals registered, or not, in the list of votes chain",
+	Fields: graphql.Fields{
+		"registered": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"unregistered": &graphql.Field{
+			Type: graphql.Int,
+		},
+	}})
+
+var InitiatorListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "InitiatorList",
+	Description: "An eligible voter list controlled by the initiator",
+	Fields: graphql.Fields{
+		"admin": &graphql.Field{
+			Type: ELAdminGraphQLType,
+		},
+		"voters": &graphql.Field{
+			Description: "Number of voters with a weight above 0, as of the latest block",
+			Type:        graphql.Int,
+		},
+		"totalWeight": &graphql.Field{
+			Description: "Sum of the weights of the voters, as of the latest block",
+			Type:        graphql.String,
+		},
+		"votes": &graphql.Field{
+			Description: "Number of proposals using the list",
+			Type:        graphql.Int,
+		},
+	}})
+
+var VoteOutcomeGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "VoteOutcome",
+	Description: "The result of a complete vote",
+	Fields: graphql.Fields{
+		"voteChain": &graphql.Field{
+			Type: graphql.String,
+		},
+		"title": &graphql.Field{
+			Type: graphql.String,
+		},
+		"blockHeight": &graphql.Field{
+			Description: "End of the reveal phase, when the vote was decided",
+			Type:        graphql.Int,
+		},
+		"result": &graphql.Field{
+			Type: VoteResultsGraphQLType,
+		},
+	}})
+
+var OutcomeSummaryGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "OutcomeSummary",
+	Description: "Outcomes over the complete proposals",
+	Fields: graphql.Fields{
+		"complete": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"accepted": &graphql.Field{
+			Description: "Proposals that met their acceptance criteria",
+			Type:        graphql.Int,
+		},
+		"acceptanceRate": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"averageTurnout": &graphql.Field{
+			Type: graphql.String,
+		},
+		"averageWeightedTurnout": &graphql.Field{
+			Type: graphql.String,
+		},
+	}})
+
+var InitiatorDashboardGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "VoteInitiator",
+	Description: "Everything an identity created: proposals, eligible lists, and their outcomes",
+	Fields: graphql.Fields{
+		"voteInitiator": &graphql.Field{
+			Type: graphql.String,
+		},
+		"proposals": &graphql.Field{
+			Type: InitiatorProposalsGraphQLType,
+		},
+		"registration": &graphql.Field{
+			Type: RegistrationCountsGraphQLType,
+		},
+		"eligibleLists": &graphql.Field{
+			Type: graphql.NewList(InitiatorListGraphQLType),
+		},
+		"outcomes": &graphql.Field{
+			Description: "Results of the complete proposals, in the order they were decided",
+			Type:        graphql.NewList(VoteOutcomeGraphQLType),
+		},
+		"summary": &graphql.Field{
+			Type: OutcomeSummaryGraphQLType,
+		},
+	}})
+
+func (s *GraphQLServer) voteInitiator() *graphql.Field {
+	return &graphql.Field{
+		Type:        InitiatorDashboardGraphQLType,
+		Description: "The proposals, eligible lists and outcomes of a vote initiator",
+		Args: graphql.FieldConfigArgument{
+			"id": &graphql.ArgumentConfig{
+				Description: "Identity chain id of the initiator",
+				Type:        graphql.NewNonNull(graphql.String),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id := p.Args["id"].(string)
+			return s.SQLDB.FetchInitiatorDashboard(p.Context, id)
+		},
+	}
+}
+
+// FetchInitiatorDashboard returns everything created by the identity. An
+// identity that has created nothing has an empty dashboard.
+func (g *GraphQLSQLDB) FetchInitiatorDashboard(ctx context.Context, initiator string) (*InitiatorDashboard, error) {
+	initiator, err := chainIDArg("initiator", initiator)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &InitiatorDashboard{VoteInitiator: initiator}
+	if err := g.fetchInitiatorProposals(ctx, d); err != nil {
+		return nil, err
+	}
+	if d.EligibleLists, err = g.fetchInitiatorLists(ctx, initiator); err != nil {
+		return nil, err
+	}
+	if d.Outcomes, err = g.fetchInitiatorOutcomes(ctx, initiator); err != nil {
+		return nil, err
+	}
+	d.Summary = outcomeSummary(d.Outcomes)
+	return d, nil
+}
+
+func (g *GraphQLSQLDB) fetchInitiatorProposals(ctx context.Context, d *InitiatorDashboard) error {
+	height := g.FetchHighestDBInserted(ctx)
+
+	query := fmt.Sprintf(`SELECT %s FROM proposals WHERE vote_initiator = $1 ORDER BY block_height DESC, entry_hash`, voterow)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, d.VoteInitiator)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	p := &d.Proposals
+	p.Discussion, p.Commit, p.Reveal, p.Complete = []Vote{}, []Vote{}, []Vote{}, []Vote{}
+	for rows.Next() {
+		v := new(Vote)
+		if err := scanVote(rows, v, nil); err != nil {
+			return err
+		}
+
+		if v.Admin.Registered {
+			d.Registration.Registered++
+		} else {
+			d.Registration.Unregistered++
+		}
+
+		v.Admin.Status = phaseAt(v, height)
+		switch v.Admin.Status {
+		case "discussion":
+			p.Discussion = append(p.Discussion, *v)
+		case "commit":
+			p.Commit = append(p.Commit, *v)
+		case "reveal":
+			p.Reveal = append(p.Reveal, *v)
+		default:
+			p.Complete = append(p.Complete, *v)
+		}
+	}
+	return rows.Err()
+}
+
+// phaseAt is the phase of the vote at the height, by the same bounds as the
+// allProposals status filter. The gap between the commit and reveal phases,
+// if any, is counted as the reveal phase.
+func phaseAt(v *Vote, height int) string {
+	phases := v.Definition.PhasesBlockHeights
+	switch {
+	case height < phases.CommitStart:
+		return "discussion"
+	case height < phases.CommitStop:
+		return "commit"
+	case height < phases.RevealStop:
+		return "reveal"
+	}
+	return "complete"
+}
+
+func (g *GraphQLSQLDB) fetchInitiatorLists(ctx context.Context, initiator string) ([]InitiatorList, error) {
+	// The voters are as of the latest block, fetch_eligible_voters only
+	// counts entries below the height given
+	query := fmt.Sprintf(`SELECT %s,
+			(SELECT count(*) FROM proposals WHERE eligible_voter_chain = eligible_list.chain_id),
+			voters.count, voters.weight
+		FROM eligible_list
+			LEFT JOIN LATERAL (SELECT count(*) AS count, coalesce(sum(v.weight), 0) AS weight
+				FROM fetch_eligible_voters(eligible_list.chain_id, 2147483647) AS v WHERE v.weight > 0) AS voters ON TRUE
+		WHERE vote_initiator = $1 ORDER BY chain_id`, eligibleListRow)
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, initiator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []InitiatorList{}
+	for rows.Next() {
+		var l InitiatorList
+		err := rows.Scan(
+			&l.Admin.ChainID,
+			&l.Admin.Initiator,
+			&l.Admin.Nonce,
+			&l.Admin.SigningKey,
+			&l.Admin.Signature,
+			&l.Votes,
+			&l.Voters,
+			&l.TotalWeight,
+		)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	return lists, rows.Err()
+}
+
+func (g *GraphQLSQLDB) fetchInitiatorOutcomes(ctx context.Context, initiator string) ([]VoteOutcome, error) {
+	r := new(common.VoteStats)
+	query := fmt.Sprintf(`SELECT %s, coalesce(proposals.title, ''), proposals.reveal_stop
+		FROM results JOIN proposals ON proposals.chain_id = results.vote_chain
+		WHERE proposals.vote_initiator = $1 ORDER BY proposals.reveal_stop, proposals.entry_hash`, r.SelectRows())
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, query, initiator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	outcomes := []VoteOutcome{}
+	for rows.Next() {
+		var o VoteOutcome
+		if err := scanVoteResults(rows, &o.Result, []interface{}{&o.Title, &o.BlockHeight}); err != nil {
+			return nil, err
+		}
+		o.VoteChain = o.Result.VoteChain
+		outcomes = append(outcomes, o)
+	}
+	return outcomes, rows.Err()
+}
+
+func outcomeSummary(outcomes []VoteOutcome) OutcomeSummary {
+	var s OutcomeSummary
+	for _, o := range outcomes {
+		s.Complete++
+		if o.Result.Valid {
+			s.Accepted++
+		}
+		s.AverageTurnout = s.AverageTurnout.Add(o.Result.Turnout.UnweightedTurnout)
+		s.AverageWeightedTurnout = s.AverageWeightedTurnout.Add(o.Result.Turnout.WeightedTurnout)
+	}
+
+	if s.Complete > 0 {
+		total := float64(s.Complete)
+		s.AcceptanceRate = float64(s.Accepted) / total
+		s.AverageTurnout = s.AverageTurnout.Quo(common.NewDecimal(int64(s.Complete)))
+		s.AverageWeightedTurnout = s.AverageWeightedTurnout.Quo(common.NewDecimal(int64(s.Complete)))
+	}
+	return s
+}
@@ -0,0 +1,478 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// Limits protect the database from expensive queries and busy clients. A zero
+// value disables that limit.
+type Limits struct {
+	// MaxDepth is the deepest a query can nest selections
+	MaxDepth int
+	// MaxCost is the most a query can cost, see Check
+	MaxCost int
+	// MaxLimit is the largest 'limit', 'first' or 'last' a list can be asked for.
+	// It is also the size assumed of lists that are not limited.
+	MaxLimit int
+	// Timeout cancels the request, and the sql it is running
+	Timeout time.Duration
+
+	// Rate is the requests per second each client can make, with bursts of up to Burst
+	Rate  float64
+	Burst int
+	// APIKeys are the keys clients may send in the X-API-Key header to be
+	// limited on their own, rather than by ip
+	APIKeys []string
+
+	// FieldCosts override the default weights of fields, by field name
+	FieldCosts map[string]int
+}
+
+// defaultListSize is assumed of lists that are not limited, if there is no MaxLimit
+const defaultListSize = 100
+
+// defaultFieldCosts are the weights of the fields that cost more than a row.
+// Object fields default to 1, and scalars to 0.
+var defaultFieldCosts = map[string]int{
+	"allProposals":         5,
+	"searchProposals":      10,
+	"voter":                20,
+	"voteInitiator":        20,
+	"proposalEntries":      20,
+	"eligibleVoters":       10,
+	"commits":              5,
+	"reveals":              5,
+	"results":              5,
+	"recount":              20,
+	"identityKeysAtHeight": 10,
+	"factomdProperties":    5,
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDepth: 10,
+		MaxCost:  50000,
+		MaxLimit: 1000,
+		Timeout:  30 * time.Second,
+		Rate:     10,
+		Burst:    30,
+	}
+}
+
+// QueryError is a request rejected by the limits. Reason is the limit hit.
+type QueryError struct {
+	Reason  string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+// Check parses the query and rejects it if it is too deep, costs too much, or
+// asks for too large a page. A field costs its weight plus the cost of its
+// selections, times the number of items it can return. Lists that are not
+// limited are assumed to be MaxLimit long. Queries that do not parse are let
+// through, so the executor reports the syntax error.
+func (l *Limits) Check(schema graphql.Schema, query string, variables map[string]interface{}, operationName string) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	a := &queryAnalysis{limits: l, schema: schema, variables: variables, fragments: make(map[string]*ast.FragmentDefinition)}
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if op == nil && (operationName == "" || (def.Name != nil && def.Name.Value == operationName)) {
+				op = def
+			}
+		}
+	}
+	if op == nil {
+		return nil
+	}
+
+	root := schema.QueryType()
+	if op.Operation == ast.OperationTypeSubscription {
+		root = schema.SubscriptionType()
+	}
+
+	cost, depth, err := a.selections(op.SelectionSet, root, 1, false, nil)
+	if err != nil {
+		return err
+	}
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return &QueryError{"depth", fmt.Sprintf("query depth %d is more than the maximum of %d", depth, l.MaxDepth)}
+	}
+	if l.MaxCost > 0 && cost > l.MaxCost {
+		return &QueryError{"cost", fmt.Sprintf("query cost %d is more than the maximum of %d", cost, l.MaxCost)}
+	}
+	return nil
+}
+
+// fieldCost is the weight of a single item of the field
+func (l *Limits) fieldCost(name string, leaf bool) int {
+	if c, ok := l.FieldCosts[name]; ok {
+		return c
+	}
+	if c, ok := defaultFieldCosts[name]; ok {
+		return c
+	}
+	if leaf {
+		return 0
+	}
+	return 1
+}
+
+func (l *Limits) listSize() int {
+	if l.MaxLimit > 0 {
+		return l.MaxLimit
+	}
+	return defaultListSize
+}
+
+type queryAnalysis struct {
+	limits    *Limits
+	schema    graphql.Schema
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// selections returns the cost and depth of a selection set. paged is set if
+// the parent field was limited, so its lists are already counted.
+func (a *queryAnalysis) selections(set *ast.SelectionSet, parent *graphql.Object, depth int, paged bool, seen []string) (cost, maxDepth int, err error) {
+	if set == nil || parent == nil {
+		return 0, depth - 1, nil
+	}
+	maxDepth = depth
+	add := func(c, d int) {
+		cost += c
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			c, d, err := a.field(sel, parent, depth, paged, seen)
+			if err != nil {
+				return 0, 0, err
+			}
+			add(c, d)
+		case *ast.InlineFragment:
+			t := parent
+			if sel.TypeCondition != nil {
+				t, _ = a.schema.Type(sel.TypeCondition.Name.Value).(*graphql.Object)
+			}
+			c, d, err := a.selections(sel.SelectionSet, t, depth, paged, seen)
+			if err != nil {
+				return 0, 0, err
+			}
+			add(c, d)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			frag, ok := a.fragments[name]
+			if !ok || contains(seen, name) {
+				// The executor rejects unknown and cyclic fragments
+				continue
+			}
+			t, _ := a.schema.Type(frag.TypeCondition.Name.Value).(*graphql.Object)
+			c, d, err := a.selections(frag.SelectionSet, t, depth, paged, append(seen, name))
+			if err != nil {
+				return 0, 0, err
+			}
+			add(c, d)
+		}
+	}
+	return cost, maxDepth, nil
+}
+
+func (a *queryAnalysis) field(f *ast.Field, parent *graphql.Object, depth int, paged bool, seen []string) (int, int, error) {
+	name := f.Name.Value
+	if strings.HasPrefix(name, "__") {
+		// Introspection is cheap, and the playground needs it
+		return 0, depth, nil
+	}
+	def, ok := parent.Fields()[name]
+	if !ok {
+		return 0, depth, nil
+	}
+
+	size, limited, err := a.pageSize(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	t := def.Type
+	if nn, ok := t.(*graphql.NonNull); ok {
+		t = nn.OfType
+	}
+	_, isList := t.(*graphql.List)
+	multiplier := 1
+	switch {
+	case limited:
+		multiplier = size
+	case hasPageArgs(def):
+		multiplier = a.limits.listSize()
+	case isList && !paged:
+		multiplier = a.limits.listSize()
+	}
+
+	for {
+		if w, ok := t.(*graphql.List); ok {
+			t = w.OfType
+		} else if w, ok := t.(*graphql.NonNull); ok {
+			t = w.OfType
+		} else {
+			break
+		}
+	}
+	obj, _ := t.(*graphql.Object)
+	if obj == nil || f.SelectionSet == nil {
+		return multiplier * a.limits.fieldCost(name, true), depth, nil
+	}
+
+	children, d, err := a.selections(f.SelectionSet, obj, depth+1, limited || hasPageArgs(def), seen)
+	if err != nil {
+		return 0, 0, err
+	}
+	return multiplier * (a.limits.fieldCost(name, false) + children), d, nil
+}
+
+// pageSize returns the largest of the 'limit', 'first' and 'last' arguments
+func (a *queryAnalysis) pageSize(f *ast.Field) (int, bool, error) {
+	size, limited := 0, false
+	for _, arg := range f.Arguments {
+		switch arg.Name.Value {
+		case "limit", "first", "last":
+		default:
+			continue
+		}
+		// A limit of 0 is no limit
+		n, ok := a.intValue(arg.Value)
+		if !ok || n <= 0 {
+			continue
+		}
+		if max := a.limits.MaxLimit; max > 0 && n > max {
+			return 0, false, &QueryError{"limit", fmt.Sprintf("'%s' of %d on %s is more than the maximum of %d", arg.Name.Value, n, f.Name.Value, max)}
+		}
+		if n > size {
+			size = n
+		}
+		limited = true
+	}
+	return size, limited, nil
+}
+
+func (a *queryAnalysis) intValue(v ast.Value) (int, bool) {
+	switch v := v.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(v.Value)
+		return n, err == nil
+	case *ast.Variable:
+		switch n := a.variables[v.Name.Value].(type) {
+		case float64:
+			return int(n), true
+		case int:
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func hasPageArgs(def *graphql.FieldDefinition) bool {
+	for _, arg := range def.Args {
+		switch arg.Name() {
+		case "limit", "first", "last":
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// maxRequestSize is the largest request body read
+const maxRequestSize = 1 << 20
+
+// limitRequests applies the limits to the graphql handler. Rejected requests
+// get a graphql error response, so clients can handle them like any other.
+func (s *GraphQLServer) limitRequests(schema graphql.Schema, h http.Handler) http.Handler {
+	return s.limitClients(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Subscriptions are checked as they start, see wsConnection, and are
+		// not timed out
+		if websocket.IsWebSocketUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		opts, err := readRequestOptions(r)
+		if err != nil {
+			writeLimitError(w, http.StatusBadRequest, err)
+			return
+		}
+		if opts.Query != "" {
+			if err := s.Limits.Check(schema, opts.Query, opts.Variables, opts.OperationName); err != nil {
+				writeLimitError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		s.withTimeout(h).ServeHTTP(w, r)
+	}))
+}
+
+// limitClients rate limits each client. The graphql and rest apis share the limiter.
+func (s *GraphQLServer) limitClients(h http.Handler) http.Handler {
+	s.limiterOnce.Do(func() {
+		if s.Limits.Rate > 0 {
+			s.limiter = newRateLimiter(s.Limits.Rate, s.Limits.Burst)
+		}
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := s.Limits.client(r)
+		if err != nil {
+			writeLimitError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		if s.limiter != nil {
+			if ok, wait := s.limiter.allow(client, time.Now()); !ok {
+				secs := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(secs))
+				writeLimitError(w, http.StatusTooManyRequests,
+					&QueryError{"rate", fmt.Sprintf("rate limit exceeded, retry in %ds", secs)})
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// withTimeout cancels the request, and any sql it runs, after the timeout
+func (s *GraphQLServer) withTimeout(h http.Handler) http.Handler {
+	if s.Limits.Timeout <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.Limits.Timeout)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// client identifies who is making the request, by api key if one is given, else by ip
+func (l *Limits) client(r *http.Request) (string, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		if !contains(l.APIKeys, key) {
+			return "", &QueryError{"key", "unknown api key"}
+		}
+		return "key:" + key, nil
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, nil
+}
+
+type requestOptions struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// readRequestOptions reads the query the same way the graphql handler will.
+// The body is put back for the handler to read again.
+func readRequestOptions(r *http.Request) (*requestOptions, error) {
+	opts := new(requestOptions)
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		opts.Query = q.Get("query")
+		opts.OperationName = q.Get("operationName")
+		json.Unmarshal([]byte(q.Get("variables")), &opts.Variables)
+		return opts, nil
+	}
+	if r.Body == nil {
+		return opts, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxRequestSize {
+		return nil, &QueryError{"size", fmt.Sprintf("request body is more than %d bytes", maxRequestSize)}
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	contentType := strings.Split(r.Header.Get("Content-Type"), ";")[0]
+	switch contentType {
+	case "application/graphql":
+		opts.Query = string(body)
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return opts, nil
+		}
+		opts.Query = form.Get("query")
+		opts.OperationName = form.Get("operationName")
+		json.Unmarshal([]byte(form.Get("variables")), &opts.Variables)
+	default:
+		// Bad json is left for the handler to report
+		json.Unmarshal(body, opts)
+	}
+	return opts, nil
+}
+
+func writeLimitError(w http.ResponseWriter, status int, err error) {
+	reason := "other"
+	if qerr, ok := err.(*QueryError); ok {
+		reason = qerr.Reason
+	}
+	RejectedRequests.WithLabelValues(reason).Inc()
+	writeError(w, status, err)
+}
+
+// writeError writes the error in the graphql response format
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,51 @@
+package apiserver_test
+
+import (
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/api-server"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	schema, err := new(GraphQLServer).CreateSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := DefaultLimits()
+
+	type Query struct {
+		Query     string
+		Variables map[string]interface{}
+		Reason    string
+	}
+	queries := []Query{
+		{`{ allProposals(limit: 10) { voteList { voteChainId admin { voteInitiator } } } }`, nil, ""},
+		{`{ proposalEntries(chain: "a") { voterId commitEntry { entryhash } } }`, nil, ""},
+		{`{ __schema { types { fields { type { ofType { ofType { ofType { ofType { ofType { ofType { name } } } } } } } } } } }`, nil, ""},
+		{`{ commits(voteChain: "a", limit: 1001) { commits { voterId } } }`, nil, "limit"},
+		{`query($n: Int) { commits(voteChain: "a", first: $n) { edges { cursor } } }`, map[string]interface{}{"n": float64(5000)}, "limit"},
+		{`{ allProposals(limit: 1000) { voteList { eligibleVoters { voterId } } } }`, nil, "cost"},
+		{`{ allProposals(limit: 1) { voteList { eligibleVoters { voterId } } } }`, nil, ""},
+		{`{ commits(voteChain: "a") { commits { reveal { commit { reveal { commit { reveal { commit { reveal { commit { reveal { entryhash } } } } } } } } } } } }`, nil, "depth"},
+		{`{ commits(voteChain: "a") { commits { ...c } } } fragment c on VoteCommit { reveal { commit { reveal { commit { reveal { commit { reveal { commit { entryhash } } } } } } } } }`, nil, "depth"},
+		{`{ not valid`, nil, ""},
+	}
+
+	for _, q := range queries {
+		err := limits.Check(schema, q.Query, q.Variables, "")
+		if q.Reason == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", q.Query, err)
+			}
+			continue
+		}
+		qerr, ok := err.(*QueryError)
+		if !ok {
+			t.Errorf("%s: exp %s error, got %v", q.Query, q.Reason, err)
+			continue
+		}
+		if qerr.Reason != q.Reason {
+			t.Errorf("%s: exp %s error, got %s: %s", q.Query, q.Reason, qerr.Reason, qerr.Message)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/Emyrk/go-factom-vote/blocktime"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/graphql-go/graphql"
+)
+
+// Nested fields, like the commit of every entry in a proposal, would cost a
+// sql query per row. Instead they are resolved with thunks through per request
+// loaders. graphql-go runs every resolver at a depth before the first thunk, so
+// all the keys at that depth are known and fetched with one query.
+
+// VoterKey identifies a voter in a vote
+type VoterKey struct {
+	VoteChain string
+	VoterID   string
+}
+
+// EligibleKey identifies the eligible voters of a list at a height
+type EligibleKey struct {
+	EligibleList string
+	BlockHeight  int
+}
+
+// BatchFetcher fetches many keys in one query. Keys not found are left out of
+// the returned maps.
+type BatchFetcher interface {
+	FetchCommitsByVoter(ctx context.Context, keys []VoterKey) (map[VoterKey]VoteCommit, error)
+	FetchRevealsByVoter(ctx context.Context, keys []VoterKey) (map[VoterKey]VoteReveal, error)
+	FetchVoteStatsByChain(ctx context.Context, chains []string) (map[string]common.VoteStats, error)
+	FetchEligibleVotersAt(ctx context.Context, keys []EligibleKey) (map[EligibleKey][]EligibleVoter, error)
+	FetchBlockTimes(ctx context.Context, heights []int) (map[int]blocktime.Estimate, error)
+}
+
+type loadResult struct {
+	value interface{}
+	err   error
+	done  bool
+}
+
+// batchLoader queues keys and fetches every queued key the first time any of
+// them is needed. Results are cached for the life of the loader.
+type batchLoader struct {
+	fetch func(keys []interface{}) (map[interface{}]interface{}, error)
+
+	sync.Mutex
+	cache   map[interface{}]*loadResult
+	pending []interface{}
+}
+
+func newBatchLoader(fetch func(keys []interface{}) (map[interface{}]interface{}, error)) *batchLoader {
+	l := new(batchLoader)
+	l.fetch = fetch
+	l.cache = make(map[interface{}]*loadResult)
+	return l
+}
+
+// Load queues the key and returns a thunk for its value
+func (l *batchLoader) Load(key interface{}) func() (interface{}, error) {
+	l.Lock()
+	res, ok := l.cache[key]
+	if !ok {
+		res = new(loadResult)
+		l.cache[key] = res
+		l.pending = append(l.pending, key)
+	}
+	l.Unlock()
+
+	return func() (interface{}, error) {
+		l.Lock()
+		defer l.Unlock()
+		if !res.done {
+			l.dispatch()
+		}
+		return res.value, res.err
+	}
+}
+
+// dispatch fetches the pending keys. Must be called with the lock held.
+func (l *batchLoader) dispatch() {
+	keys := l.pending
+	l.pending = nil
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := l.fetch(keys)
+	for _, k := range keys {
+		res := l.cache[k]
+		res.value, res.err, res.done = values[k], err, true
+	}
+}
+
+// Loaders are the batch loaders of a single request
+type Loaders struct {
+	Commits        *batchLoader
+	Reveals        *batchLoader
+	Results        *batchLoader
+	EligibleVoters *batchLoader
+	BlockTimes     *batchLoader
+}
+
+// NewLoaders creates the loaders for a request. Queries are cancelled with ctx.
+func NewLoaders(ctx context.Context, f BatchFetcher) *Loaders {
+	l := new(Loaders)
+	l.Commits = newBatchLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		voters := make([]VoterKey, len(keys))
+		for i, k := range keys {
+			voters[i] = k.(VoterKey)
+		}
+		found, err := f.FetchCommitsByVoter(ctx, voters)
+		values := make(map[interface{}]interface{}, len(found))
+		for k, v := range found {
+			values[k] = v
+		}
+		return values, err
+	})
+	l.Reveals = newBatchLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		voters := make([]VoterKey, len(keys))
+		for i, k := range keys {
+			voters[i] = k.(VoterKey)
+		}
+		found, err := f.FetchRevealsByVoter(ctx, voters)
+		values := make(map[interface{}]interface{}, len(found))
+		for k, v := range found {
+			values[k] = v
+		}
+		return values, err
+	})
+	l.Results = newBatchLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		chains := make([]string, len(keys))
+		for i, k := range keys {
+			chains[i] = k.(string)
+		}
+		found, err := f.FetchVoteStatsByChain(ctx, chains)
+		values := make(map[interface{}]interface{}, len(found))
+		for k, v := range found {
+			values[k] = v
+		}
+		return values, err
+	})
+	l.EligibleVoters = newBatchLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		lists := make([]EligibleKey, len(keys))
+		for i, k := range keys {
+			lists[i] = k.(EligibleKey)
+		}
+		found, err := f.FetchEligibleVotersAt(ctx, lists)
+		values := make(map[interface{}]interface{}, len(found))
+		for k, v := range found {
+			values[k] = v
+		}
+		return values, err
+	})
+	l.BlockTimes = newBatchLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		heights := make([]int, len(keys))
+		for i, k := range keys {
+			heights[i] = k.(int)
+		}
+		found, err := f.FetchBlockTimes(ctx, heights)
+		values := make(map[interface{}]interface{}, len(found))
+		for k, v := range found {
+			values[k] = v
+		}
+		return values, err
+	})
+	return l
+}
+
+type loadersKey struct{}
+type fetcherKey struct{}
+
+// WithLoaders returns a context carrying the loaders of one request
+func WithLoaders(ctx context.Context, l *Loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+// WithFetcher returns a context where every resolve gets loaders of its own.
+// Subscriptions use it, as a cache must not outlive the event it was made for.
+func WithFetcher(ctx context.Context, f BatchFetcher) context.Context {
+	return context.WithValue(ctx, fetcherKey{}, f)
+}
+
+// withRequestLoaders gives every http request a fresh set of loaders, so
+// nothing is cached between requests.
+func (s *GraphQLServer) withRequestLoaders(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(WithLoaders(r.Context(), NewLoaders(r.Context(), &s.SQLDB))))
+	})
+}
+
+// requestLoaders finds the loaders of the request in the resolve context
+func requestLoaders(p graphql.ResolveParams) (*Loaders, error) {
+	if l, ok := p.Context.Value(loadersKey{}).(*Loaders); ok {
+		return l, nil
+	}
+	if f, ok := p.Context.Value(fetcherKey{}).(BatchFetcher); ok {
+		return NewLoaders(p.Context, f), nil
+	}
+	return nil, fmt.Errorf("no loaders in the request context")
+}
+
+func init() {
+	ProposalEntryGraphQLType.AddFieldConfig("commitEntry", &graphql.Field{
+		Type:        VoteCommitGraphQLType,
+		Description: "The voter's commit (if exists)",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			ent, ok := p.Source.(ProposalEntry)
+			if !ok {
+				return nil, fmt.Errorf("Incorrect type supplied")
+			}
+			return load(p, func(l *Loaders) *batchLoader { return l.Commits }, VoterKey{ent.VoteChain, ent.VoterId})
+		},
+	})
+	ProposalEntryGraphQLType.AddFieldConfig("revealEntry", &graphql.Field{
+		Type:        VoteRevealGraphQLType,
+		Description: "The voter's reveal (if exists)",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			ent, ok := p.Source.(ProposalEntry)
+			if !ok {
+				return nil, fmt.Errorf("Incorrect type supplied")
+			}
+			return load(p, func(l *Loaders) *batchLoader { return l.Reveals }, VoterKey{ent.VoteChain, ent.VoterId})
+		},
+	})
+
+	VoteCommitGraphQLType.AddFieldConfig("reveal", &graphql.Field{
+		Type:        VoteRevealGraphQLType,
+		Description: "The reveal of this commit (if exists)",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			var c VoteCommit
+			switch src := p.Source.(type) {
+			case VoteCommit:
+				c = src
+			case *VoteCommit:
+				c = *src
+			default:
+				return nil, fmt.Errorf("Incorrect type supplied")
+			}
+			return load(p, func(l *Loaders) *batchLoader { return l.Reveals }, VoterKey{c.VoteChain, c.VoterID})
+		},
+	})
+	VoteRevealGraphQLType.AddFieldConfig("commit", &graphql.Field{
+		Type:        VoteCommitGraphQLType,
+		Description: "The commit this reveal opens",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			var r VoteReveal
+			switch src := p.Source.(type) {
+			case VoteReveal:
+				r = src
+			case *VoteReveal:
+				r = *src
+			default:
+				return nil, fmt.Errorf("Incorrect type supplied")
+			}
+			return load(p, func(l *Loaders) *batchLoader { return l.Commits }, VoterKey{r.VoteChain, r.VoterID})
+		},
+	})
+
+	VoteGraphQLType.AddFieldConfig("result", &graphql.Field{
+		Type:        VoteResultsGraphQLType,
+		Description: "Results of the vote (once complete)",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			v, err := voteSource(p)
+			if err != nil {
+				return nil, err
+			}
+			return load(p, func(l *Loaders) *batchLoader { return l.Results }, v.Chainid)
+		},
+	})
+	VoteGraphQLType.AddFieldConfig("eligibleVoters", &graphql.Field{
+		Type:        graphql.NewList(ELVoter),
+		Description: "Voters eligible for the vote, as of the start of the commit phase",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			v, err := voteSource(p)
+			if err != nil {
+				return nil, err
+			}
+			key := EligibleKey{v.Definition.EligibleVoterChain, v.Definition.PhasesBlockHeights.CommitStart}
+			return load(p, func(l *Loaders) *batchLoader { return l.EligibleVoters }, key)
+		},
+	})
+}
+
+// load queues the key on one of the request's loaders
+func load(p graphql.ResolveParams, loader func(l *Loaders) *batchLoader, key interface{}) (interface{}, error) {
+	l, err := requestLoaders(p)
+	if err != nil {
+		return nil, err
+	}
+	return loader(l).Load(key), nil
+}
+
+func voteSource(p graphql.ResolveParams) (*Vote, error) {
+	switch src := p.Source.(type) {
+	case Vote:
+		return &src, nil
+	case *Vote:
+		return src, nil
+	}
+	return nil, fmt.Errorf("Incorrect type supplied")
+}
@@ -0,0 +1,171 @@
+package apiserver_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Emyrk/go-factom-vote/blocktime"
+	. "github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/graphql-go/graphql"
+)
+
+// countingFetcher counts the queries a request makes
+type countingFetcher struct {
+	queries map[string]int
+}
+
+func (f *countingFetcher) FetchCommitsByVoter(ctx context.Context, keys []VoterKey) (map[VoterKey]VoteCommit, error) {
+	f.queries["commits"]++
+	m := make(map[VoterKey]VoteCommit)
+	for _, k := range keys {
+		m[k] = VoteCommit{VoterID: k.VoterID, VoteChain: k.VoteChain, EntryHash: "c" + k.VoterID}
+	}
+	return m, nil
+}
+
+func (f *countingFetcher) FetchRevealsByVoter(ctx context.Context, keys []VoterKey) (map[VoterKey]VoteReveal, error) {
+	f.queries["reveals"]++
+	m := make(map[VoterKey]VoteReveal)
+	for _, k := range keys {
+		m[k] = VoteReveal{VoterID: k.VoterID, VoteChain: k.VoteChain, EntryHash: "r" + k.VoterID}
+	}
+	return m, nil
+}
+
+func (f *countingFetcher) FetchVoteStatsByChain(ctx context.Context, chains []string) (map[string]common.VoteStats, error) {
+	f.queries["results"]++
+	m := make(map[string]common.VoteStats)
+	for _, c := range chains {
+		m[c] = common.VoteStats{VoteChain: c, Valid: true}
+	}
+	return m, nil
+}
+
+func (f *countingFetcher) FetchEligibleVotersAt(ctx context.Context, keys []EligibleKey) (map[EligibleKey][]EligibleVoter, error) {
+	f.queries["eligibleVoters"]++
+	m := make(map[EligibleKey][]EligibleVoter)
+	for _, k := range keys {
+		m[k] = []EligibleVoter{{VoterID: "voter", EligibleList: k.EligibleList, BlockHeight: k.BlockHeight}}
+	}
+	return m, nil
+}
+
+func (f *countingFetcher) FetchBlockTimes(ctx context.Context, heights []int) (map[int]blocktime.Estimate, error) {
+	f.queries["blockTimes"]++
+	m := make(map[int]blocktime.Estimate)
+	for _, h := range heights {
+		m[h] = blocktime.Estimate{BlockHeight: h}
+	}
+	return m, nil
+}
+
+func testSchema(t *testing.T, entries []ProposalEntry, votes []Vote) graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "RootQuery",
+			Fields: graphql.Fields{
+				"proposalEntries": &graphql.Field{
+					Type: graphql.NewList(ProposalEntryGraphQLType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return entries, nil
+					},
+				},
+				"allProposals": &graphql.Field{
+					Type: graphql.NewList(VoteGraphQLType),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return votes, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestLoaderQueryCount(t *testing.T) {
+	var entries []ProposalEntry
+	var votes []Vote
+	for i := 0; i < 100; i++ {
+		entries = append(entries, ProposalEntry{VoteChain: "chain", VoterId: fmt.Sprintf("voter%d", i)})
+
+		v := Vote{Chainid: fmt.Sprintf("chain%d", i)}
+		v.Definition.EligibleVoterChain = fmt.Sprintf("list%d", i%10)
+		v.Definition.PhasesBlockHeights.CommitStart = 100
+		votes = append(votes, v)
+	}
+	schema := testSchema(t, entries, votes)
+
+	type Query struct {
+		Query   string
+		Queries map[string]int
+	}
+	queries := []Query{
+		{`{ proposalEntries { voterId commitEntry { entryhash } revealEntry { entryhash } } }`,
+			map[string]int{"commits": 1, "reveals": 1}},
+		// The reveal of each commit is found one level deeper, in a second batch
+		{`{ proposalEntries { commitEntry { reveal { entryhash commit { entryhash } } } } }`,
+			map[string]int{"commits": 1, "reveals": 1}},
+		{`{ allProposals { voteChainId result { valid } eligibleVoters { voterId } } }`,
+			map[string]int{"results": 1, "eligibleVoters": 1}},
+		{`{ a: allProposals { result { valid } } b: allProposals { result { chainId } } }`,
+			map[string]int{"results": 1}},
+		{`{ allProposals { schedule { commit { start { time } end { time observed } } } } }`,
+			map[string]int{"blockTimes": 1}},
+	}
+
+	for _, q := range queries {
+		f := &countingFetcher{queries: make(map[string]int)}
+		res := graphql.Do(graphql.Params{
+			Schema:        schema,
+			RequestString: q.Query,
+			Context:       WithLoaders(context.Background(), NewLoaders(context.Background(), f)),
+		})
+		if res.HasErrors() {
+			t.Errorf("%s: %v", q.Query, res.Errors)
+			continue
+		}
+		if len(f.queries) != len(q.Queries) {
+			t.Errorf("%s: exp queries %v, got %v", q.Query, q.Queries, f.queries)
+			continue
+		}
+		for k, n := range q.Queries {
+			if f.queries[k] != n {
+				t.Errorf("%s: exp %d %s queries, got %d", q.Query, n, k, f.queries[k])
+			}
+		}
+	}
+}
+
+func TestLoaderResults(t *testing.T) {
+	entries := []ProposalEntry{{VoteChain: "chain", VoterId: "a"}, {VoteChain: "chain", VoterId: "b"}}
+	schema := testSchema(t, entries, nil)
+
+	f := &countingFetcher{queries: make(map[string]int)}
+	res := graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: `{ proposalEntries { voterId commitEntry { entryhash reveal { entryhash } } } }`,
+		Context:       WithLoaders(context.Background(), NewLoaders(context.Background(), f)),
+	})
+	if res.HasErrors() {
+		t.Fatal(res.Errors)
+	}
+
+	list := res.Data.(map[string]interface{})["proposalEntries"].([]interface{})
+	for _, e := range list {
+		entry := e.(map[string]interface{})
+		voter := entry["voterId"].(string)
+		commit := entry["commitEntry"].(map[string]interface{})
+		if commit["entryhash"] != "c"+voter {
+			t.Errorf("voter %s got commit %v", voter, commit["entryhash"])
+		}
+		reveal := commit["reveal"].(map[string]interface{})
+		if reveal["entryhash"] != "r"+voter {
+			t.Errorf("voter %s got reveal %v", voter, reveal["entryhash"])
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/vote/database"
+	"github.com/graphql-go/graphql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ResolverDuration tracks how long each root graphql field takes to resolve
+	ResolverDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "factom_vote_graphql_resolver_duration_seconds",
+		Help:    "Latency of graphql root field resolvers, by field",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"field"})
+
+	// ResolverErrors counts the root graphql field resolvers that returned an error
+	ResolverErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factom_vote_graphql_resolver_errors_total",
+		Help: "Graphql root field resolvers that returned an error, by field",
+	}, []string{"field"})
+
+	// RejectedRequests counts the requests refused by the limits
+	RejectedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factom_vote_graphql_rejected_requests_total",
+		Help: "Graphql requests refused by the api limits, by reason (rate, depth, cost, limit, key)",
+	}, []string{"reason"})
+
+	registerOnce sync.Once
+)
+
+// RegisterPrometheus registers the apiserver and database metrics. It is safe to call more than once.
+func RegisterPrometheus() {
+	registerOnce.Do(func() {
+		prometheus.MustRegister(ResolverDuration)
+		prometheus.MustRegister(ResolverErrors)
+		prometheus.MustRegister(RejectedRequests)
+	})
+	database.RegisterPrometheus()
+}
+
+// instrumentFields wraps the resolver of every field to record its latency and errors
+func instrumentFields(fields graphql.Fields) graphql.Fields {
+	for name, field := range fields {
+		if field.Resolve == nil {
+			continue
+		}
+		field.Resolve = instrumentResolver(name, field.Resolve)
+	}
+	return fields
+}
+
+func instrumentResolver(name string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		start := time.Now()
+		res, err := resolve(p)
+		if err != nil && p.Context != nil && p.Context.Err() == context.DeadlineExceeded {
+			// The sql error of a cancelled query says nothing of why
+			err = fmt.Errorf("request timed out")
+		}
+		ResolverDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			ResolverErrors.WithLabelValues(name).Inc()
+		}
+		return res, err
+	}
+}
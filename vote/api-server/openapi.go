@@ -0,0 +1,178 @@
+package apiserver
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// The openapi document is generated from the rest routes, and the schemas of
+// the responses from the json tags of the go types, so it cannot drift from
+// what is served.
+
+var nullStringType = reflect.TypeOf(sql.NullString{})
+var timeType = reflect.TypeOf(time.Time{})
+
+func openAPIDocument(routes []restRoute) map[string]interface{} {
+	g := &schemaGenerator{schemas: make(map[string]interface{})}
+	errorResponse := map[string]interface{}{
+		"description": "Graphql style error list",
+		"content":     jsonContent(g.schema(reflect.TypeOf(restErrors{}))),
+	}
+
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		var params []interface{}
+		for _, p := range pathParams(route.Path) {
+			params = append(params, map[string]interface{}{
+				"name":     p,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, p := range route.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          "query",
+				"description": p.Description,
+				"schema":      map[string]interface{}{"type": p.Type},
+			})
+		}
+
+		var content map[string]interface{}
+		if raw, ok := route.Response.(rawResponse); ok {
+			content = map[string]interface{}{
+				raw.ContentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		} else {
+			content = jsonContent(g.schema(reflect.TypeOf(route.Response)))
+		}
+
+		op := map[string]interface{}{
+			"summary": route.Summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": route.Summary,
+					"content":     content,
+				},
+				"400":     errorResponse,
+				"404":     errorResponse,
+				"429":     errorResponse,
+				"default": errorResponse,
+			},
+		}
+		if route.Description != "" {
+			op["description"] = route.Description
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		paths[route.Path] = map[string]interface{}{"get": op}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":       "Factom vote api",
+			"description": "Read only rest view of the factom vote graphql api",
+			"version":     "1",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+		},
+	}
+}
+
+// restErrors documents the error response written by writeError
+type restErrors struct {
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func pathParams(path string) []string {
+	var params []string
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			params = append(params, strings.Trim(seg, "{}"))
+		}
+	}
+	return params
+}
+
+// schemaGenerator builds json schemas from go types. Named structs are added
+// to the components, and referenced.
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nullStringType {
+		return map[string]interface{}{"type": "string", "nullable": true}
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// Reserved first, so recursive types terminate
+			g.schemas[t.Name()] = nil
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	// interface{}, anything goes
+	return map[string]interface{}{}
+}
+
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName := strings.TrimSpace(strings.Split(tag, ",")[0])
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		props[name] = g.schema(f.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
@@ -0,0 +1,236 @@
+package apiserver
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Relay style cursor pagination. Every paginated list is ordered by
+// (block_height, entry_hash), which is stable as new rows are added, and a
+// cursor is an opaque encoding of that pair.
+// https://facebook.github.io/relay/graphql/connections.htm
+
+type Cursor struct {
+	BlockHeight int
+	EntryHash   string
+}
+
+func NewCursor(blockHeight int, entryHash string) Cursor {
+	return Cursor{BlockHeight: blockHeight, EntryHash: strings.TrimSpace(entryHash)}
+}
+
+func (c Cursor) String() string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", c.BlockHeight, c.EntryHash)))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, argumentErrorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return nil, argumentErrorf("invalid cursor")
+	}
+	height, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, argumentErrorf("invalid cursor")
+	}
+	return &Cursor{BlockHeight: height, EntryHash: parts[1]}, nil
+}
+
+type PageInfo struct {
+	HasNextPage     bool   `json:"hasNextPage"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
+}
+
+type Edge struct {
+	Cursor string      `json:"cursor"`
+	Node   interface{} `json:"node"`
+}
+
+// PageArgs are the relay arguments of a list query
+type PageArgs struct {
+	First  int
+	Last   int
+	After  *Cursor
+	Before *Cursor
+}
+
+// pageArgs are added to every paginated root field
+var pageArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: "Return the first n items, after the 'after' cursor if given",
+	},
+	"after": &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "Cursor of the item to start after",
+	},
+	"last": &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: "Return the last n items, before the 'before' cursor if given",
+	},
+	"before": &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "Cursor of the item to end before",
+	},
+}
+
+// withPageArgs adds the relay arguments to the field arguments
+func withPageArgs(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	for k, v := range pageArgs {
+		args[k] = v
+	}
+	return args
+}
+
+// ParsePageArgs returns nil if no relay arguments are given, in which case the
+// list falls back to offset/limit.
+func ParsePageArgs(args map[string]interface{}) (*PageArgs, error) {
+	p := new(PageArgs)
+	first, hasFirst := args["first"].(int)
+	last, hasLast := args["last"].(int)
+	after, hasAfter := args["after"].(string)
+	before, hasBefore := args["before"].(string)
+	if !hasFirst && !hasLast && !hasAfter && !hasBefore {
+		return nil, nil
+	}
+
+	if _, ok := args["offset"].(int); ok {
+		return nil, argumentErrorf("'offset' cannot be combined with cursor pagination")
+	}
+	if hasFirst && hasLast {
+		return nil, argumentErrorf("'first' and 'last' cannot be used together")
+	}
+	if first < 0 || last < 0 {
+		return nil, argumentErrorf("'first' and 'last' cannot be negative")
+	}
+	p.First, p.Last = first, last
+
+	var err error
+	if hasAfter {
+		if p.After, err = DecodeCursor(after); err != nil {
+			return nil, err
+		}
+	}
+	if hasBefore {
+		if p.Before, err = DecodeCursor(before); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Wrap pages an inner query. The inner query must select block_height and
+// entry_hash columns, and any window functions (the full_count) in it still
+// count every row, not just the page. One extra row is fetched past the page
+// size, so the caller can tell if there is another page; see Trim.
+func (p *PageArgs) Wrap(inner string, args []interface{}) (string, []interface{}) {
+	var where []string
+	if p.After != nil {
+		where = append(where, fmt.Sprintf("(block_height, entry_hash) > ($%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, p.After.BlockHeight, p.After.EntryHash)
+	}
+	if p.Before != nil {
+		where = append(where, fmt.Sprintf("(block_height, entry_hash) < ($%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, p.Before.BlockHeight, p.Before.EntryHash)
+	}
+
+	query := fmt.Sprintf("SELECT * FROM (%s) AS page", inner)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	// 'last' reads backwards from the end, and Trim puts the page back in order
+	if p.Last > 0 {
+		query += fmt.Sprintf(" ORDER BY block_height DESC, entry_hash DESC LIMIT %d", p.Last+1)
+	} else {
+		query += " ORDER BY block_height ASC, entry_hash ASC"
+		if p.First > 0 {
+			query += fmt.Sprintf(" LIMIT %d", p.First+1)
+		}
+	}
+	return query, args
+}
+
+// Trim drops the extra row fetched by Wrap, puts the page in ascending order,
+// and returns the page info. n is the number of rows fetched, swap reorders two of them.
+// It returns the number of rows to keep.
+func (p *PageArgs) Trim(n int, swap func(i, j int)) (int, PageInfo) {
+	var info PageInfo
+	size := p.First
+	if p.Last > 0 {
+		size = p.Last
+	}
+	extra := size > 0 && n > size
+	if extra {
+		n = size
+	}
+
+	if p.Last > 0 {
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+		info.HasPreviousPage = extra
+		info.HasNextPage = p.Before != nil
+	} else {
+		info.HasNextPage = extra
+		info.HasPreviousPage = p.After != nil
+	}
+	return n, info
+}
+
+// offsetPageInfo is the page info of an offset/limit list
+func offsetPageInfo(offset, count, total int) PageInfo {
+	return PageInfo{
+		HasNextPage:     offset+count < total,
+		HasPreviousPage: offset > 0,
+	}
+}
+
+// setCursors fills in the start and end cursors from the edges
+func (info *PageInfo) setCursors(edges []Edge) {
+	if len(edges) > 0 {
+		info.StartCursor = edges[0].Cursor
+		info.EndCursor = edges[len(edges)-1].Cursor
+	}
+}
+
+var PageInfoGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "PageInfo",
+	Description: "Relay page information",
+	Fields: graphql.Fields{
+		"hasNextPage": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+		"hasPreviousPage": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+		"startCursor": &graphql.Field{
+			Type: graphql.String,
+		},
+		"endCursor": &graphql.Field{
+			Type: graphql.String,
+		},
+	}})
+
+// EdgeGraphQLType is the relay edge for a node type
+func EdgeGraphQLType(name string, node graphql.Output) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: name + "Edge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{
+				Type: graphql.String,
+			},
+			"node": &graphql.Field{
+				Type: node,
+			},
+		}})
+}
@@ -0,0 +1,80 @@
+package apiserver
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle clients are forgotten
+const sweepInterval = time.Minute
+
+// rateLimiter is a token bucket per client. Each bucket holds up to burst
+// tokens, refilled at rate per second, and every request takes one.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	r := new(rateLimiter)
+	r.rate = rate
+	r.burst = float64(burst)
+	if r.burst < 1 {
+		r.burst = 1
+	}
+	r.buckets = make(map[string]*bucket)
+	return r
+}
+
+// allow takes a token from the client's bucket. If it is empty, it returns
+// how long until the next token.
+func (r *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	if now.Sub(r.lastSweep) > sweepInterval {
+		r.sweep(now)
+	}
+
+	b, ok := r.buckets[client]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[client] = b
+	}
+	b.refill(now, r.rate, r.burst)
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / r.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+func (b *bucket) refill(now time.Time, rate, burst float64) {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+}
+
+// sweep drops the buckets that have refilled, as a new bucket starts full anyway
+func (r *rateLimiter) sweep(now time.Time) {
+	for client, b := range r.buckets {
+		b.refill(now, r.rate, r.burst)
+		if b.tokens >= r.burst {
+			delete(r.buckets, client)
+		}
+	}
+	r.lastSweep = now
+}
@@ -0,0 +1,191 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/graphql-go/graphql"
+)
+
+// A receipt is the proof a voter's commit and reveal are in the chain. The
+// blocks are read from factomd when asked for, and the receipt can be checked
+// offline with `go-factom-vote verify-receipt`.
+
+// BlockFetcher reads the blocks receipts are built from. The scraper's
+// APIReader is one.
+type BlockFetcher interface {
+	FetchEntry(ctx context.Context, hash string) (interfaces.IEntry, error)
+	FetchEBlock(ctx context.Context, hash interfaces.IHash) (interfaces.IEntryBlock, error)
+	FetchDBlockByHeight(ctx context.Context, dBlockHeight uint32) (interfaces.IDirectoryBlock, error)
+}
+
+var MerkleNodeGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "MerkleNode",
+	Description: "A step of a merkle path, top is sha256(left || right)",
+	Fields: graphql.Fields{
+		"left": &graphql.Field{
+			Type: graphql.String,
+		},
+		"right": &graphql.Field{
+			Type: graphql.String,
+		},
+		"top": &graphql.Field{
+			Type: graphql.String,
+		},
+	}})
+
+var EntryReceiptGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "EntryReceipt",
+	Description: "Proof an entry is in a directory block",
+	Fields: graphql.Fields{
+		"entryHash": &graphql.Field{
+			Type: graphql.String,
+		},
+		"entry": &graphql.Field{
+			Description: "Hex of the entry, as it was hashed",
+			Type:        graphql.String,
+		},
+		"chainId": &graphql.Field{
+			Type: graphql.String,
+		},
+		"eblockKeyMR": &graphql.Field{
+			Type: graphql.String,
+		},
+		"eblockHeader": &graphql.Field{
+			Description: "Hex of the EBlock header",
+			Type:        graphql.String,
+		},
+		"entryPath": &graphql.Field{
+			Description: "Path from the entry hash to the EBlock body MR, then the EBlock KeyMR",
+			Type:        graphql.NewList(MerkleNodeGraphQLType),
+		},
+		"dblockHeight": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"dblockKeyMR": &graphql.Field{
+			Type: graphql.String,
+		},
+		"dblockHeader": &graphql.Field{
+			Description: "Hex of the DBlock header",
+			Type:        graphql.String,
+		},
+		"eblockPath": &graphql.Field{
+			Description: "Path from the chain id and EBlock KeyMR to the DBlock body MR, then the DBlock KeyMR",
+			Type:        graphql.NewList(MerkleNodeGraphQLType),
+		},
+	}})
+
+var VoterReceiptGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "VoterReceipt",
+	Description: "Proof the commit and reveal of a voter are in the chain",
+	Fields: graphql.Fields{
+		"voteChain": &graphql.Field{
+			Type: graphql.String,
+		},
+		"voterId": &graphql.Field{
+			Type: graphql.String,
+		},
+		"commit": &graphql.Field{
+			Description: "Null if the voter did not commit",
+			Type:        EntryReceiptGraphQLType,
+		},
+		"reveal": &graphql.Field{
+			Description: "Null if the voter did not reveal",
+			Type:        EntryReceiptGraphQLType,
+		},
+	}})
+
+func (s *GraphQLServer) receipt() *graphql.Field {
+	return &graphql.Field{
+		Type:        VoterReceiptGraphQLType,
+		Description: "Proof the commit and reveal of a voter are in the chain, checked offline with `go-factom-vote verify-receipt`",
+		Args: graphql.FieldConfigArgument{
+			"chain": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"voterId": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			chain, _ := p.Args["chain"].(string)
+			voterID, _ := p.Args["voterId"].(string)
+			return s.FetchReceipt(p.Context, chain, voterID)
+		},
+	}
+}
+
+// FetchReceipt builds the receipts of the commit and reveal of the voter
+func (s *GraphQLServer) FetchReceipt(ctx context.Context, chain, voterID string) (*common.VoterReceipt, error) {
+	if s.Blocks == nil {
+		return nil, fmt.Errorf("receipts are not available, there is no factomd to read blocks from")
+	}
+
+	r := new(common.VoterReceipt)
+	r.VoteChain = chain
+	r.VoterID = voterID
+
+	commit, err := s.SQLDB.FetchCommit(ctx, voterID, chain)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil, &NotFoundError{"commit"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Commit, err = EntryReceipt(ctx, s.Blocks, chain, commit.EntryHash, commit.BlockHeight); err != nil {
+		return nil, err
+	}
+
+	reveal, err := s.SQLDB.FetchReveal(ctx, voterID, chain)
+	if _, ok := err.(*NotFoundError); ok {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Reveal, err = EntryReceipt(ctx, s.Blocks, chain, reveal.EntryHash, reveal.BlockHeight); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// EntryReceipt builds the receipt of an entry of the chain in the DBlock at
+// the height
+func EntryReceipt(ctx context.Context, blocks BlockFetcher, chain, entryHash string, height int) (*common.EntryReceipt, error) {
+	chainID, err := primitives.HexToHash(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	dblock, err := blocks.FetchDBlockByHeight(ctx, uint32(height))
+	if err != nil {
+		return nil, fmt.Errorf("dblock %d: %s", height, err.Error())
+	}
+	var eblockKeyMR interfaces.IHash
+	for _, e := range dblock.GetDBEntries() {
+		if bytes.Equal(e.GetChainID().Bytes(), chainID.Bytes()) {
+			eblockKeyMR = e.GetKeyMR()
+		}
+	}
+	if eblockKeyMR == nil {
+		return nil, fmt.Errorf("chain %s has no eblock in dblock %d", chain, height)
+	}
+
+	eblock, err := blocks.FetchEBlock(ctx, eblockKeyMR)
+	if err != nil {
+		return nil, fmt.Errorf("eblock %s: %s", eblockKeyMR.String(), err.Error())
+	}
+	entry, err := blocks.FetchEntry(ctx, entryHash)
+	if err != nil {
+		return nil, fmt.Errorf("entry %s: %s", entryHash, err.Error())
+	}
+	data, err := entry.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return common.NewBlockEntryReceipt(data, eblock, dblock)
+}
@@ -0,0 +1,312 @@
+package apiserver
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/graphql-go/graphql"
+)
+
+// A recount runs the tally again, as of any block height and with the
+// criteria of the vote overridden, to answer what the result would have been.
+// It is never authoritative, the stored results are.
+
+const (
+	allEligibleVoters = "ALL_ELIGIBLE_VOTERS"
+	participantsOnly  = "PARTICIPANTS_ONLY"
+)
+
+// Recount is a result computed on request, rather than the one stored when
+// the vote completed
+type Recount struct {
+	VoteChain string `json:"voteChain"`
+	// BlockHeight is the height the commits and reveals are counted up to
+	BlockHeight int `json:"blockHeight"`
+	// EligibleHeight is the height the eligible voters are taken at
+	EligibleHeight int               `json:"eligibleHeight"`
+	Authoritative  bool              `json:"authoritative"`
+	Overrides      *RecountOverrides `json:"overrides,omitempty"`
+	Result         *common.VoteStats `json:"result"`
+}
+
+// RecountOverrides replace the criteria of the vote. Nil fields are left as
+// the vote defined them.
+type RecountOverrides struct {
+	ComputeResultsAgainst *string                           `json:"computeResultsAgainst,omitempty"`
+	MinTurnout            *common.CriteriaWeights           `json:"minTurnout,omitempty"`
+	MinSupport            map[string]common.CriteriaWeights `json:"minSupport,omitempty"`
+}
+
+var CriteriaWeightsInputGraphQLType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CriteriaWeightsInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"weighted": &graphql.InputObjectFieldConfig{
+			Description: "An exact decimal",
+			Type:        graphql.String,
+		},
+		"unweighted": &graphql.InputObjectFieldConfig{
+			Description: "An exact decimal",
+			Type:        graphql.String,
+		},
+	}})
+
+var OptionSupportInputGraphQLType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "OptionSupportInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"option": &graphql.InputObjectFieldConfig{
+			Description: "An option of the vote, or * for every option without its own",
+			Type:        graphql.NewNonNull(graphql.String),
+		},
+		"weighted": &graphql.InputObjectFieldConfig{
+			Description: "An exact decimal",
+			Type:        graphql.String,
+		},
+		"unweighted": &graphql.InputObjectFieldConfig{
+			Description: "An exact decimal",
+			Type:        graphql.String,
+		},
+	}})
+
+var RecountOverridesInputGraphQLType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "RecountOverrides",
+	Description: "Criteria to recount with instead of those of the vote",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"computeResultsAgainst": &graphql.InputObjectFieldConfig{
+			Description: "ALL_ELIGIBLE_VOTERS or PARTICIPANTS_ONLY",
+			Type:        graphql.String,
+		},
+		"minTurnout": &graphql.InputObjectFieldConfig{
+			Description: "Acceptance criteria turnout",
+			Type:        CriteriaWeightsInputGraphQLType,
+		},
+		"minSupport": &graphql.InputObjectFieldConfig{
+			Description: "Winner criteria support, replacing all of those of the vote",
+			Type:        graphql.NewList(OptionSupportInputGraphQLType),
+		},
+	}})
+
+var RecountGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Recount",
+	Description: "A result computed on request. It is not the authoritative result of the vote.",
+	Fields: graphql.Fields{
+		"voteChain": &graphql.Field{
+			Type: graphql.String,
+		},
+		"blockHeight": &graphql.Field{
+			Description: "Commits and reveals are counted up to and including this height",
+			Type:        graphql.Int,
+		},
+		"eligibleHeight": &graphql.Field{
+			Description: "Height the eligible voters are taken at, the start of the commit phase or the recount height if before it",
+			Type:        graphql.Int,
+		},
+		"authoritative": &graphql.Field{
+			Description: "Always false, the stored result of the vote is authoritative",
+			Type:        graphql.Boolean,
+		},
+		"overrides": &graphql.Field{
+			Description: "Criteria that were overridden",
+			Type:        JSON,
+		},
+		"result": &graphql.Field{
+			Type: VoteResultsGraphQLType,
+		},
+	}})
+
+func (s *GraphQLServer) recount() *graphql.Field {
+	return &graphql.Field{
+		Type:        RecountGraphQLType,
+		Description: "Compute the result of a vote as of a block height, optionally with other criteria. Not authoritative.",
+		Args: graphql.FieldConfigArgument{
+			"chain": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"atHeight": &graphql.ArgumentConfig{
+				Description: "Count the commits and reveals up to this height, defaults to the latest block",
+				Type:        graphql.Int,
+			},
+			"overrides": &graphql.ArgumentConfig{
+				Type: RecountOverridesInputGraphQLType,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			chain, _ := p.Args["chain"].(string)
+			height := -1
+			if h, ok := p.Args["atHeight"].(int); ok {
+				if h < 0 {
+					return nil, argumentErrorf("atHeight must not be negative")
+				}
+				height = h
+			}
+			overrides, err := recountOverridesArg(p.Args["overrides"])
+			if err != nil {
+				return nil, err
+			}
+			return s.SQLDB.FetchRecount(p.Context, chain, height, overrides)
+		},
+	}
+}
+
+func recountOverridesArg(arg interface{}) (*RecountOverrides, error) {
+	m, ok := arg.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	o := new(RecountOverrides)
+	if against, ok := m["computeResultsAgainst"].(string); ok {
+		if against != allEligibleVoters && against != participantsOnly {
+			return nil, argumentErrorf("'%s' is not a computeResultsAgainst value, expected %s or %s", against, allEligibleVoters, participantsOnly)
+		}
+		o.ComputeResultsAgainst = &against
+	}
+	if turnout, ok := m["minTurnout"].(map[string]interface{}); ok {
+		w, err := criteriaWeightsArg("minTurnout", turnout)
+		if err != nil {
+			return nil, err
+		}
+		o.MinTurnout = &w
+	}
+	if support, ok := m["minSupport"].([]interface{}); ok {
+		o.MinSupport = make(map[string]common.CriteriaWeights)
+		for _, s := range support {
+			s, _ := s.(map[string]interface{})
+			option, _ := s["option"].(string)
+			w, err := criteriaWeightsArg("minSupport", s)
+			if err != nil {
+				return nil, err
+			}
+			o.MinSupport[option] = w
+		}
+	}
+	return o, nil
+}
+
+// criteriaWeightsArg parses the weights, which are strings so they are exact.
+// Weights left out are 0.
+func criteriaWeightsArg(name string, m map[string]interface{}) (common.CriteriaWeights, error) {
+	var w common.CriteriaWeights
+	for key, d := range map[string]*common.Decimal{"weighted": &w.Weighted, "unweighted": &w.Unweighted} {
+		s, ok := m[key].(string)
+		if !ok {
+			continue
+		}
+		v, err := common.ParseDecimal(s)
+		if err != nil {
+			return w, argumentErrorf("%s %s: %s", name, key, err)
+		}
+		*d = v
+	}
+	return w, nil
+}
+
+// Apply replaces the criteria of the vote
+func (o *RecountOverrides) Apply(v *common.Vote) error {
+	if o == nil {
+		return nil
+	}
+
+	config := &v.Proposal.Vote.Config
+	if o.ComputeResultsAgainst != nil {
+		config.ComputeResultsAgainst = *o.ComputeResultsAgainst
+	}
+	if o.MinTurnout != nil {
+		config.AcceptanceCriteria.MinTurnout = *o.MinTurnout
+	}
+	if o.MinSupport != nil {
+		for option := range o.MinSupport {
+			if option != "*" && !contains(config.Options, option) {
+				return argumentErrorf("'%s' is not an option of the vote", option)
+			}
+		}
+		config.WinnerCriteria.MinSupport = o.MinSupport
+	}
+	return nil
+}
+
+// FetchRecount computes the result of the vote as of the height, or the
+// latest block if the height is negative
+func (g *GraphQLSQLDB) FetchRecount(ctx context.Context, chainid string, height int, overrides *RecountOverrides) (*Recount, error) {
+	chainid, err := chainIDArg("vote", chainid)
+	if err != nil {
+		return nil, err
+	}
+	if height < 0 {
+		height = g.FetchHighestDBInserted(ctx)
+	}
+
+	v, err := g.fetchTallyVote(ctx, chainid)
+	if err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(v); err != nil {
+		return nil, err
+	}
+
+	r := &Recount{VoteChain: chainid, BlockHeight: height, Overrides: overrides}
+	r.Result, r.EligibleHeight, err = g.tallyAt(ctx, v, height)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (g *GraphQLSQLDB) fetchTallyVote(ctx context.Context, chainid string) (*common.Vote, error) {
+	v, err := g.SQLDatabase.FetchVote(ctx, chainid)
+	if err == sql.ErrNoRows {
+		return nil, &NotFoundError{"vote"}
+	}
+	return v, err
+}
+
+// tallyAt computes the result of the vote from the commits and reveals up to
+// and including the height, the same way the scraper does once the vote is
+// complete. Results before the end of the reveal phase are provisional. It
+// returns the height the eligible voters were taken at.
+func (g *GraphQLSQLDB) tallyAt(ctx context.Context, v *common.Vote, height int) (*common.VoteStats, int, error) {
+	phases := v.Proposal.Vote.PhasesBlockHeights
+
+	// The eligible voters are fixed at the start of the commit phase.
+	// fetch_eligible_voters counts the entries below the height given.
+	eligibleHeight := phases.CommitStart
+	if height+1 < eligibleHeight {
+		eligibleHeight = height + 1
+	}
+
+	voters, err := g.SQLDatabase.FetchEligibleVoters(ctx, v.Proposal.Vote.EligibleVotersChainID.String(), eligibleHeight)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	chainid := v.Proposal.ProposalChain.String()
+	allCommits, err := g.SQLDatabase.FetchCommits(ctx, chainid)
+	if err != nil {
+		return nil, 0, err
+	}
+	var commits []*common.VoteCommit
+	for _, c := range allCommits {
+		if c.BlockHeight <= height {
+			commits = append(commits, c)
+		}
+	}
+
+	allReveals, err := g.SQLDatabase.FetchReveals(ctx, chainid)
+	if err != nil {
+		return nil, 0, err
+	}
+	var reveals []*common.VoteReveal
+	for _, r := range allReveals {
+		if r.BlockHeight <= height {
+			reveals = append(reveals, r)
+		}
+	}
+
+	stats, err := common.ComputeResult(v, voters, reveals)
+	if err != nil {
+		return nil, 0, err
+	}
+	stats.Provisional = height < phases.RevealEnd
+	stats.Unrevealed = common.ComputeUnrevealed(voters, commits, reveals)
+	return stats, eligibleHeight, nil
+}
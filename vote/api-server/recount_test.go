@@ -0,0 +1,43 @@
+package apiserver_test
+
+import (
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+func TestRecountOverridesApply(t *testing.T) {
+	v := common.NewVote()
+	config := &v.Proposal.Vote.Config
+	config.Options = []string{"yes", "no"}
+	config.ComputeResultsAgainst = "ALL_ELIGIBLE_VOTERS"
+	config.AcceptanceCriteria.MinTurnout = common.CriteriaWeights{Weighted: common.MustDecimal("0.1"), Unweighted: common.MustDecimal("0.1")}
+
+	// No overrides leave the vote as is
+	var none *RecountOverrides
+	if err := none.Apply(v); err != nil || config.ComputeResultsAgainst != "ALL_ELIGIBLE_VOTERS" {
+		t.Errorf("exp the vote unchanged, got %v %s", err, config.ComputeResultsAgainst)
+	}
+
+	against := "PARTICIPANTS_ONLY"
+	o := &RecountOverrides{
+		ComputeResultsAgainst: &against,
+		MinTurnout:            &common.CriteriaWeights{Weighted: common.MustDecimal("0.5")},
+	}
+	if err := o.Apply(v); err != nil {
+		t.Fatal(err)
+	}
+	if config.ComputeResultsAgainst != against || config.AcceptanceCriteria.MinTurnout.Weighted.String() != "0.5" || !config.AcceptanceCriteria.MinTurnout.Unweighted.IsZero() {
+		t.Errorf("exp the criteria overridden, got %s %v", config.ComputeResultsAgainst, config.AcceptanceCriteria.MinTurnout)
+	}
+
+	o = &RecountOverrides{MinSupport: map[string]common.CriteriaWeights{"maybe": {}}}
+	if err := o.Apply(v); err == nil {
+		t.Error("exp an error for support of an option the vote does not have")
+	}
+	o = &RecountOverrides{MinSupport: map[string]common.CriteriaWeights{"yes": {Weighted: common.MustDecimal("0.6")}, "*": {}}}
+	if err := o.Apply(v); err != nil || config.WinnerCriteria.MinSupport["yes"].Weighted.String() != "0.6" {
+		t.Errorf("exp the support overridden, got %v %v", err, config.WinnerCriteria.MinSupport)
+	}
+}
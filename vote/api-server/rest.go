@@ -0,0 +1,395 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	log "github.com/sirupsen/logrus"
+)
+
+var restlog = log.WithFields(log.Fields{"file": "rest.go"})
+
+// The rest api is a read only view over the same fetchers as graphql, for
+// clients that cannot easily speak graphql. Responses are the json of the
+// graphql types, and errors use the graphql error format.
+
+// RESTPrefix is the path the rest api is served under
+const RESTPrefix = "/v1/"
+
+// restRoute is a single endpoint. Path segments in braces are parameters.
+type restRoute struct {
+	Path        string
+	Summary     string
+	Description string
+	Params      []restParam
+	// Response is a value of the type returned, for the openapi document
+	Response interface{}
+
+	handle func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error)
+}
+
+type restParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+var pageParams = []restParam{
+	{"limit", "integer", "Maximum number of items to return"},
+	{"offset", "integer", "Number of items to skip"},
+	{"first", "integer", "Return the first n items, after the 'after' cursor if given"},
+	{"after", "string", "Cursor of the item to start after"},
+	{"last", "integer", "Return the last n items, before the 'before' cursor if given"},
+	{"before", "string", "Cursor of the item to end before"},
+}
+
+func (s *GraphQLServer) restRoutes() []restRoute {
+	return []restRoute{
+		{
+			Path:    "/v1/votes",
+			Summary: "List votes",
+			Params: append([]restParam{
+				{"registered", "boolean", "Only registered (true) or unregistered (false) votes"},
+				{"active", "boolean", "Only votes in discussion, commit or reveal phase. Overridden by 'status'"},
+				{"status", "string", "One of 'discussion', 'commit', 'reveal', or 'complete'"},
+				{"title", "string", "Votes with a title containing the string"},
+				{"search", "string", "Full text search of the title, text and external reference. Best matches first, unless sorted or paged by cursor"},
+				{"voter", "string", "Votes the voter is eligible for. Matches partial hashes"},
+				{"initiator", "string", "Votes created by the identity. Matches partial hashes"},
+				{"voteChain", "string", "Votes with the chain id. Matches partial hashes"},
+				{"sort", "string", "Columns to sort by, comma separated"},
+				{"sortOrder", "string", "ASC or DESC for each sort column, comma separated. Default is DESC"},
+			}, pageParams...),
+			Response: VoteList{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				args, err := s.restArgs(q, []string{"registered", "active"}, []string{"status", "title", "search", "voter", "initiator", "voteChain", "sort", "sortOrder"}, nil)
+				if err != nil {
+					return nil, err
+				}
+				if initiator, ok := args["initiator"]; ok {
+					args["voteInitiator"] = initiator
+				}
+
+				registered := 0
+				if reg, ok := args["registered"].(bool); ok {
+					if reg {
+						registered = 1
+					} else {
+						registered = 2
+					}
+				}
+				active, _ := args["active"].(bool)
+				limit, _ := args["limit"].(int)
+				offset, _ := args["offset"].(int)
+				page, err := ParsePageArgs(args)
+				if err != nil {
+					return nil, err
+				}
+				return s.SQLDB.FetchAllVotes(ctx, registered, active, limit, offset, page, args)
+			},
+		},
+		{
+			Path:     "/v1/votes/{chain}",
+			Summary:  "Get a vote",
+			Response: Vote{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				return s.SQLDB.FetchVote(ctx, path["chain"])
+			},
+		},
+		{
+			Path:     "/v1/votes/{chain}/commits",
+			Summary:  "List the commits of a vote",
+			Params:   pageParams,
+			Response: VoteCommitContainer{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				args, err := s.restArgs(q, nil, nil, nil)
+				if err != nil {
+					return nil, err
+				}
+				limit, _ := args["limit"].(int)
+				offset, _ := args["offset"].(int)
+				page, err := ParsePageArgs(args)
+				if err != nil {
+					return nil, err
+				}
+				return s.SQLDB.FetchAllCommits(ctx, path["chain"], limit, offset, page)
+			},
+		},
+		{
+			Path:     "/v1/votes/{chain}/reveals",
+			Summary:  "List the reveals of a vote",
+			Params:   pageParams,
+			Response: VoteRevealContainer{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				args, err := s.restArgs(q, nil, nil, nil)
+				if err != nil {
+					return nil, err
+				}
+				limit, _ := args["limit"].(int)
+				offset, _ := args["offset"].(int)
+				page, err := ParsePageArgs(args)
+				if err != nil {
+					return nil, err
+				}
+				return s.SQLDB.FetchAllReveals(ctx, path["chain"], limit, offset, page)
+			},
+		},
+		{
+			Path:        "/v1/votes/{chain}/schedule",
+			Summary:     "Get the estimated times of the phases of a vote",
+			Description: "Times of blocks that exist are their timestamps, the rest are estimated with a 95% confidence range",
+			Response:    VoteSchedule{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				v, err := s.SQLDB.FetchVote(ctx, path["chain"])
+				if err != nil {
+					return nil, err
+				}
+				return s.SQLDB.FetchVoteSchedule(ctx, v)
+			},
+		},
+		{
+			Path:     "/v1/votes/{chain}/calendar.ics",
+			Summary:  "iCalendar of the phases of a vote",
+			Response: rawResponse{ContentType: calendarContentType},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				v, err := s.SQLDB.FetchVote(ctx, path["chain"])
+				if err != nil {
+					return nil, err
+				}
+				cal, err := s.SQLDB.FetchVoteCalendar(ctx, []Vote{*v})
+				if err != nil {
+					return nil, err
+				}
+				return &rawResponse{calendarContentType, icalendar(v.Proposal.Title, time.Now(), cal)}, nil
+			},
+		},
+		{
+			Path:     "/v1/calendar.ics",
+			Summary:  "iCalendar of the phases of every registered vote",
+			Response: rawResponse{ContentType: calendarContentType},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				votes, err := s.SQLDB.FetchAllVotes(ctx, 1, false, 0, 0, nil, map[string]interface{}{})
+				if err != nil {
+					return nil, err
+				}
+				cal, err := s.SQLDB.FetchVoteCalendar(ctx, votes.Votes)
+				if err != nil {
+					return nil, err
+				}
+				return &rawResponse{calendarContentType, icalendar("Factom votes", time.Now(), cal)}, nil
+			},
+		},
+		{
+			Path:        "/v1/votes/{chain}/results",
+			Summary:     "Get the results of a vote",
+			Description: "Results exist once the vote is complete, or provisional results of the reveals so far can be asked for",
+			Params: []restParam{
+				{"provisional", "boolean", "Before the vote is complete, return the results of the reveals so far"},
+			},
+			Response: common.VoteStats{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				args, err := s.restArgs(q, []string{"provisional"}, nil, nil)
+				if err != nil {
+					return nil, err
+				}
+				if provisional, _ := args["provisional"].(bool); provisional {
+					return s.SQLDB.FetchProvisionalVoteStats(ctx, path["chain"])
+				}
+				return s.SQLDB.FetchVoteStats(ctx, path["chain"])
+			},
+		},
+		{
+			Path:        "/v1/votes/{chain}/results/history",
+			Summary:     "Get the history of the results of a vote",
+			Description: "Every time the stored results were replaced by a recompute, oldest first",
+			Response:    []ResultChange{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				return s.SQLDB.FetchResultHistory(ctx, path["chain"])
+			},
+		},
+		{
+			Path:        "/v1/votes/{chain}/results/attestations",
+			Summary:     "Get the signatures of the scraper over the results of a vote",
+			Description: "Newest first, each can be verified offline with `go-factom-vote verify-attestation`",
+			Response:    []ResultAttestation{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				return s.SQLDB.FetchResultAttestations(ctx, path["chain"])
+			},
+		},
+		{
+			Path:        "/v1/votes/{chain}/receipts/{voterId}",
+			Summary:     "Get the proof the commit and reveal of a voter are in the chain",
+			Description: "Merkle paths from the entries to their EBlock, and from the EBlock to the DBlock. Check offline with `go-factom-vote verify-receipt`",
+			Response:    common.VoterReceipt{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				return s.FetchReceipt(ctx, path["chain"], path["voterId"])
+			},
+		},
+		{
+			Path:        "/v1/voters/{id}",
+			Summary:     "Get the history of a voter",
+			Description: "The eligible lists the voter has been on, and what they did in each vote they could take part in",
+			Response:    VoterProfile{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				return s.SQLDB.FetchVoterProfile(ctx, path["id"])
+			},
+		},
+		{
+			Path:        "/v1/initiators/{id}",
+			Summary:     "Get the dashboard of a vote initiator",
+			Description: "The proposals and eligible lists created by the identity, and their outcomes",
+			Response:    InitiatorDashboard{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				return s.SQLDB.FetchInitiatorDashboard(ctx, path["id"])
+			},
+		},
+		{
+			Path:    "/v1/eligible-lists/{chain}/voters",
+			Summary: "List the voters of an eligible list",
+			Params: append([]restParam{
+				{"blockHeight", "integer", "Voters as of this height. Default is the latest"},
+			}, pageParams...),
+			Response: EligibleVoterContainer{},
+			handle: func(ctx context.Context, path map[string]string, q url.Values) (interface{}, error) {
+				args, err := s.restArgs(q, nil, nil, []string{"blockHeight"})
+				if err != nil {
+					return nil, err
+				}
+				height, _ := args["blockHeight"].(int)
+				limit, _ := args["limit"].(int)
+				offset, _ := args["offset"].(int)
+				page, err := ParsePageArgs(args)
+				if err != nil {
+					return nil, err
+				}
+				return s.SQLDB.FetchEligibleVoters(ctx, path["chain"], height, limit, offset, page)
+			},
+		},
+	}
+}
+
+// RESTHandler serves the rest api and its openapi document under RESTPrefix
+func (s *GraphQLServer) RESTHandler() http.Handler {
+	routes := s.restRoutes()
+	doc := openAPIDocument(routes)
+
+	return disableCors(s.limitClients(s.withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("the rest api is read only"))
+			return
+		}
+
+		if r.URL.Path == RESTPrefix+"openapi.json" {
+			writeJSON(w, http.StatusOK, doc)
+			return
+		}
+
+		for _, route := range routes {
+			path, ok := matchPath(route.Path, r.URL.Path)
+			if !ok {
+				continue
+			}
+
+			res, err := route.handle(r.Context(), path, r.URL.Query())
+			if err != nil {
+				writeError(w, restStatus(err), err)
+				return
+			}
+			if raw, ok := res.(*rawResponse); ok {
+				w.Header().Set("Content-Type", raw.ContentType)
+				w.Write(raw.Body)
+				return
+			}
+			writeJSON(w, http.StatusOK, res)
+			return
+		}
+		writeError(w, http.StatusNotFound, fmt.Errorf("no endpoint %s", r.URL.Path))
+	}))))
+}
+
+// matchPath returns the path parameters if the path matches the pattern
+func matchPath(pattern, path string) (map[string]string, bool) {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i := range want {
+		if strings.HasPrefix(want[i], "{") && strings.HasSuffix(want[i], "}") {
+			if got[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(want[i], "{}")] = got[i]
+		} else if want[i] != got[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// restArgs converts the query string to arguments in the form the graphql
+// resolvers get. The paging arguments are always read, and checked against
+// the maximum limit.
+func (s *GraphQLServer) restArgs(q url.Values, bools, strs, ints []string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	for _, name := range strs {
+		if v, ok := q[name]; ok {
+			args[name] = v[0]
+		}
+	}
+	for _, name := range []string{"after", "before"} {
+		if v, ok := q[name]; ok {
+			args[name] = v[0]
+		}
+	}
+	for _, name := range bools {
+		if v, ok := q[name]; ok {
+			b, err := strconv.ParseBool(v[0])
+			if err != nil {
+				return nil, argumentErrorf("'%s' must be true or false", name)
+			}
+			args[name] = b
+		}
+	}
+	for _, name := range append(ints, "limit", "offset", "first", "last") {
+		if v, ok := q[name]; ok {
+			i, err := strconv.Atoi(v[0])
+			if err != nil {
+				return nil, argumentErrorf("'%s' must be an integer", name)
+			}
+			args[name] = i
+		}
+	}
+
+	for _, name := range []string{"limit", "first", "last"} {
+		if n, ok := args[name].(int); ok && s.Limits.MaxLimit > 0 && n > s.Limits.MaxLimit {
+			return nil, &QueryError{"limit", fmt.Sprintf("'%s' of %d is more than the maximum of %d", name, n, s.Limits.MaxLimit)}
+		}
+	}
+	return args, nil
+}
+
+func restStatus(err error) int {
+	switch e := err.(type) {
+	case *NotFoundError:
+		return http.StatusNotFound
+	case *ArgumentError:
+		return http.StatusBadRequest
+	case *QueryError:
+		RejectedRequests.WithLabelValues(e.Reason).Inc()
+		return http.StatusBadRequest
+	}
+	restlog.WithField("func", "RESTHandler").Error(err)
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,89 @@
+package apiserver_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/api-server"
+)
+
+func TestRESTOpenAPI(t *testing.T) {
+	srv := new(GraphQLServer)
+	srv.Limits = DefaultLimits()
+	h := srv.RESTHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exp 200, got %d", rec.Code)
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/v1/votes", "/v1/votes/{chain}", "/v1/votes/{chain}/commits",
+		"/v1/votes/{chain}/reveals", "/v1/votes/{chain}/results", "/v1/eligible-lists/{chain}/voters"} {
+		if _, ok := doc.Paths[path]["get"]; !ok {
+			t.Errorf("openapi document is missing %s", path)
+		}
+	}
+
+	// Every reference must resolve
+	body := rec.Body.String()
+	for _, part := range strings.Split(body, `"$ref":"#/components/schemas/`)[1:] {
+		name := part[:strings.Index(part, `"`)]
+		if doc.Components.Schemas[name] == nil {
+			t.Errorf("schema %s is referenced but not defined", name)
+		}
+	}
+}
+
+func TestRESTErrors(t *testing.T) {
+	srv := new(GraphQLServer)
+	srv.Limits = DefaultLimits()
+	h := srv.RESTHandler()
+
+	type Request struct {
+		Method string
+		Path   string
+		Status int
+	}
+	requests := []Request{
+		{"GET", "/v1/nothing", http.StatusNotFound},
+		{"GET", "/v1/votes/a/b/c", http.StatusNotFound},
+		{"POST", "/v1/votes", http.StatusMethodNotAllowed},
+		{"GET", "/v1/votes?limit=abc", http.StatusBadRequest},
+		{"GET", "/v1/votes?registered=maybe", http.StatusBadRequest},
+		{"GET", "/v1/votes?limit=100000", http.StatusBadRequest},
+		{"GET", "/v1/votes/a/commits?first=1&last=1", http.StatusBadRequest},
+		{"GET", "/v1/votes/a/commits?after=notacursor", http.StatusBadRequest},
+	}
+
+	for _, r := range requests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(r.Method, r.Path, nil))
+		if rec.Code != r.Status {
+			t.Errorf("%s %s: exp %d, got %d: %s", r.Method, r.Path, r.Status, rec.Code, rec.Body.String())
+			continue
+		}
+
+		var res struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || len(res.Errors) == 0 {
+			t.Errorf("%s %s: exp a graphql error list, got %s", r.Method, r.Path, rec.Body.String())
+		}
+	}
+}
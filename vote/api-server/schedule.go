@@ -0,0 +1,204 @@
+package apiserver
+
+import (
+	"context"
+	"time"
+
+	"github.com/Emyrk/go-factom-vote/blocktime"
+	"github.com/graphql-go/graphql"
+	"github.com/lib/pq"
+)
+
+// Proposals define their phases in block heights. The schedule converts them
+// to wall clock times, from the timestamps of the blocks that exist, and
+// estimates for those that do not yet.
+
+// VoteSchedule is when each phase of a vote starts and ends. The discussion
+// phase starts when the vote is created.
+type VoteSchedule struct {
+	Discussion PhaseSchedule `json:"discussion"`
+	Commit     PhaseSchedule `json:"commit"`
+	Reveal     PhaseSchedule `json:"reveal"`
+}
+
+// PhaseSchedule is nil at either end if there are no block times to estimate from
+type PhaseSchedule struct {
+	Start *blocktime.Estimate `json:"start"`
+	End   *blocktime.Estimate `json:"end"`
+}
+
+var BlockTimeEstimateGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "BlockTimeEstimate",
+	Description: "When a block was, or is expected to be, created",
+	Fields: graphql.Fields{
+		"blockHeight": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"time": &graphql.Field{
+			Description: "Timestamp of the block if observed, otherwise the estimate",
+			Type:        graphql.DateTime,
+		},
+		"earliest": &graphql.Field{
+			Description: "Start of the 95% confidence range of the estimate",
+			Type:        graphql.DateTime,
+		},
+		"latest": &graphql.Field{
+			Description: "End of the 95% confidence range of the estimate",
+			Type:        graphql.DateTime,
+		},
+		"observed": &graphql.Field{
+			Description: "True if the block exists, and the time is its timestamp",
+			Type:        graphql.Boolean,
+		},
+	}})
+
+var PhaseScheduleGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "PhaseSchedule",
+	Description: "When a phase starts and ends",
+	Fields: graphql.Fields{
+		"start": &graphql.Field{
+			Type: BlockTimeEstimateGraphQLType,
+		},
+		"end": &graphql.Field{
+			Type: BlockTimeEstimateGraphQLType,
+		},
+	}})
+
+var VoteScheduleGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "VoteSchedule",
+	Description: "Wall clock times of the phases of a vote",
+	Fields: graphql.Fields{
+		"discussion": &graphql.Field{
+			Type: PhaseScheduleGraphQLType,
+		},
+		"commit": &graphql.Field{
+			Type: PhaseScheduleGraphQLType,
+		},
+		"reveal": &graphql.Field{
+			Type: PhaseScheduleGraphQLType,
+		},
+	}})
+
+func init() {
+	VoteGraphQLType.AddFieldConfig("schedule", &graphql.Field{
+		Type:        VoteScheduleGraphQLType,
+		Description: "Estimated start and end times of each phase, from the block times",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			v, err := voteSource(p)
+			if err != nil {
+				return nil, err
+			}
+			l, err := requestLoaders(p)
+			if err != nil {
+				return nil, err
+			}
+
+			heights := scheduleHeights(v)
+			thunks := make([]func() (interface{}, error), len(heights))
+			for i, h := range heights {
+				thunks[i] = l.BlockTimes.Load(h)
+			}
+			return func() (interface{}, error) {
+				times := make(map[int]blocktime.Estimate)
+				for i, thunk := range thunks {
+					t, err := thunk()
+					if err != nil {
+						return nil, err
+					}
+					if est, ok := t.(blocktime.Estimate); ok {
+						times[heights[i]] = est
+					}
+				}
+				return newVoteSchedule(v, times), nil
+			}, nil
+		},
+	})
+}
+
+// scheduleHeights are the heights of the phase boundaries of the vote
+func scheduleHeights(v *Vote) []int {
+	phases := v.Definition.PhasesBlockHeights
+	return []int{v.Admin.AdminBlockHeight, phases.CommitStart, phases.CommitStop, phases.RevealStart, phases.RevealStop}
+}
+
+func newVoteSchedule(v *Vote, times map[int]blocktime.Estimate) *VoteSchedule {
+	at := func(height int) *blocktime.Estimate {
+		if est, ok := times[height]; ok {
+			return &est
+		}
+		return nil
+	}
+
+	phases := v.Definition.PhasesBlockHeights
+	return &VoteSchedule{
+		Discussion: PhaseSchedule{at(v.Admin.AdminBlockHeight), at(phases.CommitStart)},
+		Commit:     PhaseSchedule{at(phases.CommitStart), at(phases.CommitStop)},
+		Reveal:     PhaseSchedule{at(phases.RevealStart), at(phases.RevealStop)},
+	}
+}
+
+// FetchVoteSchedule returns the schedule of the vote
+func (g *GraphQLSQLDB) FetchVoteSchedule(ctx context.Context, v *Vote) (*VoteSchedule, error) {
+	times, err := g.FetchBlockTimes(ctx, scheduleHeights(v))
+	if err != nil {
+		return nil, err
+	}
+	return newVoteSchedule(v, times), nil
+}
+
+// FetchBlockTimes returns the timestamps of the blocks at the heights, or an
+// estimate for those not in the database. Heights are left out if there are
+// no block times to estimate from.
+func (g *GraphQLSQLDB) FetchBlockTimes(ctx context.Context, heights []int) (map[int]blocktime.Estimate, error) {
+	arr := make([]int64, len(heights))
+	for i, h := range heights {
+		arr[i] = int64(h)
+	}
+
+	// One query returns the blocks asked for, and the recent blocks to
+	// estimate the rest from
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, `SELECT block_height, block_timestamp FROM completed
+		WHERE block_timestamp IS NOT NULL AND (block_height = ANY($1::integer[])
+			OR block_height > (SELECT max(block_height) FROM completed) - $2)`, pq.Array(arr), blocktime.DefaultWindow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[int]time.Time)
+	var samples []blocktime.Sample
+	latest := 0
+	for rows.Next() {
+		var s blocktime.Sample
+		if err := rows.Scan(&s.Height, &s.Time); err != nil {
+			return nil, err
+		}
+		found[s.Height] = s.Time
+		samples = append(samples, s)
+		if s.Height > latest {
+			latest = s.Height
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Blocks asked for that are older than the window are not samples
+	window := samples[:0]
+	for _, s := range samples {
+		if s.Height > latest-blocktime.DefaultWindow {
+			window = append(window, s)
+		}
+	}
+	est := blocktime.NewEstimator(window)
+
+	times := make(map[int]blocktime.Estimate, len(heights))
+	for _, h := range heights {
+		if t, ok := found[h]; ok {
+			times[h] = blocktime.Observed(blocktime.Sample{Height: h, Time: t})
+		} else if est != nil {
+			times[h] = est.Estimate(h)
+		}
+	}
+	return times, nil
+}
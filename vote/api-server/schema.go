@@ -13,6 +13,9 @@ func (s *GraphQLServer) CreateSchema() (graphql.Schema, error) {
 		"completed":            s.completedField(),
 		"proposal":             s.proposal(),
 		"allProposals":         s.allProposals(),
+		"searchProposals":      s.searchProposals(),
+		"voter":                s.voter(),
+		"voteInitiator":        s.voteInitiator(),
 		"eligibleList":         s.eligibleList(),
 		"eligibleVoters":       s.eligibleListVoters(),
 		"commit":               s.commit(),
@@ -20,14 +23,22 @@ func (s *GraphQLServer) CreateSchema() (graphql.Schema, error) {
 		"commits":              s.commits(),
 		"reveals":              s.reveals(),
 		"result":               s.result(),
+		"recount":              s.recount(),
+		"resultHistory":        s.resultHistory(),
+		"resultAttestations":   s.resultAttestations(),
+		"receipt":              s.receipt(),
 		"results":              s.results(),
 		"identityKeysAtHeight": s.identityKeysAtHeight(),
 		"proposalEntries":      s.proposalEntries(),
 		"properties":           s.properties(),
 	}
 
-	rootQuery := graphql.ObjectConfig{Name: "RootQuery", Fields: fields}
-	schemaConfig := graphql.SchemaConfig{Query: graphql.NewObject(rootQuery)}
+	rootQuery := graphql.ObjectConfig{Name: "RootQuery", Fields: instrumentFields(fields)}
+	subscription := graphql.ObjectConfig{Name: "Subscription", Fields: s.subscriptionFields()}
+	schemaConfig := graphql.SchemaConfig{
+		Query:        graphql.NewObject(rootQuery),
+		Subscription: graphql.NewObject(subscription),
+	}
 	schema, err := graphql.NewSchema(schemaConfig)
 	if err != nil {
 		log.Fatalf("failed to create new schema, error: %v", err)
@@ -45,7 +56,7 @@ func (s *GraphQLServer) properties() *graphql.Field {
 				"syncedHeight": &graphql.Field{
 					Type: graphql.Int,
 					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-						return s.SQLDB.FetchHighestDBInserted(), nil
+						return s.SyncedHeight(p.Context), nil
 					},
 				},
 				"factomdProperties": &graphql.Field{
@@ -58,7 +69,7 @@ func (s *GraphQLServer) properties() *graphql.Field {
 				"totalVoteResults": &graphql.Field{
 					Type: graphql.Int,
 					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-						return s.SQLDB.FetchNumberOfResults()
+						return s.SQLDB.FetchNumberOfResults(p.Context)
 					},
 				},
 			}}),
@@ -81,7 +92,7 @@ func (s *GraphQLServer) proposalEntries() *graphql.Field {
 		},
 		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 			chain := params.Args["chain"].(string)
-			return s.SQLDB.FetchProposalEntries(chain)
+			return s.SQLDB.FetchProposalEntries(params.Context, chain)
 		},
 	}
 }
@@ -122,7 +133,7 @@ func (s *GraphQLServer) completedField() *graphql.Field {
 	return &graphql.Field{
 		Type: graphql.String,
 		Resolve: func(q graphql.ResolveParams) (interface{}, error) {
-			return s.SQLDB.FetchHighestDBInserted(), nil
+			return s.SQLDB.FetchHighestDBInserted(q.Context), nil
 		},
 	}
 }
@@ -138,7 +149,7 @@ func (s *GraphQLServer) proposal() *graphql.Field {
 		},
 		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 			chain := params.Args["chain"].(string)
-			return s.SQLDB.FetchVote(chain)
+			return s.SQLDB.FetchVote(params.Context, chain)
 		},
 	}
 }
@@ -146,7 +157,7 @@ func (s *GraphQLServer) proposal() *graphql.Field {
 func (s *GraphQLServer) commits() *graphql.Field {
 	return &graphql.Field{
 		Type: CommitListGraphQLType,
-		Args: graphql.FieldConfigArgument{
+		Args: withPageArgs(graphql.FieldConfigArgument{
 			"offset": &graphql.ArgumentConfig{
 				Type: graphql.Int,
 			},
@@ -156,13 +167,17 @@ func (s *GraphQLServer) commits() *graphql.Field {
 			"voteChain": &graphql.ArgumentConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
-		},
+		}),
 		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 			offset, _ := params.Args["offset"].(int)
 			limit, _ := params.Args["limit"].(int)
 			voterChain, _ := params.Args["voteChain"].(string)
+			page, err := ParsePageArgs(params.Args)
+			if err != nil {
+				return nil, err
+			}
 
-			return s.SQLDB.FetchAllCommits(voterChain, limit, offset)
+			return s.SQLDB.FetchAllCommits(params.Context, voterChain, limit, offset, page)
 		},
 	}
 }
@@ -182,7 +197,7 @@ func (s *GraphQLServer) commit() *graphql.Field {
 			voterId, _ := params.Args["voterId"].(string)
 			voterChain, _ := params.Args["voteChain"].(string)
 
-			return s.SQLDB.FetchCommit(voterId, voterChain)
+			return s.SQLDB.FetchCommit(params.Context, voterId, voterChain)
 		},
 	}
 }
@@ -202,7 +217,7 @@ func (s *GraphQLServer) reveal() *graphql.Field {
 			voterId, _ := params.Args["voterId"].(string)
 			voterChain, _ := params.Args["voteChain"].(string)
 
-			return s.SQLDB.FetchReveal(voterId, voterChain)
+			return s.SQLDB.FetchReveal(params.Context, voterId, voterChain)
 		},
 	}
 }
@@ -210,7 +225,7 @@ func (s *GraphQLServer) reveal() *graphql.Field {
 func (s *GraphQLServer) reveals() *graphql.Field {
 	return &graphql.Field{
 		Type: RevealListGraphQLType,
-		Args: graphql.FieldConfigArgument{
+		Args: withPageArgs(graphql.FieldConfigArgument{
 			"offset": &graphql.ArgumentConfig{
 				Type: graphql.Int,
 			},
@@ -220,13 +235,17 @@ func (s *GraphQLServer) reveals() *graphql.Field {
 			"voteChain": &graphql.ArgumentConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
-		},
+		}),
 		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 			offset, _ := params.Args["offset"].(int)
 			limit, _ := params.Args["limit"].(int)
 			voterChain, _ := params.Args["voteChain"].(string)
+			page, err := ParsePageArgs(params.Args)
+			if err != nil {
+				return nil, err
+			}
 
-			return s.SQLDB.FetchAllReveals(voterChain, limit, offset)
+			return s.SQLDB.FetchAllReveals(params.Context, voterChain, limit, offset, page)
 		},
 	}
 }
@@ -234,7 +253,7 @@ func (s *GraphQLServer) reveals() *graphql.Field {
 func (s *GraphQLServer) allProposals() *graphql.Field {
 	return &graphql.Field{
 		Type: VoteListGraphQLType,
-		Args: graphql.FieldConfigArgument{
+		Args: withPageArgs(graphql.FieldConfigArgument{
 			"registered": &graphql.ArgumentConfig{
 				Description: "Only show registered votes.",
 				Type:        graphql.Boolean,
@@ -257,6 +276,10 @@ func (s *GraphQLServer) allProposals() *graphql.Field {
 				Description: "Allows for filtering by title. If a title is given, any title that contains the given string will be returned.",
 				Type:        graphql.String,
 			},
+			"search": &graphql.ArgumentConfig{
+				Description: "Full text search of the title, text and external reference. Ranks the best matches first, unless sorted or paged by cursor, and sets 'search' on each vote.",
+				Type:        graphql.String,
+			},
 			"voter": &graphql.ArgumentConfig{
 				Description: "Will filter votes that this voter is able to vote in. Will match partial hashes",
 				Type:        graphql.String,
@@ -277,7 +300,7 @@ func (s *GraphQLServer) allProposals() *graphql.Field {
 				Description: "Can set the sort to ASC or DESC. Default is DESC if not provided",
 				Type:        graphql.String,
 			},
-		},
+		}),
 		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 			reg, ok := params.Args["registered"].(bool)
 			act, _ := params.Args["active"].(bool)
@@ -293,7 +316,12 @@ func (s *GraphQLServer) allProposals() *graphql.Field {
 				}
 			}
 
-			return s.SQLDB.FetchAllVotes(regNumber, act, limit, offset, params.Args)
+			page, err := ParsePageArgs(params.Args)
+			if err != nil {
+				return nil, err
+			}
+
+			return s.SQLDB.FetchAllVotes(params.Context, regNumber, act, limit, offset, page, params.Args)
 		},
 	}
 }
@@ -308,7 +336,7 @@ func (s *GraphQLServer) eligibleList() *graphql.Field {
 		},
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			chainid := p.Args["chain"].(string)
-			list, err := s.SQLDB.FetchEligibleList(chainid)
+			list, err := s.SQLDB.FetchEligibleList(p.Context, chainid)
 			if err != nil {
 				return nil, err
 			}
@@ -345,7 +373,7 @@ func (g *GraphQLServer) eligibleListVoters() *graphql.Field {
 	return &graphql.Field{
 		Type: ELContainerGraphQLType,
 		Name: "VoterList",
-		Args: graphql.FieldConfigArgument{
+		Args: withPageArgs(graphql.FieldConfigArgument{
 			"chain": &graphql.ArgumentConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
@@ -362,17 +390,21 @@ func (g *GraphQLServer) eligibleListVoters() *graphql.Field {
 				Type:        graphql.Boolean,
 				Description: "If set to true, that means the provided chainId is a vote chain id, not an eligible list chain id. It will return the list of voters of the eligible chain from the vote, with the commitStart as the blockHeight",
 			},
-		},
+		}),
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			chainid := p.Args["chain"].(string)
 			votechain, _ := p.Args["voteChain"].(bool)
 			offset, _ := p.Args["offset"].(int)
 			limit, _ := p.Args["limit"].(int)
 			blockHeight, _ := p.Args["blockHeight"].(int)
+			page, err := ParsePageArgs(p.Args)
+			if err != nil {
+				return nil, err
+			}
 
 			egChain := chainid
 			if votechain {
-				vote, err := g.SQLDB.FetchVote(chainid)
+				vote, err := g.SQLDB.FetchVote(p.Context, chainid)
 				if err != nil {
 					return nil, err
 				}
@@ -381,7 +413,7 @@ func (g *GraphQLServer) eligibleListVoters() *graphql.Field {
 				egChain = vote.Definition.EligibleVoterChain
 			}
 
-			return g.SQLDB.FetchEligibleVoters(egChain, blockHeight, limit, offset)
+			return g.SQLDB.FetchEligibleVoters(p.Context, egChain, blockHeight, limit, offset, page)
 		},
 	}
 }
@@ -389,7 +421,7 @@ func (g *GraphQLServer) eligibleListVoters() *graphql.Field {
 func (g *GraphQLServer) results() *graphql.Field {
 	return &graphql.Field{
 		Type: VoteResultsListGraphQLType,
-		Args: graphql.FieldConfigArgument{
+		Args: withPageArgs(graphql.FieldConfigArgument{
 			"valid": &graphql.ArgumentConfig{
 				Type: graphql.Boolean,
 			},
@@ -399,12 +431,17 @@ func (g *GraphQLServer) results() *graphql.Field {
 			"limit": &graphql.ArgumentConfig{
 				Type: graphql.Int,
 			},
-		},
+		}),
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			valid, _ := p.Args["valid"].(bool)
 			offset, _ := p.Args["offset"].(int)
 			limit, _ := p.Args["limit"].(int)
-			return g.SQLDB.FetchAllVoteStats(valid, limit, offset)
+			page, err := ParsePageArgs(p.Args)
+			if err != nil {
+				return nil, err
+			}
+
+			return g.SQLDB.FetchAllVoteStats(p.Context, valid, offset, limit, page)
 		},
 	}
 }
@@ -416,11 +453,19 @@ func (s *GraphQLServer) result() *graphql.Field {
 			"voteChain": &graphql.ArgumentConfig{
 				Type: graphql.NewNonNull(graphql.String),
 			},
+			"provisional": &graphql.ArgumentConfig{
+				Description: "Before the vote is complete, return the results of the reveals so far, marked as provisional",
+				Type:        graphql.Boolean,
+			},
 		},
 		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
 			voteChain, _ := params.Args["voteChain"].(string)
+			provisional, _ := params.Args["provisional"].(bool)
 
-			return s.SQLDB.FetchVoteStats(voteChain)
+			if provisional {
+				return s.SQLDB.FetchProvisionalVoteStats(params.Context, voteChain)
+			}
+			return s.SQLDB.FetchVoteStats(params.Context, voteChain)
 		},
 	}
 }
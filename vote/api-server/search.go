@@ -0,0 +1,174 @@
+package apiserver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Proposals are searched with the postgres full text search, over the
+// proposals.search_vector column. The title is weighted above the text, and
+// the text above the external reference, so matches in the title rank first.
+
+// tsQuery is the text search query built from a 'search' argument. Every word
+// must match, after stemming.
+const tsQuery = "plainto_tsquery('english', ?)"
+
+// SearchMatch is how well a vote matched a search, and the matching parts of
+// its proposal with the search terms highlighted.
+type SearchMatch struct {
+	Score     float64         `json:"score"`
+	Highlight SearchHighlight `json:"highlight"`
+}
+
+// SearchHighlight has the matched words wrapped in <b></b>. The text is cut
+// down to the fragment around the matches.
+type SearchHighlight struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	Href  string `json:"href"`
+}
+
+var SearchHighlightGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "SearchHighlight",
+	Description: "The proposal with the search terms wrapped in <b></b>",
+	Fields: graphql.Fields{
+		"title": &graphql.Field{
+			Type: graphql.String,
+		},
+		"text": &graphql.Field{
+			Description: "The fragment of the text around the matches",
+			Type:        graphql.String,
+		},
+		"href": &graphql.Field{
+			Type: graphql.String,
+		},
+	}})
+
+var SearchMatchGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "SearchMatch",
+	Description: "How a vote matched a search",
+	Fields: graphql.Fields{
+		"score": &graphql.Field{
+			Description: "Relevance of the match, higher is better",
+			Type:        graphql.Float,
+		},
+		"highlight": &graphql.Field{
+			Type: SearchHighlightGraphQLType,
+		},
+	}})
+
+// ProposalMatch is a vote found by searchProposals
+type ProposalMatch struct {
+	Score     float64         `json:"score"`
+	Highlight SearchHighlight `json:"highlight"`
+	Proposal  Vote            `json:"proposal"`
+}
+
+type ProposalSearchList struct {
+	Info    ListInfo        `json:"listInfo"`
+	Results []ProposalMatch `json:"results"`
+}
+
+var ProposalMatchGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ProposalMatch",
+	Description: "A vote that matched a search",
+	Fields: graphql.Fields{
+		"score": &graphql.Field{
+			Description: "Relevance of the match, higher is better",
+			Type:        graphql.Float,
+		},
+		"highlight": &graphql.Field{
+			Type: SearchHighlightGraphQLType,
+		},
+		"proposal": &graphql.Field{
+			Type: VoteGraphQLType,
+		},
+	}})
+
+var ProposalSearchListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ProposalSearchList",
+	Description: "Votes matching a search, best match first",
+	Fields: graphql.Fields{
+		"listInfo": &graphql.Field{
+			Description: "Information about location in list (offset, total, limit)",
+			Type:        JSON,
+		},
+		"results": &graphql.Field{
+			Type: graphql.NewList(ProposalMatchGraphQLType),
+		},
+	}})
+
+func (s *GraphQLServer) searchProposals() *graphql.Field {
+	return &graphql.Field{
+		Type:        ProposalSearchListGraphQLType,
+		Description: "Searches the title, text and external reference of proposals. Results are ordered by score.",
+		Args: graphql.FieldConfigArgument{
+			"query": &graphql.ArgumentConfig{
+				Description: "Words to search for. All must match, in any form (eg: 'vote' matches 'voting')",
+				Type:        graphql.NewNonNull(graphql.String),
+			},
+			"registered": &graphql.ArgumentConfig{
+				Description: "Only show registered votes.",
+				Type:        graphql.Boolean,
+			},
+			"status": &graphql.ArgumentConfig{
+				Description: "Options include: 'discussion', 'commit', 'reveal', or 'complete'.",
+				Type:        graphql.String,
+			},
+			"offset": &graphql.ArgumentConfig{
+				Type: graphql.Int,
+			},
+			"limit": &graphql.ArgumentConfig{
+				Type: graphql.Int,
+			},
+		},
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			query, _ := params.Args["query"].(string)
+			status, _ := params.Args["status"].(string)
+			offset, _ := params.Args["offset"].(int)
+			limit, _ := params.Args["limit"].(int)
+
+			regNumber := 0
+			if reg, ok := params.Args["registered"].(bool); ok {
+				if reg {
+					regNumber = 1
+				} else {
+					regNumber = 2
+				}
+			}
+
+			return s.SQLDB.FetchSearchProposals(params.Context, query, regNumber, status, limit, offset)
+		},
+	}
+}
+
+// FetchSearchProposals returns the votes matching the search query, best
+// match first
+func (g *GraphQLSQLDB) FetchSearchProposals(ctx context.Context, query string, registered int, status string, limit, offset int) (*ProposalSearchList, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, argumentErrorf("'query' cannot be empty")
+	}
+
+	votes, err := g.FetchAllVotes(ctx, registered, false, limit, offset, nil, map[string]interface{}{
+		"search": query,
+		"status": status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := new(ProposalSearchList)
+	list.Info = votes.Info
+	list.Results = make([]ProposalMatch, 0, len(votes.Votes))
+	for _, v := range votes.Votes {
+		m := ProposalMatch{Proposal: v}
+		if v.Search != nil {
+			m.Score = v.Search.Score
+			m.Highlight = v.Search.Highlight
+		}
+		list.Results = append(list.Results, m)
+	}
+	return list, nil
+}
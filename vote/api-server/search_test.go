@@ -0,0 +1,266 @@
+package apiserver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/api-server"
+	"github.com/Emyrk/go-factom-vote/vote/database"
+)
+
+// recordingDriver is a database/sql driver that records the sql run, and
+// answers every query with the same rows
+type recordingDriver struct{}
+
+// recording is the sql run on a database, and the rows it answers with
+type recording struct {
+	sync.Mutex
+	rows    [][]driver.Value
+	queries []string
+	args    [][]driver.Value
+}
+
+var recordings = struct {
+	sync.Mutex
+	m map[string]*recording
+}{m: make(map[string]*recording)}
+
+func init() {
+	sql.Register("apiserver-recording", recordingDriver{})
+}
+
+func recordingDB(t *testing.T, rows ...[]driver.Value) (*GraphQLSQLDB, *recording) {
+	r := &recording{rows: rows}
+	recordings.Lock()
+	name := strconv.Itoa(len(recordings.m))
+	recordings.m[name] = r
+	recordings.Unlock()
+
+	db, err := sql.Open("apiserver-recording", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &GraphQLSQLDB{SQLDatabase: &database.SQLDatabase{DB: db}}, r
+}
+
+func (recordingDriver) Open(name string) (driver.Conn, error) {
+	recordings.Lock()
+	defer recordings.Unlock()
+	return recordingConn{recordings.m[name]}, nil
+}
+
+type recordingConn struct {
+	r *recording
+}
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.r, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("read only") }
+
+type recordingStmt struct {
+	r     *recording
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("read only")
+}
+func (s recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.r.Lock()
+	defer s.r.Unlock()
+	s.r.queries = append(s.r.queries, s.query)
+	s.r.args = append(s.r.args, args)
+	return &recordedRows{values: s.r.rows}, nil
+}
+
+type recordedRows struct {
+	values [][]driver.Value
+}
+
+func (r *recordedRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0]))
+}
+func (r *recordedRows) Close() error { return nil }
+func (r *recordedRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// searchRow is a vote of the search, in the columns of voterow followed by
+// the count and the search columns
+func searchRow(chain, title string, height int64, score float64) []driver.Value {
+	return []driver.Value{
+		"initiator", "key", "sig",
+		title, "text", "href", "hash", "sha256",
+		int64(10), int64(20), int64(21), int64(30),
+		"list",
+		int64(0), "yes,no", true, "ALL_ELIGIBLE_VOTERS", int64(1), int64(1), "", "",
+		chain, "entry" + chain, height, true, false, int64(1),
+		int64(2),
+		score, "<b>" + title + "</b>", "the <b>text</b>", "href",
+	}
+}
+
+func TestFetchAllVotesArguments(t *testing.T) {
+	db, r := recordingDB(t)
+	page := &PageArgs{First: 10}
+
+	for _, c := range []struct {
+		Name   string
+		Page   *PageArgs
+		Params map[string]interface{}
+		Error  string
+	}{
+		{"status", nil, map[string]interface{}{"status": "finished"}, "'finished' is not a valid status"},
+		{"sort column", nil, map[string]interface{}{"sort": "title,score"}, "is not a valid sorting option"},
+		{"sort with cursors", page, map[string]interface{}{"sort": "title"}, "'sort' cannot be combined with cursor pagination"},
+		{"sorted search with cursors", page, map[string]interface{}{"sort": "title", "search": "budget"}, "'sort' cannot be combined with cursor pagination"},
+	} {
+		_, err := db.FetchAllVotes(context.Background(), 0, false, 0, 0, c.Page, c.Params)
+		if _, ok := err.(*ArgumentError); !ok || !strings.Contains(err.Error(), c.Error) {
+			t.Errorf("%s: exp an argument error %q, got %v", c.Name, c.Error, err)
+		}
+	}
+
+	for _, query := range []string{"", "  "} {
+		_, err := db.FetchSearchProposals(context.Background(), query, 0, "", 0, 0)
+		if _, ok := err.(*ArgumentError); !ok {
+			t.Errorf("exp an empty query %q to be an argument error, got %v", query, err)
+		}
+	}
+	if _, err := db.FetchSearchProposals(context.Background(), "budget", 0, "over", 0, 0); err == nil {
+		t.Errorf("exp an invalid status to fail the search")
+	}
+
+	// Arguments are checked before any sql is run
+	if len(r.queries) != 0 {
+		t.Errorf("exp no sql, got %v", r.queries)
+	}
+}
+
+func TestFetchAllVotesSearchSQL(t *testing.T) {
+	for _, c := range []struct {
+		Name   string
+		Page   *PageArgs
+		Params map[string]interface{}
+		// Order is the end of the sql, after its last 'ORDER BY'
+		Order string
+	}{
+		// Best matches first
+		{"search", nil, map[string]interface{}{"search": "budget"}, "search_rank DESC, block_height DESC LIMIT 10 OFFSET 5"},
+		// A sort replaces the ranking
+		{"sorted", nil, map[string]interface{}{"search": "budget", "sort": "title", "sortOrder": "ASC"}, "title ASC LIMIT 10 OFFSET 5"},
+		// Cursors are by block height only
+		{"cursors", &PageArgs{First: 3}, map[string]interface{}{"search": "budget"}, "block_height ASC, entry_hash ASC LIMIT 4"},
+	} {
+		db, r := recordingDB(t)
+		if _, err := db.FetchAllVotes(context.Background(), 0, false, 10, 5, c.Page, c.Params); err != nil {
+			t.Fatalf("%s: %s", c.Name, err.Error())
+		}
+		if len(r.queries) != 1 {
+			t.Fatalf("%s: exp a single query, got %v", c.Name, r.queries)
+		}
+		q := r.queries[0]
+
+		order := q[strings.LastIndex(q, "ORDER BY ")+len("ORDER BY "):]
+		if order != c.Order {
+			t.Errorf("%s: exp the order %q, got %q in\n%s", c.Name, c.Order, order, q)
+		}
+		if strings.Count(q, "search_rank") != 1+strings.Count(c.Order, "search_rank") {
+			t.Errorf("%s: exp the rank to be selected once, got\n%s", c.Name, q)
+		}
+		if !strings.Contains(q, "WHERE search_vector @@ plainto_tsquery('english', $5)") {
+			t.Errorf("%s: exp the match to be filtered on, got\n%s", c.Name, q)
+		}
+
+		// The rank, three highlights and the filter each take the search
+		args := r.args[0]
+		if len(args) != 5 {
+			t.Fatalf("%s: exp 5 args, got %v", c.Name, args)
+		}
+		for _, a := range args {
+			if a != "budget" {
+				t.Errorf("%s: exp every arg to be the search, got %v", c.Name, args)
+			}
+		}
+	}
+}
+
+func TestFetchSearchProposals(t *testing.T) {
+	db, r := recordingDB(t, searchRow("chain1", "Budget", 100, 0.5), searchRow("chain2", "Budget 2", 90, 0.25))
+
+	list, err := db.FetchSearchProposals(context.Background(), "budget", 1, "complete", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := r.queries[0]
+	for _, where := range []string{"registered = TRUE", "reveal_stop <= (SELECT max(block_height) FROM completed)"} {
+		if !strings.Contains(q, where) {
+			t.Errorf("exp the search to filter by %q, got\n%s", where, q)
+		}
+	}
+
+	if list.Info.TotalCount != 2 || list.Info.Limit != 10 || len(list.Results) != 2 {
+		t.Fatalf("exp 2 results of 2, got %+v", list)
+	}
+	m := list.Results[0]
+	if m.Proposal.Chainid != "chain1" || m.Score != 0.5 {
+		t.Errorf("exp the best match first, got %+v", m)
+	}
+	if m.Highlight != (SearchHighlight{Title: "<b>Budget</b>", Text: "the <b>text</b>", Href: "href"}) {
+		t.Errorf("exp the highlights of the match, got %+v", m.Highlight)
+	}
+	if m.Proposal.Search == nil || m.Proposal.Search.Score != 0.5 {
+		t.Errorf("exp the search match on the vote, got %+v", m.Proposal.Search)
+	}
+	if list.Results[1].Proposal.Chainid != "chain2" || list.Results[1].Score != 0.25 {
+		t.Errorf("exp the second match, got %+v", list.Results[1])
+	}
+}
+
+func TestRESTSearch(t *testing.T) {
+	db, r := recordingDB(t)
+	srv := new(GraphQLServer)
+	srv.Limits = DefaultLimits()
+	srv.SQLDB = *db
+	h := srv.RESTHandler()
+
+	for _, c := range []struct {
+		Path   string
+		Status int
+	}{
+		{"/v1/votes?search=budget&sort=title&first=2", http.StatusBadRequest},
+		{"/v1/votes?search=budget&sort=score", http.StatusBadRequest},
+		{"/v1/votes?search=budget&status=finished", http.StatusBadRequest},
+		{"/v1/votes?search=budget&first=2", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", c.Path, nil))
+		if rec.Code != c.Status {
+			t.Errorf("%s: exp %d, got %d: %s", c.Path, c.Status, rec.Code, rec.Body.String())
+		}
+	}
+	if len(r.queries) != 1 {
+		t.Errorf("exp only the valid search to run sql, got %d queries", len(r.queries))
+	}
+}
@@ -14,9 +14,10 @@ import (
 )
 
 type ProposalEntry struct {
-	VoterId   string  `json:"voterId"`
-	Weight    float64 `json:"weight"`
-	EntryHash string  `json:"entryHash"`
+	VoteChain string         `json:"voteChain"`
+	VoterId   string         `json:"voterId"`
+	Weight    common.Decimal `json:"weight"`
+	EntryHash string         `json:"entryHash"`
 
 	// These can be null
 	Commit sql.NullString `json:"commit"`
@@ -89,8 +90,8 @@ var ProposalEntryGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 			Description: "EntryHash of the entry that added the voter to the eligible list",
 		},
 		"weight": &graphql.Field{
-			Type:        graphql.Float,
-			Description: "Voter's voting weight",
+			Type:        graphql.String,
+			Description: "Voter's voting weight, an exact decimal",
 		},
 		"commit": &graphql.Field{
 			Type:        graphql.String,
@@ -135,11 +136,17 @@ type ListInfo struct {
 type VoteList struct {
 	Info  ListInfo `json:"listInfo"`
 	Votes []Vote   `json:"voteList"`
+
+	Edges    []Edge   `json:"edges"`
+	PageInfo PageInfo `json:"pageInfo"`
 }
 
 type VoteResultList struct {
 	Info  ListInfo           `json:"listInfo"`
 	Votes []common.VoteStats `json:"resultList"`
+
+	Edges    []Edge   `json:"edges"`
+	PageInfo PageInfo `json:"pageInfo"`
 }
 
 var VoteListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
@@ -153,6 +160,12 @@ var VoteListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 		"voteList": &graphql.Field{
 			Type: graphql.NewList(VoteGraphQLType),
 		},
+		"edges": &graphql.Field{
+			Type: graphql.NewList(EdgeGraphQLType("Vote", VoteGraphQLType)),
+		},
+		"pageInfo": &graphql.Field{
+			Type: PageInfoGraphQLType,
+		},
 	}})
 
 type Vote struct {
@@ -162,6 +175,9 @@ type Vote struct {
 	Results    VoteResult     `json:"result"`
 
 	Proposal VoteDetails `json:"proposal"` // Title, description, etc
+
+	// Only set when listed by a search
+	Search *SearchMatch `json:"search,omitempty"`
 }
 
 var VoteGraphQLType = graphql.NewObject(graphql.ObjectConfig{
@@ -181,6 +197,10 @@ var VoteGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 		"proposal": &graphql.Field{
 			Type: VAVoteInfoGraphQLType,
 		},
+		"search": &graphql.Field{
+			Description: "How the vote matched the 'search' it was listed by. Null if not searched.",
+			Type:        SearchMatchGraphQLType,
+		},
 	}})
 
 type VoteAdmin struct {
@@ -415,7 +435,7 @@ var VDConfigGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 					return nil, err
 				}
 
-				if crit.MinTurnout.Weighted+crit.MinTurnout.Unweighted == 0 {
+				if crit.MinTurnout.Weighted.Add(crit.MinTurnout.Unweighted).IsZero() {
 					return nil, nil
 				}
 
@@ -460,6 +480,9 @@ type VoteResult struct {
 type VoteCommitContainer struct {
 	Commits []VoteCommit `json:"commits"`
 	Info    ListInfo     `json:"listInfo"`
+
+	Edges    []Edge   `json:"edges"`
+	PageInfo PageInfo `json:"pageInfo"`
 }
 
 var CommitListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
@@ -472,6 +495,12 @@ var CommitListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 		"commits": &graphql.Field{
 			Type: graphql.NewList(VoteCommitGraphQLType),
 		},
+		"edges": &graphql.Field{
+			Type: graphql.NewList(EdgeGraphQLType("VoteCommit", VoteCommitGraphQLType)),
+		},
+		"pageInfo": &graphql.Field{
+			Type: PageInfoGraphQLType,
+		},
 	}})
 
 type VoteCommit struct {
@@ -513,6 +542,9 @@ var VoteCommitGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 type VoteRevealContainer struct {
 	Reveals []VoteReveal `json:"reveals"`
 	Info    ListInfo     `json:"listInfo"`
+
+	Edges    []Edge   `json:"edges"`
+	PageInfo PageInfo `json:"pageInfo"`
 }
 
 var RevealListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
@@ -525,6 +557,12 @@ var RevealListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 		"reveals": &graphql.Field{
 			Type: graphql.NewList(VoteRevealGraphQLType),
 		},
+		"edges": &graphql.Field{
+			Type: graphql.NewList(EdgeGraphQLType("VoteReveal", VoteRevealGraphQLType)),
+		},
+		"pageInfo": &graphql.Field{
+			Type: PageInfoGraphQLType,
+		},
 	}})
 
 type VoteReveal struct {
@@ -570,24 +608,29 @@ var VoteRevealGraphQLType = graphql.NewObject(graphql.ObjectConfig{
  */
 
 type EligibleList struct {
-	Admin struct {
-		ChainID    string `json:"chainId"`
-		Initiator  string `json:"initiator"`
-		Nonce      string `json:"nonce"`
-		SigningKey string `json:"signingKey"`
-		Signature  string `json:"signature"`
-	} `json:"admin"`
+	Admin EligibleListAdmin `json:"admin"`
+}
+
+type EligibleListAdmin struct {
+	ChainID    string `json:"chainId"`
+	Initiator  string `json:"initiator"`
+	Nonce      string `json:"nonce"`
+	SigningKey string `json:"signingKey"`
+	Signature  string `json:"signature"`
 }
 
 type EligibleVoterContainer struct {
 	EligibleVoters []EligibleVoter `json:"voters"`
 	Info           ListInfo        `json:"listInfo"`
+
+	Edges    []Edge   `json:"edges"`
+	PageInfo PageInfo `json:"pageInfo"`
 }
 
 type EligibleVoter struct {
 	// Given by Entry
-	VoterID    string  `json:"voterId"`
-	VoteWeight float64 `json:"weight"`
+	VoterID    string         `json:"voterId"`
+	VoteWeight common.Decimal `json:"weight"`
 
 	// Given by entry context
 	BlockHeight  int    `json:"blockHeight"`
@@ -608,6 +651,12 @@ var ELContainerGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 			Description: "TODO: Should allow this to be broken up",
 			Type:        graphql.NewList(ELVoter),
 		},
+		"edges": &graphql.Field{
+			Type: graphql.NewList(EdgeGraphQLType("EligibleVoter", ELVoter)),
+		},
+		"pageInfo": &graphql.Field{
+			Type: PageInfoGraphQLType,
+		},
 	}})
 
 var ELVoter = graphql.NewObject(graphql.ObjectConfig{
@@ -617,7 +666,8 @@ var ELVoter = graphql.NewObject(graphql.ObjectConfig{
 			Type: graphql.String,
 		},
 		"weight": &graphql.Field{
-			Type: graphql.Float,
+			Description: "An exact decimal",
+			Type:        graphql.String,
 		},
 		"blockHeight": &graphql.Field{
 			Type: graphql.Int,
@@ -663,6 +713,45 @@ var VoteResultsListGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 		"resultList": &graphql.Field{
 			Type: graphql.NewList(VoteResultsGraphQLType),
 		},
+		"edges": &graphql.Field{
+			Type: graphql.NewList(EdgeGraphQLType("VoteResults", VoteResultsGraphQLType)),
+		},
+		"pageInfo": &graphql.Field{
+			Type: PageInfoGraphQLType,
+		},
+	}})
+
+var BallotGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Ballot",
+	Description: "How a reveal was tallied",
+	Fields: graphql.Fields{
+		"voterId": &graphql.Field{
+			Type: graphql.String,
+		},
+		"entryHash": &graphql.Field{
+			Description: "Entry hash of the reveal",
+			Type:        graphql.String,
+		},
+		"options": &graphql.Field{
+			Description: "Options of the reveal, as given",
+			Type:        graphql.NewList(graphql.String),
+		},
+		"status": &graphql.Field{
+			Description: "counted or excluded",
+			Type:        graphql.String,
+		},
+		"reason": &graphql.Field{
+			Description: "Why the reveal was excluded",
+			Type:        graphql.String,
+		},
+		"weight": &graphql.Field{
+			Description: "Weight applied to the tally, 0 if excluded",
+			Type:        graphql.String,
+		},
+		"rounds": &graphql.Field{
+			Description: "IRV only. The option the ballot counted toward in each round, empty once all its options are eliminated",
+			Type:        graphql.NewList(graphql.String),
+		},
 	}})
 
 var VoteResultsGraphQLType = graphql.NewObject(graphql.ObjectConfig{
@@ -702,6 +791,39 @@ var VoteResultsGraphQLType = graphql.NewObject(graphql.ObjectConfig{
 			Description: "Winner(s) of the vote",
 			Type:        JSON,
 		},
+		"provisional": &graphql.Field{
+			Description: "The vote is not complete, and the results are of the reveals so far",
+			Type:        graphql.Boolean,
+		},
+		"unrevealed": &graphql.Field{
+			Description: "Provisional only. Count and weight of the committed voters, those that have not revealed, and their share",
+			Type:        JSON,
+		},
+		"ballots": &graphql.Field{
+			Description: "Every reveal, and whether it was counted or why not",
+			Type:        graphql.NewList(BallotGraphQLType),
+		},
+		"algorithmVersion": &graphql.Field{
+			Description: "Version of the tally the results were computed with, 0 if before versioning",
+			Type:        graphql.Int,
+		},
+		"inputHash": &graphql.Field{
+			Description: "sha256 of the vote config, eligible voters and reveals the results were computed from",
+			Type:        graphql.String,
+		},
+		"outdated": &graphql.Field{
+			Description: "The results were computed with an older version of the tally",
+			Type:        graphql.Boolean,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				switch r := p.Source.(type) {
+				case *common.VoteStats:
+					return r.AlgorithmVersion < common.ResultsAlgorithmVersion, nil
+				case common.VoteStats:
+					return r.AlgorithmVersion < common.ResultsAlgorithmVersion, nil
+				}
+				return nil, nil
+			},
+		},
 	}})
 
 // JSON json type
@@ -747,3 +869,20 @@ func parseLiteral(astValue ast.Value) interface{} {
 		return nil
 	}
 }
+
+var PhaseChangeGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "PhaseChange",
+	Description: "A vote moving into its commit, reveal or complete phase",
+	Fields: graphql.Fields{
+		"chain": &graphql.Field{
+			Type: graphql.String,
+		},
+		"phase": &graphql.Field{
+			Type:        graphql.String,
+			Description: "The phase entered: 'commit', 'reveal', or 'complete'",
+		},
+		"height": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "The block height the phase changed at",
+		},
+	}})
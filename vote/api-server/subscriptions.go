@@ -0,0 +1,111 @@
+package apiserver
+
+import (
+	"fmt"
+
+	"github.com/Emyrk/go-factom-vote/notify"
+	"github.com/graphql-go/graphql"
+)
+
+// subscriptionFields are the root subscription fields. Each pushes a result
+// whenever the scraper publishes a matching event.
+func (s *GraphQLServer) subscriptionFields() graphql.Fields {
+	chainArg := func(required bool) graphql.FieldConfigArgument {
+		t := graphql.Input(graphql.String)
+		if required {
+			t = graphql.NewNonNull(graphql.String)
+		}
+		return graphql.FieldConfigArgument{
+			"chain": &graphql.ArgumentConfig{
+				Description: "Proposal chain id",
+				Type:        t,
+			},
+		}
+	}
+
+	return graphql.Fields{
+		"voteUpdated": &graphql.Field{
+			Type:        VoteGraphQLType,
+			Description: "The proposal, each time it is added, registered, or has its results computed",
+			Args:        chainArg(true),
+			Subscribe:   s.subscribe(notify.VoteUpdated),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return s.SQLDB.FetchVote(p.Context, p.Source.(notify.Event).Chain)
+			},
+		},
+		"newCommit": &graphql.Field{
+			Type:        VoteCommitGraphQLType,
+			Description: "Every commit accepted into the vote",
+			Args:        chainArg(true),
+			Subscribe:   s.subscribe(notify.NewCommit),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				e := p.Source.(notify.Event)
+				return s.SQLDB.FetchCommit(p.Context, e.VoterID, e.Chain)
+			},
+		},
+		"newReveal": &graphql.Field{
+			Type:        VoteRevealGraphQLType,
+			Description: "Every reveal accepted into the vote",
+			Args:        chainArg(true),
+			Subscribe:   s.subscribe(notify.NewReveal),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				e := p.Source.(notify.Event)
+				return s.SQLDB.FetchReveal(p.Context, e.VoterID, e.Chain)
+			},
+		},
+		"phaseChanged": &graphql.Field{
+			Type:        PhaseChangeGraphQLType,
+			Description: "Votes moving into a new phase. All votes if no chain is given",
+			Args:        chainArg(false),
+			Subscribe:   s.subscribe(notify.PhaseChanged),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source, nil
+			},
+		},
+		"syncedHeight": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "The height of every block applied to the database",
+			Subscribe:   s.subscribe(notify.BlockApplied),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(notify.Event).Height, nil
+			},
+		},
+	}
+}
+
+// subscribe returns a subscriber that feeds events of the given kind, and for
+// the 'chain' argument if given, until the subscription's context is done.
+func (s *GraphQLServer) subscribe(kind string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if s.Events == nil {
+			return nil, fmt.Errorf("subscriptions are not available, this server does not receive events from the scraper")
+		}
+		chain, _ := p.Args["chain"].(string)
+
+		events, unsubscribe := s.Events.Subscribe(16)
+		results := make(chan interface{})
+		go func() {
+			defer close(results)
+			defer unsubscribe()
+			for {
+				select {
+				case <-p.Context.Done():
+					return
+				case e, ok := <-events:
+					if !ok {
+						return
+					}
+					if e.Kind != kind || (chain != "" && e.Chain != chain) {
+						continue
+					}
+					select {
+					case results <- e:
+					case <-p.Context.Done():
+						return
+					}
+				}
+			}
+		}()
+		return results, nil
+	}
+}
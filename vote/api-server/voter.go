@@ -0,0 +1,405 @@
+package apiserver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/graphql-go/graphql"
+)
+
+// A voter's profile is everything the database knows about one identity: the
+// eligible lists it has been on, and every vote it could take part in, or
+// tried to.
+
+// Outcomes of a voter's participation in a vote
+const (
+	OutcomePending  = "pending"  // The vote has not reached the end of the voter's next phase
+	OutcomeMissed   = "missed"   // The voter did not commit, or did not reveal, in time
+	OutcomeRejected = "rejected" // The voter revealed, but it does not count
+	OutcomeCounted  = "counted"  // The voter's reveal is in the results
+)
+
+type VoterProfile struct {
+	VoterID       string              `json:"voterId"`
+	Memberships   []VoterMembership   `json:"memberships"`
+	Participation []VoteParticipation `json:"participation"`
+	Rates         ParticipationRates  `json:"rates"`
+}
+
+// VoterMembership is the voter's weight in an eligible list, and every change
+// to it. A weight of 0 removes the voter from the list.
+type VoterMembership struct {
+	EligibleList string         `json:"eligibleList"`
+	Weight       common.Decimal `json:"weight"`
+	History      []WeightChange `json:"history"`
+}
+
+type WeightChange struct {
+	Weight      common.Decimal `json:"weight"`
+	BlockHeight int            `json:"blockHeight"`
+	EntryHash   string         `json:"entryHash"`
+}
+
+// VoteParticipation is what the voter did in a vote they were eligible for,
+// or committed to.
+type VoteParticipation struct {
+	VoteChain    string         `json:"voteChain"`
+	Title        string         `json:"title"`
+	EligibleList string         `json:"eligibleList"`
+	Eligible     bool           `json:"eligible"`
+	Weight       common.Decimal `json:"weight"`
+
+	Complete  bool    `json:"complete"`
+	Committed bool    `json:"committed"`
+	Commit    *string `json:"commit"`
+	Revealed  bool    `json:"revealed"`
+	Reveal    *string `json:"reveal"`
+	Counted   bool    `json:"counted"`
+
+	Outcome        string  `json:"outcome"`
+	RejectedReason *string `json:"rejectedReason"`
+}
+
+// ParticipationRates are taken over the complete votes the voter was eligible
+// for, as the outcome of the others can still change.
+type ParticipationRates struct {
+	Eligible    int     `json:"eligible"`
+	Complete    int     `json:"complete"`
+	Committed   int     `json:"committed"`
+	Revealed    int     `json:"revealed"`
+	Counted     int     `json:"counted"`
+	Rejected    int     `json:"rejected"`
+	Missed      int     `json:"missed"`
+	CommitRate  float64 `json:"commitRate"`
+	RevealRate  float64 `json:"revealRate"`
+	CountedRate float64 `json:"countedRate"`
+}
+
+var WeightChangeGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "WeightChange",
+	Description: "An entry setting the voter's weight in an eligible list",
+	Fields: graphql.Fields{
+		"weight": &graphql.Field{
+			Description: "0 removes the voter from the list",
+			Type:        graphql.String,
+		},
+		"blockHeight": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"entryHash": &graphql.Field{
+			Type: graphql.String,
+		},
+	}})
+
+var VoterMembershipGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "VoterMembership",
+	Description: "The voter's weight in an eligible list",
+	Fields: graphql.Fields{
+		"eligibleList": &graphql.Field{
+			Description: "Chain ID of the eligible list",
+			Type:        graphql.String,
+		},
+		"weight": &graphql.Field{
+			Description: "Current weight. 0 if the voter has been removed",
+			Type:        graphql.String,
+		},
+		"history": &graphql.Field{
+			Description: "Every change to the weight, oldest first",
+			Type:        graphql.NewList(WeightChangeGraphQLType),
+		},
+	}})
+
+var VoteParticipationGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "VoteParticipation",
+	Description: "What a voter did in a vote",
+	Fields: graphql.Fields{
+		"voteChain": &graphql.Field{
+			Type: graphql.String,
+		},
+		"title": &graphql.Field{
+			Type: graphql.String,
+		},
+		"eligibleList": &graphql.Field{
+			Type: graphql.String,
+		},
+		"eligible": &graphql.Field{
+			Description: "If the voter was on the eligible list at the start of the commit phase",
+			Type:        graphql.Boolean,
+		},
+		"weight": &graphql.Field{
+			Description: "Weight at the start of the commit phase",
+			Type:        graphql.String,
+		},
+		"complete": &graphql.Field{
+			Description: "If the results of the vote are computed",
+			Type:        graphql.Boolean,
+		},
+		"committed": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+		"commit": &graphql.Field{
+			Description: "Entryhash of the commit (if exists)",
+			Type:        graphql.String,
+		},
+		"revealed": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+		"reveal": &graphql.Field{
+			Description: "Entryhash of the reveal (if exists)",
+			Type:        graphql.String,
+		},
+		"counted": &graphql.Field{
+			Description: "If the reveal is in the results of the complete vote",
+			Type:        graphql.Boolean,
+		},
+		"outcome": &graphql.Field{
+			Description: "One of 'pending', 'missed', 'rejected' or 'counted'",
+			Type:        graphql.String,
+		},
+		"rejectedReason": &graphql.Field{
+			Description: "Why the vote does not count, if rejected",
+			Type:        graphql.String,
+		},
+	}})
+
+var ParticipationRatesGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ParticipationRates",
+	Description: "Participation over the complete votes the voter was eligible for",
+	Fields: graphql.Fields{
+		"eligible": &graphql.Field{
+			Description: "Votes the voter was eligible for, complete or not",
+			Type:        graphql.Int,
+		},
+		"complete": &graphql.Field{
+			Description: "Complete votes the voter was eligible for. The rates are out of these",
+			Type:        graphql.Int,
+		},
+		"committed": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"revealed": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"counted": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"rejected": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"missed": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"commitRate": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"revealRate": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"countedRate": &graphql.Field{
+			Type: graphql.Float,
+		},
+	}})
+
+var VoterProfileGraphQLType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Voter",
+	Description: "A voter's history across eligible lists and votes",
+	Fields: graphql.Fields{
+		"voterId": &graphql.Field{
+			Type: graphql.String,
+		},
+		"memberships": &graphql.Field{
+			Description: "Eligible lists the voter is, or has been, on",
+			Type:        graphql.NewList(VoterMembershipGraphQLType),
+		},
+		"participation": &graphql.Field{
+			Description: "Votes the voter was eligible for, or committed to. Newest first",
+			Type:        graphql.NewList(VoteParticipationGraphQLType),
+		},
+		"rates": &graphql.Field{
+			Type: ParticipationRatesGraphQLType,
+		},
+	}})
+
+func (s *GraphQLServer) voter() *graphql.Field {
+	return &graphql.Field{
+		Type:        VoterProfileGraphQLType,
+		Description: "The eligible lists, votes and participation of a voter",
+		Args: graphql.FieldConfigArgument{
+			"id": &graphql.ArgumentConfig{
+				Description: "Identity chain id of the voter",
+				Type:        graphql.NewNonNull(graphql.String),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			id := p.Args["id"].(string)
+			return s.SQLDB.FetchVoterProfile(p.Context, id)
+		},
+	}
+}
+
+// FetchVoterProfile returns the memberships and participation of the voter.
+// An identity that has never been on a list has an empty profile.
+func (g *GraphQLSQLDB) FetchVoterProfile(ctx context.Context, voterID string) (*VoterProfile, error) {
+	voterID, err := chainIDArg("voter", voterID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &VoterProfile{VoterID: voterID}
+	profile.Memberships, err = g.fetchVoterMemberships(ctx, voterID)
+	if err != nil {
+		return nil, err
+	}
+	profile.Participation, err = g.fetchVoterParticipation(ctx, voterID)
+	if err != nil {
+		return nil, err
+	}
+	profile.Rates = participationRates(profile.Participation)
+	return profile, nil
+}
+
+func (g *GraphQLSQLDB) fetchVoterMemberships(ctx context.Context, voterID string) ([]VoterMembership, error) {
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, `SELECT eligible_list, weight, block_height, entry_hash FROM eligible_voters
+		WHERE voter_id = $1 ORDER BY eligible_list, block_height, id`, voterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []VoterMembership{}
+	for rows.Next() {
+		var list string
+		var change WeightChange
+		if err := rows.Scan(&list, &change.Weight, &change.BlockHeight, &change.EntryHash); err != nil {
+			return nil, err
+		}
+
+		if n := len(memberships); n == 0 || memberships[n-1].EligibleList != list {
+			memberships = append(memberships, VoterMembership{EligibleList: list})
+		}
+		m := &memberships[len(memberships)-1]
+		m.History = append(m.History, change)
+		m.Weight = change.Weight
+	}
+	return memberships, rows.Err()
+}
+
+// voterParticipationQuery finds the votes the voter was on the eligible list
+// of at the start of the commit phase, and those the voter committed to
+// anyway, with the voter's commit and reveal.
+const voterParticipationQuery = `WITH candidates AS (
+		SELECT chain_id FROM proposals WHERE eligible_voter_chain IN (SELECT eligible_list FROM eligible_voters WHERE voter_id = $1)
+		UNION SELECT vote_chain FROM commits WHERE voter_id = $1
+		UNION SELECT vote_chain FROM reveals WHERE voter_id = $1
+	)
+	SELECT p.chain_id, coalesce(p.title, ''), p.eligible_voter_chain, p.commit_stop, p.reveal_stop, p.complete,
+		p.vote_options, p.vote_min_options, p.vote_max_options, p.vote_allow_abstain,
+		ev.voter_id IS NOT NULL, coalesce(ev.weight, 0), c.entry_hash, r.entry_hash, coalesce(r.vote, ''),
+		coalesce((SELECT max(block_height) FROM completed), 0)
+	FROM proposals AS p
+		JOIN candidates ON candidates.chain_id = p.chain_id
+		LEFT JOIN LATERAL (SELECT * FROM fetch_eligible_voters(p.eligible_voter_chain, p.commit_start) AS voters
+			WHERE voters.voter_id = $1) AS ev ON TRUE
+		LEFT JOIN commits AS c ON c.vote_chain = p.chain_id AND c.voter_id = $1
+		LEFT JOIN reveals AS r ON r.vote_chain = p.chain_id AND r.voter_id = $1
+	ORDER BY p.block_height DESC, p.entry_hash`
+
+func (g *GraphQLSQLDB) fetchVoterParticipation(ctx context.Context, voterID string) ([]VoteParticipation, error) {
+	rows, err := g.SQLDatabase.DB.QueryContext(ctx, voterParticipationQuery, voterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participation := []VoteParticipation{}
+	for rows.Next() {
+		var v VoteParticipation
+		var commitStop, revealStop, minOptions, maxOptions, height int
+		var allowAbstain bool
+		var options, vote string
+		var commit, reveal sql.NullString
+		err := rows.Scan(&v.VoteChain, &v.Title, &v.EligibleList, &commitStop, &revealStop, &v.Complete,
+			&options, &minOptions, &maxOptions, &allowAbstain,
+			&v.Eligible, &v.Weight, &commit, &reveal, &vote,
+			&height)
+		if err != nil {
+			return nil, err
+		}
+		if commit.Valid {
+			v.Committed = true
+			v.Commit = &commit.String
+		}
+		if reveal.Valid {
+			v.Revealed = true
+			v.Reveal = &reveal.String
+		}
+
+		// The same rules the results are computed by
+		var rejected error
+		switch {
+		case !v.Eligible:
+			rejected = fmt.Errorf("not an eligible voter")
+		case v.Revealed:
+			rejected = common.CheckVoteOptions(common.SplitString(vote, ","), common.SplitString(options, ","), minOptions, maxOptions, allowAbstain)
+		}
+
+		switch {
+		case rejected != nil:
+			v.Outcome = OutcomeRejected
+			reason := rejected.Error()
+			v.RejectedReason = &reason
+		case v.Revealed && v.Complete:
+			v.Outcome = OutcomeCounted
+			v.Counted = true
+		case v.Revealed:
+			v.Outcome = OutcomePending
+		case v.Committed && revealStop <= height:
+			v.Outcome = OutcomeMissed
+		case !v.Committed && commitStop <= height:
+			v.Outcome = OutcomeMissed
+		default:
+			v.Outcome = OutcomePending
+		}
+		participation = append(participation, v)
+	}
+	return participation, rows.Err()
+}
+
+func participationRates(participation []VoteParticipation) ParticipationRates {
+	var r ParticipationRates
+	for _, v := range participation {
+		if !v.Eligible {
+			continue
+		}
+		r.Eligible++
+		if !v.Complete {
+			continue
+		}
+
+		r.Complete++
+		if v.Committed {
+			r.Committed++
+		}
+		if v.Revealed {
+			r.Revealed++
+		}
+		switch v.Outcome {
+		case OutcomeCounted:
+			r.Counted++
+		case OutcomeRejected:
+			r.Rejected++
+		case OutcomeMissed:
+			r.Missed++
+		}
+	}
+
+	if r.Complete > 0 {
+		total := float64(r.Complete)
+		r.CommitRate = float64(r.Committed) / total
+		r.RevealRate = float64(r.Revealed) / total
+		r.CountedRate = float64(r.Counted) / total
+	}
+	return r
+}
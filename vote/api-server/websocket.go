@@ -0,0 +1,197 @@
+package apiserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	log "github.com/sirupsen/logrus"
+)
+
+var wslog = log.WithFields(log.Fields{"file": "websocket.go"})
+
+// The graphql-ws protocol, as spoken by apollo's subscriptions-transport-ws
+// https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md
+const (
+	gqlConnectionInit      = "connection_init"
+	gqlConnectionAck       = "connection_ack"
+	gqlConnectionError     = "connection_error"
+	gqlConnectionKeepAlive = "ka"
+	gqlConnectionTerminate = "connection_terminate"
+	gqlStart               = "start"
+	gqlStop                = "stop"
+	gqlData                = "data"
+	gqlError               = "error"
+	gqlComplete            = "complete"
+
+	keepAliveInterval = 20 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{"graphql-ws"},
+	// Cors is disabled for the http api, so any origin can subscribe as well
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsStartPayload struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// wsConnection is a single websocket client, which can run many subscriptions
+type wsConnection struct {
+	conn   *websocket.Conn
+	schema graphql.Schema
+	limits *Limits
+
+	// gorilla allows only one concurrent writer
+	writeLock sync.Mutex
+
+	sync.Mutex
+	subscriptions map[string]context.CancelFunc
+}
+
+func (s *GraphQLServer) serveSubscriptions(w http.ResponseWriter, r *http.Request, schema graphql.Schema) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		wslog.WithField("func", "serveSubscriptions").Error(err)
+		return
+	}
+
+	c := new(wsConnection)
+	c.conn = conn
+	c.schema = schema
+	c.limits = &s.Limits
+	c.subscriptions = make(map[string]context.CancelFunc)
+
+	ctx, cancel := context.WithCancel(WithFetcher(r.Context(), &s.SQLDB))
+	defer cancel()
+	defer conn.Close()
+	c.readLoop(ctx)
+}
+
+func (c *wsConnection) readLoop(ctx context.Context) {
+	flog := wslog.WithField("func", "readLoop")
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				flog.Debug(err)
+			}
+			return
+		}
+
+		switch msg.Type {
+		case gqlConnectionInit:
+			c.write(wsMessage{Type: gqlConnectionAck})
+			go c.keepAlive(ctx)
+		case gqlStart:
+			var payload wsStartPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				c.writeError(msg.ID, err)
+				continue
+			}
+			c.start(ctx, msg.ID, payload)
+		case gqlStop:
+			c.stop(msg.ID)
+		case gqlConnectionTerminate:
+			return
+		default:
+			c.write(wsMessage{Type: gqlConnectionError, Payload: errorPayload("unknown message type " + msg.Type)})
+		}
+	}
+}
+
+// start runs the subscription until it is stopped or the connection closes
+func (c *wsConnection) start(ctx context.Context, id string, payload wsStartPayload) {
+	if err := c.limits.Check(c.schema, payload.Query, payload.Variables, payload.OperationName); err != nil {
+		c.writeError(id, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.Lock()
+	if old, ok := c.subscriptions[id]; ok {
+		old()
+	}
+	c.subscriptions[id] = cancel
+	c.Unlock()
+
+	results := graphql.Subscribe(graphql.Params{
+		Schema:         c.schema,
+		RequestString:  payload.Query,
+		VariableValues: payload.Variables,
+		OperationName:  payload.OperationName,
+		Context:        ctx,
+	})
+
+	go func() {
+		// The results must be drained until closed, or the graphql executor
+		// blocks forever sending the last one.
+		for res := range results {
+			if ctx.Err() != nil {
+				continue
+			}
+			data, err := json.Marshal(res)
+			if err != nil {
+				c.writeError(id, err)
+				continue
+			}
+			c.write(wsMessage{ID: id, Type: gqlData, Payload: data})
+		}
+		if ctx.Err() == nil {
+			c.write(wsMessage{ID: id, Type: gqlComplete})
+		}
+		c.stop(id)
+	}()
+}
+
+func (c *wsConnection) stop(id string) {
+	c.Lock()
+	defer c.Unlock()
+	if cancel, ok := c.subscriptions[id]; ok {
+		cancel()
+		delete(c.subscriptions, id)
+	}
+}
+
+func (c *wsConnection) keepAlive(ctx context.Context) {
+	t := time.NewTicker(keepAliveInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.write(wsMessage{Type: gqlConnectionKeepAlive})
+		}
+	}
+}
+
+func (c *wsConnection) write(msg wsMessage) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		wslog.WithFields(log.Fields{"func": "write", "type": msg.Type}).Debug(err)
+	}
+}
+
+func (c *wsConnection) writeError(id string, err error) {
+	c.write(wsMessage{ID: id, Type: gqlError, Payload: errorPayload(err.Error())})
+}
+
+func errorPayload(message string) json.RawMessage {
+	data, _ := json.Marshal(map[string]string{"message": message})
+	return data
+}
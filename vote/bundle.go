@@ -0,0 +1,115 @@
+package vote
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/FactomProject/factom"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// RecountVote reads the chains of the vote from factomd into a bundle, with
+// the receipt of every entry, and recounts it in memory. The identity keys are
+// looked up on factomd and recorded in the bundle.
+func (c *Controller) RecountVote(ctx context.Context, votechain interfaces.IHash) (*VoteBundle, *Recount, error) {
+	b := new(VoteBundle)
+	b.Version = BundleVersion
+	b.VoteChain = votechain.String()
+
+	head, err := c.Reader.FetchDBlockHead()
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch dblock head: %s", err.Error())
+	}
+	b.BlockHeight = int(head.GetDatabaseHeight())
+
+	voteEntries, err := c.FetchChainEntriesInCreateOrder(votechain)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch vote chain: %s", err.Error())
+	}
+	if len(voteEntries) == 0 {
+		return nil, nil, fmt.Errorf("vote chain %s has no entries", votechain.String())
+	}
+	entries, err := bundleEntries(voteEntries)
+	if err != nil {
+		return nil, nil, err
+	}
+	b.Proposal, b.VoteEntries = entries[0], entries[1:]
+
+	keys := b.RecordKeys(factom.GetActiveIdentityKeysAtHeight)
+	prop, err := NewProposalEntryWithKeys(voteEntries[0].Entry, int(voteEntries[0].BlockHeight), keys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing prop: %s", err.Error())
+	}
+	eligibleEntries, err := c.FetchChainEntriesInCreateOrder(&prop.Vote.EligibleVotersChainID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetch eligible voter chain: %s", err.Error())
+	}
+	if b.EligibleList, err = bundleEntries(eligibleEntries); err != nil {
+		return nil, nil, err
+	}
+
+	r, err := b.Recount(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, r, nil
+}
+
+// ExportVote reads the vote into a bundle, which can be verified with no
+// factomd, along with the entries of the identity chains its keys come from
+func (c *Controller) ExportVote(ctx context.Context, votechain interfaces.IHash) (*VoteBundle, error) {
+	b, _, err := c.RecountVote(ctx, votechain)
+	if err != nil {
+		return nil, err
+	}
+
+	heights := make(map[string]int)
+	var chains []string
+	for _, k := range b.IdentityKeys {
+		if _, ok := heights[k.IdentityChain]; !ok {
+			chains = append(chains, k.IdentityChain)
+		}
+		if k.BlockHeight > heights[k.IdentityChain] {
+			heights[k.IdentityChain] = k.BlockHeight
+		}
+	}
+	for _, chain := range chains {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		id, err := primitives.HexToHash(chain)
+		if err != nil {
+			return nil, err
+		}
+		idEntries, err := c.FetchChainEntriesInCreateOrder(id)
+		if err != nil {
+			return nil, fmt.Errorf("fetch identity chain %s: %s", chain, err.Error())
+		}
+		var below []ParsingEntry
+		for _, e := range idEntries {
+			if int(e.BlockHeight) <= heights[chain] {
+				below = append(below, e)
+			}
+		}
+		entries, err := bundleEntries(below)
+		if err != nil {
+			return nil, err
+		}
+		b.IdentityEntries = append(b.IdentityEntries, entries...)
+	}
+	return b, nil
+}
+
+func bundleEntries(list []ParsingEntry) ([]BundleEntry, error) {
+	var entries []BundleEntry
+	for _, e := range list {
+		b, err := NewBundleEntry(e.Entry, e.EBlock, e.DBlock)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, b)
+	}
+	return entries, nil
+}
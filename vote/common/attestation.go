@@ -0,0 +1,160 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// attestationDomain is prepended to the signed data, so a result signature
+// cannot be passed off as a signature of anything else
+const attestationDomain = "factom-vote result attestation\n"
+
+// ResultAttestation is the signature of a daemon over a result it computed,
+// and the state of the chain it was computed at. It can be verified offline,
+// with only the public key of the daemon.
+type ResultAttestation struct {
+	VoteChain     string `json:"voteChain"`
+	DBlockHeight  int    `json:"dblockHeight"`
+	DBlockKeyMR   string `json:"dblockKeyMR"`
+	EngineVersion string `json:"engineVersion"`
+	// Result is the canonical json of the results that were signed
+	Result    string `json:"result"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// attestationPayload fields are in the order of their names
+type attestationPayload struct {
+	DBlockHeight  int    `json:"dblockHeight"`
+	DBlockKeyMR   string `json:"dblockKeyMR"`
+	EngineVersion string `json:"engineVersion"`
+	Result        string `json:"result"`
+	VoteChain     string `json:"voteChain"`
+}
+
+// SignedData is what the signature is over
+func (a *ResultAttestation) SignedData() []byte {
+	data, _ := marshalCanonical(attestationPayload{
+		DBlockHeight:  a.DBlockHeight,
+		DBlockKeyMR:   a.DBlockKeyMR,
+		EngineVersion: a.EngineVersion,
+		Result:        a.Result,
+		VoteChain:     a.VoteChain,
+	})
+	return append([]byte(attestationDomain), data...)
+}
+
+// Verify checks the signature, and that the signed results are of the vote.
+// It does not check who signed, compare the public key to the one expected.
+func (a *ResultAttestation) Verify() error {
+	pub, err := hex.DecodeString(a.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("public key is not %d bytes of hex", ed25519.PublicKeySize)
+	}
+	sig, err := hex.DecodeString(a.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("signature is not %d bytes of hex", ed25519.SignatureSize)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), a.SignedData(), sig) {
+		return fmt.Errorf("signature is not valid")
+	}
+
+	stats, err := a.Stats()
+	if err != nil {
+		return err
+	}
+	if stats.VoteChain != a.VoteChain {
+		return fmt.Errorf("signed results are of vote %s, not %s", stats.VoteChain, a.VoteChain)
+	}
+	return nil
+}
+
+// Stats are the signed results
+func (a *ResultAttestation) Stats() (*VoteStats, error) {
+	stats := NewVoteStats()
+	if err := json.Unmarshal([]byte(a.Result), stats); err != nil {
+		return nil, fmt.Errorf("signed results: %s", err.Error())
+	}
+	return stats, nil
+}
+
+// CanonicalVoteStatsJSON is the results as signed: the json of the api, with
+// exact decimals, winners sorted by option, and without what is only set on
+// provisional results. The same results are always the same bytes.
+func CanonicalVoteStatsJSON(stats *VoteStats) ([]byte, error) {
+	c := *stats
+	c.Provisional = false
+	c.Unrevealed = nil
+	c.WeightedWinners = append([]VoteOptionStats{}, stats.WeightedWinners...)
+	sort.Slice(c.WeightedWinners, func(i, j int) bool { return c.WeightedWinners[i].Option < c.WeightedWinners[j].Option })
+	return marshalCanonical(c)
+}
+
+// marshalCanonical is json without html escaping, as javascript writes it
+func marshalCanonical(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// ResultSigner signs results with the ed25519 key of the daemon
+type ResultSigner struct {
+	key ed25519.PrivateKey
+}
+
+// NewResultSigner creates a signer from a 32 byte ed25519 seed
+func NewResultSigner(seed []byte) (*ResultSigner, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key must be a %d byte seed, found %d bytes", ed25519.SeedSize, len(seed))
+	}
+	return &ResultSigner{key: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// LoadResultSigner reads the signing key from a file of the hex seed
+func LoadResultSigner(path string) (*ResultSigner, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %s", path, err.Error())
+	}
+	return NewResultSigner(seed)
+}
+
+// PublicKey is the hex public key attestations are verified with
+func (s *ResultSigner) PublicKey() string {
+	return hex.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign attests to the results, computed with the chain synced to the
+// directory block of the height and keymr
+func (s *ResultSigner) Sign(stats *VoteStats, dblockHeight int, dblockKeyMR string) (*ResultAttestation, error) {
+	result, err := CanonicalVoteStatsJSON(stats)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ResultAttestation{
+		VoteChain:     stats.VoteChain,
+		DBlockHeight:  dblockHeight,
+		DBlockKeyMR:   dblockKeyMR,
+		EngineVersion: EngineVersion,
+		Result:        string(result),
+		PublicKey:     s.PublicKey(),
+	}
+	a.Signature = hex.EncodeToString(ed25519.Sign(s.key, a.SignedData()))
+	return a, nil
+}
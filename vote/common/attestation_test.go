@@ -0,0 +1,146 @@
+package common_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+func testSigner(t *testing.T, b byte) *ResultSigner {
+	s, err := NewResultSigner(bytes.Repeat([]byte{b}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func testResults(t *testing.T) *VoteStats {
+	tv := MakeTestVote([]string{"yes", "no"}, 1, 1)
+	tv.SetType(VOTE_BINARY)
+	tv.AddVote([]string{"yes"}, 2)
+	tv.AddVote([]string{"no"}, 1)
+	stats, err := ComputeResult(tv.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func TestResultAttestation(t *testing.T) {
+	signer := testSigner(t, 1)
+	stats := testResults(t)
+	keyMR := "0c5e8c2a2b7d4e0d9b7ae4c4c3a0b4c1c7e1b5f25e3d8a2c1c2e1f0a9b8c7d6e"
+	a, err := signer.Sign(stats, 1000, keyMR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Verify(); err != nil {
+		t.Fatalf("exp a valid attestation, got %s", err.Error())
+	}
+	if a.EngineVersion != EngineVersion || a.VoteChain != stats.VoteChain || a.PublicKey != signer.PublicKey() {
+		t.Errorf("attestation fields not set: %+v", a)
+	}
+
+	// As served by the api, and read back
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := new(ResultAttestation)
+	if err := json.Unmarshal(data, read); err != nil {
+		t.Fatal(err)
+	}
+	if err := read.Verify(); err != nil {
+		t.Errorf("exp the json to verify, got %s", err.Error())
+	}
+	signed, err := read.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := DiffResults(stats, signed); len(diffs) > 0 {
+		t.Errorf("exp the signed results to be the results, differ in %v", diffs)
+	}
+
+	tamper := []struct {
+		Name   string
+		Change func(a *ResultAttestation)
+	}{
+		{"result", func(a *ResultAttestation) { a.Result = strings.Replace(a.Result, `"valid":true`, `"valid":false`, 1) }},
+		{"dblock height", func(a *ResultAttestation) { a.DBlockHeight++ }},
+		{"dblock keymr", func(a *ResultAttestation) { a.DBlockKeyMR = keyMR[:63] + "f" }},
+		{"engine version", func(a *ResultAttestation) { a.EngineVersion = "go-factom-vote/v0.0.0 results/0" }},
+		{"vote chain", func(a *ResultAttestation) { a.VoteChain = keyMR }},
+		{"public key", func(a *ResultAttestation) { a.PublicKey = testSigner(t, 2).PublicKey() }},
+		{"signature", func(a *ResultAttestation) {
+			last := "0"
+			if strings.HasSuffix(a.Signature, last) {
+				last = "1"
+			}
+			a.Signature = a.Signature[:127] + last
+		}},
+	}
+	for _, c := range tamper {
+		changed := *a
+		c.Change(&changed)
+		if changed == *a {
+			t.Fatalf("%s: did not change", c.Name)
+		}
+		if err := changed.Verify(); err == nil {
+			t.Errorf("exp a changed %s to fail", c.Name)
+		}
+	}
+}
+
+func TestCanonicalVoteStatsJSON(t *testing.T) {
+	stats := testResults(t)
+	stats.WeightedWinners = []VoteOptionStats{stats.OptionStats["yes"], stats.OptionStats["no"]}
+	a, err := CanonicalVoteStatsJSON(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The order of the winners, and provisional fields, are not signed
+	stats.WeightedWinners = []VoteOptionStats{stats.OptionStats["no"], stats.OptionStats["yes"]}
+	stats.Provisional = true
+	stats.Unrevealed = new(UnrevealedStats)
+	b, err := CanonicalVoteStatsJSON(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("exp the same bytes\n%s\n%s", a, b)
+	}
+	if stats.WeightedWinners[0].Option != "no" {
+		t.Errorf("exp the results not to be changed")
+	}
+}
+
+func TestLoadResultSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "key")
+	ioutil.WriteFile(path, []byte(hex.EncodeToString(bytes.Repeat([]byte{1}, 32))+"\n"), 0600)
+	s, err := LoadResultSigner(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PublicKey() != testSigner(t, 1).PublicKey() {
+		t.Errorf("exp the key of the seed")
+	}
+
+	ioutil.WriteFile(path, []byte("abcd"), 0600)
+	if _, err := LoadResultSigner(path); err == nil {
+		t.Errorf("exp a short seed to fail")
+	}
+}
@@ -0,0 +1,312 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/FactomProject/factomd/common/entryBlock"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// A bundle is everything the result of a vote is computed from, as the raw
+// entries of its chains, so the result can be recomputed with no factomd or
+// database. It is written by `go-factom-vote export-vote` and checked by
+// `go-factom-vote verify-bundle`.
+//
+// Every entry comes with the receipt of the DBlock it is in, so its height is
+// as trustworthy as the DBlock KeyMR, which can be compared to any factomd.
+// The active keys of identities are derived from the entries of their chains,
+// and the keys factomd gave at export are checked against them.
+
+// BundleVersion is the version of the bundle format
+const BundleVersion = 2
+
+// BundleEntry is a raw entry and the block it is in
+type BundleEntry struct {
+	EntryHash string `json:"entryHash"`
+	// Entry is the hex of the entry, as it was hashed
+	Entry       string    `json:"entry"`
+	BlockHeight int       `json:"blockHeight"`
+	Timestamp   time.Time `json:"timestamp"`
+	DBlockKeyMR string    `json:"dblockKeyMR"`
+	// Receipt proves the entry is in the DBlock
+	Receipt *EntryReceipt `json:"receipt"`
+}
+
+// BundleKeys are the active keys of an identity at a height
+type BundleKeys struct {
+	IdentityChain string   `json:"identityChain"`
+	BlockHeight   int      `json:"blockHeight"`
+	Keys          []string `json:"keys"`
+}
+
+// VoteBundle is a vote, as the entries of its chains up to a height
+type VoteBundle struct {
+	Version   int    `json:"version"`
+	VoteChain string `json:"voteChain"`
+	// BlockHeight is the height of the chain the bundle was exported at
+	BlockHeight int `json:"blockHeight"`
+
+	Proposal BundleEntry `json:"proposal"`
+	// EligibleList is every entry of the eligible voter chain, the header first
+	EligibleList []BundleEntry `json:"eligibleList"`
+	// VoteEntries is every entry of the vote chain after the proposal
+	VoteEntries []BundleEntry `json:"voteEntries"`
+
+	IdentityKeys    []BundleKeys  `json:"identityKeys"`
+	IdentityEntries []BundleEntry `json:"identityEntries"`
+}
+
+// NewBundleEntry records the entry with the receipt of the blocks it is in
+func NewBundleEntry(entry interfaces.IEBEntry, eblock interfaces.IEntryBlock, dblock interfaces.IDirectoryBlock) (BundleEntry, error) {
+	var b BundleEntry
+	data, err := entry.MarshalBinary()
+	if err != nil {
+		return b, err
+	}
+	b.Receipt, err = NewBlockEntryReceipt(data, eblock, dblock)
+	if err != nil {
+		return b, fmt.Errorf("receipt of entry %x: %s", EntryHash(data), err.Error())
+	}
+	b.EntryHash = b.Receipt.EntryHash
+	b.Entry = b.Receipt.Entry
+	b.BlockHeight = b.Receipt.DBlockHeight
+	b.Timestamp = dblock.GetTimestamp().GetTime()
+	b.DBlockKeyMR = b.Receipt.DBlockKeyMR
+	return b, nil
+}
+
+// VerifyReceipt checks the entry is an entry of the chain, in the DBlock at
+// its height
+func (b BundleEntry) VerifyReceipt(chain string) error {
+	r := b.Receipt
+	if r == nil {
+		return fmt.Errorf("entry %s has no receipt", b.EntryHash)
+	}
+	if err := r.Verify(); err != nil {
+		return fmt.Errorf("entry %s: receipt: %s", b.EntryHash, err.Error())
+	}
+	if r.EntryHash != b.EntryHash || r.Entry != b.Entry {
+		return fmt.Errorf("entry %s: receipt is of entry %s", b.EntryHash, r.EntryHash)
+	}
+	if r.DBlockHeight != b.BlockHeight || r.DBlockKeyMR != b.DBlockKeyMR {
+		return fmt.Errorf("entry %s: receipt is of dblock %s at %d, not %s at %d", b.EntryHash, r.DBlockKeyMR, r.DBlockHeight, b.DBlockKeyMR, b.BlockHeight)
+	}
+	data, _ := hex.DecodeString(b.Entry)
+	e, err := DecodeEntry(data)
+	if err != nil {
+		return fmt.Errorf("entry %s: %s", b.EntryHash, err.Error())
+	}
+	if hex.EncodeToString(e.ChainID) != chain || r.ChainID != chain {
+		return fmt.Errorf("entry %s is not an entry of chain %s", b.EntryHash, chain)
+	}
+	return nil
+}
+
+// ParseEntry decodes the raw entry, which must hash to the entry hash
+func (b BundleEntry) ParseEntry() (interfaces.IEBEntry, error) {
+	data, err := hex.DecodeString(b.Entry)
+	if err != nil {
+		return nil, fmt.Errorf("entry %s: %s", b.EntryHash, err.Error())
+	}
+	if hex.EncodeToString(EntryHash(data)) != b.EntryHash {
+		return nil, fmt.Errorf("entry does not hash to %s", b.EntryHash)
+	}
+
+	entry := entryBlock.NewEntry()
+	if err := entry.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("entry %s: %s", b.EntryHash, err.Error())
+	}
+	return entry, nil
+}
+
+// RecordKeys looks up keys with lookup, and adds them to the bundle
+func (v *VoteBundle) RecordKeys(lookup KeyLookup) KeyLookup {
+	return func(identityChain string, height int64) ([]string, error) {
+		if keys, err := v.Keys(identityChain, height); err == nil {
+			return keys, nil
+		}
+		keys, err := lookup(identityChain, height)
+		if err != nil {
+			return nil, err
+		}
+		v.IdentityKeys = append(v.IdentityKeys, BundleKeys{identityChain, int(height), keys})
+		return keys, nil
+	}
+}
+
+// Keys looks up the identity keys recorded in the bundle, as factomd gave them
+func (v *VoteBundle) Keys(identityChain string, height int64) ([]string, error) {
+	for _, k := range v.IdentityKeys {
+		if k.IdentityChain == identityChain && k.BlockHeight == int(height) {
+			return k.Keys, nil
+		}
+	}
+	return nil, fmt.Errorf("bundle has no keys of identity %s at height %d", identityChain, height)
+}
+
+// Recount applies the entries of the bundle, with the identity keys from
+// keys. The proposal must be valid, other entries that are not are skipped.
+func (v *VoteBundle) Recount(keys KeyLookup) (*Recount, error) {
+	entry, err := v.Proposal.ParseEntry()
+	if err != nil {
+		return nil, fmt.Errorf("proposal: %s", err.Error())
+	}
+	if entry.GetChainID().String() != v.VoteChain {
+		return nil, fmt.Errorf("proposal is not an entry of the vote chain %s", v.VoteChain)
+	}
+	proposal, err := NewProposalEntryWithKeys(entry, v.Proposal.BlockHeight, keys)
+	if err != nil {
+		return nil, fmt.Errorf("proposal: %s", err.Error())
+	}
+
+	r, err := NewRecount(proposal, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	apply := func(entries []BundleEntry, add func(interfaces.IEBEntry, int) error) error {
+		for _, b := range entries {
+			entry, err := b.ParseEntry()
+			if err != nil {
+				return err
+			}
+			add(entry, b.BlockHeight)
+		}
+		return nil
+	}
+	if err := apply(v.EligibleList, r.AddEligibleEntry); err != nil {
+		return nil, fmt.Errorf("eligible list: %s", err.Error())
+	}
+	if err := apply(v.VoteEntries, r.AddVoteEntry); err != nil {
+		return nil, fmt.Errorf("vote chain: %s", err.Error())
+	}
+	return r, nil
+}
+
+// DerivedKeys derives the identity keys from the identity entries of the
+// bundle
+func (v *VoteBundle) DerivedKeys(identityChain string, height int64) ([]string, error) {
+	return IdentityKeys(identityChain, v.IdentityEntries, height)
+}
+
+// Verify recomputes the result of the vote from the bundle alone. Every entry
+// must be in the DBlock its receipt proves, at or below the height of the
+// bundle, and the identity keys are derived from the identity entries.
+func (v *VoteBundle) Verify() (*VoteStats, *Recount, error) {
+	if v.Version != BundleVersion {
+		return nil, nil, fmt.Errorf("bundle version %d is not supported, exp %d", v.Version, BundleVersion)
+	}
+
+	// There is one DBlock at each height
+	dblocks := make(map[int]string)
+	check := func(name string, entries []BundleEntry, chain func(BundleEntry) string) error {
+		for _, b := range entries {
+			if b.BlockHeight > v.BlockHeight {
+				return fmt.Errorf("%s: entry %s at %d is above the bundle height %d", name, b.EntryHash, b.BlockHeight, v.BlockHeight)
+			}
+			if err := b.VerifyReceipt(chain(b)); err != nil {
+				return fmt.Errorf("%s: %s", name, err.Error())
+			}
+			if keyMR, ok := dblocks[b.BlockHeight]; ok && keyMR != b.DBlockKeyMR {
+				return fmt.Errorf("%s: entry %s is in dblock %s at %d, another entry is in %s", name, b.EntryHash, b.DBlockKeyMR, b.BlockHeight, keyMR)
+			}
+			dblocks[b.BlockHeight] = b.DBlockKeyMR
+		}
+		return nil
+	}
+	voteChain := func(BundleEntry) string { return v.VoteChain }
+	if err := check("proposal", []BundleEntry{v.Proposal}, voteChain); err != nil {
+		return nil, nil, err
+	}
+	if err := check("vote chain", v.VoteEntries, voteChain); err != nil {
+		return nil, nil, err
+	}
+
+	// Identity entries are of the identities keys were looked up for
+	identities := make(map[string]bool)
+	for _, k := range v.IdentityKeys {
+		identities[k.IdentityChain] = true
+	}
+	identityChain := func(b BundleEntry) string {
+		data, _ := hex.DecodeString(b.Entry)
+		if e, err := DecodeEntry(data); err == nil && identities[hex.EncodeToString(e.ChainID)] {
+			return hex.EncodeToString(e.ChainID)
+		}
+		return "an identity of the vote"
+	}
+	if err := check("identity chains", v.IdentityEntries, identityChain); err != nil {
+		return nil, nil, err
+	}
+	for _, k := range v.IdentityKeys {
+		keys, err := v.DerivedKeys(k.IdentityChain, int64(k.BlockHeight))
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.Join(keys, ",") != strings.Join(k.Keys, ",") {
+			return nil, nil, fmt.Errorf("identity %s at %d: the keys factomd gave are not the keys of its chain", k.IdentityChain, k.BlockHeight)
+		}
+	}
+
+	r, err := v.Recount(v.DerivedKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	eligibleChain := r.Vote.Proposal.Vote.EligibleVotersChainID.String()
+	if err := check("eligible list", v.EligibleList, func(BundleEntry) string { return eligibleChain }); err != nil {
+		return nil, nil, err
+	}
+	stats, err := r.Result(v.BlockHeight)
+	return stats, r, err
+}
+
+// DBlocks are the KeyMRs of the DBlocks the entries of the bundle are in, by
+// height, to compare to factomd
+func (v *VoteBundle) DBlocks() map[int]string {
+	dblocks := make(map[int]string)
+	for _, list := range [][]BundleEntry{{v.Proposal}, v.EligibleList, v.VoteEntries, v.IdentityEntries} {
+		for _, b := range list {
+			dblocks[b.BlockHeight] = b.DBlockKeyMR
+		}
+	}
+	return dblocks
+}
+
+// WriteBundle writes the bundle as gzipped json
+func WriteBundle(w io.Writer, v *VoteBundle) error {
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadBundle reads a bundle written by WriteBundle, or as plain json
+func ReadBundle(r io.Reader) (*VoteBundle, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	var in io.Reader = br
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		in = zr
+	}
+
+	v := new(VoteBundle)
+	if err := json.NewDecoder(in).Decode(v); err != nil {
+		return nil, fmt.Errorf("reading bundle: %s", err.Error())
+	}
+	return v, nil
+}
@@ -1,4 +1,4 @@
-package vote
+package common
 
 const (
 	// External ID 0's
@@ -0,0 +1,204 @@
+package common
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact number, used for voter weights and everything the tally
+// computes from them. Summing fractional weights as floats can flip threshold
+// comparisons, so the tally is done in rationals.
+//
+// Decimals are immutable, the arithmetic returns new values. The zero value is
+// 0. They are written to json and the database as decimal strings, and read
+// from json numbers or strings.
+type Decimal struct {
+	r *big.Rat
+}
+
+// divisionDigits are the digits after the point of decimals that do not
+// terminate, like 1/3, when written out. Comparisons are always exact.
+const divisionDigits = 18
+
+var (
+	decimalOne  = NewDecimal(1)
+	decimalTwo  = NewDecimal(2)
+	decimalHalf = decimalOne.Quo(decimalTwo)
+)
+
+func NewDecimal(i int64) Decimal {
+	return Decimal{new(big.Rat).SetInt64(i)}
+}
+
+// jsonNumber is the grammar of a json number. big.Rat also accepts fractions,
+// hex, octal, underscores and forms like +.5, which json cannot hold.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// ParseDecimal parses a decimal, in the forms of a json number
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if !jsonNumber.MatchString(s) {
+		return Decimal{}, fmt.Errorf("'%s' is not a decimal", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, fmt.Errorf("'%s' is not a decimal", s)
+	}
+	return Decimal{r}, nil
+}
+
+// MustDecimal parses a decimal, and panics if it is not one
+func MustDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// DecimalFromFloat is the shortest decimal that is the float, so 0.1 is
+// exactly 1/10
+func DecimalFromFloat(f float64) Decimal {
+	return MustDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func (d Decimal) rat() *big.Rat {
+	if d.r == nil {
+		return new(big.Rat)
+	}
+	return d.r
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{new(big.Rat).Add(d.rat(), o.rat())}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{new(big.Rat).Sub(d.rat(), o.rat())}
+}
+
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{new(big.Rat).Mul(d.rat(), o.rat())}
+}
+
+// Quo is d / o. It panics if o is 0, the same as integer division.
+func (d Decimal) Quo(o Decimal) Decimal {
+	return Decimal{new(big.Rat).Quo(d.rat(), o.rat())}
+}
+
+// Ceil is the smallest integer not less than d
+func (d Decimal) Ceil() Decimal {
+	r := d.rat()
+	q, m := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+	if m.Sign() != 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	return Decimal{new(big.Rat).SetInt(q)}
+}
+
+// Cmp returns -1, 0 or 1 as d is less than, equal to or greater than o
+func (d Decimal) Cmp(o Decimal) int {
+	return d.rat().Cmp(o.rat())
+}
+
+func (d Decimal) Sign() int {
+	return d.rat().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Float64 is the nearest float, for statistics that do not need to be exact
+func (d Decimal) Float64() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+// String is the exact decimal if it terminates, or rounded to 18 digits after
+// the point if not
+func (d Decimal) String() string {
+	r := d.rat()
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// The decimal terminates if the denominator only has the factors 2 and 5,
+	// and then it needs as many digits as the larger of their powers
+	denom := new(big.Int).Set(r.Denom())
+	digits := 0
+	for _, f := range []int64{2, 5} {
+		n := 0
+		for new(big.Int).Mod(denom, big.NewInt(f)).Sign() == 0 {
+			denom.Quo(denom, big.NewInt(f))
+			n++
+		}
+		if n > digits {
+			digits = n
+		}
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		digits = divisionDigits
+	}
+
+	s := r.FloatString(digits)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a json number, without going through a float, or a
+// string of one
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	v, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Scan reads numeric columns, and double precision columns of databases that
+// have not been migrated
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*d = Decimal{}
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+	case string:
+		*d, err = ParseDecimal(v)
+	case int64:
+		*d = NewDecimal(v)
+	case float64:
+		*d = DecimalFromFloat(v)
+	default:
+		err = fmt.Errorf("cannot scan %T into a decimal", src)
+	}
+	return err
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,191 @@
+package common_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	if sum := MustDecimal("0.1").Add(MustDecimal("0.2")); sum.Cmp(MustDecimal("0.3")) != 0 || sum.String() != "0.3" {
+		t.Errorf("exp 0.1 + 0.2 to be 0.3, got %s", sum)
+	}
+
+	// Ten weights of 0.1 are exactly 1
+	var sum Decimal
+	for i := 0; i < 10; i++ {
+		sum = sum.Add(dec(0.1))
+	}
+	if sum.Cmp(NewDecimal(1)) != 0 {
+		t.Errorf("exp ten 0.1 to be 1, got %s", sum)
+	}
+
+	third := NewDecimal(1).Quo(NewDecimal(3))
+	if third.String() != "0.333333333333333333" {
+		t.Errorf("exp 1/3 to 18 digits, got %s", third)
+	}
+	if third.Mul(NewDecimal(3)).Cmp(NewDecimal(1)) != 0 {
+		t.Errorf("exp 1/3 * 3 to be exactly 1")
+	}
+
+	for _, c := range []struct {
+		In, Ceil string
+	}{{"2.5", "3"}, {"3", "3"}, {"-2.5", "-2"}, {"0.000001", "1"}} {
+		if ceil := MustDecimal(c.In).Ceil().String(); ceil != c.Ceil {
+			t.Errorf("exp ceil of %s to be %s, got %s", c.In, c.Ceil, ceil)
+		}
+	}
+
+	var zero Decimal
+	if !zero.IsZero() || zero.String() != "0" {
+		t.Errorf("exp the zero value to be 0, got %s", zero)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	for _, c := range []struct {
+		In  string
+		Exp string
+	}{
+		{`0.1`, "0.1"},
+		{`"0.1"`, "0.1"},
+		{`1e-2`, "0.01"},
+		{`12345678901234567890.123456789`, "12345678901234567890.123456789"},
+		{`null`, "0"},
+	} {
+		var d Decimal
+		if err := json.Unmarshal([]byte(c.In), &d); err != nil {
+			t.Errorf("%s: %s", c.In, err)
+			continue
+		}
+		if d.String() != c.Exp {
+			t.Errorf("exp %s to be %s, got %s", c.In, c.Exp, d)
+		}
+	}
+
+	for _, in := range []string{`"1/3"`, `"abc"`, `true`, `"0x10"`, `"0o17"`, `"017"`, `"1_000"`, `"+.5"`, `".5"`, `"+1"`, `"1."`, `"1e"`, `"Inf"`, `""`} {
+		var d Decimal
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("exp %s to fail, got %s", in, d)
+		}
+	}
+
+	data, err := json.Marshal(struct{ W Decimal }{MustDecimal("0.30")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"W":"0.3"}` {
+		t.Errorf("exp weight written as a string, got %s", data)
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	for _, src := range []interface{}{[]byte("0.3"), "0.3", 0.3} {
+		var d Decimal
+		if err := d.Scan(src); err != nil {
+			t.Errorf("%v: %s", src, err)
+		} else if d.Cmp(MustDecimal("0.3")) != 0 {
+			t.Errorf("exp %v to scan as 0.3, got %s", src, d)
+		}
+	}
+	var d Decimal
+	if err := d.Scan(true); err == nil {
+		t.Errorf("exp a bool not to scan")
+	}
+}
+
+// thresholdVote is a binary vote with weights 0.7 and 0.1 for "yes", 0.2 for
+// "no", and an eligible voter of weight 0.25 that does not vote. As floats,
+// 0.7 + 0.1 is 0.7999999999999999. The weighted turnout is 1 / 1.25 = 0.8.
+func thresholdVote() *TestVote {
+	tv := MakeTestVote([]string{"yes", "no"}, 1, 1)
+	tv.SetType(VOTE_BINARY)
+	tv.AddVote([]string{"yes"}, 0.7)
+	tv.AddVote([]string{"yes"}, 0.1)
+	tv.AddVote([]string{"no"}, 0.2)
+
+	id := primitives.RandomHash()
+	tv.EligibleVoters = append(tv.EligibleVoters, &EligibleVoter{VoterID: *(id.(*primitives.Hash)), VoteWeight: dec(0.25)})
+	return tv
+}
+
+func TestDecimalThresholds(t *testing.T) {
+	t.Run("support exactly at the minimum wins", func(t *testing.T) {
+		tv := thresholdVote()
+		tv.Vote.Proposal.Vote.Config.ComputeResultsAgainst = "PARTICIPANTS_ONLY"
+		tv.Vote.Proposal.Vote.Config.WinnerCriteria.MinSupport = map[string]CriteriaWeights{
+			"yes": {Weighted: dec(0.8), Unweighted: dec(0)},
+		}
+		stats, err := ComputeResult(tv.Params())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.OptionStats["yes"].WeightedSupport.String() != "0.8" {
+			t.Errorf("exp weighted support of 0.8, got %s", stats.OptionStats["yes"].WeightedSupport)
+		}
+		if err := ExpectedWinners(stats, []string{"yes"}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("support just under the minimum does not win", func(t *testing.T) {
+		tv := thresholdVote()
+		tv.Vote.Proposal.Vote.Config.ComputeResultsAgainst = "PARTICIPANTS_ONLY"
+		tv.Vote.Proposal.Vote.Config.WinnerCriteria.MinSupport = map[string]CriteriaWeights{
+			"yes": {Weighted: MustDecimal("0.800000000000000001"), Unweighted: dec(0)},
+		}
+		stats, err := ComputeResult(tv.Params())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ExpectedWinners(stats, []string{}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// The turnout must be above the minimum, so exactly at it is not valid
+	for _, c := range []struct {
+		MinTurnout string
+		Valid      bool
+	}{{"0.8", false}, {"0.799999999999999999", true}} {
+		tv := thresholdVote()
+		tv.Vote.Proposal.Vote.Config.AcceptanceCriteria.MinTurnout = CriteriaWeights{Weighted: MustDecimal(c.MinTurnout)}
+		stats, err := ComputeResult(tv.Params())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Turnout.WeightedTurnout.Cmp(dec(0.8)) != 0 {
+			t.Errorf("exp weighted turnout of 0.8, got %s", stats.Turnout.WeightedTurnout)
+		}
+		if stats.Valid != c.Valid {
+			t.Errorf("min turnout %s: exp valid %t, got %t", c.MinTurnout, c.Valid, stats.Valid)
+		}
+	}
+}
+
+func TestIRVMajorityThreshold(t *testing.T) {
+	// 2 of 4 first choices is not a majority, so "b" and "c" are eliminated
+	// and "a" wins with 3 in the second round
+	tv := MakeTestVote([]string{"a", "b", "c"}, 1, 3)
+	tv.SetType(VOTE_IRV)
+	tv.AddVote([]string{"a"}, 1)
+	tv.AddVote([]string{"a"}, 1)
+	tv.AddVote([]string{"b"}, 1)
+	tv.AddVote([]string{"c", "a"}, 1)
+
+	stats, err := ComputeResult(tv.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.IRVRounds) != 2 {
+		t.Fatalf("exp 2 rounds, got %d", len(stats.IRVRounds))
+	}
+	if err := ExpectedWinners(stats, []string{"a"}); err != nil {
+		t.Error(err)
+	}
+	if stats.OptionStats["a"].Weight.String() != "3" {
+		t.Errorf("exp weight 3 for a, got %s", stats.OptionStats["a"].Weight)
+	}
+}
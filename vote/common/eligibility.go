@@ -29,7 +29,7 @@ func NewEligibleList() *EligibleList {
 func (l *EligibleList) AddVoter(e *EligibleVoterEntry) error {
 	// TODO: Check signature
 	for _, eg := range e.Content {
-		if _, ok := l.EligibleVoters[eg.VoterID.Fixed()]; eg.VoteWeight == 0 && ok {
+		if _, ok := l.EligibleVoters[eg.VoterID.Fixed()]; eg.VoteWeight.IsZero() && ok {
 			delete(l.EligibleVoters, eg.VoterID.Fixed())
 		} else {
 			l.EligibleVoters[eg.VoterID.Fixed()] = eg
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// The active keys of an identity come from the entries of its chain. The
+// first entry lists the keys, highest priority first:
+//
+//	ExtIDs: "IdentityChain", name...
+//	Content: {"version": 1, "keys": ["idpub...", ...]}
+//
+// and each later entry may replace a key with one signed by a key of the same
+// or higher priority:
+//
+//	ExtIDs: "ReplaceKey", old key, new key, signature, signer key
+//
+// where the signature is of the chain id, old key and new key as text. This is
+// how factomd's clients find the keys, so a bundle can be checked without it.
+
+const (
+	identityChainExt0 = "IdentityChain"
+	replaceKeyExt0    = "ReplaceKey"
+)
+
+// IdentityKeys derives the active keys of the identity at the height, from
+// the entries of its chain in the order they were made. Entries of other
+// chains are ignored, and entries above the height are not applied.
+func IdentityKeys(identityChain string, entries []BundleEntry, height int64) ([]string, error) {
+	var keys []string
+	used := make(map[string]bool)
+	created := false
+	for _, b := range entries {
+		data, err := hex.DecodeString(b.Entry)
+		if err != nil {
+			return nil, fmt.Errorf("entry %s: %s", b.EntryHash, err.Error())
+		}
+		if hex.EncodeToString(EntryHash(data)) != b.EntryHash {
+			return nil, fmt.Errorf("entry does not hash to %s", b.EntryHash)
+		}
+		e, err := DecodeEntry(data)
+		if err != nil {
+			return nil, fmt.Errorf("entry %s: %s", b.EntryHash, err.Error())
+		}
+		if hex.EncodeToString(e.ChainID) != identityChain || int64(b.BlockHeight) > height {
+			continue
+		}
+
+		if !created {
+			if len(e.ExtIDs) == 0 || string(e.ExtIDs[0]) != identityChainExt0 {
+				return nil, fmt.Errorf("first entry of identity %s is not an %s entry", identityChain, identityChainExt0)
+			}
+			var content struct {
+				Keys []string `json:"keys"`
+			}
+			if err := json.Unmarshal(e.Content, &content); err != nil {
+				return nil, fmt.Errorf("identity %s: %s", identityChain, err.Error())
+			}
+			if len(content.Keys) == 0 {
+				return nil, fmt.Errorf("identity %s has no keys", identityChain)
+			}
+			keys = content.Keys
+			for _, k := range keys {
+				used[k] = true
+			}
+			created = true
+			continue
+		}
+
+		if len(e.ExtIDs) != 5 || string(e.ExtIDs[0]) != replaceKeyExt0 {
+			continue
+		}
+		oldKey, newKey, sig, signer := string(e.ExtIDs[1]), string(e.ExtIDs[2]), e.ExtIDs[3], string(e.ExtIDs[4])
+		oldIndex, signerIndex := -1, -1
+		for i, k := range keys {
+			if k == oldKey {
+				oldIndex = i
+			}
+			if k == signer {
+				signerIndex = i
+			}
+		}
+		// A key is only replaced by a key of the same or higher priority, and
+		// never with a key the identity has had
+		if oldIndex < 0 || signerIndex < 0 || signerIndex > oldIndex || used[newKey] {
+			continue
+		}
+		pub := IdentityPublicKey(signer)
+		if len(pub) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+			continue
+		}
+		if !ed25519.Verify(pub, []byte(identityChain+oldKey+newKey), sig) {
+			continue
+		}
+		keys = append([]string{}, keys...)
+		keys[oldIndex] = newKey
+		used[newKey] = true
+	}
+	if !created {
+		return nil, fmt.Errorf("bundle has no entries of identity %s at height %d", identityChain, height)
+	}
+	return keys, nil
+}
@@ -0,0 +1,80 @@
+package common_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+	"golang.org/x/crypto/ed25519"
+)
+
+type identityKeyPair struct {
+	Key  string
+	Priv ed25519.PrivateKey
+}
+
+func newIdentityKeyPair(b byte) identityKeyPair {
+	priv := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{b}, ed25519.SeedSize))
+	return identityKeyPair{identityKey(priv.Public().(ed25519.PublicKey)), priv}
+}
+
+func identityEntry(height int, data []byte) BundleEntry {
+	return BundleEntry{EntryHash: hex.EncodeToString(EntryHash(data)), Entry: hex.EncodeToString(data), BlockHeight: height}
+}
+
+func replaceKey(chainID []byte, height int, oldKey, newKey string, signer identityKeyPair) BundleEntry {
+	sig := ed25519.Sign(signer.Priv, []byte(hex.EncodeToString(chainID)+oldKey+newKey))
+	return identityEntry(height, rawEntry(chainID, nil, []byte("ReplaceKey"), []byte(oldKey), []byte(newKey), sig, []byte(signer.Key)))
+}
+
+func TestIdentityKeys(t *testing.T) {
+	chainID := sum([]byte("identity"))
+	chain := hex.EncodeToString(chainID)
+	k1, k2, k3, k4 := newIdentityKeyPair(1), newIdentityKeyPair(2), newIdentityKeyPair(3), newIdentityKeyPair(4)
+
+	content := []byte(fmt.Sprintf(`{"version":1,"keys":["%s","%s"]}`, k1.Key, k2.Key))
+	forged := rawEntry(chainID, nil, []byte("ReplaceKey"), []byte(k1.Key), []byte(k4.Key), make([]byte, 64), []byte(k1.Key))
+	entries := []BundleEntry{
+		identityEntry(5, rawEntry(chainID, content, []byte("IdentityChain"), []byte("name"))),
+		identityEntry(6, rawEntry(sum([]byte("other")), content, []byte("IdentityChain"))),
+		// A lower priority key cannot replace a higher one
+		replaceKey(chainID, 10, k1.Key, k4.Key, k2),
+		// Not signed by the key it claims
+		identityEntry(12, forged),
+		replaceKey(chainID, 20, k2.Key, k3.Key, k1),
+		// A key the identity had cannot come back
+		replaceKey(chainID, 30, k3.Key, k2.Key, k3),
+	}
+
+	for _, c := range []struct {
+		Height int64
+		Keys   []string
+	}{
+		{5, []string{k1.Key, k2.Key}},
+		{19, []string{k1.Key, k2.Key}},
+		{20, []string{k1.Key, k3.Key}},
+		{40, []string{k1.Key, k3.Key}},
+	} {
+		keys, err := IdentityKeys(chain, entries, c.Height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(c.Keys) {
+			t.Errorf("at %d: exp keys %v, got %v", c.Height, c.Keys, keys)
+		}
+	}
+
+	if _, err := IdentityKeys(chain, entries, 4); err == nil {
+		t.Errorf("exp keys before the identity was made to fail")
+	}
+	if _, err := IdentityKeys(chain, entries[2:], 40); err == nil {
+		t.Errorf("exp an identity without its first entry to fail")
+	}
+	tampered := append([]BundleEntry{}, entries...)
+	tampered[0].Entry = hex.EncodeToString(rawEntry(chainID, content, []byte("IdentityChain"), []byte("other name")))
+	if _, err := IdentityKeys(chain, tampered, 40); err == nil {
+		t.Errorf("exp an entry that does not hash to its entry hash to fail")
+	}
+}
@@ -38,7 +38,26 @@ func NewEmptyProposalEntry() *ProposalEntry {
 	return p
 }
 
+// KeyLookup returns the active keys of an identity at a height, in the base58
+// form factom.GetActiveIdentityKeysAtHeight gives them
+type KeyLookup func(identityChain string, height int64) ([]string, error)
+
+// IdentityPublicKey is the ed25519 public key of a base58 identity key
+func IdentityPublicKey(key string) []byte {
+	data := base58.Decode(key)
+	if len(data) < factom.IDKeyBodyLength {
+		return nil
+	}
+	return data[factom.IDKeyPrefixLength:factom.IDKeyBodyLength]
+}
+
 func NewProposalEntry(entry interfaces.IEBEntry, dbheight int) (*ProposalEntry, error) {
+	return NewProposalEntryWithKeys(entry, dbheight, factom.GetActiveIdentityKeysAtHeight)
+}
+
+// NewProposalEntryWithKeys is NewProposalEntry with the identity keys looked
+// up by keys, rather than asked of factomd
+func NewProposalEntryWithKeys(entry interfaces.IEBEntry, dbheight int, keys KeyLookup) (*ProposalEntry, error) {
 	if len(entry.ExternalIDs()) != 5 {
 		return nil, fmt.Errorf("expected 5 external ids, found %d", len(entry.ExternalIDs()))
 	}
@@ -69,16 +88,14 @@ func NewProposalEntry(entry interfaces.IEBEntry, dbheight int) (*ProposalEntry,
 	}
 
 	// Validate Identity has key
-	keys, err := factom.GetActiveIdentityKeysAtHeight(p.VoteInitiator.String(), int64(dbheight))
+	active, err := keys(p.VoteInitiator.String(), int64(dbheight))
 	if err != nil {
 		return nil, err
 	}
 
 	validKey := false
-	for _, k := range keys {
-		data := base58.Decode(k)
-		pubkey := data[factom.IDKeyPrefixLength:factom.IDKeyBodyLength]
-		if bytes.Compare(pubkey, p.InitiatorKey[:]) == 0 {
+	for _, k := range active {
+		if bytes.Compare(IdentityPublicKey(k), p.InitiatorKey[:]) == 0 {
 			validKey = true
 			break
 		}
@@ -143,8 +160,8 @@ type VoteContent struct {
 }
 
 type CriteriaWeights struct {
-	Weighted   float64 `json:"weighted"`
-	Unweighted float64 `json:"unweighted"`
+	Weighted   Decimal `json:"weighted"`
+	Unweighted Decimal `json:"unweighted"`
 }
 
 type AcceptCriteriaStruct struct {
@@ -0,0 +1,378 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// A receipt proves an entry is in the Factom chain, from the data in the
+// receipt alone. The entry hash is a leaf of the merkle tree of the EBlock
+// body, and the EBlock a leaf of the tree of the DBlock body. Both trees are
+// built the same way as factomd, where a node is sha256(left || right) and
+// the last node of an odd level is paired with itself.
+//
+// The receipt is trusted as far as the DBlock KeyMR is, which can be looked
+// up on any factomd or explorer.
+
+const (
+	eblockHeaderLen = 140
+	dblockHeaderLen = 113
+)
+
+// MerkleNode is a step of a merkle path, where Top is sha256(Left || Right)
+type MerkleNode struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	Top   string `json:"top"`
+}
+
+// EntryReceipt proves an entry is in a directory block
+type EntryReceipt struct {
+	EntryHash string `json:"entryHash"`
+	// Entry is the hex of the entry as it was hashed
+	Entry   string `json:"entry"`
+	ChainID string `json:"chainId"`
+
+	EBlockKeyMR  string `json:"eblockKeyMR"`
+	EBlockHeader string `json:"eblockHeader"`
+	// EntryPath is from the entry hash to the body MR of the EBlock. The last
+	// node joins the hash of the header and the body MR into the KeyMR.
+	EntryPath []MerkleNode `json:"entryPath"`
+
+	DBlockHeight int    `json:"dblockHeight"`
+	DBlockKeyMR  string `json:"dblockKeyMR"`
+	DBlockHeader string `json:"dblockHeader"`
+	// EBlockPath is from the EBlock to the KeyMR of the DBlock. The first
+	// node is the chain id and EBlock KeyMR the DBlock lists, and the last
+	// joins the hash of the header and the body MR into the KeyMR.
+	EBlockPath []MerkleNode `json:"eblockPath"`
+}
+
+// VoterReceipt is the proof of the commit and reveal of a voter
+type VoterReceipt struct {
+	VoteChain string        `json:"voteChain"`
+	VoterID   string        `json:"voterId"`
+	Commit    *EntryReceipt `json:"commit"`
+	Reveal    *EntryReceipt `json:"reveal"`
+}
+
+// DBlockEntry is an entry of the body of a directory block
+type DBlockEntry struct {
+	ChainID []byte
+	KeyMR   []byte
+}
+
+// RawEntry is an entry decoded from its binary form
+type RawEntry struct {
+	ChainID []byte
+	ExtIDs  [][]byte
+	Content []byte
+}
+
+// DecodeEntry decodes an entry as it is hashed: the version, the chain id,
+// the size of the external ids, each external id after its length, and the
+// content.
+func DecodeEntry(data []byte) (*RawEntry, error) {
+	if len(data) < 35 {
+		return nil, fmt.Errorf("entry is %d bytes, too short for a header", len(data))
+	}
+	if data[0] != 0 {
+		return nil, fmt.Errorf("entry version %d is not 0", data[0])
+	}
+	e := new(RawEntry)
+	e.ChainID = data[1:33]
+	size := int(binary.BigEndian.Uint16(data[33:35]))
+	if 35+size > len(data) {
+		return nil, fmt.Errorf("external ids of %d bytes run past the entry", size)
+	}
+	ext := data[35 : 35+size]
+	for len(ext) > 0 {
+		if len(ext) < 2 {
+			return nil, fmt.Errorf("external id length is cut short")
+		}
+		n := int(binary.BigEndian.Uint16(ext[:2]))
+		if 2+n > len(ext) {
+			return nil, fmt.Errorf("external id of %d bytes runs past the external ids", n)
+		}
+		e.ExtIDs = append(e.ExtIDs, ext[2:2+n])
+		ext = ext[2+n:]
+	}
+	e.Content = data[35+size:]
+	return e, nil
+}
+
+// EntryHash is the hash factom identifies an entry by
+func EntryHash(entry []byte) []byte {
+	sum := sha512.Sum512(entry)
+	h := sha256.Sum256(append(sum[:], entry...))
+	return h[:]
+}
+
+func hashPair(left, right []byte) []byte {
+	h := sha256.Sum256(append(append([]byte{}, left...), right...))
+	return h[:]
+}
+
+func sha(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+// merklePath returns the path from the leaf at index to the root, and the root
+func merklePath(leaves [][]byte, index int) ([]MerkleNode, []byte) {
+	path := []MerkleNode{}
+	level := leaves
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			left, right := level[i], level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			top := hashPair(left, right)
+			if i == index || i+1 == index {
+				path = append(path, MerkleNode{hex.EncodeToString(left), hex.EncodeToString(right), hex.EncodeToString(top)})
+			}
+			next = append(next, top)
+		}
+		index /= 2
+		level = next
+	}
+	return path, level[0]
+}
+
+// NewEntryReceipt builds the receipt of the entry from the raw blocks it is
+// in. The EBlock entries are its whole body, including the minute markers.
+func NewEntryReceipt(entry, eblockHeader []byte, eblockEntries [][]byte, dblockHeader []byte, dblockEntries []DBlockEntry) (*EntryReceipt, error) {
+	r := new(EntryReceipt)
+	hash := EntryHash(entry)
+	r.EntryHash = hex.EncodeToString(hash)
+	r.Entry = hex.EncodeToString(entry)
+
+	// The entry into the EBlock
+	if len(eblockHeader) != eblockHeaderLen {
+		return nil, fmt.Errorf("eblock header is %d bytes, exp %d", len(eblockHeader), eblockHeaderLen)
+	}
+	chainID, bodyMR := eblockHeader[:32], eblockHeader[32:64]
+	r.ChainID = hex.EncodeToString(chainID)
+	r.EBlockHeader = hex.EncodeToString(eblockHeader)
+
+	index := -1
+	for i, e := range eblockEntries {
+		if bytes.Equal(e, hash) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("entry %x is not in the eblock", hash)
+	}
+	path, root := merklePath(eblockEntries, index)
+	if !bytes.Equal(root, bodyMR) {
+		return nil, fmt.Errorf("eblock body does not match the body MR of its header")
+	}
+	headerHash := sha(eblockHeader)
+	keyMR := hashPair(headerHash, bodyMR)
+	r.EntryPath = append(path, MerkleNode{hex.EncodeToString(headerHash), hex.EncodeToString(bodyMR), hex.EncodeToString(keyMR)})
+	r.EBlockKeyMR = hex.EncodeToString(keyMR)
+
+	// The EBlock into the DBlock
+	if len(dblockHeader) != dblockHeaderLen {
+		return nil, fmt.Errorf("dblock header is %d bytes, exp %d", len(dblockHeader), dblockHeaderLen)
+	}
+	dbodyMR := dblockHeader[5:37]
+	r.DBlockHeight = int(binary.BigEndian.Uint32(dblockHeader[105:109]))
+	r.DBlockHeader = hex.EncodeToString(dblockHeader)
+	if height := int(binary.BigEndian.Uint32(eblockHeader[132:136])); height != r.DBlockHeight {
+		return nil, fmt.Errorf("eblock is at height %d, not in the dblock at %d", height, r.DBlockHeight)
+	}
+
+	index = -1
+	leaves := make([][]byte, len(dblockEntries))
+	for i, e := range dblockEntries {
+		leaves[i] = hashPair(e.ChainID, e.KeyMR)
+		if bytes.Equal(e.ChainID, chainID) && bytes.Equal(e.KeyMR, keyMR) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("eblock %x is not in the dblock", keyMR)
+	}
+	path, root = merklePath(leaves, index)
+	if !bytes.Equal(root, dbodyMR) {
+		return nil, fmt.Errorf("dblock body does not match the body MR of its header")
+	}
+	dheaderHash := sha(dblockHeader)
+	dkeyMR := hashPair(dheaderHash, dbodyMR)
+	r.EBlockPath = append([]MerkleNode{{r.ChainID, r.EBlockKeyMR, hex.EncodeToString(leaves[index])}}, path...)
+	r.EBlockPath = append(r.EBlockPath, MerkleNode{hex.EncodeToString(dheaderHash), hex.EncodeToString(dbodyMR), hex.EncodeToString(dkeyMR)})
+	r.DBlockKeyMR = hex.EncodeToString(dkeyMR)
+	return r, nil
+}
+
+// NewBlockEntryReceipt builds the receipt of the raw entry from the EBlock and
+// DBlock factomd gave for it
+func NewBlockEntryReceipt(entry []byte, eblock interfaces.IEntryBlock, dblock interfaces.IDirectoryBlock) (*EntryReceipt, error) {
+	eheader, err := eblock.GetHeader().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var eentries [][]byte
+	for _, h := range eblock.GetBody().GetEBEntries() {
+		eentries = append(eentries, h.Bytes())
+	}
+
+	dheader, err := dblock.GetHeader().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var dentries []DBlockEntry
+	for _, e := range dblock.GetDBEntries() {
+		dentries = append(dentries, DBlockEntry{ChainID: e.GetChainID().Bytes(), KeyMR: e.GetKeyMR().Bytes()})
+	}
+	return NewEntryReceipt(entry, eheader, eentries, dheader, dentries)
+}
+
+// Verify checks the entry is in the DBlock of the receipt. It does not check
+// the DBlock is in the chain, the DBlockKeyMR should be compared to factomd.
+func (r *EntryReceipt) Verify() error {
+	entry, err := hex.DecodeString(r.Entry)
+	if err != nil {
+		return fmt.Errorf("entry: %s", err.Error())
+	}
+	if hex.EncodeToString(EntryHash(entry)) != r.EntryHash {
+		return fmt.Errorf("entry does not hash to %s", r.EntryHash)
+	}
+
+	eheader, err := hex.DecodeString(r.EBlockHeader)
+	if err != nil || len(eheader) != eblockHeaderLen {
+		return fmt.Errorf("eblock header is not %d bytes of hex", eblockHeaderLen)
+	}
+	if hex.EncodeToString(eheader[:32]) != r.ChainID {
+		return fmt.Errorf("eblock is not of chain %s", r.ChainID)
+	}
+	if height := int(binary.BigEndian.Uint32(eheader[132:136])); height != r.DBlockHeight {
+		return fmt.Errorf("eblock is at height %d, not %d", height, r.DBlockHeight)
+	}
+	if err := verifyBlockPath(r.EntryPath, r.EntryHash, eheader, eheader[32:64], r.EBlockKeyMR); err != nil {
+		return fmt.Errorf("entry path: %s", err.Error())
+	}
+
+	dheader, err := hex.DecodeString(r.DBlockHeader)
+	if err != nil || len(dheader) != dblockHeaderLen {
+		return fmt.Errorf("dblock header is not %d bytes of hex", dblockHeaderLen)
+	}
+	if height := int(binary.BigEndian.Uint32(dheader[105:109])); height != r.DBlockHeight {
+		return fmt.Errorf("dblock is at height %d, not %d", height, r.DBlockHeight)
+	}
+	if len(r.EBlockPath) == 0 {
+		return fmt.Errorf("eblock path is empty")
+	}
+	first := r.EBlockPath[0]
+	if first.Left != r.ChainID || first.Right != r.EBlockKeyMR {
+		return fmt.Errorf("eblock path does not start at the eblock")
+	}
+	if err := verifyNode(first); err != nil {
+		return fmt.Errorf("eblock path: %s", err.Error())
+	}
+	if err := verifyBlockPath(r.EBlockPath[1:], first.Top, dheader, dheader[5:37], r.DBlockKeyMR); err != nil {
+		return fmt.Errorf("eblock path: %s", err.Error())
+	}
+	return nil
+}
+
+// verifyBlockPath checks the path goes from the leaf to the body MR of the
+// block, and the last node joins the header and body MR into the KeyMR
+func verifyBlockPath(path []MerkleNode, leaf string, header, bodyMR []byte, keyMR string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("is empty")
+	}
+	at := leaf
+	for i, n := range path[:len(path)-1] {
+		if n.Left != at && n.Right != at {
+			return fmt.Errorf("node %d does not include %s", i, at)
+		}
+		if err := verifyNode(n); err != nil {
+			return fmt.Errorf("node %d: %s", i, err.Error())
+		}
+		at = n.Top
+	}
+	if at != hex.EncodeToString(bodyMR) {
+		return fmt.Errorf("does not reach the body MR of the block")
+	}
+
+	last := path[len(path)-1]
+	if last.Left != hex.EncodeToString(sha(header)) || last.Right != at {
+		return fmt.Errorf("last node is not the header and body MR of the block")
+	}
+	if err := verifyNode(last); err != nil {
+		return fmt.Errorf("last node: %s", err.Error())
+	}
+	if last.Top != keyMR {
+		return fmt.Errorf("does not reach the KeyMR %s", keyMR)
+	}
+	return nil
+}
+
+func verifyNode(n MerkleNode) error {
+	left, err := hex.DecodeString(n.Left)
+	if err != nil {
+		return err
+	}
+	right, err := hex.DecodeString(n.Right)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(hashPair(left, right)) != n.Top {
+		return fmt.Errorf("top is not the hash of left and right")
+	}
+	return nil
+}
+
+// Verify checks the receipts of the commit and reveal, which must be entries
+// of the vote chain by the voter. Either can be nil if the voter did not
+// commit or reveal.
+func (v *VoterReceipt) Verify() error {
+	for _, r := range []struct {
+		Name    string
+		Ext0    string
+		Receipt *EntryReceipt
+	}{{"commit", EXT0_VOTE_COMMIT, v.Commit}, {"reveal", EXT0_VOTE_REVEAL, v.Reveal}} {
+		if r.Receipt == nil {
+			continue
+		}
+		if r.Receipt.ChainID != v.VoteChain {
+			return fmt.Errorf("%s is an entry of chain %s, not the vote %s", r.Name, r.Receipt.ChainID, v.VoteChain)
+		}
+		if err := r.Receipt.Verify(); err != nil {
+			return fmt.Errorf("%s: %s", r.Name, err.Error())
+		}
+
+		// The entry is the commit or reveal of the voter
+		data, _ := hex.DecodeString(r.Receipt.Entry)
+		entry, err := DecodeEntry(data)
+		if err != nil {
+			return fmt.Errorf("%s: %s", r.Name, err.Error())
+		}
+		if hex.EncodeToString(entry.ChainID) != v.VoteChain {
+			return fmt.Errorf("%s entry is of chain %x, not the vote %s", r.Name, entry.ChainID, v.VoteChain)
+		}
+		if len(entry.ExtIDs) < 2 || string(entry.ExtIDs[0]) != r.Ext0 {
+			return fmt.Errorf("%s entry is not a %s entry", r.Name, r.Ext0)
+		}
+		if voter := hex.EncodeToString(entry.ExtIDs[1]); voter != strings.ToLower(v.VoterID) {
+			return fmt.Errorf("%s entry is by voter %s, not %s", r.Name, voter, v.VoterID)
+		}
+	}
+	if v.Commit == nil && v.Reveal == nil {
+		return fmt.Errorf("no commit or reveal")
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package common_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+func sum(data ...[]byte) []byte {
+	h := sha256.Sum256(bytes.Join(data, nil))
+	return h[:]
+}
+
+// merkleRoot is the root factomd computes, a level at a time
+func merkleRoot(leaves [][]byte) []byte {
+	for len(leaves) > 1 {
+		var next [][]byte
+		for i := 0; i < len(leaves); i += 2 {
+			right := leaves[i]
+			if i+1 < len(leaves) {
+				right = leaves[i+1]
+			}
+			next = append(next, sum(leaves[i], right))
+		}
+		leaves = next
+	}
+	return leaves[0]
+}
+
+type testBlocks struct {
+	Entries       [][]byte
+	EBlockHeader  []byte
+	EBlockEntries [][]byte
+	DBlockHeader  []byte
+	DBlockEntries []DBlockEntry
+	DBlockKeyMR   []byte
+}
+
+// makeTestBlocks puts the entries in an EBlock of the chain, with a minute
+// marker after them, and the EBlock in a DBlock at the height
+func makeTestBlocks(chainID []byte, height uint32, entries ...[]byte) *testBlocks {
+	b := new(testBlocks)
+	b.Entries = entries
+	for _, e := range entries {
+		b.EBlockEntries = append(b.EBlockEntries, EntryHash(e))
+	}
+	b.EBlockEntries = append(b.EBlockEntries, append(make([]byte, 31), 1))
+
+	num := func(n uint32) []byte {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, n)
+		return data
+	}
+
+	bodyMR := merkleRoot(b.EBlockEntries)
+	b.EBlockHeader = bytes.Join([][]byte{chainID, bodyMR, make([]byte, 64), num(7), num(height), num(uint32(len(entries)))}, nil)
+	keyMR := sum(sum(b.EBlockHeader), bodyMR)
+
+	// The admin, entry credit and factoid blocks, then the eblocks
+	for _, c := range [][]byte{sum([]byte("a")), sum([]byte("c")), sum([]byte("f")), chainID, sum([]byte("other"))} {
+		b.DBlockEntries = append(b.DBlockEntries, DBlockEntry{ChainID: c, KeyMR: sum(c, []byte("keymr"))})
+	}
+	b.DBlockEntries[3].KeyMR = keyMR
+
+	var leaves [][]byte
+	for _, e := range b.DBlockEntries {
+		leaves = append(leaves, sum(e.ChainID, e.KeyMR))
+	}
+	dbodyMR := merkleRoot(leaves)
+	b.DBlockHeader = bytes.Join([][]byte{{0}, num(0xFA92E5A2), dbodyMR, make([]byte, 64), num(0), num(height), num(uint32(len(leaves)))}, nil)
+	b.DBlockKeyMR = sum(sum(b.DBlockHeader), dbodyMR)
+	return b
+}
+
+func (b *testBlocks) receipt(t *testing.T, i int) *EntryReceipt {
+	r, err := NewEntryReceipt(b.Entries[i], b.EBlockHeader, b.EBlockEntries, b.DBlockHeader, b.DBlockEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestEntryReceipt(t *testing.T) {
+	chainID := sum([]byte("vote"))
+	b := makeTestBlocks(chainID, 1000, []byte("commit"), []byte("reveal"), []byte("other"))
+
+	for i := range b.Entries {
+		r := b.receipt(t, i)
+		if err := r.Verify(); err != nil {
+			t.Errorf("%d: exp a valid receipt, got %s", i, err.Error())
+		}
+		if r.DBlockKeyMR != hex.EncodeToString(b.DBlockKeyMR) || r.DBlockHeight != 1000 || r.ChainID != hex.EncodeToString(chainID) {
+			t.Errorf("%d: exp the dblock of the entry, got %d %s", i, r.DBlockHeight, r.DBlockKeyMR)
+		}
+	}
+
+	if _, err := NewEntryReceipt([]byte("missing"), b.EBlockHeader, b.EBlockEntries, b.DBlockHeader, b.DBlockEntries); err == nil {
+		t.Errorf("exp an entry not in the eblock to fail")
+	}
+
+	flip := func(s string) string {
+		last := "0"
+		if strings.HasSuffix(s, last) {
+			last = "1"
+		}
+		return s[:len(s)-1] + last
+	}
+	tamper := []struct {
+		Name   string
+		Change func(r *EntryReceipt)
+	}{
+		{"entry", func(r *EntryReceipt) { r.Entry = hex.EncodeToString([]byte("commit!")) }},
+		{"entry hash", func(r *EntryReceipt) { r.EntryHash = flip(r.EntryHash) }},
+		{"chain", func(r *EntryReceipt) { r.ChainID = flip(r.ChainID) }},
+		{"entry path", func(r *EntryReceipt) { r.EntryPath[0].Top = flip(r.EntryPath[0].Top) }},
+		{"short entry path", func(r *EntryReceipt) { r.EntryPath = r.EntryPath[1:] }},
+		{"eblock keymr", func(r *EntryReceipt) { r.EBlockKeyMR = flip(r.EBlockKeyMR) }},
+		{"eblock header", func(r *EntryReceipt) { r.EBlockHeader = flip(r.EBlockHeader) }},
+		{"eblock path", func(r *EntryReceipt) { r.EBlockPath[1].Left = flip(r.EBlockPath[1].Left) }},
+		{"dblock height", func(r *EntryReceipt) { r.DBlockHeight++ }},
+		{"dblock keymr", func(r *EntryReceipt) { r.DBlockKeyMR = flip(r.DBlockKeyMR) }},
+		{"dblock header", func(r *EntryReceipt) { r.DBlockHeader = flip(r.DBlockHeader) }},
+	}
+	for _, c := range tamper {
+		r := b.receipt(t, 1)
+		c.Change(r)
+		if err := r.Verify(); err == nil {
+			t.Errorf("exp a changed %s to fail", c.Name)
+		}
+	}
+}
+
+// rawEntry is the binary of an entry, as factomd hashes it
+func rawEntry(chainID, content []byte, extIDs ...[]byte) []byte {
+	var ext []byte
+	for _, x := range extIDs {
+		ext = append(ext, byte(len(x)>>8), byte(len(x)))
+		ext = append(ext, x...)
+	}
+	data := append([]byte{0}, chainID...)
+	data = append(data, byte(len(ext)>>8), byte(len(ext)))
+	return append(append(data, ext...), content...)
+}
+
+func TestDecodeEntry(t *testing.T) {
+	chainID := sum([]byte("vote"))
+	data := rawEntry(chainID, []byte("content"), []byte(EXT0_VOTE_REVEAL), []byte{}, []byte("voter"))
+	e, err := DecodeEntry(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(e.ChainID, chainID) || string(e.Content) != "content" || len(e.ExtIDs) != 3 || string(e.ExtIDs[2]) != "voter" {
+		t.Errorf("exp the entry back, got %+v", e)
+	}
+
+	for _, bad := range [][]byte{data[:20], append([]byte{1}, data[1:]...), data[:38], append(data[:35:35], 0, 200)} {
+		if _, err := DecodeEntry(bad); err == nil {
+			t.Errorf("exp %x to fail", bad)
+		}
+	}
+}
+
+func TestVoterReceipt(t *testing.T) {
+	chainID := sum([]byte("vote"))
+	voter, other := sum([]byte("voter")), sum([]byte("other voter"))
+	commit := rawEntry(chainID, []byte(`{"commitment":"00"}`), []byte(EXT0_VOTE_COMMIT), voter, make([]byte, 32), make([]byte, 64))
+	reveal := rawEntry(chainID, []byte(`{"vote":["yes"]}`), []byte(EXT0_VOTE_REVEAL), voter)
+	otherReveal := rawEntry(chainID, []byte(`{"vote":["no"]}`), []byte(EXT0_VOTE_REVEAL), other)
+	b := makeTestBlocks(chainID, 1000, commit, reveal, otherReveal)
+
+	v := &VoterReceipt{VoteChain: hex.EncodeToString(chainID), VoterID: hex.EncodeToString(voter), Commit: b.receipt(t, 0)}
+	if err := v.Verify(); err != nil {
+		t.Errorf("exp a commit without a reveal to be valid, got %s", err.Error())
+	}
+	v.Reveal = b.receipt(t, 1)
+	if err := v.Verify(); err != nil {
+		t.Errorf("exp a valid receipt, got %s", err.Error())
+	}
+
+	// Entries in the chain, but not the commit and reveal of the voter
+	tampered := []struct {
+		Name   string
+		Change func(v *VoterReceipt)
+	}{
+		{"reveal of another voter", func(v *VoterReceipt) { v.Reveal = b.receipt(t, 2) }},
+		{"reveal as the commit", func(v *VoterReceipt) { v.Commit = b.receipt(t, 1) }},
+		{"commit as the reveal", func(v *VoterReceipt) { v.Reveal = b.receipt(t, 0) }},
+		{"voter", func(v *VoterReceipt) { v.VoterID = hex.EncodeToString(other) }},
+	}
+	for _, c := range tampered {
+		tv := *v
+		c.Change(&tv)
+		if err := tv.Verify(); err == nil {
+			t.Errorf("%s: exp the receipt to fail", c.Name)
+		}
+	}
+
+	// An entry of another chain is not a vote
+	otherChain := makeTestBlocks(sum([]byte("other")), 1000, reveal)
+	v.Reveal = otherChain.receipt(t, 0)
+	if err := v.Verify(); err == nil {
+		t.Errorf("exp an entry of another chain to fail")
+	}
+
+	if err := (&VoterReceipt{VoteChain: v.VoteChain}).Verify(); err == nil {
+		t.Errorf("exp an empty receipt to fail")
+	}
+}
@@ -0,0 +1,304 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// Recount applies the entries of a vote in memory, with the rules the scraper
+// applies through the database functions, and computes the result. The
+// eligible list is applied before the vote chain, so a commit is only checked
+// against the voters added at or below its height.
+type Recount struct {
+	Vote *Vote
+	// Keys looks up the identity keys of the proposal initiator and voters
+	Keys KeyLookup
+
+	header   *EligibleVoterHeader
+	voters   []*EligibleVoter
+	commits  map[[32]byte]*VoteCommit
+	reveals  []*VoteReveal
+	replayed map[string]bool
+
+	// Skipped are the entries that were not applied, and why
+	Skipped []SkippedEntry
+}
+
+// SkippedEntry is an entry a recount did not apply
+type SkippedEntry struct {
+	EntryHash   string `json:"entryHash"`
+	BlockHeight int    `json:"blockHeight"`
+	Reason      string `json:"reason"`
+}
+
+// NewRecount starts a recount of the vote of the proposal
+func NewRecount(proposal *ProposalEntry, keys KeyLookup) (*Recount, error) {
+	if valid, err := proposal.IsDataValid(); !valid {
+		return nil, fmt.Errorf("invalid proposal: %s", err.Error())
+	}
+
+	r := new(Recount)
+	r.Vote = new(Vote)
+	r.Vote.Proposal = proposal
+	r.Keys = keys
+	r.commits = make(map[[32]byte]*VoteCommit)
+	r.replayed = make(map[string]bool)
+	return r, nil
+}
+
+// AddEligibleEntry applies an entry of the eligible voter chain. The first is
+// the header, which can list voters in its content.
+func (r *Recount) AddEligibleEntry(entry interfaces.IEBEntry, height int) error {
+	err := r.addEligibleEntry(entry, height)
+	r.skip(entry, height, err)
+	return err
+}
+
+func (r *Recount) addEligibleEntry(entry interfaces.IEBEntry, height int) error {
+	if entry.GetChainID().String() != r.Vote.Proposal.Vote.EligibleVotersChainID.String() {
+		return fmt.Errorf("not an entry of the eligible voter chain")
+	}
+	if len(entry.ExternalIDs()) < 1 || string(entry.ExternalIDs()[0]) != EXT0_ELIGIBLE_VOTER_CHAIN {
+		return fmt.Errorf("not an eligible voter entry")
+	}
+
+	data, err := entry.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(data)
+	if r.replayed[string(hash[:])] {
+		return fmt.Errorf("repeated eligible entry tossed")
+	}
+
+	var voters []EligibleVoter
+	if r.header == nil {
+		if len(entry.ExternalIDs()) == 3 {
+			return fmt.Errorf("eligibility list does not exist")
+		}
+		head, err := NewEligibleVoterHeader(entry)
+		if err != nil {
+			return err
+		}
+
+		err = json.Unmarshal(entry.GetContent(), &voters)
+		if err != nil && len(entry.GetContent()) > 0 {
+			return err
+		}
+		for i := range voters {
+			voters[i].BlockHeight = height
+			voters[i].EligibleList.SetBytes(entry.GetChainID().Bytes())
+			voters[i].EntryHash.SetBytes(entry.GetHash().Bytes())
+		}
+		r.header = head
+	} else {
+		if len(entry.ExternalIDs()) != 3 {
+			return fmt.Errorf("eligibility list already exists")
+		}
+		ee, err := NewEligibleVoterEntry(entry, height, hex.EncodeToString(r.header.InitiatorKey[:]))
+		if err != nil {
+			return err
+		}
+		voters = ee.Content
+	}
+
+	for i := range voters {
+		if err := r.AddVoter(voters[i]); err != nil {
+			return err
+		}
+	}
+	r.replayed[string(hash[:])] = true
+	return nil
+}
+
+// AddVoter adds an eligible voter, with the keys of the voter's identity at
+// the height of the entry. A voter repeated in an entry is a replay.
+func (r *Recount) AddVoter(voter EligibleVoter) error {
+	for _, v := range r.voters {
+		if v.VoterID.IsSameAs(&voter.VoterID) && v.EntryHash.IsSameAs(&voter.EntryHash) {
+			return nil
+		}
+	}
+
+	keys, err := r.Keys(voter.VoterID.String(), int64(voter.BlockHeight))
+	if err != nil {
+		return err
+	}
+	voter.SigningKeys = nil
+	for _, k := range keys {
+		voter.SigningKeys = append(voter.SigningKeys, hex.EncodeToString(IdentityPublicKey(k)))
+	}
+	r.voters = append(r.voters, &voter)
+	return nil
+}
+
+// AddVoteEntry applies a commit or reveal of the vote chain. Other entries
+// are ignored.
+func (r *Recount) AddVoteEntry(entry interfaces.IEBEntry, height int) error {
+	err := r.addVoteEntry(entry, height)
+	r.skip(entry, height, err)
+	return err
+}
+
+func (r *Recount) addVoteEntry(entry interfaces.IEBEntry, height int) error {
+	if !entry.GetChainID().IsSameAs(r.Vote.Proposal.ProposalChain) {
+		return fmt.Errorf("not an entry of the vote chain")
+	}
+	if len(entry.ExternalIDs()) < 1 {
+		return nil
+	}
+
+	switch string(entry.ExternalIDs()[0]) {
+	case EXT0_VOTE_COMMIT:
+		c, err := NewVoteCommitFromEntry(entry, height)
+		if err != nil {
+			return err
+		}
+		return r.AddCommit(c)
+	case EXT0_VOTE_REVEAL:
+		rev, err := NewVoteRevealFromEntry(entry, height)
+		if err != nil {
+			return err
+		}
+		return r.AddReveal(rev)
+	case EXT0_VOTE_CHAIN:
+		return fmt.Errorf("vote chain already exists: %s", entry.GetChainID().String())
+	}
+	return nil
+}
+
+// AddCommit applies a commit, which replaces any earlier commit of the voter.
+// The key must be one of the voter's in the eligible list, and the commit in
+// the commit phase.
+func (r *Recount) AddCommit(c *VoteCommit) error {
+	replay := "commit:" + c.VoterID.String() + ":" + c.Content.Commitment
+	if r.replayed[replay] {
+		return nil
+	}
+
+	key := hex.EncodeToString(c.VoterKey[:])
+	validKey := false
+	for _, v := range r.voters {
+		if !bytes.Equal(v.VoterID.Bytes(), c.VoterID.Bytes()) || v.BlockHeight > c.BlockHeight {
+			continue
+		}
+		for _, k := range v.SigningKeys {
+			validKey = validKey || k == key
+		}
+	}
+	if !validKey {
+		return fmt.Errorf("signing key is not a key of the voter")
+	}
+
+	phases := r.Vote.Proposal.Vote.PhasesBlockHeights
+	if c.BlockHeight < phases.CommitStart || c.BlockHeight > phases.CommitEnd {
+		return fmt.Errorf("outside of the commit phase")
+	}
+
+	r.commits[c.VoterID.Fixed()] = c
+	r.replayed[replay] = true
+	return nil
+}
+
+// AddReveal applies a reveal in the reveal phase, which must match the
+// commitment of the voter's commit
+func (r *Recount) AddReveal(rev *VoteReveal) error {
+	replay := "reveal:" + rev.VoterID.String() + ":" + strings.Join(rev.Content.VoteOptions, ",")
+	if r.replayed[replay] {
+		return nil
+	}
+
+	phases := r.Vote.Proposal.Vote.PhasesBlockHeights
+	if rev.BlockHeight < phases.RevealStart || rev.BlockHeight > phases.RevealEnd {
+		return fmt.Errorf("outside of the reveal phase")
+	}
+
+	c, ok := r.commits[rev.VoterID.Fixed()]
+	if !ok {
+		return fmt.Errorf("no commit found for this reveal")
+	}
+	commitment, _ := hex.DecodeString(c.Content.Commitment)
+	secret, _ := hex.DecodeString(rev.Content.Secret)
+	if !CheckMAC(rev.Content.HmacAlgo, []byte(strings.Join(rev.Content.VoteOptions, "")), commitment, secret) {
+		return fmt.Errorf("reveal does not validate hmac against commit.")
+	}
+
+	r.reveals = append(r.reveals, rev)
+	r.replayed[replay] = true
+	return nil
+}
+
+func (r *Recount) skip(entry interfaces.IEBEntry, height int, err error) {
+	if err != nil {
+		r.Skipped = append(r.Skipped, SkippedEntry{entry.GetHash().String(), height, err.Error()})
+	}
+}
+
+// EligibleVoters are the latest entries of each voter below the height, the
+// same as fetch_eligible_voters
+func (r *Recount) EligibleVoters(height int) []*EligibleVoter {
+	latest := make(map[[32]byte]int)
+	for _, v := range r.voters {
+		if h, ok := latest[v.VoterID.Fixed()]; v.BlockHeight < height && (!ok || v.BlockHeight > h) {
+			latest[v.VoterID.Fixed()] = v.BlockHeight
+		}
+	}
+
+	var voters []*EligibleVoter
+	for _, v := range r.voters {
+		if h, ok := latest[v.VoterID.Fixed()]; ok && v.BlockHeight == h {
+			voters = append(voters, v)
+		}
+	}
+	return voters
+}
+
+// Commits are the commits that count, one per voter
+func (r *Recount) Commits() []*VoteCommit {
+	var commits []*VoteCommit
+	for _, c := range r.commits {
+		commits = append(commits, c)
+	}
+	sort.Slice(commits, func(i, j int) bool {
+		if commits[i].BlockHeight != commits[j].BlockHeight {
+			return commits[i].BlockHeight < commits[j].BlockHeight
+		}
+		return commits[i].EntryHash.String() < commits[j].EntryHash.String()
+	})
+	return commits
+}
+
+// Reveals are the reveals that match a commit, in the order they are tallied
+func (r *Recount) Reveals() []*VoteReveal {
+	reveals := append([]*VoteReveal{}, r.reveals...)
+	sort.SliceStable(reveals, func(i, j int) bool {
+		if reveals[i].BlockHeight != reveals[j].BlockHeight {
+			return reveals[i].BlockHeight < reveals[j].BlockHeight
+		}
+		return reveals[i].EntryHash.String() < reveals[j].EntryHash.String()
+	})
+	return reveals
+}
+
+// Result computes the result from the eligible voters at the start of the
+// commit phase and the reveals, the same as the scraper. It is provisional if
+// the recount is of the chain before the end of the reveal phase.
+func (r *Recount) Result(height int) (*VoteStats, error) {
+	phases := r.Vote.Proposal.Vote.PhasesBlockHeights
+	voters := r.EligibleVoters(phases.CommitStart)
+	reveals := r.Reveals()
+
+	stats, err := ComputeResult(r.Vote, voters, reveals)
+	if stats != nil && height < phases.RevealEnd {
+		stats.Provisional = true
+		stats.Unrevealed = ComputeUnrevealed(voters, r.Commits(), reveals)
+	}
+	return stats, err
+}
@@ -0,0 +1,254 @@
+package common_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/FactomProject/btcutil/base58"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// identityKey is the base58 form of an identity key with the public key
+func identityKey(pub []byte) string {
+	data := append([]byte{0x3f, 0xbe, 0xba}, pub...)
+	return base58.Encode(append(data, 0, 0, 0, 0))
+}
+
+type recountVoter struct {
+	ID  primitives.Hash
+	Key [32]byte
+}
+
+func newRecountVoter(b byte) *recountVoter {
+	v := new(recountVoter)
+	v.ID.SetBytes(bytes.Repeat([]byte{b}, 32))
+	v.Key[0] = b
+	return v
+}
+
+func (v *recountVoter) eligible(weight int64, height int) EligibleVoter {
+	var e EligibleVoter
+	e.VoterID = v.ID
+	e.VoteWeight = NewDecimal(weight)
+	e.BlockHeight = height
+	e.EntryHash.SetBytes(sum([]byte(fmt.Sprintf("%s %d", v.ID.String(), height))))
+	return e
+}
+
+func (v *recountVoter) commit(key [32]byte, height int, secret string, options ...string) *VoteCommit {
+	c := NewVoteCommit()
+	c.VoterID.SetBytes(v.ID.Bytes())
+	c.VoterKey = key
+	c.BlockHeight = height
+	c.EntryHash = primitives.RandomHash()
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, o := range options {
+		mac.Write([]byte(o))
+	}
+	c.Content.Commitment = hex.EncodeToString(mac.Sum(nil))
+	return c
+}
+
+func (v *recountVoter) reveal(height int, secret string, options ...string) *VoteReveal {
+	r := NewVoteReveal()
+	r.VoterID.SetBytes(v.ID.Bytes())
+	r.BlockHeight = height
+	r.EntryHash = primitives.RandomHash()
+	r.Content.VoteOptions = options
+	r.Content.Secret = hex.EncodeToString([]byte(secret))
+	r.Content.HmacAlgo = "sha256"
+	return r
+}
+
+func TestRecount(t *testing.T) {
+	p := NewEmptyProposalEntry()
+	p.ProposalChain = primitives.RandomHash()
+	p.Vote.PhasesBlockHeights.CommitStart = 10
+	p.Vote.PhasesBlockHeights.CommitEnd = 20
+	p.Vote.PhasesBlockHeights.RevealStart = 21
+	p.Vote.PhasesBlockHeights.RevealEnd = 30
+	p.Vote.VoteType = VOTE_BINARY
+	p.Vote.Config.Options = []string{"yes", "no"}
+	p.Vote.Config.MinOptions = 1
+	p.Vote.Config.MaxOptions = 1
+	p.Vote.Config.ComputeResultsAgainst = "ALL_ELIGIBLE_VOTERS"
+
+	v1, v2, v3 := newRecountVoter(1), newRecountVoter(2), newRecountVoter(3)
+	keys := func(identityChain string, height int64) ([]string, error) {
+		for _, v := range []*recountVoter{v1, v2, v3} {
+			if v.ID.String() == identityChain {
+				return []string{identityKey(v.Key[:])}, nil
+			}
+		}
+		return nil, fmt.Errorf("no identity %s", identityChain)
+	}
+
+	r, err := NewRecount(p, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The third voter is added during the commit phase
+	for _, v := range []EligibleVoter{v1.eligible(1, 5), v2.eligible(2, 5), v1.eligible(1, 5), v3.eligible(1, 15)} {
+		if err := r.AddVoter(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if voters := r.EligibleVoters(10); len(voters) != 2 {
+		t.Errorf("exp 2 eligible voters at the commit start, got %d", len(voters))
+	}
+
+	steps := []struct {
+		Name  string
+		Apply func() error
+		Valid bool
+	}{
+		{"commit", func() error { return r.AddCommit(v1.commit(v1.Key, 12, "s1", "yes")) }, true},
+		{"replayed commit", func() error { return r.AddCommit(v1.commit(v1.Key, 13, "s1", "yes")) }, true},
+		{"commit with another's key", func() error { return r.AddCommit(v2.commit(v1.Key, 12, "s2", "no")) }, false},
+		{"commit before the voter is added", func() error { return r.AddCommit(v3.commit(v3.Key, 12, "s3", "yes")) }, false},
+		{"commit after the commit phase", func() error { return r.AddCommit(v2.commit(v2.Key, 21, "s2", "no")) }, false},
+		{"second voter commit", func() error { return r.AddCommit(v2.commit(v2.Key, 13, "s2", "no")) }, true},
+		{"third voter commit", func() error { return r.AddCommit(v3.commit(v3.Key, 16, "s3", "yes")) }, true},
+		{"reveal in the commit phase", func() error { return r.AddReveal(v1.reveal(20, "s1", "yes")) }, false},
+		{"reveal", func() error { return r.AddReveal(v1.reveal(22, "s1", "yes")) }, true},
+		{"reveal of another vote", func() error { return r.AddReveal(v2.reveal(22, "s2", "yes")) }, false},
+		{"reveal with another secret", func() error { return r.AddReveal(v2.reveal(22, "s1", "no")) }, false},
+		{"second voter reveal", func() error { return r.AddReveal(v2.reveal(23, "s2", "no")) }, true},
+		{"third voter reveal", func() error { return r.AddReveal(v3.reveal(24, "s3", "yes")) }, true},
+	}
+	for _, s := range steps {
+		if err := s.Apply(); (err == nil) != s.Valid {
+			t.Errorf("%s: exp valid %t, got %v", s.Name, s.Valid, err)
+		}
+	}
+	if len(r.Commits()) != 3 || len(r.Reveals()) != 3 {
+		t.Errorf("exp 3 commits and reveals, got %d and %d", len(r.Commits()), len(r.Reveals()))
+	}
+
+	stats, err := r.Result(40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Provisional || stats.Unrevealed != nil {
+		t.Errorf("exp a final result after the reveal phase")
+	}
+	if w := stats.OptionStats["no"].Weight; w.Cmp(NewDecimal(2)) != 0 {
+		t.Errorf("exp a weight of 2 for no, got %s", w)
+	}
+	if len(stats.Ballots) != 3 || stats.Ballots[2].Status != BallotExcluded {
+		t.Errorf("exp the voter added in the commit phase to be excluded, got %+v", stats.Ballots)
+	}
+
+	provisional, err := r.Result(25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !provisional.Provisional || provisional.Unrevealed == nil {
+		t.Errorf("exp a provisional result in the reveal phase")
+	}
+}
+
+func TestBundleKeys(t *testing.T) {
+	b := new(VoteBundle)
+	b.Version = BundleVersion
+	b.VoteChain = hex.EncodeToString(sum([]byte("vote")))
+
+	asked := 0
+	keys := b.RecordKeys(func(identityChain string, height int64) ([]string, error) {
+		asked++
+		if identityChain == "missing" {
+			return nil, fmt.Errorf("no identity")
+		}
+		return []string{identityChain + "-key"}, nil
+	})
+	for i := 0; i < 2; i++ {
+		if k, err := keys("id", 10); err != nil || len(k) != 1 || k[0] != "id-key" {
+			t.Errorf("exp the keys of the identity, got %v %v", k, err)
+		}
+	}
+	if _, err := keys("missing", 10); err == nil {
+		t.Errorf("exp a failed lookup to fail")
+	}
+	if asked != 2 || len(b.IdentityKeys) != 1 {
+		t.Errorf("exp the keys to be recorded once, asked %d times and recorded %d", asked, len(b.IdentityKeys))
+	}
+	if _, err := b.Keys("id", 11); err == nil {
+		t.Errorf("exp keys not in the bundle to fail")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := json.Marshal(b)
+	for _, data := range [][]byte{buf.Bytes(), plain} {
+		read, err := ReadBundle(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k, err := read.Keys("id", 10); err != nil || k[0] != "id-key" || read.VoteChain != b.VoteChain {
+			t.Errorf("exp the bundle to be read back, got %+v", read)
+		}
+	}
+
+	b.Version = 0
+	if _, _, err := b.Verify(); err == nil {
+		t.Errorf("exp an unknown version to fail")
+	}
+}
+
+func TestBundleEntryReceipt(t *testing.T) {
+	chainID := sum([]byte("vote"))
+	chain := hex.EncodeToString(chainID)
+	blocks := makeTestBlocks(chainID, 1000, rawEntry(chainID, []byte("proposal"), []byte(EXT0_VOTE_CHAIN)), rawEntry(chainID, []byte("reveal")))
+	withReceipt := func(r *EntryReceipt) BundleEntry {
+		return BundleEntry{EntryHash: r.EntryHash, Entry: r.Entry, BlockHeight: r.DBlockHeight, DBlockKeyMR: r.DBlockKeyMR, Receipt: r}
+	}
+	entry := func(i int) BundleEntry { return withReceipt(blocks.receipt(t, i)) }
+	if err := entry(0).VerifyReceipt(chain); err != nil {
+		t.Errorf("exp a valid receipt, got %s", err.Error())
+	}
+
+	other := makeTestBlocks(sum([]byte("other")), 1000, rawEntry(chainID, []byte("reveal")))
+	tampered := []struct {
+		Name   string
+		Change func(b *BundleEntry)
+	}{
+		{"no receipt", func(b *BundleEntry) { b.Receipt = nil }},
+		{"height", func(b *BundleEntry) { b.BlockHeight = 999 }},
+		{"dblock", func(b *BundleEntry) { b.DBlockKeyMR = hex.EncodeToString(sum([]byte("dblock"))) }},
+		{"receipt of another entry", func(b *BundleEntry) { b.Receipt = blocks.receipt(t, 1) }},
+		{"entry", func(b *BundleEntry) { b.Entry = blocks.receipt(t, 1).Entry }},
+		{"eblock of another chain", func(b *BundleEntry) { *b = withReceipt(other.receipt(t, 0)) }},
+	}
+	for _, c := range tampered {
+		b := entry(0)
+		c.Change(&b)
+		if err := b.VerifyReceipt(chain); err == nil {
+			t.Errorf("%s: exp the receipt to fail", c.Name)
+		}
+	}
+	if err := entry(0).VerifyReceipt(hex.EncodeToString(sum([]byte("other")))); err == nil {
+		t.Errorf("exp an entry of another chain to fail")
+	}
+
+	// A bundle is checked before anything is counted
+	b := &VoteBundle{Version: BundleVersion, VoteChain: chain, BlockHeight: 1000, Proposal: entry(0)}
+	b.Proposal.Receipt = nil
+	if _, _, err := b.Verify(); err == nil {
+		t.Errorf("exp a proposal without a receipt to fail")
+	}
+	b.Proposal, b.BlockHeight = entry(0), 999
+	if _, _, err := b.Verify(); err == nil {
+		t.Errorf("exp an entry above the bundle height to fail")
+	}
+	if dblocks := b.DBlocks(); dblocks[1000] != hex.EncodeToString(blocks.DBlockKeyMR) {
+		t.Errorf("exp the dblock of the proposal, got %v", dblocks)
+	}
+}
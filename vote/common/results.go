@@ -2,7 +2,6 @@ package common
 
 import (
 	"fmt"
-	"math"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -19,63 +18,114 @@ var plog = log.WithField("file", "results")
 // https://github.com/PaulBernier/factom-vote/blob/master/src/read-vote/compute-vote-result.js
 
 func ComputeResult(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*VoteReveal) (*VoteStats, error) {
-	reveals = FilterInvalidVotes(vote, eligibleVoters, reveals)
+	allReveals := reveals
+	reveals, ballots := AuditReveals(vote, eligibleVoters, reveals)
+
+	var stats *VoteStats
+	var err error
 	switch vote.Proposal.Vote.VoteType {
 	case VOTE_BINARY: // Binary :: 2 options, no abstain
-		return ComputeBinaryVote(vote, eligibleVoters, reveals)
+		stats, err = ComputeBinaryVote(vote, eligibleVoters, reveals)
 	case VOTE_SINGLE: // Single Option
-		return ComputeSingleVote(vote, eligibleVoters, reveals)
+		stats, err = ComputeSingleVote(vote, eligibleVoters, reveals)
 	case VOTE_IRV: // Instant Run-Off Voting
-		return ComputeIRVVote(vote, eligibleVoters, reveals)
+		stats, err = ComputeIRVVote(vote, eligibleVoters, reveals)
+	default:
+		return nil, fmt.Errorf("unsupported vote type: %d", vote.Proposal.Vote.VoteType)
+	}
+
+	if stats != nil {
+		stats.AlgorithmVersion = ResultsAlgorithmVersion
+		stats.InputHash = ResultInputHash(vote, eligibleVoters, allReveals)
+		if vote.Proposal.Vote.VoteType == VOTE_IRV {
+			for i := range ballots {
+				ballots[i].traceIRVRounds(stats.IRVRounds)
+			}
+		}
+		stats.Ballots = ballots
 	}
-	return nil, fmt.Errorf("unsupported vote type: %d", vote.Proposal.Vote.VoteType)
+	return stats, err
 }
 
 func FilterInvalidVotes(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*VoteReveal) []*VoteReveal {
-	minOptions := vote.Proposal.Vote.Config.MinOptions
-	maxOptions := vote.Proposal.Vote.Config.MaxOptions
+	validVotes, _ := AuditReveals(vote, eligibleVoters, reveals)
+	return validVotes
+}
 
-	// First convert the eligible voters to a map. We will remove from the map as we count the votes.
+// AuditReveals returns the reveals that count, and a ballot for every reveal
+// with why it is counted or excluded. Only the first valid reveal of a voter
+// counts.
+func AuditReveals(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*VoteReveal) ([]*VoteReveal, []Ballot) {
+	config := vote.Proposal.Vote.Config
+
+	// First convert the eligible voters to a map. Voters are marked as they are counted.
 	voterMap := make(map[[32]byte]*EligibleVoter)
 	for _, v := range eligibleVoters {
 		voterMap[v.VoterID.Fixed()] = v
 	}
-
-	validOptions := make(map[string]bool)
-	for _, s := range vote.Proposal.Vote.Config.Options {
-		validOptions[s] = true
-	}
+	counted := make(map[[32]byte]bool)
 
 	var validVotes []*VoteReveal
-ValidVoteLoop:
+	ballots := make([]Ballot, 0, len(reveals))
 	for _, r := range reveals {
-		// Voter exists
-		if _, ok := voterMap[r.VoterID.Fixed()]; ok {
-			// Option length is ok
-			if len(r.Content.VoteOptions) >= minOptions && len(r.Content.VoteOptions) <= maxOptions {
-				for _, v := range r.Content.VoteOptions {
-					// All vote options exist
-					if _, ok := validOptions[v]; !ok && v != "" {
-						continue ValidVoteLoop
-					}
-				}
-				// Valid
-				validVotes = append(validVotes, r)
-				delete(voterMap, r.VoterID.Fixed())
-			} else if len(r.Content.VoteOptions) == 0 && vote.Proposal.Vote.Config.AllowAbstention {
-				// Valid
-				validVotes = append(validVotes, r)
-				delete(voterMap, r.VoterID.Fixed())
+		b := Ballot{VoterID: r.VoterID.String(), Options: r.Content.VoteOptions, Status: BallotExcluded}
+		if r.EntryHash != nil {
+			b.EntryHash = r.EntryHash.String()
+		}
+		if b.Options == nil {
+			b.Options = []string{}
+		}
+
+		id := r.VoterID.Fixed()
+		voter, ok := voterMap[id]
+		if !ok {
+			b.Reason = "not an eligible voter"
+		} else if counted[id] {
+			b.Reason = "the voter already has a counted reveal"
+		} else if err := CheckVoteOptions(r.Content.VoteOptions, config.Options, config.MinOptions, config.MaxOptions, config.AllowAbstention); err != nil {
+			b.Reason = err.Error()
+		} else {
+			b.Status = BallotCounted
+			b.Weight = voter.VoteWeight
+			validVotes = append(validVotes, r)
+			counted[id] = true
+		}
+		ballots = append(ballots, b)
+	}
+	return validVotes, ballots
+}
+
+// CheckVoteOptions returns why the options chosen in a reveal do not count,
+// or nil if they do.
+func CheckVoteOptions(chosen, options []string, minOptions, maxOptions int, allowAbstention bool) error {
+	// Option length is ok
+	if len(chosen) >= minOptions && len(chosen) <= maxOptions {
+		validOptions := make(map[string]bool)
+		for _, s := range options {
+			validOptions[s] = true
+		}
+		// All vote options exist
+		for _, v := range chosen {
+			if _, ok := validOptions[v]; !ok && v != "" {
+				return fmt.Errorf("'%s' is not an option of the vote", v)
 			}
 		}
+		return nil
 	}
-	return validVotes
+
+	if len(chosen) == 0 {
+		if allowAbstention {
+			return nil
+		}
+		return fmt.Errorf("abstention is not allowed")
+	}
+	return fmt.Errorf("%d options chosen, the vote requires between %d and %d", len(chosen), minOptions, maxOptions)
 }
 
 type IRVRoundResult struct {
 	Option string
-	Count  float64
-	Weight float64
+	Count  Decimal
+	Weight Decimal
 }
 
 func ComputeIRVVote(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*VoteReveal) (*VoteStats, error) {
@@ -96,8 +146,8 @@ func ComputeIRVVote(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*Vote
 	voterMap := make(map[[32]byte]*EligibleVoter)
 	for _, v := range eligibleVoters {
 		voterMap[v.VoterID.Fixed()] = v
-		stats.CompleteStats.Count += 1
-		stats.CompleteStats.Weight += float64(v.VoteWeight)
+		stats.CompleteStats.Count = stats.CompleteStats.Count.Add(decimalOne)
+		stats.CompleteStats.Weight = stats.CompleteStats.Weight.Add(v.VoteWeight)
 	}
 
 	var revealCopies []*VoteReveal
@@ -109,12 +159,12 @@ func ComputeIRVVote(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*Vote
 	for _, r := range revealCopies {
 		// Get the totals
 		if voter, ok := voterMap[r.VoterID.Fixed()]; ok {
-			stats.VotedStats.Count += 1
-			stats.VotedStats.Weight += float64(voter.VoteWeight)
+			stats.VotedStats.Count = stats.VotedStats.Count.Add(decimalOne)
+			stats.VotedStats.Weight = stats.VotedStats.Weight.Add(voter.VoteWeight)
 
 			if len(r.Content.VoteOptions) == 0 {
-				stats.AbstainedStats.Count += 1
-				stats.AbstainedStats.Weight += float64(voter.VoteWeight)
+				stats.AbstainedStats.Count = stats.AbstainedStats.Count.Add(decimalOne)
+				stats.AbstainedStats.Weight = stats.AbstainedStats.Weight.Add(voter.VoteWeight)
 			}
 		}
 	}
@@ -127,7 +177,7 @@ func ComputeIRVVote(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*Vote
 
 		// Init rounds to 0 votes per option
 		for opt, _ := range availableOptions {
-			round[opt] = IRVRoundResult{Option: opt, Count: 0, Weight: 0}
+			round[opt] = IRVRoundResult{Option: opt}
 		}
 
 		// Tally votes
@@ -189,20 +239,20 @@ func ComputeIRVVote(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*Vote
 }
 
 func minority(roundResult map[string]IRVRoundResult) []IRVRoundResult {
-	lowest := -1.0
+	var lowest Decimal
 	var lowestR []IRVRoundResult
 	for _, r := range roundResult {
-		if lowest == -1 {
-			lowest = r.Count
+		if lowestR == nil {
+			lowest = r.Weight
 			lowestR = append(lowestR, r)
 			continue
 		}
-		if r.Weight < lowest {
-			lowest = r.Count
+		if r.Weight.Cmp(lowest) < 0 {
+			lowest = r.Weight
 			lowestR = []IRVRoundResult{r}
 			continue
 		}
-		if r.Weight == lowest {
+		if r.Weight.Cmp(lowest) == 0 {
 			lowestR = append(lowestR, r)
 		}
 	}
@@ -220,28 +270,28 @@ func majority(roundResult map[string]IRVRoundResult) *IRVRoundResult {
 	//}
 
 	// Uses 50% weight threshold
-	total := float64(0)
+	var total Decimal
 	for _, r := range roundResult {
-		total += r.Count
+		total = total.Add(r.Count)
 	}
-	threshold := math.Ceil((total / 2) + 0.5)
+	threshold := total.Quo(decimalTwo).Add(decimalHalf).Ceil()
 	for _, r := range roundResult {
-		if r.Count >= threshold {
+		if r.Count.Cmp(threshold) >= 0 {
 			return &r
 		}
 	}
 	return nil
 }
 
-func addRoundVote(round map[string]IRVRoundResult, opt string, weight float64) {
+func addRoundVote(round map[string]IRVRoundResult, opt string, weight Decimal) {
 	if res, ok := round[opt]; ok {
-		res.Weight += weight
-		res.Count += 1
+		res.Weight = res.Weight.Add(weight)
+		res.Count = res.Count.Add(decimalOne)
 		round[opt] = res
 		return
 	}
 
-	round[opt] = IRVRoundResult{Option: opt, Count: 1, Weight: weight}
+	round[opt] = IRVRoundResult{Option: opt, Count: decimalOne, Weight: weight}
 }
 
 func ComputeBinaryVote(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*VoteReveal) (*VoteStats, error) {
@@ -266,18 +316,18 @@ func ComputeSingleVote(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*V
 
 type OptionStats struct {
 	Option string  `json:"option,omitempty"`
-	Count  float64 `json:"count"`
-	Weight float64 `json:"weight"`
+	Count  Decimal `json:"count"`
+	Weight Decimal `json:"weight"`
 }
 
 func (a OptionStats) IsSameAs(b OptionStats) bool {
 	if a.Option != b.Option {
 		return false
 	}
-	if a.Count != b.Count {
+	if a.Count.Cmp(b.Count) != 0 {
 		return false
 	}
-	if a.Weight != b.Weight {
+	if a.Weight.Cmp(b.Weight) != 0 {
 		return false
 	}
 	return true
@@ -285,18 +335,18 @@ func (a OptionStats) IsSameAs(b OptionStats) bool {
 
 type VoteOptionStats struct {
 	OptionStats
-	Support         float64 `json:"support"`
-	WeightedSupport float64 `json:"weightedSupport"`
+	Support         Decimal `json:"support"`
+	WeightedSupport Decimal `json:"weightedSupport"`
 }
 
 func (a VoteOptionStats) IsSameAs(b VoteOptionStats) bool {
 	if !a.OptionStats.IsSameAs(b.OptionStats) {
 		return false
 	}
-	if a.Support != b.Support {
+	if a.Support.Cmp(b.Support) != 0 {
 		return false
 	}
-	if a.WeightedSupport != b.WeightedSupport {
+	if a.WeightedSupport.Cmp(b.WeightedSupport) != 0 {
 		return false
 	}
 	return true
@@ -312,18 +362,77 @@ type VoteStats struct {
 	OptionStats    map[string]VoteOptionStats `json:"options"` // Count and weight of each option
 
 	Turnout struct {
-		UnweightedTurnout float64 `json:"unweightedTurnout"`
-		WeightedTurnout   float64 `json:"weightedTurnout"`
+		UnweightedTurnout Decimal `json:"unweightedTurnout"`
+		WeightedTurnout   Decimal `json:"weightedTurnout"`
 	} `json:"turnout"`
 
 	Support struct {
-		CountDenominator  float64 `json:"countDenominator"`
-		WeightDenominator float64 `json:"weightDenominator"`
+		CountDenominator  Decimal `json:"countDenominator"`
+		WeightDenominator Decimal `json:"weightDenominator"`
 	} `json:"support"`
 
 	IRVRounds []map[string]IRVRoundResult `json:"irvRounds, omitempty"`
 
 	WeightedWinners []VoteOptionStats `json:"weightedWinners,omitempty"`
+
+	// Provisional stats are computed from the reveals so far, before the vote
+	// is complete, and can still change.
+	Provisional bool             `json:"provisional,omitempty"`
+	Unrevealed  *UnrevealedStats `json:"unrevealed,omitempty"`
+
+	// Ballots are the audit trail of every reveal, in the order given
+	Ballots []Ballot `json:"ballots,omitempty"`
+
+	// AlgorithmVersion is the ResultsAlgorithmVersion the result was computed
+	// with, and InputHash the ResultInputHash of what it was computed from
+	AlgorithmVersion int    `json:"algorithmVersion"`
+	InputHash        string `json:"inputHash,omitempty"`
+}
+
+const (
+	BallotCounted  = "counted"
+	BallotExcluded = "excluded"
+)
+
+// Ballot is the audit trail of a reveal: whether it was counted, and if not,
+// why. Weight is the weight applied to the tally, 0 if excluded.
+type Ballot struct {
+	VoterID   string   `json:"voterId"`
+	EntryHash string   `json:"entryHash"`
+	Options   []string `json:"options"`
+	Status    string   `json:"status"`
+	Reason    string   `json:"reason,omitempty"`
+	Weight    Decimal  `json:"weight"`
+	// Rounds of an IRV vote are the option the ballot counted toward in each
+	// round, empty once all its options are eliminated
+	Rounds []string `json:"rounds,omitempty"`
+}
+
+// traceIRVRounds fills in the option the ballot counted toward in each
+// round, the same way the rounds are tallied. The options still in a round
+// are the keys of its results.
+func (b *Ballot) traceIRVRounds(rounds []map[string]IRVRoundResult) {
+	if b.Status != BallotCounted {
+		return
+	}
+	b.Rounds = make([]string, len(rounds))
+	for i, round := range rounds {
+		for _, opt := range b.Options {
+			if _, ok := round[opt]; ok {
+				b.Rounds[i] = opt
+				break
+			}
+		}
+	}
+}
+
+// UnrevealedStats are the eligible voters that have committed but not yet
+// revealed, out of all that have committed.
+type UnrevealedStats struct {
+	Committed     OptionStats `json:"committed"`
+	Unrevealed    OptionStats `json:"unrevealed"`
+	Share         Decimal     `json:"share"`
+	WeightedShare Decimal     `json:"weightedShare"`
 }
 
 func NewVoteStats() *VoteStats {
@@ -334,13 +443,13 @@ func NewVoteStats() *VoteStats {
 
 // ComputeWinners will compute the highest weighted options
 func (s *VoteStats) ComputeWinners(v *Vote) {
-	maxWeight := float64(0)
+	var maxWeight Decimal
 	var winners []VoteOptionStats
 	for _, optStats := range s.OptionStats {
-		if optStats.Weight > maxWeight {
+		if optStats.Weight.Cmp(maxWeight) > 0 {
 			winners = []VoteOptionStats{optStats}
 			maxWeight = optStats.Weight
-		} else if optStats.Weight == maxWeight {
+		} else if optStats.Weight.Cmp(maxWeight) == 0 {
 			winners = append(winners, optStats)
 		}
 	}
@@ -350,11 +459,11 @@ func (s *VoteStats) ComputeWinners(v *Vote) {
 	for _, opt := range winners {
 		// Check for criteria for this option
 		if minSupport, ok := criteria.MinSupport[opt.Option]; ok {
-			if opt.Support >= minSupport.Unweighted && opt.WeightedSupport >= minSupport.Weighted {
+			if opt.Support.Cmp(minSupport.Unweighted) >= 0 && opt.WeightedSupport.Cmp(minSupport.Weighted) >= 0 {
 				s.WeightedWinners = append(s.WeightedWinners, opt)
 			}
 		} else if minSupport, ok := criteria.MinSupport["*"]; ok { // All options use this by default if not explicit
-			if opt.Support >= minSupport.Unweighted && opt.WeightedSupport >= minSupport.Weighted {
+			if opt.Support.Cmp(minSupport.Unweighted) >= 0 && opt.WeightedSupport.Cmp(minSupport.Weighted) >= 0 {
 				s.WeightedWinners = append(s.WeightedWinners, opt)
 			}
 		} else {
@@ -374,8 +483,8 @@ func (s *VoteStats) ComputeSupport(vote *Vote) error {
 			s.Support.CountDenominator = s.VotedStats.Count
 			s.Support.WeightDenominator = s.VotedStats.Weight
 		} else {
-			s.Support.CountDenominator = s.VotedStats.Count + s.AbstainedStats.Count
-			s.Support.WeightDenominator = s.VotedStats.Weight + s.AbstainedStats.Weight
+			s.Support.CountDenominator = s.VotedStats.Count.Add(s.AbstainedStats.Count)
+			s.Support.WeightDenominator = s.VotedStats.Weight.Add(s.AbstainedStats.Weight)
 		}
 	default:
 		// IRV does not use this, so don't report it's invalid
@@ -384,30 +493,68 @@ func (s *VoteStats) ComputeSupport(vote *Vote) error {
 		}
 	}
 
-	if s.Support.WeightDenominator != 0 && s.Support.CountDenominator != 0 {
+	if !s.Support.WeightDenominator.IsZero() && !s.Support.CountDenominator.IsZero() {
 		for k, opt := range s.OptionStats {
-			opt.Support = opt.Count / s.Support.CountDenominator
-			opt.WeightedSupport = opt.Weight / s.Support.WeightDenominator
+			opt.Support = opt.Count.Quo(s.Support.CountDenominator)
+			opt.WeightedSupport = opt.Weight.Quo(s.Support.WeightDenominator)
 			s.OptionStats[k] = opt
 		}
 	}
 
 	// Determine if the vote is valid
 	criteria := vote.Proposal.Vote.Config.AcceptanceCriteria
-	if s.CompleteStats.Weight == 0 || s.CompleteStats.Count == 0 {
+	if s.CompleteStats.Weight.IsZero() || s.CompleteStats.Count.IsZero() {
 		return nil
 	}
 
-	s.Turnout.UnweightedTurnout = s.VotedStats.Count / s.CompleteStats.Count
-	s.Turnout.WeightedTurnout = s.VotedStats.Weight / s.CompleteStats.Weight
+	s.Turnout.UnweightedTurnout = s.VotedStats.Count.Quo(s.CompleteStats.Count)
+	s.Turnout.WeightedTurnout = s.VotedStats.Weight.Quo(s.CompleteStats.Weight)
 
-	if s.Turnout.WeightedTurnout > criteria.MinTurnout.Weighted && s.Turnout.UnweightedTurnout > criteria.MinTurnout.Unweighted {
+	if s.Turnout.WeightedTurnout.Cmp(criteria.MinTurnout.Weighted) > 0 && s.Turnout.UnweightedTurnout.Cmp(criteria.MinTurnout.Unweighted) > 0 {
 		s.Valid = true
 	}
 
 	return nil
 }
 
+// ComputeUnrevealed counts the eligible voters with a commit and no reveal
+func ComputeUnrevealed(eligibleVoters []*EligibleVoter, commits []*VoteCommit, reveals []*VoteReveal) *UnrevealedStats {
+	voterMap := make(map[[32]byte]*EligibleVoter)
+	for _, v := range eligibleVoters {
+		voterMap[v.VoterID.Fixed()] = v
+	}
+	revealed := make(map[[32]byte]bool)
+	for _, r := range reveals {
+		revealed[r.VoterID.Fixed()] = true
+	}
+
+	u := new(UnrevealedStats)
+	counted := make(map[[32]byte]bool)
+	for _, c := range commits {
+		id := c.VoterID.Fixed()
+		voter, ok := voterMap[id]
+		if !ok || counted[id] {
+			continue
+		}
+		counted[id] = true
+
+		u.Committed.Count = u.Committed.Count.Add(decimalOne)
+		u.Committed.Weight = u.Committed.Weight.Add(voter.VoteWeight)
+		if !revealed[id] {
+			u.Unrevealed.Count = u.Unrevealed.Count.Add(decimalOne)
+			u.Unrevealed.Weight = u.Unrevealed.Weight.Add(voter.VoteWeight)
+		}
+	}
+
+	if u.Committed.Count.Sign() > 0 {
+		u.Share = u.Unrevealed.Count.Quo(u.Committed.Count)
+	}
+	if u.Committed.Weight.Sign() > 0 {
+		u.WeightedShare = u.Unrevealed.Weight.Quo(u.Committed.Weight)
+	}
+	return u
+}
+
 // ComputeVoteStatistics
 func ComputeVoteStatistics(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*VoteReveal) (*VoteStats, error) {
 	flog := plog.WithFields(log.Fields{"vote": vote.Proposal.ProposalChain.String()})
@@ -427,16 +574,16 @@ func ComputeVoteStatistics(vote *Vote, eligibleVoters []*EligibleVoter, reveals
 	voterMap := make(map[[32]byte]*EligibleVoter)
 	for _, v := range eligibleVoters {
 		voterMap[v.VoterID.Fixed()] = v
-		stats.CompleteStats.Count += 1
-		stats.CompleteStats.Weight += float64(v.VoteWeight)
+		stats.CompleteStats.Count = stats.CompleteStats.Count.Add(decimalOne)
+		stats.CompleteStats.Weight = stats.CompleteStats.Weight.Add(v.VoteWeight)
 	}
 
 	// Run through the reveals to tally up the vote options
 	for _, r := range reveals {
 		if voter, ok := voterMap[r.VoterID.Fixed()]; ok {
 			if vote.Proposal.Vote.Config.AllowAbstention && len(r.Content.VoteOptions) == 0 {
-				stats.AbstainedStats.Count += 1
-				stats.AbstainedStats.Weight += float64(voter.VoteWeight)
+				stats.AbstainedStats.Count = stats.AbstainedStats.Count.Add(decimalOne)
+				stats.AbstainedStats.Weight = stats.AbstainedStats.Weight.Add(voter.VoteWeight)
 			} else if len(r.Content.VoteOptions) > maxOptions || len(r.Content.VoteOptions) < minOptions {
 				flog.WithFields(log.Fields{"eHash": r.EntryHash.String(), "reason": "optioncount"}).Errorf("Toss")
 				continue // Ignore, as it does not have the correct amount of votes
@@ -447,12 +594,12 @@ func ComputeVoteStatistics(vote *Vote, eligibleVoters []*EligibleVoter, reveals
 				if !ok {
 					continue
 				}
-				stat.Weight += float64(voter.VoteWeight)
-				stat.Count += 1
+				stat.Weight = stat.Weight.Add(voter.VoteWeight)
+				stat.Count = stat.Count.Add(decimalOne)
 				stats.OptionStats[v] = stat
 			}
-			stats.VotedStats.Count += 1
-			stats.VotedStats.Weight += float64(voter.VoteWeight)
+			stats.VotedStats.Count = stats.VotedStats.Count.Add(decimalOne)
+			stats.VotedStats.Weight = stats.VotedStats.Weight.Add(voter.VoteWeight)
 			delete(voterMap, r.VoterID.Fixed())
 		}
 	}
@@ -6,6 +6,9 @@ import (
 	"fmt"
 
 	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 
 	. "github.com/Emyrk/go-factom-vote/vote/common"
 	"github.com/FactomProject/factomd/common/primitives"
@@ -69,7 +72,7 @@ func (tv *TestVote) AddVote(options []string, weight float64) {
 	reveal.EntryHash = primitives.RandomHash()
 
 	voter := new(EligibleVoter)
-	voter.VoteWeight = weight
+	voter.VoteWeight = DecimalFromFloat(weight)
 	voter.VoterID = *(reveal.VoterID.(*primitives.Hash))
 
 	tv.Reveals = append(tv.Reveals, reveal)
@@ -154,10 +157,43 @@ func ExpectedChecks(stats *VoteStats, vector VoteVector) error {
 	return nil
 }
 
+// dec is the exact decimal of a literal, so 4.1 is 41/10
+func dec(f float64) Decimal {
+	return DecimalFromFloat(f)
+}
+
 func (tv *TestVote) Params() (*Vote, []*EligibleVoter, []*VoteReveal) {
 	return tv.Vote, tv.EligibleVoters, tv.Reveals
 }
 
+// TestResultVectors checks the result of every vector in testdata/results
+// against its expected result
+func TestResultVectors(t *testing.T) {
+	dir := filepath.Join("testdata", "results")
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no result vectors in %s", dir)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vectors []*ResultVector
+		if err := json.Unmarshal(data, &vectors); err != nil {
+			t.Fatalf("%s: %s", file, err.Error())
+		}
+		for _, rv := range vectors {
+			if err := rv.Check(); err != nil {
+				t.Errorf("%s: %s", rv.Title, err.Error())
+			}
+		}
+	}
+}
+
 // Test all vote Types
 
 func TestVoteVector(t *testing.T) {
@@ -270,6 +306,39 @@ func TestIRVVoteMultiple(t *testing.T) {
 	}
 }
 
+// A heavy voter for A is one count but the most weight. The options of lowest
+// weight are eliminated, D then C, and A stays in every round. Eliminating by
+// a count compared to weights depended on the order of the round map, so the
+// vote is computed a few times.
+func TestIRVEliminatesLowestWeight(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		vote := MakeTestVote([]string{"A", "B", "C", "D"}, 1, 4)
+		vote.SetType(VOTE_IRV)
+		vote.AddVote([]string{"A"}, 4)
+		vote.AddVote([]string{"B"}, 1)
+		vote.AddVote([]string{"B", "A"}, 1)
+		vote.AddVote([]string{"C", "B"}, 1)
+		vote.AddVote([]string{"D"}, 0.5)
+
+		stats, err := ComputeResult(vote.Params())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ExpectedWinners(stats, []string{"B"}); err != nil {
+			t.Fatal(err)
+		}
+		if w := stats.OptionStats["A"].Weight; w.Cmp(dec(4)) != 0 {
+			t.Fatalf("exp A to keep a weight of 4 to the last round, got %s", w)
+		}
+		exp := [][]string{{"A", "A", "A"}, {"B", "B", "B"}, {"B", "B", "B"}, {"C", "C", "B"}, {"D", "", ""}}
+		for j, b := range stats.Ballots {
+			if fmt.Sprint(b.Rounds) != fmt.Sprint(exp[j]) {
+				t.Fatalf("[%d] exp rounds %q, got %q", j, exp[j], b.Rounds)
+			}
+		}
+	}
+}
+
 // Test Vectors
 
 type IndvVote struct {
@@ -347,7 +416,7 @@ var VoteVectors = []VoteVector{
 		Options:  []string{"A", "B", "C"},
 		ExtraConfigs: NewExtraConfigs(map[string]interface{}{
 			"min": 1, "max": 1, "win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, .5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(.5)}},
 			},
 			"cpa": "ALL_ELIGIBLE_VOTERS",
 			"abs": true,
@@ -368,7 +437,7 @@ var VoteVectors = []VoteVector{
 		Options:  []string{"A", "B", "C"},
 		ExtraConfigs: NewExtraConfigs(map[string]interface{}{
 			"min": 1, "max": 1, "win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, .5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(.5)}},
 			},
 			"cpa": "PARTICIPANTS_ONLY",
 		}),
@@ -383,7 +452,7 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			WinnerStats: map[string]VoteOptionStats{
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 2, Weight: 2}, Support: 2.0 / 3.0, WeightedSupport: 2.0 / 3.0},
+					OptionStats: OptionStats{Option: "A", Count: dec(2), Weight: dec(2)}, Support: dec(2.0).Quo(dec(3.0)), WeightedSupport: dec(2.0).Quo(dec(3.0))},
 			},
 		},
 	},
@@ -394,7 +463,7 @@ var VoteVectors = []VoteVector{
 		Options:  []string{"A", "B", "C"},
 		ExtraConfigs: NewExtraConfigs(map[string]interface{}{
 			"min": 1, "max": 1, "win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, .5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(.5)}},
 			},
 			"cpa": "PARTICIPANTS_ONLY",
 			"abs": true,
@@ -409,9 +478,9 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 2, Weight: 2}},
+					OptionStats: OptionStats{Option: "A", Count: dec(2), Weight: dec(2)}},
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 3, Weight: 3}},
+					OptionStats: OptionStats{Option: "", Count: dec(3), Weight: dec(3)}},
 			},
 		},
 		Winners: []string{},
@@ -424,7 +493,7 @@ var VoteVectors = []VoteVector{
 		Options:  []string{"A", "B", "C"},
 		ExtraConfigs: NewExtraConfigs(map[string]interface{}{
 			"min": 1, "max": 5, "win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, .5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(.5)}},
 			},
 			"cpa": "PARTICIPANTS_ONLY",
 			"abs": true,
@@ -440,17 +509,17 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 4, Weight: 4}},
+					OptionStats: OptionStats{Option: "A", Count: dec(4), Weight: dec(4)}},
 				"B": VoteOptionStats{
-					OptionStats: OptionStats{Option: "B", Count: 4, Weight: 4}},
+					OptionStats: OptionStats{Option: "B", Count: dec(4), Weight: dec(4)}},
 				"C": VoteOptionStats{
-					OptionStats: OptionStats{Option: "C", Count: 5, Weight: 4.1}},
+					OptionStats: OptionStats{Option: "C", Count: dec(5), Weight: dec(4.1)}},
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 1, Weight: 1}},
+					OptionStats: OptionStats{Option: "", Count: dec(1), Weight: dec(1)}},
 			},
 			WinnerStats: map[string]VoteOptionStats{
 				"C": VoteOptionStats{
-					OptionStats: OptionStats{Option: "C", Count: 5, Weight: 4.1}, Support: 5.0 / 6.0, WeightedSupport: 4.1 / 5.1},
+					OptionStats: OptionStats{Option: "C", Count: dec(5), Weight: dec(4.1)}, Support: dec(5.0).Quo(dec(6.0)), WeightedSupport: dec(4.1).Quo(dec(5.1))},
 			},
 		},
 		Winners: []string{"C"},
@@ -466,7 +535,7 @@ var VoteVectors = []VoteVector{
 		Options:  []string{"A", "B"},
 		ExtraConfigs: NewExtraConfigs(map[string]interface{}{
 			"min": 1, "max": 5, "win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, 0.5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(0.5)}},
 			},
 			"cpa": "PARTICIPANTS_ONLY",
 			"abs": true,
@@ -482,11 +551,11 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 4, Weight: 4}},
+					OptionStats: OptionStats{Option: "A", Count: dec(4), Weight: dec(4)}},
 				"B": VoteOptionStats{
-					OptionStats: OptionStats{Option: "B", Count: 1, Weight: 1}},
+					OptionStats: OptionStats{Option: "B", Count: dec(1), Weight: dec(1)}},
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 1, Weight: 10}},
+					OptionStats: OptionStats{Option: "", Count: dec(1), Weight: dec(10)}},
 			},
 		},
 		Winners: []string{},
@@ -498,7 +567,7 @@ var VoteVectors = []VoteVector{
 		Options:  []string{"A", "B"},
 		ExtraConfigs: NewExtraConfigs(map[string]interface{}{
 			"min": 1, "max": 2, "win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.3, 0.5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.3), dec(0.5)}},
 			},
 			"cpa": "PARTICIPANTS_ONLY",
 			"abs": true,
@@ -510,11 +579,11 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 1, Weight: 1.01}},
+					OptionStats: OptionStats{Option: "A", Count: dec(1), Weight: dec(1.01)}},
 				"B": VoteOptionStats{
-					OptionStats: OptionStats{Option: "B", Count: 1, Weight: 1}},
+					OptionStats: OptionStats{Option: "B", Count: dec(1), Weight: dec(1)}},
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 0, Weight: 0}},
+					OptionStats: OptionStats{Option: "", Count: dec(0), Weight: dec(0)}},
 			},
 		},
 		Winners: []string{"A"},
@@ -526,7 +595,7 @@ var VoteVectors = []VoteVector{
 		Options:  []string{"A", "B"},
 		ExtraConfigs: NewExtraConfigs(map[string]interface{}{
 			"min": 1, "max": 2, "win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.3, 0.5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.3), dec(0.5)}},
 			},
 			"cpa": "ALL_ELIGIBLE_VOTERS",
 			"abs": true,
@@ -541,11 +610,11 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 1, Weight: 1.01}},
+					OptionStats: OptionStats{Option: "A", Count: dec(1), Weight: dec(1.01)}},
 				"B": VoteOptionStats{
-					OptionStats: OptionStats{Option: "B", Count: 1, Weight: 1}},
+					OptionStats: OptionStats{Option: "B", Count: dec(1), Weight: dec(1)}},
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 0, Weight: 0}},
+					OptionStats: OptionStats{Option: "", Count: dec(0), Weight: dec(0)}},
 			},
 		},
 		Winners: []string{},
@@ -674,7 +743,7 @@ var VoteVectors = []VoteVector{
 			"cpa": "ALL_ELIGIBLE_VOTERS",
 			"abs": true,
 			"win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, 0.5}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(0.5)}},
 			},
 		}),
 		Votes: []IndvVote{
@@ -1042,10 +1111,10 @@ var VoteVectors = []VoteVector{
 			"min": 6, "max": 6,
 			"cpa": "ALL_ELIGIBLE_VOTERS",
 			"abs": true,
-			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: 0.5}},
+			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: dec(0.5)}},
 			// DOES NOT AFFECT IRV
 			"win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, 0}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(0)}},
 			},
 		}),
 		Votes: []IndvVote{
@@ -1057,7 +1126,7 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 3, Weight: 3}},
+					OptionStats: OptionStats{Option: "", Count: dec(3), Weight: dec(3)}},
 			},
 			Additional: map[string]interface{}{
 				"valid": true,
@@ -1076,7 +1145,7 @@ var VoteVectors = []VoteVector{
 			"min": 3, "max": 3,
 			"cpa": "ALL_ELIGIBLE_VOTERS",
 			"abs": true,
-			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: 0.5}},
+			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: dec(0.5)}},
 		}),
 		Votes: []IndvVote{
 			IndvVote{[]string{}, 1},
@@ -1087,9 +1156,9 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 3, Weight: 3}},
+					OptionStats: OptionStats{Option: "", Count: dec(3), Weight: dec(3)}},
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 1, Weight: 1}, Support: 1.0 / 4.0, WeightedSupport: 1.0 / 4.0},
+					OptionStats: OptionStats{Option: "A", Count: dec(1), Weight: dec(1)}, Support: dec(1.0).Quo(dec(4.0)), WeightedSupport: dec(1.0).Quo(dec(4.0))},
 			},
 			Additional: map[string]interface{}{
 				"valid": true,
@@ -1117,9 +1186,9 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 1, Weight: 1}},
+					OptionStats: OptionStats{Option: "", Count: dec(1), Weight: dec(1)}},
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 2, Weight: 2}, Support: 2.0 / 4.0, WeightedSupport: 2.0 / 4.0},
+					OptionStats: OptionStats{Option: "A", Count: dec(2), Weight: dec(2)}, Support: dec(2.0).Quo(dec(4.0)), WeightedSupport: dec(2.0).Quo(dec(4.0))},
 			},
 			Additional: map[string]interface{}{
 				"valid": true,
@@ -1137,9 +1206,9 @@ var VoteVectors = []VoteVector{
 			"min": 1, "max": 10,
 			"cpa": "ALL_ELIGIBLE_VOTERS",
 			"abs": true,
-			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: 0.5}},
+			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: dec(0.5)}},
 			"win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{0, 0}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(0), dec(0)}},
 			},
 		}),
 		Votes: []IndvVote{
@@ -1151,9 +1220,9 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 0, Weight: 0}},
+					OptionStats: OptionStats{Option: "", Count: dec(0), Weight: dec(0)}},
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 1, Weight: 1}, Support: 1.0 / 4.0, WeightedSupport: 1.0 / 4.0},
+					OptionStats: OptionStats{Option: "A", Count: dec(1), Weight: dec(1)}, Support: dec(1.0).Quo(dec(4.0)), WeightedSupport: dec(1.0).Quo(dec(4.0))},
 			},
 			Additional: map[string]interface{}{
 				"valid": false,
@@ -1171,9 +1240,9 @@ var VoteVectors = []VoteVector{
 			"min": 1, "max": 10,
 			"cpa": "PARTICIPANTS_ONLY",
 			"abs": true,
-			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: 0.0}},
+			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: dec(0.0)}},
 			"win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, 0}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(0)}},
 			},
 		}),
 		Votes: []IndvVote{
@@ -1185,9 +1254,9 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 0, Weight: 0}},
+					OptionStats: OptionStats{Option: "", Count: dec(0), Weight: dec(0)}},
 				"A": VoteOptionStats{
-					OptionStats: OptionStats{Option: "A", Count: 1, Weight: 1}, Support: 1.0 / 1.0, WeightedSupport: 1.0 / 1.0},
+					OptionStats: OptionStats{Option: "A", Count: dec(1), Weight: dec(1)}, Support: dec(1.0).Quo(dec(1.0)), WeightedSupport: dec(1.0).Quo(dec(1.0))},
 			},
 			Additional: map[string]interface{}{
 				"valid": true,
@@ -1205,10 +1274,10 @@ var VoteVectors = []VoteVector{
 			"min": 1, "max": 10,
 			"cpa": "ALL_ELIGIBLE_VOTERS",
 			"abs": true,
-			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: 0.5}},
+			"sup": AcceptCriteriaStruct{MinTurnout: CriteriaWeights{Unweighted: dec(0.5)}},
 			// Ignored in IRV
 			"win": WinnerCriteriaStruct{
-				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{.5, 0}},
+				MinSupport: map[string]CriteriaWeights{"*": CriteriaWeights{dec(.5), dec(0)}},
 			},
 		}),
 		Votes: []IndvVote{
@@ -1220,7 +1289,7 @@ var VoteVectors = []VoteVector{
 		ExtraChecks: &ExtraChecks{
 			OptionStats: map[string]VoteOptionStats{
 				"": VoteOptionStats{
-					OptionStats: OptionStats{Option: "", Count: 0, Weight: 0}},
+					OptionStats: OptionStats{Option: "", Count: dec(0), Weight: dec(0)}},
 			},
 			Additional: map[string]interface{}{
 				"valid": false,
@@ -1257,3 +1326,143 @@ var VoteVectors = []VoteVector{
 		},
 	},
 }
+
+func TestCheckVoteOptions(t *testing.T) {
+	options := []string{"yes", "no", "maybe"}
+	type Check struct {
+		Chosen  []string
+		Abstain bool
+		Valid   bool
+	}
+	checks := []Check{
+		{[]string{"yes"}, false, true},
+		{[]string{"yes", "no"}, false, true},
+		{[]string{"yes", "no", "maybe"}, false, false},
+		{[]string{"never"}, false, false},
+		{[]string{}, false, false},
+		{[]string{}, true, true},
+	}
+
+	for i, c := range checks {
+		err := CheckVoteOptions(c.Chosen, options, 1, 2, c.Abstain)
+		if (err == nil) != c.Valid {
+			t.Errorf("[%d] %v: exp valid %t, got %v", i, c.Chosen, c.Valid, err)
+		}
+	}
+}
+
+func TestComputeUnrevealed(t *testing.T) {
+	var voters []*EligibleVoter
+	var commits []*VoteCommit
+	var reveals []*VoteReveal
+	for i, weight := range []float64{1, 2, 3, 4} {
+		id := primitives.RandomHash()
+		voters = append(voters, &EligibleVoter{VoterID: *(id.(*primitives.Hash)), VoteWeight: dec(weight)})
+
+		// The first three commit, twice for the first, and the first two reveal
+		if i < 3 {
+			commits = append(commits, &VoteCommit{VoterID: id})
+		}
+		if i == 0 {
+			commits = append(commits, &VoteCommit{VoterID: id})
+		}
+		if i < 2 {
+			reveals = append(reveals, &VoteReveal{VoterID: id})
+		}
+	}
+	// Not eligible
+	commits = append(commits, &VoteCommit{VoterID: primitives.RandomHash()})
+
+	u := ComputeUnrevealed(voters, commits, reveals)
+	if u.Committed.Count.Cmp(dec(3)) != 0 || u.Committed.Weight.Cmp(dec(6)) != 0 {
+		t.Errorf("exp 3 committed of weight 6, got %v", u.Committed)
+	}
+	if u.Unrevealed.Count.Cmp(dec(1)) != 0 || u.Unrevealed.Weight.Cmp(dec(3)) != 0 {
+		t.Errorf("exp 1 unrevealed of weight 3, got %v", u.Unrevealed)
+	}
+	if u.Share.Cmp(dec(1).Quo(dec(3))) != 0 || u.WeightedShare.Cmp(dec(0.5)) != 0 {
+		t.Errorf("exp shares of 1/3 and 0.5, got %s and %s", u.Share, u.WeightedShare)
+	}
+}
+
+func TestBallots(t *testing.T) {
+	vote := MakeTestVote([]string{"yes", "no"}, 1, 1)
+	vote.SetType(VOTE_SINGLE)
+	vote.AddVote([]string{"yes"}, 2)
+	vote.AddVote([]string{"maybe"}, 1)
+	vote.AddVote([]string{"yes", "no"}, 1)
+
+	// A second reveal of the first voter, and one of a voter not on the list
+	again := vote.Reveals[0].Copy()
+	again.Content.VoteOptions = []string{"no"}
+	vote.Reveals = append(vote.Reveals, again)
+	stranger := vote.Reveals[0].Copy()
+	stranger.VoterID = primitives.RandomHash()
+	vote.Reveals = append(vote.Reveals, stranger)
+
+	stats, err := ComputeResult(vote.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Ballots) != len(vote.Reveals) {
+		t.Fatalf("exp a ballot per reveal, got %d", len(stats.Ballots))
+	}
+
+	statuses := []string{BallotCounted, BallotExcluded, BallotExcluded, BallotExcluded, BallotExcluded}
+	for i, b := range stats.Ballots {
+		if b.Status != statuses[i] {
+			t.Errorf("[%d] exp %s, got %s (%s)", i, statuses[i], b.Status, b.Reason)
+		}
+		if b.Status == BallotExcluded && (b.Reason == "" || !b.Weight.IsZero()) {
+			t.Errorf("[%d] exp a reason and no weight, got %v", i, b)
+		}
+	}
+	if stats.Ballots[0].Weight.Cmp(dec(2)) != 0 {
+		t.Errorf("exp the weight of the voter applied, got %s", stats.Ballots[0].Weight)
+	}
+}
+
+// The ballots keep the options as given, quotes included, and are passed to
+// insert_results as a string like the option stats
+func TestBallotsInsertParams(t *testing.T) {
+	vote := MakeTestVote([]string{"yes", "no"}, 1, 1)
+	vote.SetType(VOTE_SINGLE)
+	vote.AddVote([]string{"it's'); DROP TABLE results; --"}, 1)
+
+	stats, err := ComputeResult(vote.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := InsertQueryParams(stats)
+	if !strings.Contains(params, `param_ballots := '[{`) || !strings.Contains(params, `it''s''); DROP TABLE results; --`) {
+		t.Errorf("exp the ballots as a quoted string, got %s", params)
+	}
+	if strings.Contains(params, "decode(") {
+		t.Errorf("exp no bytes, got %s", params)
+	}
+}
+
+func TestIRVBallotRounds(t *testing.T) {
+	vote := MakeTestVote([]string{"A", "B", "C"}, 1, 2)
+	vote.SetType(VOTE_IRV)
+	vote.AddVote([]string{"A"}, 1)
+	vote.AddVote([]string{"B"}, 1)
+	vote.AddVote([]string{"B"}, 1)
+	vote.AddVote([]string{"C", "B"}, 1)
+
+	stats, err := ComputeResult(vote.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExpectedWinners(stats, []string{"B"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A and C are eliminated in the first round
+	exp := [][]string{{"A", ""}, {"B", "B"}, {"B", "B"}, {"C", "B"}}
+	for i, b := range stats.Ballots {
+		if fmt.Sprint(b.Rounds) != fmt.Sprint(exp[i]) {
+			t.Errorf("[%d] exp rounds %q, got %q", i, exp[i], b.Rounds)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"database/sql"
 	"encoding/hex"
 	"encoding/json"
 	"strings"
@@ -433,6 +434,9 @@ func (v *VoteStats) InsertFunction() string {
 
 func (v *VoteStats) ScanRow(row SQLRowWithScan) (*VoteStats, error) {
 	var optJson, winJosn string
+	var ballotJson []byte // Null for results stored before ballots were
+	var version sql.NullInt64
+	var inputHash sql.NullString
 
 	err := row.Scan(
 		&v.VoteChain,
@@ -449,13 +453,20 @@ func (v *VoteStats) ScanRow(row SQLRowWithScan) (*VoteStats, error) {
 		&v.Support.WeightDenominator,
 		&optJson,
 		&winJosn,
+		&ballotJson,
+		&version,
+		&inputHash,
 	)
 	if err != nil {
 		return nil, err
 	}
+	v.AlgorithmVersion, v.InputHash = int(version.Int64), inputHash.String
 
 	json.Unmarshal([]byte(optJson), &v.OptionStats)
 	json.Unmarshal([]byte(winJosn), &v.WeightedWinners)
+	if ballotJson != nil {
+		json.Unmarshal(ballotJson, &v.Ballots)
+	}
 
 	return v, nil
 }
@@ -474,14 +485,19 @@ func (v VoteStats) SelectRows() string {
 			support_unweighted,
 			support_weighted,
 			option_stats,
-			winner_stats`
+			winner_stats,
+			ballots,
+			algorithm_version,
+			input_hash`
 }
 
 func (v *VoteStats) RowValuePointers() []interface{} {
 	optBytes, _ := json.Marshal(&v.OptionStats)
 	winBytes, _ := json.Marshal(&v.WeightedWinners)
 
-	optJson, winJson := string(optBytes), string(winBytes)
+	ballotBytes, _ := json.Marshal(&v.Ballots)
+
+	optJson, winJson, ballotJson := string(optBytes), string(winBytes), string(ballotBytes)
 
 	return []interface{}{
 		&v.VoteChain,
@@ -498,5 +514,8 @@ func (v *VoteStats) RowValuePointers() []interface{} {
 		&v.Support.WeightDenominator,
 		&optJson,
 		&winJson,
+		&ballotJson,
+		&v.AlgorithmVersion,
+		&v.InputHash,
 	}
 }
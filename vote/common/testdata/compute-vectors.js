@@ -0,0 +1,104 @@
+#!/usr/bin/env node
+// compute-vectors.js sets the expected results of the vectors in results/ to
+// what the javascript implementation computes for their inputs.
+//
+//	node vote/common/testdata/compute-vectors.js <factom-vote checkout>
+//
+// The checkout is https://github.com/PaulBernier/factom-vote, with its npm
+// dependencies installed. Each vector is given to computeResult in
+// src/read-vote/compute-vote-result.js, and its result is written in the
+// canonical json of CanonicalResultJSON in vectors.go. A vector the javascript
+// throws on gets the message of the error as expectedError.
+'use strict';
+
+const fs = require('fs');
+const path = require('path');
+
+if (process.argv.length !== 3) {
+    console.error('usage: node compute-vectors.js <factom-vote checkout>');
+    process.exit(2);
+}
+const reference = require(path.resolve(process.argv[2], 'src', 'read-vote', 'compute-vote-result.js'));
+const computeResult = reference.computeResult || reference;
+
+const dir = path.join(__dirname, 'results');
+
+// compute returns the result of the javascript implementation for the vector
+function compute(vector) {
+    const vote = {
+        definition: {
+            vote: {
+                type: vector.vote.type,
+                config: vector.vote.config
+            }
+        },
+        eligibleVoters: vector.eligibleVoters,
+        reveals: vector.reveals.map(r => ({ voterId: r.voterId, entryHash: r.entryHash, content: { vote: r.vote } }))
+    };
+    return computeResult(vote);
+}
+
+function pick(obj, keys) {
+    const out = {};
+    for (const k of keys) {
+        out[k] = obj[k];
+    }
+    return out;
+}
+
+const optionKeys = ['count', 'option', 'support', 'weight', 'weightedSupport'];
+const statsKeys = ['count', 'weight'];
+
+// canonical is the result with the fields and order of CanonicalResultJSON
+function canonical(result) {
+    const c = {
+        abstain: pick(result.abstain, statsKeys),
+        options: {},
+        support: pick(result.support, ['countDenominator', 'weightDenominator']),
+        total: pick(result.total, statsKeys),
+        turnout: pick(result.turnout, ['unweightedTurnout', 'weightedTurnout']),
+        valid: result.valid,
+        voted: pick(result.voted, statsKeys),
+        weightedWinners: (result.weightedWinners || [])
+            .map(o => pick(o, optionKeys))
+            .sort((a, b) => (a.option < b.option ? -1 : a.option > b.option ? 1 : 0))
+    };
+    if (result.invalidReason) {
+        c.invalidReason = result.invalidReason;
+    }
+    for (const name of Object.keys(result.options).sort()) {
+        c.options[name] = pick(result.options[name], optionKeys);
+    }
+    return sortKeys(c);
+}
+
+function sortKeys(value) {
+    if (Array.isArray(value)) {
+        return value.map(sortKeys);
+    }
+    if (value === null || typeof value !== 'object') {
+        return value;
+    }
+    const out = {};
+    for (const k of Object.keys(value).sort()) {
+        out[k] = sortKeys(value[k]);
+    }
+    return out;
+}
+
+for (const file of fs.readdirSync(dir).filter(f => f.endsWith('.json')).sort()) {
+    const vectors = JSON.parse(fs.readFileSync(path.join(dir, file), 'utf8'));
+    for (const vector of vectors) {
+        delete vector.expected;
+        delete vector.expectedError;
+        try {
+            vector.expected = canonical(compute(vector));
+        } catch (e) {
+            vector.expectedError = e.message;
+        }
+    }
+    // A vector per line, as the go test writes the inputs
+    const lines = vectors.map(v => JSON.stringify(v));
+    fs.writeFileSync(path.join(dir, file), '[\n' + lines.join(',\n') + '\n]\n');
+    console.log(`${file}: ${vectors.length} vectors`);
+}
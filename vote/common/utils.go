@@ -48,7 +48,12 @@ func RowValuesFromPointer(pointers []interface{}) []interface{} {
 		v := reflect.ValueOf(p).Elem()
 		ptype := reflect.TypeOf(p).String()
 		if ptype == "*string" {
-			vals[i] = fmt.Sprintf("'%v'", v)
+			// A quote in the string is doubled, so it stays in the literal
+			vals[i] = fmt.Sprintf("'%s'", strings.Replace(v.String(), "'", "''", -1))
+			continue
+		} else if d, ok := p.(*Decimal); ok {
+			// A numeric literal, exact
+			vals[i] = d.String()
 			continue
 		} else if ptype == "*time.Time" {
 			t := p.(*time.Time)
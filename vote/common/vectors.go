@@ -0,0 +1,203 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// ResultVector is a test case of the tally: the vote, its eligible voters and
+// reveals, and the expected result in canonical json, or the error computing
+// it. The expected results checked in were recorded from this tally, so they
+// catch changes to it, not differences from the javascript implementation.
+// testdata/compute-vectors.js replaces them with the javascript results.
+type ResultVector struct {
+	Title          string               `json:"title"`
+	Vote           ResultVectorVote     `json:"vote"`
+	EligibleVoters []ResultVectorVoter  `json:"eligibleVoters"`
+	Reveals        []ResultVectorReveal `json:"reveals"`
+	Expected       json.RawMessage      `json:"expected,omitempty"`
+	ExpectedError  string               `json:"expectedError,omitempty"`
+}
+
+// ResultVectorVote is the type and config of the vote, as in the proposal
+type ResultVectorVote struct {
+	Type   int             `json:"type"`
+	Config json.RawMessage `json:"config"`
+}
+
+// ResultVectorVoter weights are json numbers, exact as written
+type ResultVectorVoter struct {
+	VoterID string      `json:"voterId"`
+	Weight  json.Number `json:"weight"`
+}
+
+// ResultVectorReveal is a reveal, in the order the reveals are counted
+type ResultVectorReveal struct {
+	VoterID   string   `json:"voterId"`
+	EntryHash string   `json:"entryHash"`
+	Vote      []string `json:"vote"`
+}
+
+// Inputs are the vote, eligible voters and reveals to compute the result from
+func (rv *ResultVector) Inputs() (*Vote, []*EligibleVoter, []*VoteReveal, error) {
+	vote := NewVote()
+	vote.Proposal.ProposalChain = primitives.NewZeroHash()
+	vote.Proposal.Vote.VoteType = rv.Vote.Type
+	if err := json.Unmarshal(rv.Vote.Config, &vote.Proposal.Vote.Config); err != nil {
+		return nil, nil, nil, fmt.Errorf("config: %s", err.Error())
+	}
+
+	voters := make([]*EligibleVoter, len(rv.EligibleVoters))
+	for i, v := range rv.EligibleVoters {
+		id, err := primitives.HexToHash(v.VoterID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("eligible voter %d: %s", i, err.Error())
+		}
+		weight, err := ParseDecimal(v.Weight.String())
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("eligible voter %d: %s", i, err.Error())
+		}
+		voters[i] = &EligibleVoter{VoterID: *id.(*primitives.Hash), VoteWeight: weight}
+	}
+
+	reveals := make([]*VoteReveal, len(rv.Reveals))
+	for i, r := range rv.Reveals {
+		reveal := NewVoteReveal()
+		reveal.VoteChain = vote.Proposal.ProposalChain
+		var err error
+		if reveal.VoterID, err = primitives.HexToHash(r.VoterID); err != nil {
+			return nil, nil, nil, fmt.Errorf("reveal %d: %s", i, err.Error())
+		}
+		if reveal.EntryHash, err = primitives.HexToHash(r.EntryHash); err != nil {
+			return nil, nil, nil, fmt.Errorf("reveal %d: %s", i, err.Error())
+		}
+		reveal.Content.VoteOptions = r.Vote
+		reveals[i] = reveal
+	}
+	return vote, voters, reveals, nil
+}
+
+// Check computes the result of the vector and compares it byte for byte to
+// the expected
+func (rv *ResultVector) Check() error {
+	if len(rv.Expected) == 0 && rv.ExpectedError == "" {
+		return fmt.Errorf("no expected result, run testdata/compute-vectors.js")
+	}
+	result, resultErr, err := rv.compute()
+	if err != nil {
+		return err
+	}
+	if resultErr != rv.ExpectedError {
+		return fmt.Errorf("exp error '%s', got '%s'", rv.ExpectedError, resultErr)
+	}
+	// The expected result may be indented in the file
+	expected := new(bytes.Buffer)
+	if len(rv.Expected) > 0 {
+		if err := json.Compact(expected, rv.Expected); err != nil {
+			return fmt.Errorf("expected: %s", err.Error())
+		}
+	}
+	if !bytes.Equal(result, expected.Bytes()) {
+		return fmt.Errorf("exp %s, got %s", expected, result)
+	}
+	return nil
+}
+
+// compute returns the canonical result, or the error of the tally. The error
+// is only for vectors that cannot be read.
+func (rv *ResultVector) compute() (json.RawMessage, string, error) {
+	vote, voters, reveals, err := rv.Inputs()
+	if err != nil {
+		return nil, "", err
+	}
+	stats, err := ComputeResult(vote, voters, reveals)
+	if err != nil {
+		return nil, err.Error(), nil
+	}
+	result, err := CanonicalResultJSON(stats)
+	return result, "", err
+}
+
+// canonicalNumber is written as a javascript number, the nearest float
+type canonicalNumber Decimal
+
+func (n canonicalNumber) MarshalJSON() ([]byte, error) {
+	// encoding/json writes floats the same as javascript's Number.toString
+	return json.Marshal(Decimal(n).Float64())
+}
+
+type canonicalStats struct {
+	Count  canonicalNumber `json:"count"`
+	Weight canonicalNumber `json:"weight"`
+}
+
+type canonicalOption struct {
+	Count           canonicalNumber `json:"count"`
+	Option          string          `json:"option"`
+	Support         canonicalNumber `json:"support"`
+	Weight          canonicalNumber `json:"weight"`
+	WeightedSupport canonicalNumber `json:"weightedSupport"`
+}
+
+// canonicalResult fields are in the order of their names
+type canonicalResult struct {
+	Abstain       canonicalStats             `json:"abstain"`
+	InvalidReason string                     `json:"invalidReason,omitempty"`
+	Options       map[string]canonicalOption `json:"options"`
+	Support       struct {
+		CountDenominator  canonicalNumber `json:"countDenominator"`
+		WeightDenominator canonicalNumber `json:"weightDenominator"`
+	} `json:"support"`
+	Total   canonicalStats `json:"total"`
+	Turnout struct {
+		UnweightedTurnout canonicalNumber `json:"unweightedTurnout"`
+		WeightedTurnout   canonicalNumber `json:"weightedTurnout"`
+	} `json:"turnout"`
+	Valid           bool              `json:"valid"`
+	Voted           canonicalStats    `json:"voted"`
+	WeightedWinners []canonicalOption `json:"weightedWinners"`
+}
+
+func newCanonicalOption(o VoteOptionStats) canonicalOption {
+	return canonicalOption{
+		Count:           canonicalNumber(o.Count),
+		Option:          o.Option,
+		Support:         canonicalNumber(o.Support),
+		Weight:          canonicalNumber(o.Weight),
+		WeightedSupport: canonicalNumber(o.WeightedSupport),
+	}
+}
+
+// CanonicalResultJSON is the result in the shape the javascript
+// implementation computes, without what only this one adds (the chain, IRV
+// rounds, ballots, versions). Keys are sorted, numbers are written as
+// javascript writes them and winners are sorted by option, so the same
+// result is the same bytes in both.
+func CanonicalResultJSON(stats *VoteStats) (json.RawMessage, error) {
+	c := canonicalResult{
+		Abstain:         canonicalStats{canonicalNumber(stats.AbstainedStats.Count), canonicalNumber(stats.AbstainedStats.Weight)},
+		InvalidReason:   stats.InvalidReason,
+		Options:         make(map[string]canonicalOption),
+		Total:           canonicalStats{canonicalNumber(stats.CompleteStats.Count), canonicalNumber(stats.CompleteStats.Weight)},
+		Valid:           stats.Valid,
+		Voted:           canonicalStats{canonicalNumber(stats.VotedStats.Count), canonicalNumber(stats.VotedStats.Weight)},
+		WeightedWinners: []canonicalOption{},
+	}
+	c.Support.CountDenominator = canonicalNumber(stats.Support.CountDenominator)
+	c.Support.WeightDenominator = canonicalNumber(stats.Support.WeightDenominator)
+	c.Turnout.UnweightedTurnout = canonicalNumber(stats.Turnout.UnweightedTurnout)
+	c.Turnout.WeightedTurnout = canonicalNumber(stats.Turnout.WeightedTurnout)
+	for k, o := range stats.OptionStats {
+		c.Options[k] = newCanonicalOption(o)
+	}
+	for _, o := range stats.WeightedWinners {
+		c.WeightedWinners = append(c.WeightedWinners, newCanonicalOption(o))
+	}
+	sort.Slice(c.WeightedWinners, func(i, j int) bool { return c.WeightedWinners[i].Option < c.WeightedWinners[j].Option })
+
+	return marshalCanonical(c)
+}
@@ -0,0 +1,345 @@
+package common_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+// The inputs of the result vector corpus in testdata/results are generated
+// from the scenarios below, for every vote type, abstention,
+// computeResultsAgainst mode and criteria. After a change to the scenarios,
+// write the inputs with `go test -run TestResultVectorInputs -inputs`, then
+// set the expected results with testdata/compute-vectors.js.
+
+type vectorBallot struct {
+	Voter int // index of the eligible voter, past the end for one that is not eligible
+	Vote  []string
+}
+
+type vectorScenario struct {
+	Name    string
+	Weights []string
+	Ballots []vectorBallot
+}
+
+type vectorVoteType struct {
+	Name      string
+	Type      int
+	Options   []string
+	Min, Max  int
+	Scenarios []vectorScenario
+}
+
+type vectorCriteria struct {
+	Name       string
+	Acceptance map[string]interface{}
+	// Winner criteria can depend on the options of the vote
+	Winner func(options []string) map[string]interface{}
+}
+
+func minSupport(option, weighted, unweighted string) func([]string) map[string]interface{} {
+	return func(options []string) map[string]interface{} {
+		if option == "" {
+			option = options[0]
+		}
+		return map[string]interface{}{"minSupport": map[string]interface{}{option: criteriaWeights(weighted, unweighted)}}
+	}
+}
+
+func criteriaWeights(weighted, unweighted string) map[string]interface{} {
+	return map[string]interface{}{"weighted": json.Number(weighted), "unweighted": json.Number(unweighted)}
+}
+
+func b(voter int, vote ...string) vectorBallot {
+	return vectorBallot{voter, vote}
+}
+
+var vectorVoteTypes = []vectorVoteType{
+	{"binary", VOTE_BINARY, []string{"yes", "no"}, 1, 1, []vectorScenario{
+		{"majority", []string{"3", "2", "1", "1"}, []vectorBallot{b(0, "yes"), b(1, "no"), b(2, "yes"), b(3)}},
+		{"tie", []string{"1", "1", "1", "1"}, []vectorBallot{b(0, "yes"), b(1, "no"), b(2)}},
+		{"fractional weights", []string{"0.7", "0.1", "0.2", "0.25"}, []vectorBallot{b(0, "yes"), b(1, "yes"), b(2, "no")}},
+		{"invalid ballots", []string{"2", "1", "1"}, []vectorBallot{b(0, "yes"), b(0, "no"), b(1, "yes", "no"), b(3, "yes"), b(2, "maybe")}},
+		{"no reveals", []string{"1", "2"}, nil},
+	}},
+	{"single", VOTE_SINGLE, []string{"a", "b", "c"}, 1, 1, []vectorScenario{
+		{"plurality", []string{"1", "1", "1", "1", "1"}, []vectorBallot{b(0, "a"), b(1, "a"), b(2, "b"), b(3, "c"), b(4)}},
+		{"weight over count", []string{"5", "1", "1", "1"}, []vectorBallot{b(0, "a"), b(1, "b"), b(2, "b"), b(3, "b")}},
+		{"tie", []string{"2", "2", "1"}, []vectorBallot{b(0, "a"), b(1, "b"), b(2)}},
+		{"invalid ballots", []string{"1", "1", "1"}, []vectorBallot{b(0, "a"), b(1, "a", "b"), b(2, "d"), b(4, "a")}},
+	}},
+	{"multiple", VOTE_SINGLE, []string{"a", "b", "c", "d"}, 1, 2, []vectorScenario{
+		{"approvals", []string{"1", "2", "3", "1"}, []vectorBallot{b(0, "a", "b"), b(1, "b"), b(2, "b", "c"), b(3)}},
+		{"invalid ballots", []string{"1", "1", "1"}, []vectorBallot{b(0, "a", "b", "c"), b(1, "a"), b(2, "a", "a")}},
+		{"fractional weights", []string{"0.1", "0.2", "0.3", "0.4"}, []vectorBallot{b(0, "a", "b"), b(1, "a"), b(2, "b", "c"), b(3, "c")}},
+	}},
+	{"irv", VOTE_IRV, []string{"a", "b", "c", "d"}, 1, 4, []vectorScenario{
+		{"first round majority", []string{"1", "1", "1", "1", "1"}, []vectorBallot{b(0, "a", "b"), b(1, "a"), b(2, "a", "c"), b(3, "b"), b(4, "c")}},
+		{"runoff", []string{"1", "1", "1", "1", "1", "1", "1"}, []vectorBallot{b(0, "a", "b"), b(1, "a", "b"), b(2, "a"), b(3, "b", "a"), b(4, "b"), b(5, "c", "b"), b(6, "d", "c", "b")}},
+		{"exhausted ballots", []string{"1", "1", "1", "1", "1"}, []vectorBallot{b(0, "a"), b(1, "b"), b(2, "c"), b(3, "c", "d"), b(4, "d")}},
+		{"tied elimination", []string{"1", "1", "1", "1"}, []vectorBallot{b(0, "a"), b(1, "b"), b(2, "c", "a"), b(3, "d", "b")}},
+		{"invalid ballots", []string{"1", "1", "1"}, []vectorBallot{b(0, "a"), b(0, "b"), b(1, "e"), b(5, "a"), b(2)}},
+	}},
+}
+
+var vectorCriterias = []vectorCriteria{
+	{"no criteria", nil, nil},
+	{"min turnout", map[string]interface{}{"minTurnout": criteriaWeights("0.5", "0.5")}, nil},
+	{"high min turnout", map[string]interface{}{"minTurnout": criteriaWeights("0.9", "0.9")}, nil},
+	{"min support", nil, minSupport("*", "0.5", "0.5")},
+	// The first option of the vote
+	{"option min support", nil, minSupport("", "0.6", "0")},
+	{"min turnout and support", map[string]interface{}{"minTurnout": criteriaWeights("0.5", "0")}, minSupport("*", "0.4", "0.4")},
+}
+
+func vectorHash(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func makeResultVector(title string, voteType int, config map[string]interface{}, sc vectorScenario) (*ResultVector, error) {
+	rv := new(ResultVector)
+	rv.Title = title
+	rv.Vote.Type = voteType
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	rv.Vote.Config = data
+
+	rv.EligibleVoters = []ResultVectorVoter{}
+	for i, w := range sc.Weights {
+		rv.EligibleVoters = append(rv.EligibleVoters, ResultVectorVoter{vectorHash(fmt.Sprintf("%s/voter/%d", sc.Name, i)), json.Number(w)})
+	}
+	rv.Reveals = []ResultVectorReveal{}
+	for i, b := range sc.Ballots {
+		vote := b.Vote
+		if vote == nil {
+			vote = []string{}
+		}
+		rv.Reveals = append(rv.Reveals, ResultVectorReveal{
+			VoterID:   vectorHash(fmt.Sprintf("%s/voter/%d", sc.Name, b.Voter)),
+			EntryHash: vectorHash(fmt.Sprintf("%s/reveal/%d", title, i)),
+			Vote:      vote,
+		})
+	}
+	return rv, nil
+}
+
+// resultVectorCorpus is the corpus by file name
+func resultVectorCorpus() (map[string][]*ResultVector, error) {
+	corpus := make(map[string][]*ResultVector)
+	for _, vt := range vectorVoteTypes {
+		for _, sc := range vt.Scenarios {
+			for _, abstain := range []bool{false, true} {
+				for _, against := range []string{"ALL_ELIGIBLE_VOTERS", "PARTICIPANTS_ONLY"} {
+					for _, crit := range vectorCriterias {
+						config := map[string]interface{}{
+							"options":               vt.Options,
+							"allowAbstention":       abstain,
+							"computeResultsAgainst": against,
+							"minOptions":            vt.Min,
+							"maxOptions":            vt.Max,
+						}
+						if crit.Acceptance != nil {
+							config["acceptanceCriteria"] = crit.Acceptance
+						}
+						if crit.Winner != nil {
+							config["winnerCriteria"] = crit.Winner(vt.Options)
+						}
+
+						title := strings.Join([]string{vt.Name, sc.Name, fmt.Sprintf("abstention %t", abstain), strings.ToLower(against), crit.Name}, "/")
+						rv, err := makeResultVector(title, vt.Type, config, sc)
+						if err != nil {
+							return nil, fmt.Errorf("%s: %s", title, err.Error())
+						}
+						corpus[vt.Name+".json"] = append(corpus[vt.Name+".json"], rv)
+					}
+				}
+			}
+		}
+	}
+
+	// Configs the tally cannot compute, and IRV, which does not use
+	// computeResultsAgainst
+	sc := vectorVoteTypes[0].Scenarios[0]
+	for _, e := range []struct {
+		Title   string
+		Type    int
+		Against string
+	}{
+		{"errors/binary without computeResultsAgainst", VOTE_BINARY, ""},
+		{"errors/single with an unknown computeResultsAgainst", VOTE_SINGLE, "SOME_VOTERS"},
+		{"errors/irv without computeResultsAgainst is computed", VOTE_IRV, ""},
+		{"errors/unknown vote type", 9, "ALL_ELIGIBLE_VOTERS"},
+	} {
+		config := map[string]interface{}{
+			"options":               []string{"yes", "no"},
+			"allowAbstention":       false,
+			"computeResultsAgainst": e.Against,
+			"minOptions":            1,
+			"maxOptions":            1,
+		}
+		rv, err := makeResultVector(e.Title, e.Type, config, sc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", e.Title, err.Error())
+		}
+		corpus["errors.json"] = append(corpus["errors.json"], rv)
+	}
+	return corpus, nil
+}
+
+var writeInputs = flag.Bool("inputs", false, "write the inputs of the result vectors in testdata/results")
+
+// TestResultVectorInputs checks the inputs of the vectors in testdata/results
+// are the scenarios. With -inputs it writes them instead, keeping the
+// expected results of vectors with the same title and inputs.
+func TestResultVectorInputs(t *testing.T) {
+	corpus, err := resultVectorCorpus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join("testdata", "results")
+	for name, vectors := range corpus {
+		file := filepath.Join(dir, name)
+		existing := make(map[string]*ResultVector)
+		if data, err := ioutil.ReadFile(file); err == nil {
+			var read []*ResultVector
+			if err := json.Unmarshal(data, &read); err != nil {
+				t.Fatalf("%s: %s", file, err.Error())
+			}
+			for _, rv := range read {
+				existing[rv.Title] = rv
+			}
+		} else if !*writeInputs {
+			t.Errorf("%s: %s", file, err.Error())
+			continue
+		}
+
+		for _, rv := range vectors {
+			old, ok := existing[rv.Title]
+			same := ok && sameInputs(rv, old)
+			if same {
+				rv.Expected, rv.ExpectedError = old.Expected, old.ExpectedError
+			}
+			if !same && !*writeInputs {
+				t.Errorf("%s: inputs differ from the scenarios, write them with -inputs", rv.Title)
+			}
+		}
+		if len(existing) != len(vectors) && !*writeInputs {
+			t.Errorf("%s: exp %d vectors, got %d", file, len(vectors), len(existing))
+		}
+		if !*writeInputs {
+			continue
+		}
+		data, err := marshalResultVectors(vectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.MkdirAll(dir, 0755)
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// sameInputs compares the vectors without their expected results
+func sameInputs(a, b *ResultVector) bool {
+	ac, bc := *a, *b
+	ac.Expected, ac.ExpectedError = nil, ""
+	bc.Expected, bc.ExpectedError = nil, ""
+	ad, err := json.Marshal(ac)
+	if err != nil {
+		return false
+	}
+	bd, err := json.Marshal(bc)
+	return err == nil && bytes.Equal(ad, bd)
+}
+
+// marshalResultVectors writes a vector per line, so changes to the corpus
+// diff by vector
+func marshalResultVectors(vectors []*ResultVector) ([]byte, error) {
+	lines := make([]string, len(vectors))
+	for i, rv := range vectors {
+		data, err := json.Marshal(rv)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = string(data)
+	}
+	return []byte("[\n" + strings.Join(lines, ",\n") + "\n]\n"), nil
+}
+
+func TestCanonicalResultJSON(t *testing.T) {
+	stats := NewVoteStats()
+	stats.VoteChain = "not in the canonical result"
+	stats.CompleteStats = OptionStats{Count: NewDecimal(3), Weight: MustDecimal("1e21")}
+	stats.VotedStats = OptionStats{Count: NewDecimal(1), Weight: MustDecimal("0.0000001")}
+	stats.Turnout.UnweightedTurnout = NewDecimal(1).Quo(NewDecimal(3))
+	stats.OptionStats["<b>"] = VoteOptionStats{OptionStats: OptionStats{Option: "<b>", Count: NewDecimal(1), Weight: MustDecimal("0.1")}}
+	stats.OptionStats["a"] = VoteOptionStats{OptionStats: OptionStats{Option: "a"}}
+	stats.WeightedWinners = []VoteOptionStats{stats.OptionStats["a"], stats.OptionStats["<b>"]}
+	stats.Ballots = []Ballot{{VoterID: "not in the canonical result"}}
+
+	data, err := CanonicalResultJSON(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"abstain":{"count":0,"weight":0},` +
+		`"options":{"<b>":{"count":1,"option":"<b>","support":0,"weight":0.1,"weightedSupport":0},"a":{"count":0,"option":"a","support":0,"weight":0,"weightedSupport":0}},` +
+		`"support":{"countDenominator":0,"weightDenominator":0},"total":{"count":3,"weight":1e+21},` +
+		`"turnout":{"unweightedTurnout":0.3333333333333333,"weightedTurnout":0},"valid":false,"voted":{"count":1,"weight":1e-7},` +
+		`"weightedWinners":[{"count":1,"option":"<b>","support":0,"weight":0.1,"weightedSupport":0},{"count":0,"option":"a","support":0,"weight":0,"weightedSupport":0}]}`
+	if string(data) != exp {
+		t.Errorf("exp %s\ngot %s", exp, data)
+	}
+}
+
+func TestResultVectorCheck(t *testing.T) {
+	sc := vectorScenario{"check", []string{"1", "2"}, []vectorBallot{b(0, "yes"), b(1, "no")}}
+	config := map[string]interface{}{"options": []string{"yes", "no"}, "computeResultsAgainst": "ALL_ELIGIBLE_VOTERS", "minOptions": 1, "maxOptions": 1}
+	rv, err := makeResultVector("check", VOTE_BINARY, config, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rv.Check(); err == nil {
+		t.Error("exp a vector with no expected result to fail the check")
+	}
+	vote, voters, reveals, err := rv.Inputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := ComputeResult(vote, voters, reveals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rv.Expected, err = CanonicalResultJSON(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	// Indented in the file is the same result
+	var indented json.RawMessage
+	if indented, err = json.MarshalIndent(rv.Expected, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	rv.Expected = indented
+	if err := rv.Check(); err != nil {
+		t.Error(err)
+	}
+
+	rv.EligibleVoters[1].Weight = "3"
+	if err := rv.Check(); err == nil {
+		t.Error("exp a different weight to fail the check")
+	}
+}
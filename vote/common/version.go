@@ -0,0 +1,110 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// DaemonVersion is the release of scraperd and api-serverd
+const DaemonVersion = "v1.0.0"
+
+// ResultsAlgorithmVersion is stored with every result. Increase it with any
+// change to the tally that can change a result, so results computed before
+// can be found and recomputed. Results stored before versioning are version 0.
+//
+//	1: versioned results, with the ballot audit trail
+//	2: exact decimal weights and support
+//	3: IRV eliminates the options of lowest weight, it compared weights to a count
+const ResultsAlgorithmVersion = 3
+
+// EngineVersion is the daemon and the tally that computed a result, signed in
+// result attestations
+var EngineVersion = fmt.Sprintf("go-factom-vote/%s results/%d", DaemonVersion, ResultsAlgorithmVersion)
+
+type resultInputs struct {
+	VoteChain string        `json:"voteChain"`
+	VoteType  int           `json:"voteType"`
+	Config    interface{}   `json:"config"`
+	Voters    []voterInput  `json:"voters"`
+	Reveals   []revealInput `json:"reveals"`
+}
+
+type voterInput struct {
+	VoterID string  `json:"voterId"`
+	Weight  Decimal `json:"weight"`
+}
+
+type revealInput struct {
+	VoterID   string   `json:"voterId"`
+	EntryHash string   `json:"entryHash"`
+	Options   []string `json:"options"`
+}
+
+// ResultInputHash is the sha256 of everything the result is computed from.
+// The voters are sorted, their order does not matter to the tally. The
+// reveals are in the order given, as only the first valid reveal of a voter
+// counts.
+func ResultInputHash(vote *Vote, eligibleVoters []*EligibleVoter, reveals []*VoteReveal) string {
+	in := resultInputs{
+		VoteType: vote.Proposal.Vote.VoteType,
+		Config:   vote.Proposal.Vote.Config,
+		Voters:   make([]voterInput, len(eligibleVoters)),
+		Reveals:  make([]revealInput, len(reveals)),
+	}
+	if vote.Proposal.ProposalChain != nil {
+		in.VoteChain = vote.Proposal.ProposalChain.String()
+	}
+	for i, v := range eligibleVoters {
+		in.Voters[i] = voterInput{v.VoterID.String(), v.VoteWeight}
+	}
+	sort.Slice(in.Voters, func(i, j int) bool { return in.Voters[i].VoterID < in.Voters[j].VoterID })
+	for i, r := range reveals {
+		in.Reveals[i] = revealInput{VoterID: r.VoterID.String(), Options: r.Content.VoteOptions}
+		if r.EntryHash != nil {
+			in.Reveals[i].EntryHash = r.EntryHash.String()
+		}
+	}
+
+	data, _ := json.Marshal(in)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DiffResults returns the parts of the results that differ, by their json
+// names. The version and input hash are not compared. Results stored before
+// ballots were recorded have none, so their ballots are not compared either:
+// filling them in is not a change to the result.
+func DiffResults(a, b *VoteStats) []string {
+	parts := []struct {
+		Name string
+		A, B interface{}
+	}{
+		{"valid", a.Valid, b.Valid},
+		{"invalidReason", a.InvalidReason, b.InvalidReason},
+		{"total", a.CompleteStats, b.CompleteStats},
+		{"voted", a.VotedStats, b.VotedStats},
+		{"abstain", a.AbstainedStats, b.AbstainedStats},
+		{"options", a.OptionStats, b.OptionStats},
+		{"turnout", a.Turnout, b.Turnout},
+		{"support", a.Support, b.Support},
+		{"weightedWinners", a.WeightedWinners, b.WeightedWinners},
+		{"ballots", a.Ballots, b.Ballots},
+	}
+	if a.Ballots == nil {
+		parts = parts[:len(parts)-1]
+	}
+
+	diffs := []string{}
+	for _, p := range parts {
+		ja, _ := json.Marshal(p.A)
+		jb, _ := json.Marshal(p.B)
+		if !bytes.Equal(ja, jb) {
+			diffs = append(diffs, p.Name)
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,68 @@
+package common_test
+
+import (
+	"testing"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+)
+
+func TestResultInputHash(t *testing.T) {
+	vote := MakeTestVote([]string{"yes", "no"}, 1, 1)
+	vote.SetType(VOTE_SINGLE)
+	vote.AddVote([]string{"yes"}, 2)
+	vote.AddVote([]string{"no"}, 1)
+
+	stats, err := ComputeResult(vote.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.AlgorithmVersion != ResultsAlgorithmVersion || len(stats.InputHash) != 64 {
+		t.Errorf("exp the version and hash set, got %d %q", stats.AlgorithmVersion, stats.InputHash)
+	}
+
+	// The order of the voters does not matter, the order of the reveals does
+	v, voters, reveals := vote.Params()
+	swapped := []*EligibleVoter{voters[1], voters[0]}
+	if ResultInputHash(v, swapped, reveals) != stats.InputHash {
+		t.Error("exp the same hash with the voters in another order")
+	}
+	if ResultInputHash(v, voters, []*VoteReveal{reveals[1], reveals[0]}) == stats.InputHash {
+		t.Error("exp another hash with the reveals in another order")
+	}
+
+	v.Proposal.Vote.Config.ComputeResultsAgainst = "PARTICIPANTS_ONLY"
+	if ResultInputHash(v, voters, reveals) == stats.InputHash {
+		t.Error("exp another hash with another config")
+	}
+}
+
+func TestDiffResults(t *testing.T) {
+	vote := MakeTestVote([]string{"yes", "no"}, 1, 1)
+	vote.SetType(VOTE_SINGLE)
+	vote.AddVote([]string{"yes"}, 2)
+
+	a, err := ComputeResult(vote.Params())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ComputeResult(vote.Params())
+	if diffs := DiffResults(a, b); len(diffs) != 0 {
+		t.Errorf("exp no differences, got %v", diffs)
+	}
+
+	b.Valid = !b.Valid
+	b.AlgorithmVersion = 0
+	if diffs := DiffResults(a, b); len(diffs) != 1 || diffs[0] != "valid" {
+		t.Errorf("exp valid to differ, got %v", diffs)
+	}
+
+	// Results stored before ballots were recorded have none
+	b.Valid = a.Valid
+	b.Ballots = nil
+	if diffs := DiffResults(b, a); len(diffs) != 0 {
+		t.Errorf("exp ballots filled in not to differ, got %v", diffs)
+	}
+	if diffs := DiffResults(a, b); len(diffs) != 1 || diffs[0] != "ballots" {
+		t.Errorf("exp ballots dropped to differ, got %v", diffs)
+	}
+}
@@ -1,6 +1,8 @@
 package vote
 
 import (
+	"context"
+
 	log "github.com/sirupsen/logrus"
 
 	"fmt"
@@ -15,17 +17,15 @@ var _ = log.Error
 
 var IdentityRegisterChain, _ = primitives.HexToHash("888888001750ede0eff4b05f0c3f557890b256450cabbb84cada937f9c258327")
 
-// Controller can search the blockchain for an vote,
-// and feed entries into the parses to come up with the state of all votes.
+// Controller can search the blockchain for a vote, and recount it in memory
+// with no database.
 type Controller struct {
 	Reader factom_raw.Fetcher
-	Parser *VoteWatcher
 }
 
 func NewAPIController(apiLocation string) *Controller {
 	f := new(Controller)
 	f.Reader = factom_raw.NewAPIReader(apiLocation)
-	f.Parser = NewVoteWatcher()
 
 	return f
 }
@@ -36,50 +36,53 @@ func (a *Controller) IsWorking() bool {
 	return err == nil
 }
 
-func (c *Controller) FindVote(votechain interfaces.IHash) (*Vote, error) {
-
-	err := c.parseVoteChain(votechain)
+// FindVote recounts the vote, and returns it with the commits and reveals
+// that count
+func (c *Controller) FindVote(ctx context.Context, votechain interfaces.IHash) (*Vote, error) {
+	_, r, err := c.RecountVote(ctx, votechain)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.Parser.VoteProposals[votechain.Fixed()], nil
-}
-
-func (c *Controller) parseVoteChain(votechain interfaces.IHash) error {
-	entry, err := c.FetchFirstEntry(votechain)
-	if err != nil {
-		return fmt.Errorf("fetch first entry: %s", err.Error())
+	v := NewVote()
+	v.Proposal = r.Vote.Proposal
+	for _, c := range r.Commits() {
+		v.Commits[c.VoterID.Fixed()] = *c
 	}
-
-	prop, err := NewProposalEntry(entry.Entry, 0)
-	if err != nil {
-		return fmt.Errorf("parsing prop: %s", err.Error())
-	}
-
-	// Parse the voters
-	voterEntries, err := c.FetchChainEntriesInCreateOrder(&prop.Vote.EligibleVotersChainID)
-	if err != nil {
-		return err
+	for _, rev := range r.Reveals() {
+		if _, ok := v.Reveals[rev.VoterID.Fixed()]; !ok {
+			v.Reveals[rev.VoterID.Fixed()] = *rev
+		}
 	}
+	return v, nil
+}
 
-	err = c.Parser.ParseEntryList(voterEntries)
+// FindRegisteredVotes returns the vote chains registered in the registration
+// chain, in the order they were registered
+func (c *Controller) FindRegisteredVotes(ctx context.Context) ([]interfaces.IHash, error) {
+	chain, err := primitives.HexToHash(REGISTRATION_CHAIN)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Parse the vote
-	voteEntries, err := c.FetchChainEntriesInCreateOrder(votechain)
+	entries, err := c.FetchChainEntriesInCreateOrder(chain)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("fetch registration chain: %s", err.Error())
 	}
 
-	err = c.Parser.ParseEntryList(voteEntries)
-	if err != nil {
-		return err
+	var votes []interfaces.IHash
+	seen := make(map[[32]byte]bool)
+	for _, e := range entries {
+		ids := e.Entry.ExternalIDs()
+		if len(ids) != 2 || string(ids[0]) != EXT0_REGISTER_VOTE || len(ids[1]) != 32 {
+			continue
+		}
+		votechain := primitives.NewHash(ids[1])
+		if !seen[votechain.Fixed()] {
+			seen[votechain.Fixed()] = true
+			votes = append(votes, votechain)
+		}
 	}
-
-	return nil
+	return votes, ctx.Err()
 }
 
 func (c *Controller) FetchFirstEntry(chain interfaces.IHash) (ParsingEntry, error) {
@@ -115,7 +118,7 @@ func (c *Controller) FetchFirstEntry(chain interfaces.IHash) (ParsingEntry, erro
 	if err != nil {
 		return e, err
 	}
-	return ParsingEntry{entry, dblock.GetTimestamp().GetTime(), block.GetDatabaseHeight()}, nil
+	return ParsingEntry{entry, dblock.GetTimestamp().GetTime(), block.GetDatabaseHeight(), dblock.GetKeyMR(), block, dblock}, nil
 }
 
 // FetchChainEntriesInCreateOrder will retrieve all entries in a chain in created order
@@ -155,6 +158,7 @@ func (c *Controller) FetchChainEntriesInCreateOrder(chain interfaces.IHash) ([]P
 			return nil, err
 		}
 		ts := dblock.GetTimestamp().GetTime()
+		keyMR := dblock.GetKeyMR()
 
 		ehashes := eb.GetEntryHashes()
 		for _, e := range ehashes {
@@ -166,7 +170,7 @@ func (c *Controller) FetchChainEntriesInCreateOrder(chain interfaces.IHash) ([]P
 				return nil, err
 			}
 
-			entries = append(entries, ParsingEntry{entry, ts, height})
+			entries = append(entries, ParsingEntry{entry, ts, height, keyMR, eb, dblock})
 		}
 	}
 
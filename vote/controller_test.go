@@ -0,0 +1,158 @@
+package vote_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/Emyrk/go-factom-vote/vote"
+	"github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// The fakes are the parts of the factomd blocks the controller reads, the
+// rest of each interface is left nil.
+
+type fakeEntry struct {
+	interfaces.IEBEntry
+	extIDs [][]byte
+}
+
+func (e *fakeEntry) ExternalIDs() [][]byte { return e.extIDs }
+
+type fakeEBlockHeader struct {
+	interfaces.IEntryBlockHeader
+	prev interfaces.IHash
+}
+
+func (h *fakeEBlockHeader) GetPrevKeyMR() interfaces.IHash { return h.prev }
+
+type fakeEBlock struct {
+	interfaces.IEntryBlock
+	prev    interfaces.IHash
+	height  uint32
+	entries []interfaces.IHash
+}
+
+func (b *fakeEBlock) GetHeader() interfaces.IEntryBlockHeader { return &fakeEBlockHeader{prev: b.prev} }
+func (b *fakeEBlock) GetDatabaseHeight() uint32               { return b.height }
+func (b *fakeEBlock) GetEntryHashes() []interfaces.IHash      { return b.entries }
+
+type fakeTimestamp struct {
+	interfaces.Timestamp
+}
+
+func (fakeTimestamp) GetTime() time.Time { return time.Unix(0, 0) }
+
+type fakeDBlock struct {
+	interfaces.IDirectoryBlock
+}
+
+func (fakeDBlock) GetTimestamp() interfaces.Timestamp { return fakeTimestamp{} }
+func (fakeDBlock) GetKeyMR() interfaces.IHash         { return primitives.NewZeroHash() }
+
+// fakeChain is a single chain of entry blocks, each a list of entries
+type fakeChain struct {
+	chain   interfaces.IHash
+	head    interfaces.IHash
+	eblocks map[[32]byte]*fakeEBlock
+	entries map[[32]byte]*fakeEntry
+}
+
+func newFakeChain(chain interfaces.IHash, blocks ...[]*fakeEntry) *fakeChain {
+	f := &fakeChain{chain: chain, head: primitives.NewZeroHash(),
+		eblocks: make(map[[32]byte]*fakeEBlock), entries: make(map[[32]byte]*fakeEntry)}
+	for i, entries := range blocks {
+		eb := &fakeEBlock{prev: f.head, height: uint32(100 + i)}
+		for j, e := range entries {
+			hash := primitives.Sha([]byte(fmt.Sprintf("entry %d %d", i, j)))
+			f.entries[hash.Fixed()] = e
+			eb.entries = append(eb.entries, hash)
+		}
+		f.head = primitives.Sha([]byte(fmt.Sprintf("eblock %d", i)))
+		f.eblocks[f.head.Fixed()] = eb
+	}
+	return f
+}
+
+func (f *fakeChain) FetchDBlockHead() (interfaces.IDirectoryBlock, error) {
+	return fakeDBlock{}, nil
+}
+
+func (f *fakeChain) FetchHeadIndexByChainID(chain interfaces.IHash) (interfaces.IHash, error) {
+	if !chain.IsSameAs(f.chain) {
+		return nil, fmt.Errorf("no chain %s", chain.String())
+	}
+	return f.head, nil
+}
+
+func (f *fakeChain) FetchEBlock(hash interfaces.IHash) (interfaces.IEntryBlock, error) {
+	if eb, ok := f.eblocks[hash.Fixed()]; ok {
+		return eb, nil
+	}
+	return nil, fmt.Errorf("no eblock %s", hash.String())
+}
+
+func (f *fakeChain) FetchEntry(hash interfaces.IHash) (interfaces.IEBEntry, error) {
+	if e, ok := f.entries[hash.Fixed()]; ok {
+		return e, nil
+	}
+	return nil, fmt.Errorf("no entry %s", hash.String())
+}
+
+func (f *fakeChain) FetchDBlockByHeight(height uint32) (interfaces.IDirectoryBlock, error) {
+	return fakeDBlock{}, nil
+}
+
+func registration(extIDs ...[]byte) *fakeEntry {
+	return &fakeEntry{extIDs: extIDs}
+}
+
+func TestFindRegisteredVotes(t *testing.T) {
+	vote := func(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+	register := []byte(common.EXT0_REGISTER_VOTE)
+
+	chain, _ := primitives.HexToHash(common.REGISTRATION_CHAIN)
+	c := new(Controller)
+	c.Reader = newFakeChain(chain,
+		[]*fakeEntry{
+			registration([]byte(common.EXT0_VOTE_REGISTRATION_CHAIN)),
+			registration(register, vote(2)),
+			// Not a registration
+			registration([]byte("Register Something"), vote(7)),
+			registration(register, vote(1)),
+		},
+		[]*fakeEntry{
+			// Registered again, which keeps the first place
+			registration(register, vote(2)),
+			// Not a chain id
+			registration(register, vote(8)[:31]),
+			registration(register, vote(9), []byte("extra")),
+			registration(register),
+			registration(register, vote(3)),
+		},
+	)
+
+	votes, err := c.FindRegisteredVotes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]byte{vote(2), vote(1), vote(3)}
+	if len(votes) != len(exp) {
+		t.Fatalf("exp %d votes, got %v", len(exp), votes)
+	}
+	for i, v := range votes {
+		if !bytes.Equal(v.Bytes(), exp[i]) {
+			t.Errorf("%d: exp vote %x, got %s", i, exp[i], v.String())
+		}
+	}
+
+	// The registration chain cannot be read
+	c.Reader = newFakeChain(primitives.NewZeroHash())
+	if _, err := c.FindRegisteredVotes(context.Background()); err == nil {
+		t.Errorf("exp an error without the registration chain")
+	}
+}
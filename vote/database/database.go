@@ -34,6 +34,16 @@ type SqlConfig struct {
 	Host          string
 	Port          int
 	Schema        SCHEMA //the schema to use
+
+	// Connection pool sizes, MAX_OPEN_CON and MAX_IDLE_CON are used if 0
+	MaxOpenConns int
+	MaxIdleConns int
+}
+
+// ConnectionString is the lib/pq connection string for the config
+func (c SqlConfig) ConnectionString() string {
+	return fmt.Sprintf("user=%s password='%s' host=%s port=%d sslmode=disable",
+		c.User, c.Pass, c.Host, c.Port)
 }
 
 type SQLDatabase struct {
@@ -41,12 +51,13 @@ type SQLDatabase struct {
 }
 
 func InitLocalDB() (*SQLDatabase, error) {
-	return InitDb(SqlConfig{SQL_CON_LOCAL,
-		"postgres",
-		"password",
-		"localhost",
-		5432,
-		SCHEMA_PUBLIC})
+	return InitDb(SqlConfig{
+		SqlConfigType: SQL_CON_LOCAL,
+		User:          "postgres",
+		Pass:          "password",
+		Host:          "localhost",
+		Port:          5432,
+		Schema:        SCHEMA_PUBLIC})
 }
 
 //first param is the sql connection type, SQL_CON_LOCAL...etc.
@@ -65,8 +76,7 @@ func InitDb(sqlConfig SqlConfig) (*SQLDatabase, error) {
 	case SQL_CON_LOCAL == sqlConfig.SqlConfigType:
 		flog.Info("Creating db connection local.")
 		fmt.Printf("%s@/%s\n", sqlConfig.User, sqlConfig.Schema)
-		connStr := fmt.Sprintf("user=%s password='%s' host=%s port=%d sslmode=disable",
-			sqlConfig.User, sqlConfig.Pass, sqlConfig.Host, sqlConfig.Port)
+		connStr := sqlConfig.ConnectionString()
 		db, err = sql.Open("postgres", connStr)
 		if err != nil {
 			return nil, fmt.Errorf("Error connecting to local db: %s", err.Error())
@@ -78,15 +88,21 @@ func InitDb(sqlConfig SqlConfig) (*SQLDatabase, error) {
 	case SQL_CON_CUSTOM == sqlConfig.SqlConfigType:
 		flog.Infof("Creating db connection Custom. %s:%d", sqlConfig.Host, sqlConfig.Port)
 		fmt.Printf("%s@/%s\n", sqlConfig.User, sqlConfig.Schema)
-		connStr := fmt.Sprintf("user=%s password='%s' host=%s port=%d sslmode=disable",
-			sqlConfig.User, sqlConfig.Pass, sqlConfig.Host, sqlConfig.Port)
+		connStr := sqlConfig.ConnectionString()
 		db, err = sql.Open("postgres", connStr)
 		if err != nil {
 			return nil, fmt.Errorf("Error connecting to local db: %s", err.Error())
 		}
 	}
-	db.SetMaxIdleConns(MAX_IDLE_CON)
-	db.SetMaxOpenConns(MAX_OPEN_CON)
+	maxIdle, maxOpen := sqlConfig.MaxIdleConns, sqlConfig.MaxOpenConns
+	if maxIdle == 0 {
+		maxIdle = MAX_IDLE_CON
+	}
+	if maxOpen == 0 {
+		maxOpen = MAX_OPEN_CON
+	}
+	db.SetMaxIdleConns(maxIdle)
+	db.SetMaxOpenConns(maxOpen)
 
 	if err = db.Ping(); err != nil {
 		return nil, fmt.Errorf("Error testing initial connection: %s", err.Error())
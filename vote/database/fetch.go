@@ -1,29 +1,75 @@
 package database
 
 import (
+	"context"
+
 	"database/sql"
 
 	"fmt"
+	"time"
 
 	"github.com/Emyrk/go-factom-vote/vote/common"
 )
 
-func (s *SQLDatabase) FetchHighestDBInserted() int {
+func (s *SQLDatabase) FetchHighestDBInserted(ctx context.Context) int {
+	defer observe("fetch_highest_inserted", time.Now())
 	highest := -1
-	row := s.QueryRow(`SELECT MAX(block_height) FROM completed`)
+	row := s.QueryRowContext(ctx, `SELECT MAX(block_height) FROM completed`)
 	row.Scan(&highest)
 	return highest // Highest will be -1 in the case of no rows found, which is fine
 }
 
-func (s *SQLDatabase) IsRepeatedEntryExists(hash string) (bool, error) {
+// FetchLastCompleted returns the highest block applied to the database, and
+// when it was applied. The height is -1 if no blocks have been applied.
+func (s *SQLDatabase) FetchLastCompleted(ctx context.Context) (int, time.Time, error) {
+	defer observe("fetch_last_completed", time.Now())
+	var at time.Time
+	row := s.QueryRowContext(ctx, `SELECT block_height, completed_at FROM completed ORDER BY block_height DESC LIMIT 1`)
+	highest := -1
+	err := row.Scan(&highest, &at)
+	if err == sql.ErrNoRows {
+		return -1, at, nil
+	}
+	return highest, at, err
+}
+
+// FetchPhaseChanges returns the votes that move into a new phase once height
+// is applied, mapped to the phase they enter: 'commit', 'reveal' or 'complete'.
+func (s *SQLDatabase) FetchPhaseChanges(ctx context.Context, height int) (map[string]string, error) {
+	defer observe("fetch_phase_changes", time.Now())
+	query := `SELECT chain_id,
+		CASE WHEN reveal_stop = $1 THEN 'complete'
+			WHEN reveal_start = $1 THEN 'reveal'
+			ELSE 'commit' END
+		FROM proposals WHERE $1 IN (commit_start, reveal_start, reveal_stop)`
+	rows, err := s.DB.QueryContext(ctx, query, height)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make(map[string]string)
+	for rows.Next() {
+		var chain, phase string
+		if err := rows.Scan(&chain, &phase); err != nil {
+			return nil, err
+		}
+		changes[chain] = phase
+	}
+	return changes, rows.Err()
+}
+
+func (s *SQLDatabase) IsRepeatedEntryExists(ctx context.Context, hash string) (bool, error) {
+	defer observe("is_repeated_entry_exists", time.Now())
 	query := `SELECT repeat_hash FROM eligible_submitted WHERE repeat_hash = $1`
-	return exists(s.DB.Query(query, hash))
+	return exists(s.DB.QueryContext(ctx, query, hash))
 }
 
-func (s *SQLDatabase) IsVoteExist(voteId string) (bool, error) {
+func (s *SQLDatabase) IsVoteExist(ctx context.Context, voteId string) (bool, error) {
+	defer observe("is_vote_exist", time.Now())
 	var c string
 	query := `SELECT chain_id FROM proposals WHERE chain_id = $1`
-	row := s.DB.QueryRow(query, voteId)
+	row := s.DB.QueryRowContext(ctx, query, voteId)
 	if err := row.Scan(&c); err != nil {
 		return false, nil
 	}
@@ -31,15 +77,17 @@ func (s *SQLDatabase) IsVoteExist(voteId string) (bool, error) {
 	//return exists(s.DB.QueryRow(query, voteId))
 }
 
-func (s *SQLDatabase) IsEligibleListExist(chainId string) (bool, error) {
+func (s *SQLDatabase) IsEligibleListExist(ctx context.Context, chainId string) (bool, error) {
+	defer observe("is_eligible_list_exist", time.Now())
 	query := `SELECT chain_id FROM eligible_list WHERE chain_id = $1`
-	return exists(s.DB.Query(query, chainId))
+	return exists(s.DB.QueryContext(ctx, query, chainId))
 }
 
-func (s *SQLDatabase) IsEligibleListExistWithKey(chainId string) (bool, string, error) {
+func (s *SQLDatabase) IsEligibleListExistWithKey(ctx context.Context, chainId string) (bool, string, error) {
+	defer observe("is_eligible_list_exist_with_key", time.Now())
 	var chain, key string
 	query := `SELECT chain_id, initiator_key FROM eligible_list WHERE chain_id = $1`
-	row := s.DB.QueryRow(query, chainId)
+	row := s.DB.QueryRowContext(ctx, query, chainId)
 	err := row.Scan(&chain, &key)
 	if err != nil {
 		return false, "", err
@@ -70,12 +118,13 @@ type PartialCommit struct {
 	VoteChain  string
 }
 
-func (s *SQLDatabase) FetchCommitForReveal(reveal common.VoteReveal) (*PartialCommit, error) {
+func (s *SQLDatabase) FetchCommitForReveal(ctx context.Context, reveal common.VoteReveal) (*PartialCommit, error) {
+	defer observe("fetch_commit_for_reveal", time.Now())
 	pc := new(PartialCommit)
 
 	query := `SELECT voter_id, signing_key, commitment, vote_chain FROM commits WHERE 
 				voter_id = $1 AND vote_chain = $2`
-	row := s.DB.QueryRow(query, reveal.VoterID.String(), reveal.VoteChain.String())
+	row := s.DB.QueryRowContext(ctx, query, reveal.VoterID.String(), reveal.VoteChain.String())
 	err := row.Scan(&pc.VoterID, &pc.SigningKey, &pc.Commitment, &pc.VoteChain)
 	if err != nil {
 		return nil, err
@@ -84,12 +133,13 @@ func (s *SQLDatabase) FetchCommitForReveal(reveal common.VoteReveal) (*PartialCo
 	return pc, nil
 }
 
-func (s *SQLDatabase) FetchVote(chainid string) (*common.Vote, error) {
+func (s *SQLDatabase) FetchVote(ctx context.Context, chainid string) (*common.Vote, error) {
+	defer observe("fetch_vote", time.Now())
 	v := new(common.Vote)
 	var err error
 
 	query := fmt.Sprintf("SELECT %s FROM %s WHERE chain_id = $1", v.SelectRows(), v.Table())
-	row := s.DB.QueryRow(query, chainid)
+	row := s.DB.QueryRowContext(ctx, query, chainid)
 	v, err = v.ScanRow(row)
 	if err != nil {
 		return nil, err
@@ -98,13 +148,14 @@ func (s *SQLDatabase) FetchVote(chainid string) (*common.Vote, error) {
 	return v, nil
 }
 
-func (s *SQLDatabase) FetchCompleteVotes(height int) ([]*common.Vote, error) {
+func (s *SQLDatabase) FetchCompleteVotes(ctx context.Context, height int) ([]*common.Vote, error) {
+	defer observe("fetch_complete_votes", time.Now())
 	v := new(common.Vote)
 	var err error
 	var votes []*common.Vote
 
 	query := fmt.Sprintf("SELECT %s FROM %s WHERE reveal_stop = $1", v.SelectRows(), v.Table())
-	rows, err := s.DB.Query(query, height)
+	rows, err := s.DB.QueryContext(ctx, query, height)
 	if err != nil {
 		return nil, err
 	}
@@ -123,7 +174,40 @@ func (s *SQLDatabase) FetchCompleteVotes(height int) ([]*common.Vote, error) {
 	return votes, nil
 }
 
-func (s *SQLDatabase) FetchEligibleVoters(chainid string, block_height int) ([]*common.EligibleVoter, error) {
+// FetchResultVotes returns the votes that have stored results, in the order
+// they completed
+func (s *SQLDatabase) FetchResultVotes(ctx context.Context) ([]*common.Vote, error) {
+	defer observe("fetch_result_votes", time.Now())
+	v := new(common.Vote)
+	var votes []*common.Vote
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE chain_id IN (SELECT vote_chain FROM results) ORDER BY reveal_stop, chain_id", v.SelectRows(), v.Table())
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tmp := new(common.Vote)
+		if _, err := tmp.ScanRow(rows); err != nil {
+			return nil, err
+		}
+		votes = append(votes, tmp)
+	}
+	return votes, rows.Err()
+}
+
+// FetchResults returns the stored results of the vote
+func (s *SQLDatabase) FetchResults(ctx context.Context, chainid string) (*common.VoteStats, error) {
+	defer observe("fetch_results", time.Now())
+	v := common.NewVoteStats()
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE vote_chain = $1", v.SelectRows(), v.Table())
+	return v.ScanRow(s.DB.QueryRowContext(ctx, query, chainid))
+}
+
+func (s *SQLDatabase) FetchEligibleVoters(ctx context.Context, chainid string, block_height int) ([]*common.EligibleVoter, error) {
+	defer observe("fetch_eligible_voters", time.Now())
 	var err error
 
 	//query := fmt.Sprintf(`
@@ -136,7 +220,7 @@ func (s *SQLDatabase) FetchEligibleVoters(chainid string, block_height int) ([]*
 		SELECT voter_id, eligible_list, weight, entry_hash, block_height, signing_keys 
 		FROM fetch_eligible_voters($1, $2)`)
 	//query := fmt.Sprintf("SELECT %s FROM %s WHERE eligible_list = $1", v.SelectRows(), v.Table())
-	rows, err := s.DB.Query(query, chainid, block_height)
+	rows, err := s.DB.QueryContext(ctx, query, chainid, block_height)
 	if err != nil {
 		return nil, err
 	}
@@ -154,12 +238,13 @@ func (s *SQLDatabase) FetchEligibleVoters(chainid string, block_height int) ([]*
 	return arr, nil
 }
 
-func (s *SQLDatabase) FetchCommits(chainid string) ([]*common.VoteCommit, error) {
+func (s *SQLDatabase) FetchCommits(ctx context.Context, chainid string) ([]*common.VoteCommit, error) {
+	defer observe("fetch_commits", time.Now())
 	v := new(common.VoteCommit)
 	var err error
 
 	query := fmt.Sprintf("SELECT %s FROM %s WHERE vote_chain = $1", v.SelectRows(), v.Table())
-	rows, err := s.DB.Query(query, chainid)
+	rows, err := s.DB.QueryContext(ctx, query, chainid)
 	if err != nil {
 		return nil, err
 	}
@@ -177,12 +262,15 @@ func (s *SQLDatabase) FetchCommits(chainid string) ([]*common.VoteCommit, error)
 	return arr, nil
 }
 
-func (s *SQLDatabase) FetchReveals(chainid string) ([]*common.VoteReveal, error) {
+func (s *SQLDatabase) FetchReveals(ctx context.Context, chainid string) ([]*common.VoteReveal, error) {
+	defer observe("fetch_reveals", time.Now())
 	v := new(common.VoteReveal)
 	var err error
 
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE vote_chain = $1", v.SelectRows(), v.Table())
-	rows, err := s.DB.Query(query, chainid)
+	// Only the first valid reveal of a voter counts, so the order has to be
+	// the same every time the results are computed
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE vote_chain = $1 ORDER BY block_height, entry_hash", v.SelectRows(), v.Table())
+	rows, err := s.DB.QueryContext(ctx, query, chainid)
 	if err != nil {
 		return nil, err
 	}
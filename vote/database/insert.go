@@ -1,49 +1,121 @@
 package database
 
 import (
+	"context"
+
 	"fmt"
+	"time"
 
 	"database/sql"
 
 	"encoding/hex"
+	"encoding/json"
 
 	"github.com/Emyrk/go-factom-vote/vote/common"
 )
 
-func (db *SQLDatabase) InsertGenericTX(o common.ISQLObject, tx *sql.Tx) error {
+func (db *SQLDatabase) InsertGenericTX(ctx context.Context, o common.ISQLObject, tx *sql.Tx) error {
+	defer observe(o.InsertFunction(), time.Now())
 	query := fmt.Sprintf(`SELECT %s(%s)`, o.InsertFunction(), common.InsertQueryParams(o))
-	_, err := tx.Exec(query)
+	_, err := tx.ExecContext(ctx, query)
 	return err
 }
 
-func (db *SQLDatabase) InsertAndQueryGeneric(o common.ISQLObject) (int, error) {
+func (db *SQLDatabase) InsertAndQueryGeneric(ctx context.Context, o common.ISQLObject) (int, error) {
+	defer observe(o.InsertFunction(), time.Now())
 	query := fmt.Sprintf(`SELECT %s(%s)`, o.InsertFunction(), common.InsertQueryParams(o))
-	row := db.DB.QueryRow(query)
+	row := db.DB.QueryRowContext(ctx, query)
 	var i int
 	err := row.Scan(&i)
 	return i, err
 }
 
-func (db *SQLDatabase) InsertGeneric(o common.ISQLObject) error {
+func (db *SQLDatabase) InsertGeneric(ctx context.Context, o common.ISQLObject) error {
+	defer observe(o.InsertFunction(), time.Now())
 	query := fmt.Sprintf(`SELECT %s(%s)`, o.InsertFunction(), common.InsertQueryParams(o))
-	_, err := db.DB.Exec(query)
+	_, err := db.DB.ExecContext(ctx, query)
 	return err
 }
 
-func (db *SQLDatabase) SetRegistered(vote string, registered bool) error {
+func (db *SQLDatabase) SetRegistered(ctx context.Context, vote string, registered bool) error {
+	defer observe("set_registered", time.Now())
 	query := `UPDATE proposals SET registered = $2 WHERE chain_id = $1;`
-	_, err := db.DB.Exec(query, vote, registered)
+	_, err := db.DB.ExecContext(ctx, query, vote, registered)
 	return err
 }
 
-func (db *SQLDatabase) InsertSubmittedHash(hash [32]byte, tx *sql.Tx) error {
+func (db *SQLDatabase) InsertSubmittedHash(ctx context.Context, hash [32]byte, tx *sql.Tx) error {
+	defer observe("insert_submitted_hash", time.Now())
 	query := `INSERT INTO eligible_submitted(repeat_hash) VALUES ($1)`
-	_, err := tx.Exec(query, hex.EncodeToString(hash[:]))
+	_, err := tx.ExecContext(ctx, query, hex.EncodeToString(hash[:]))
 	return err
 }
 
-func (db *SQLDatabase) InsertCompleted(completed int) error {
-	query := "INSERT INTO completed(block_height) VALUES($1)"
-	_, err := db.DB.Exec(query, completed)
+// InsertCompleted marks the block as applied. The timestamp is the directory
+// block's, used to estimate when future blocks will be created.
+func (db *SQLDatabase) InsertCompleted(ctx context.Context, completed int, timestamp time.Time) error {
+	defer observe("insert_completed", time.Now())
+	query := "INSERT INTO completed(block_height, block_timestamp) VALUES($1, $2)"
+	_, err := db.DB.ExecContext(ctx, query, completed, timestamp)
 	return err
 }
+
+// InsertAttestation stores the signature of the scraper over results it computed
+func (db *SQLDatabase) InsertAttestation(ctx context.Context, a *common.ResultAttestation, tx *sql.Tx) error {
+	defer observe("insert_attestation", time.Now())
+	_, err := tx.ExecContext(ctx, `INSERT INTO result_attestations(vote_chain, dblock_height, dblock_keymr, engine_version,
+		result, public_key, signature) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		a.VoteChain, a.DBlockHeight, a.DBlockKeyMR, a.EngineVersion, a.Result, a.PublicKey, a.Signature)
+	return err
+}
+
+// ReplaceResults stores recomputed results of a vote in place of the previous
+// ones, and records both and what differs in the results history. The
+// attestation of the new results, if signed, is stored with them.
+func (db *SQLDatabase) ReplaceResults(ctx context.Context, previous, results *common.VoteStats, differences []string, attestation *common.ResultAttestation) error {
+	defer observe("replace_results", time.Now())
+	prevJson, err := json.Marshal(previous)
+	if err != nil {
+		return err
+	}
+	resJson, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	diffJson, err := json.Marshal(differences)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO results_history(vote_chain, previous_version, algorithm_version,
+		previous_input_hash, input_hash, differences, previous_result, result) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8)`,
+		results.VoteChain, previous.AlgorithmVersion, results.AlgorithmVersion, previous.InputHash, results.InputHash,
+		string(diffJson), string(prevJson), string(resJson))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// insert_results does not replace existing results
+	_, err = tx.ExecContext(ctx, `DELETE FROM results WHERE vote_chain = $1`, results.VoteChain)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = db.InsertGenericTX(ctx, results, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if attestation != nil {
+		if err = db.InsertAttestation(ctx, attestation, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// CallDuration tracks the latency of each database call made by the daemons
+	CallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "factom_vote_db_call_duration_seconds",
+		Help:    "Latency of postgres calls, by call",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"call"})
+
+	registerOnce sync.Once
+)
+
+// RegisterPrometheus registers the database metrics. It is safe to call more than once.
+func RegisterPrometheus() {
+	registerOnce.Do(func() {
+		prometheus.MustRegister(CallDuration)
+	})
+}
+
+// observe is meant to be deferred at the top of a database call:
+//
+//	defer observe("fetch_vote", time.Now())
+func observe(call string, start time.Time) {
+	CallDuration.WithLabelValues(call).Observe(time.Since(start).Seconds())
+}
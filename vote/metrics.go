@@ -0,0 +1,80 @@
+package vote
+
+import (
+	"sync"
+
+	. "github.com/Emyrk/go-factom-vote/vote/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// EntriesProcessed counts every vote related entry by its type and
+	// what happened to it: accepted, rejected, or ignored. An entry that
+	// arrives out of order is counted once, by the outcome of its retry, and
+	// its deferral by EntriesDeferred.
+	EntriesProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factom_vote_entries_processed_total",
+		Help: "Vote related entries processed, by entry type and outcome",
+	}, []string{"type", "outcome"})
+
+	// EntriesDeferred counts the vote related entries that arrived out of
+	// order and were queued to be retried, by entry type
+	EntriesDeferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factom_vote_entries_deferred_total",
+		Help: "Vote related entries deferred to be retried, by entry type",
+	}, []string{"type"})
+
+	// OldEntriesQueued is the number of out of order entries waiting to be retried
+	OldEntriesQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factom_vote_out_of_order_queue_depth",
+		Help: "Entries that arrived out of order and are queued to be retried",
+	})
+
+	registerOnce sync.Once
+)
+
+// RegisterPrometheus registers the vote watcher metrics. It is safe to call more than once.
+func RegisterPrometheus() {
+	registerOnce.Do(func() {
+		prometheus.MustRegister(EntriesProcessed)
+		prometheus.MustRegister(EntriesDeferred)
+		prometheus.MustRegister(OldEntriesQueued)
+	})
+}
+
+// entryTypeLabel maps the ext[0] of an entry to a short metric label
+func entryTypeLabel(ext0 string, extids int) string {
+	switch ext0 {
+	case EXT0_VOTE_CHAIN:
+		return "proposal"
+	case EXT0_VOTE_COMMIT:
+		return "commit"
+	case EXT0_VOTE_REVEAL:
+		return "reveal"
+	case EXT0_VOTE_REGISTRATION_CHAIN:
+		return "registration-chain"
+	case EXT0_REGISTER_VOTE:
+		return "register"
+	case EXT0_ELIGIBLE_VOTER_CHAIN:
+		if extids == 3 {
+			return "eligible-voter"
+		}
+		return "eligible-list"
+	}
+	return "unknown"
+}
+
+// entryOutcomeLabel reduces the results of processing an entry to a metric
+// label. It is false for an entry deferred to be retried, whose outcome is
+// counted when it is retried instead, as the retry is its last attempt.
+func entryOutcomeLabel(change, tryagain, newEntry bool, err error) (string, bool) {
+	switch {
+	case tryagain && newEntry:
+		return "", false
+	case err != nil:
+		return "rejected", true
+	case change:
+		return "accepted", true
+	}
+	return "ignored", true
+}
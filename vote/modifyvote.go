@@ -1,6 +1,8 @@
 package vote
 
 import (
+	"context"
+
 	"database/sql"
 
 	"fmt"
@@ -9,20 +11,23 @@ import (
 
 	"encoding/hex"
 
+	"github.com/Emyrk/go-factom-vote/notify"
 	. "github.com/Emyrk/go-factom-vote/vote/common"
-	"github.com/FactomProject/btcutil/base58"
 	"github.com/FactomProject/factom"
 )
 
 // All vote modifications go through here
-func (vw *VoteWatcher) AddNewVoteProposal(v *Vote) error {
-	err := vw.SQLDB.InsertGeneric(v)
+func (vw *VoteWatcher) AddNewVoteProposal(ctx context.Context, v *Vote) error {
+	err := vw.SQLDB.InsertGeneric(ctx, v)
+	if err == nil {
+		vw.publish(notify.Event{Kind: notify.VoteUpdated, Height: v.Proposal.BlockHeight, Chain: v.Proposal.ProposalChain.String()})
+	}
 	return err
 }
 
-func (vw *VoteWatcher) AddReveal(r VoteReveal, height uint32) error {
+func (vw *VoteWatcher) AddReveal(ctx context.Context, r VoteReveal, height uint32) error {
 	// Find commit
-	partialCommit, err := vw.SQLDB.FetchCommitForReveal(r)
+	partialCommit, err := vw.SQLDB.FetchCommitForReveal(ctx, r)
 	if err != nil {
 		return fmt.Errorf("(add:fetchCommit) %s", err.Error())
 	}
@@ -38,45 +43,58 @@ func (vw *VoteWatcher) AddReveal(r VoteReveal, height uint32) error {
 		return fmt.Errorf("reveal does not validate hmac against commit.")
 	}
 
-	err = vw.SQLDB.InsertGeneric(&r)
+	err = vw.SQLDB.InsertGeneric(ctx, &r)
 	if err != nil {
 		return fmt.Errorf("(add:insert) %s", err.Error())
 	}
+	vw.publish(notify.Event{Kind: notify.NewReveal, Height: int(height), Chain: r.VoteChain.String(), VoterID: r.VoterID.String()})
 	return nil
 }
 
-func (vw *VoteWatcher) AddCommit(c VoteCommit, height uint32) error {
-	err := vw.SQLDB.InsertGeneric(&c)
+func (vw *VoteWatcher) AddCommit(ctx context.Context, c VoteCommit, height uint32) error {
+	err := vw.SQLDB.InsertGeneric(ctx, &c)
 	if err != nil {
 		return err
 	}
+	vw.publish(notify.Event{Kind: notify.NewCommit, Height: int(height), Chain: c.VoteChain.String(), VoterID: c.VoterID.String()})
 	return nil
 }
 
-func (vw *VoteWatcher) SetRegistered(chain string, registered bool) error {
-	return vw.SQLDB.SetRegistered(chain, registered)
+func (vw *VoteWatcher) SetRegistered(ctx context.Context, chain string, registered bool) error {
+	err := vw.SQLDB.SetRegistered(ctx, chain, registered)
+	if err == nil {
+		vw.publish(notify.Event{Kind: notify.VoteUpdated, Chain: chain})
+	}
+	return err
+}
+
+// publish tells the notifier, if there is one, about a change
+func (vw *VoteWatcher) publish(e notify.Event) {
+	if vw.Notifier != nil {
+		vw.Notifier.Publish(e)
+	}
 }
 
-func (vw *VoteWatcher) AddNewEligibleList(e *EligibleList, hash [32]byte) error {
-	err := vw.SQLDB.InsertGeneric(e)
+func (vw *VoteWatcher) AddNewEligibleList(ctx context.Context, e *EligibleList, hash [32]byte) error {
+	err := vw.SQLDB.InsertGeneric(ctx, e)
 	if err != nil {
 		return err
 	}
 
-	tx, err := vw.SQLDB.Begin()
+	tx, err := vw.SQLDB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	for _, v := range e.EligibleVoters {
-		err := vw.addVoter(&v, tx)
+		err := vw.addVoter(ctx, &v, tx)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
 	}
 
-	err = vw.SQLDB.InsertSubmittedHash(hash, tx)
+	err = vw.SQLDB.InsertSubmittedHash(ctx, hash, tx)
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -90,20 +108,20 @@ func (vw *VoteWatcher) AddNewEligibleList(e *EligibleList, hash [32]byte) error
 	return nil
 }
 
-func (vw *VoteWatcher) AddEligibleVoter(voter *EligibleVoterEntry, hash [32]byte) error {
-	tx, err := vw.SQLDB.Begin()
+func (vw *VoteWatcher) AddEligibleVoter(ctx context.Context, voter *EligibleVoterEntry, hash [32]byte) error {
+	tx, err := vw.SQLDB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	for _, v := range voter.Content {
-		err := vw.addVoter(&v, tx)
+		err := vw.addVoter(ctx, &v, tx)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = vw.SQLDB.InsertSubmittedHash(hash, tx)
+	err = vw.SQLDB.InsertSubmittedHash(ctx, hash, tx)
 	if err != nil {
 		return err
 	}
@@ -116,7 +134,7 @@ func (vw *VoteWatcher) AddEligibleVoter(voter *EligibleVoterEntry, hash [32]byte
 	return nil
 }
 
-func (vw *VoteWatcher) addVoter(voter *EligibleVoter, tx *sql.Tx) error {
+func (vw *VoteWatcher) addVoter(ctx context.Context, voter *EligibleVoter, tx *sql.Tx) error {
 	// Must get all the voting keys for this voter
 	keys, err := factom.GetActiveIdentityKeysAtHeight(voter.VoterID.String(), int64(voter.BlockHeight))
 	if err != nil {
@@ -124,19 +142,17 @@ func (vw *VoteWatcher) addVoter(voter *EligibleVoter, tx *sql.Tx) error {
 	}
 
 	for _, k := range keys {
-		bytes := base58.Decode(k)
-		pubkey := bytes[factom.IDKeyPrefixLength:factom.IDKeyBodyLength]
-		voter.SigningKeys = append(voter.SigningKeys, fmt.Sprintf("%x", pubkey))
+		voter.SigningKeys = append(voter.SigningKeys, fmt.Sprintf("%x", IdentityPublicKey(k)))
 	}
 
-	return vw.SQLDB.InsertGenericTX(voter, tx)
+	return vw.SQLDB.InsertGenericTX(ctx, voter, tx)
 }
 
 // Retrieval based questions
-func (vw *VoteWatcher) IsEligibleListExist(chainid string) (bool, error) {
-	return vw.SQLDB.IsEligibleListExist(chainid)
+func (vw *VoteWatcher) IsEligibleListExist(ctx context.Context, chainid string) (bool, error) {
+	return vw.SQLDB.IsEligibleListExist(ctx, chainid)
 }
 
-func (vw *VoteWatcher) IsEligibleListExistWithKey(chainid string) (bool, string, error) {
-	return vw.SQLDB.IsEligibleListExistWithKey(chainid)
+func (vw *VoteWatcher) IsEligibleListExistWithKey(ctx context.Context, chainid string) (bool, string, error) {
+	return vw.SQLDB.IsEligibleListExistWithKey(ctx, chainid)
 }
@@ -1,6 +1,8 @@
 package vote
 
 import (
+	"context"
+
 	"fmt"
 
 	"sync"
@@ -13,6 +15,7 @@ import (
 
 	"encoding/hex"
 
+	"github.com/Emyrk/go-factom-vote/notify"
 	. "github.com/Emyrk/go-factom-vote/vote/common"
 	"github.com/Emyrk/go-factom-vote/vote/database"
 	"github.com/FactomProject/factomd/common/interfaces"
@@ -30,9 +33,11 @@ type VoteWatcher struct {
 	// Eligible Voter Lists
 	EligibleLists map[[32]byte]*EligibleList
 
-	SQLDB           *database.SQLDatabase
-	WalletdLocation string
-	UseMemory       bool
+	SQLDB     *database.SQLDatabase
+	UseMemory bool
+
+	// Notifier, if set, is told about every proposal, commit and reveal added
+	Notifier notify.Notifier
 
 	sync.RWMutex
 }
@@ -60,7 +65,6 @@ func newVoteWatcher() *VoteWatcher {
 	vw := new(VoteWatcher)
 	vw.VoteProposals = make(map[[32]byte]*Vote)
 	vw.EligibleLists = make(map[[32]byte]*EligibleList)
-	vw.WalletdLocation = "localhost:8089"
 
 	return vw
 }
@@ -70,11 +74,16 @@ type ParsingEntry struct {
 	Entry       interfaces.IEBEntry
 	Timestamp   time.Time
 	BlockHeight uint32
+	DBlockKeyMR interfaces.IHash
+
+	// EBlock and DBlock are the blocks the entry is in, for receipts
+	EBlock interfaces.IEntryBlock
+	DBlock interfaces.IDirectoryBlock
 }
 
-func (vw *VoteWatcher) ParseEntryList(list []ParsingEntry) error {
+func (vw *VoteWatcher) ParseEntryList(ctx context.Context, list []ParsingEntry) error {
 	for _, e := range list {
-		_, err := vw.ProcessEntry(e.Entry, e.BlockHeight, e.Timestamp, true)
+		_, err := vw.ProcessEntry(ctx, e.Entry, e.BlockHeight, e.Timestamp, true)
 		if err != nil {
 			first := ""
 			if len(e.Entry.ExternalIDs()) >= 1 {
@@ -85,7 +94,7 @@ func (vw *VoteWatcher) ParseEntryList(list []ParsingEntry) error {
 	}
 
 	// Parse the remaining
-	vw.ProcessOldEntries()
+	vw.ProcessOldEntries(ctx)
 	return nil
 }
 
@@ -96,7 +105,7 @@ func (vw *VoteWatcher) ParseEntryList(list []ParsingEntry) error {
 //		bool 	True if a vote was updated or changed
 //		error
 //
-func (vw *VoteWatcher) ProcessEntry(entry interfaces.IEBEntry,
+func (vw *VoteWatcher) ProcessEntry(ctx context.Context, entry interfaces.IEBEntry,
 	dBlockHeight uint32,
 	dBlockTimestamp time.Time,
 	newEntry bool) (bool, error) {
@@ -114,20 +123,20 @@ func (vw *VoteWatcher) ProcessEntry(entry interfaces.IEBEntry,
 	switch string(entry.ExternalIDs()[0]) {
 	// First entry to start a vote
 	case EXT0_VOTE_CHAIN:
-		change, tryagain, err = vw.ProcessVoteChain(entry, dBlockHeight, dBlockTimestamp, newEntry)
+		change, tryagain, err = vw.ProcessVoteChain(ctx, entry, dBlockHeight, dBlockTimestamp, newEntry)
 	case EXT0_VOTE_COMMIT:
-		change, tryagain, err = vw.ProcessVoteCommit(entry, dBlockHeight, dBlockTimestamp, newEntry)
+		change, tryagain, err = vw.ProcessVoteCommit(ctx, entry, dBlockHeight, dBlockTimestamp, newEntry)
 	case EXT0_VOTE_REVEAL:
-		change, tryagain, err = vw.ProcessVoteReveal(entry, dBlockHeight, dBlockTimestamp, newEntry)
+		change, tryagain, err = vw.ProcessVoteReveal(ctx, entry, dBlockHeight, dBlockTimestamp, newEntry)
 	case EXT0_VOTE_REGISTRATION_CHAIN:
 		// This doesn't need to do anything
 	case EXT0_REGISTER_VOTE:
-		change, tryagain, err = vw.ProcessVoteRegister(entry, dBlockHeight, dBlockTimestamp, newEntry)
+		change, tryagain, err = vw.ProcessVoteRegister(ctx, entry, dBlockHeight, dBlockTimestamp, newEntry)
 	case EXT0_ELIGIBLE_VOTER_CHAIN:
 		if len(entry.ExternalIDs()) == 3 {
-			change, tryagain, err = vw.ProcessNewEligibleVoter(entry, dBlockHeight, dBlockTimestamp, newEntry)
+			change, tryagain, err = vw.ProcessNewEligibleVoter(ctx, entry, dBlockHeight, dBlockTimestamp, newEntry)
 		} else {
-			change, tryagain, err = vw.ProcessNewEligibleList(entry, dBlockHeight, dBlockTimestamp, newEntry)
+			change, tryagain, err = vw.ProcessNewEligibleList(ctx, entry, dBlockHeight, dBlockTimestamp, newEntry)
 		}
 	default:
 		return false, nil
@@ -137,6 +146,13 @@ func (vw *VoteWatcher) ProcessEntry(entry interfaces.IEBEntry,
 		vw.PushEntryForLater(entry, dBlockHeight, dBlockTimestamp)
 	}
 
+	typ := entryTypeLabel(string(entry.ExternalIDs()[0]), len(entry.ExternalIDs()))
+	if outcome, final := entryOutcomeLabel(change, tryagain, newEntry, err); final {
+		EntriesProcessed.WithLabelValues(typ, outcome).Inc()
+	} else {
+		EntriesDeferred.WithLabelValues(typ).Inc()
+	}
+
 	if err != nil {
 		return change, err
 	}
@@ -157,19 +173,21 @@ func (vw *VoteWatcher) PushEntryForLater(entry interfaces.IEBEntry, dBlockHeight
 	oe.DBlockTimestamp = dBlockTimestamp
 
 	vw.OldEntries = append(vw.OldEntries, oe)
+	OldEntriesQueued.Set(float64(len(vw.OldEntries)))
 }
 
-func (vw *VoteWatcher) ProcessOldEntries() (bool, error) {
+func (vw *VoteWatcher) ProcessOldEntries(ctx context.Context) (bool, error) {
 	var change bool
 	for i := 0; i < len(vw.OldEntries); i++ {
 		oe := vw.OldEntries[i]
 		t := oe.DBlockTimestamp
 		// Process and Remove
-		localchange, _ := vw.ProcessEntry(oe.Entry, oe.DBlockHeight, t, false)
+		localchange, _ := vw.ProcessEntry(ctx, oe.Entry, oe.DBlockHeight, t, false)
 		vw.OldEntries = append(vw.OldEntries[:i], vw.OldEntries[i+1:]...)
 		// Set change
 		change = change || localchange
 	}
+	OldEntriesQueued.Set(float64(len(vw.OldEntries)))
 	return change, nil
 }
 
@@ -183,13 +201,13 @@ func (vw *VoteWatcher) ProcessOldEntries() (bool, error) {
 //		bool 	Indicates whether it should be tried again (out of order)
 //		error
 //
-func (vw *VoteWatcher) ProcessVoteChain(entry interfaces.IEBEntry,
+func (vw *VoteWatcher) ProcessVoteChain(ctx context.Context, entry interfaces.IEBEntry,
 	dBlockHeight uint32,
 	dBlockTimestamp time.Time,
 	newEntry bool) (bool, bool, error) {
 
 	// Votes are indexed by the chain
-	exists, err := vw.SQLDB.IsVoteExist(entry.GetChainID().String())
+	exists, err := vw.SQLDB.IsVoteExist(ctx, entry.GetChainID().String())
 	if exists {
 		return false, false, fmt.Errorf("vote chain already exists: %s", entry.GetChainID().String())
 	}
@@ -220,7 +238,7 @@ func (vw *VoteWatcher) ProcessVoteChain(entry interfaces.IEBEntry,
 	//	return false, true, err
 	//}
 
-	err = vw.AddNewVoteProposal(v)
+	err = vw.AddNewVoteProposal(ctx, v)
 	if err != nil {
 		return false, true, fmt.Errorf("(votechain:add) %s", err.Error())
 	}
@@ -234,12 +252,12 @@ func (vw *VoteWatcher) ProcessVoteChain(entry interfaces.IEBEntry,
 //		bool 	Indicates whether it should be tried again (out of order)
 //		error
 //
-func (vw *VoteWatcher) ProcessVoteCommit(entry interfaces.IEBEntry,
+func (vw *VoteWatcher) ProcessVoteCommit(ctx context.Context, entry interfaces.IEBEntry,
 	dBlockHeight uint32,
 	dBlockTimestamp time.Time,
 	newEntry bool) (bool, bool, error) {
 
-	exists, err := vw.SQLDB.IsVoteExist(entry.GetChainID().String())
+	exists, err := vw.SQLDB.IsVoteExist(ctx, entry.GetChainID().String())
 	if !exists {
 		return false, true, fmt.Errorf("vote chain does not exist for commit : %s", entry.GetChainID().String())
 	}
@@ -254,7 +272,7 @@ func (vw *VoteWatcher) ProcessVoteCommit(entry interfaces.IEBEntry,
 	}
 
 	// We deference, as this structure is now immutable
-	err = vw.AddCommit(*c, dBlockHeight) // v.AddCommit(*c, dBlockHeight)
+	err = vw.AddCommit(ctx, *c, dBlockHeight) // v.AddCommit(*c, dBlockHeight)
 	if err != nil {
 		return false, true, err
 	}
@@ -268,12 +286,12 @@ func (vw *VoteWatcher) ProcessVoteCommit(entry interfaces.IEBEntry,
 //		bool 	Indicates whether it should be tried again (out of order)
 //		error
 //
-func (vw *VoteWatcher) ProcessVoteReveal(entry interfaces.IEBEntry,
+func (vw *VoteWatcher) ProcessVoteReveal(ctx context.Context, entry interfaces.IEBEntry,
 	dBlockHeight uint32,
 	dBlockTimestamp time.Time,
 	newEntry bool) (bool, bool, error) {
 
-	exists, err := vw.SQLDB.IsVoteExist(entry.GetChainID().String())
+	exists, err := vw.SQLDB.IsVoteExist(ctx, entry.GetChainID().String())
 	if !exists {
 		return false, true, fmt.Errorf("vote chain does not exist for reveal")
 	}
@@ -289,7 +307,7 @@ func (vw *VoteWatcher) ProcessVoteReveal(entry interfaces.IEBEntry,
 
 	// We deference, as this structure is now immutable
 	// Do signature validation in this function, it will interact with the database
-	err = vw.AddReveal(*r, dBlockHeight)
+	err = vw.AddReveal(ctx, *r, dBlockHeight)
 	if err != nil {
 		return false, true, fmt.Errorf("(reveal:add) %s", err.Error())
 	}
@@ -303,7 +321,7 @@ func (vw *VoteWatcher) ProcessVoteReveal(entry interfaces.IEBEntry,
 //		bool 	Indicates whether it should be tried again (out of order)
 //		error
 //
-func (vw *VoteWatcher) ProcessVoteRegister(entry interfaces.IEBEntry,
+func (vw *VoteWatcher) ProcessVoteRegister(ctx context.Context, entry interfaces.IEBEntry,
 	dBlockHeight uint32,
 	dBlockTimestamp time.Time,
 	newEntry bool) (bool, bool, error) {
@@ -321,7 +339,7 @@ func (vw *VoteWatcher) ProcessVoteRegister(entry interfaces.IEBEntry,
 		return false, false, fmt.Errorf("incorrect number of bytes for chainid")
 	}
 
-	exists, err := vw.SQLDB.IsVoteExist(votechain)
+	exists, err := vw.SQLDB.IsVoteExist(ctx, votechain)
 	if !exists {
 		return false, true, fmt.Errorf("vote chain does not exist to be registered")
 	}
@@ -330,7 +348,7 @@ func (vw *VoteWatcher) ProcessVoteRegister(entry interfaces.IEBEntry,
 		return false, true, err
 	}
 
-	err = vw.SetRegistered(votechain, true)
+	err = vw.SetRegistered(ctx, votechain, true)
 	if err != nil {
 		return false, true, err
 	}
@@ -343,12 +361,12 @@ func (vw *VoteWatcher) ProcessVoteRegister(entry interfaces.IEBEntry,
 //		bool 	Indicates whether it should be tried again (out of order)
 //		error
 //
-func (vw *VoteWatcher) ProcessNewEligibleList(entry interfaces.IEBEntry,
+func (vw *VoteWatcher) ProcessNewEligibleList(ctx context.Context, entry interfaces.IEBEntry,
 	dBlockHeight uint32,
 	dBlockTimestamp time.Time,
 	newEntry bool) (bool, bool, error) {
 
-	exists, err := vw.SQLDB.IsEligibleListExist(entry.GetChainID().String())
+	exists, err := vw.SQLDB.IsEligibleListExist(ctx, entry.GetChainID().String())
 	if exists {
 		return false, true, fmt.Errorf("eligibility list already exists")
 	}
@@ -389,7 +407,7 @@ func (vw *VoteWatcher) ProcessNewEligibleList(entry interfaces.IEBEntry,
 
 	hash := sha256.Sum256(data)
 
-	err = vw.AddNewEligibleList(list, hash)
+	err = vw.AddNewEligibleList(ctx, list, hash)
 	if err != nil {
 		return false, true, err
 	}
@@ -403,12 +421,12 @@ func (vw *VoteWatcher) ProcessNewEligibleList(entry interfaces.IEBEntry,
 //		bool 	Indicates whether it should be tried again (out of order)
 //		error
 //
-func (vw *VoteWatcher) ProcessNewEligibleVoter(entry interfaces.IEBEntry,
+func (vw *VoteWatcher) ProcessNewEligibleVoter(ctx context.Context, entry interfaces.IEBEntry,
 	dBlockHeight uint32,
 	dBlockTimestamp time.Time,
 	newEntry bool) (bool, bool, error) {
 
-	exists, key, err := vw.SQLDB.IsEligibleListExistWithKey(entry.GetChainID().String())
+	exists, key, err := vw.SQLDB.IsEligibleListExistWithKey(ctx, entry.GetChainID().String())
 	if !exists {
 		return false, true, fmt.Errorf("eligibility list does not exist: %s", err.Error())
 	}
@@ -423,7 +441,7 @@ func (vw *VoteWatcher) ProcessNewEligibleVoter(entry interfaces.IEBEntry,
 	}
 
 	hash := sha256.Sum256(data)
-	exists, err = vw.SQLDB.IsRepeatedEntryExists(hex.EncodeToString(hash[:]))
+	exists, err = vw.SQLDB.IsRepeatedEntryExists(ctx, hex.EncodeToString(hash[:]))
 	if exists {
 		return false, false, fmt.Errorf("repeated eligible entry tossed")
 	}
@@ -437,7 +455,7 @@ func (vw *VoteWatcher) ProcessNewEligibleVoter(entry interfaces.IEBEntry,
 		return false, true, err
 	}
 
-	err = vw.AddEligibleVoter(ee, hash)
+	err = vw.AddEligibleVoter(ctx, ee, hash)
 	if err != nil {
 		return false, true, err
 	}